@@ -0,0 +1,181 @@
+package main
+
+import (
+	"bytes"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/ipfs/go-cid"
+	cidlink "github.com/ipld/go-ipld-prime/linking/cid"
+	"github.com/spf13/cobra"
+	"github.com/storacha/go-libstoracha/capabilities/types"
+	"github.com/storacha/go-ucanto/core/receipt"
+	"github.com/storacha/go-ucanto/core/result"
+	"github.com/storacha/go-ucanto/did"
+	ed25519verifier "github.com/storacha/go-ucanto/principal/ed25519/verifier"
+	"github.com/storacha/go-ucanto/transport/car/response"
+	uhttp "github.com/storacha/go-ucanto/transport/http"
+	"github.com/storacha/go-ucanto/ucan"
+
+	"github.com/storacha/etracker/internal/capabilities/payout"
+)
+
+var attestationCmd = &cobra.Command{
+	Use:   "attestation",
+	Short: "Work with the signed attestations of monthly node payouts",
+}
+
+var verifyAttestationCmd = &cobra.Command{
+	Use:   "verify <receipt.car>",
+	Short: "Verify a payout attestation offline",
+	Long: `Verify the signature of a payout attestation, as downloaded from the /receipts/{cid} endpoint, and
+optionally that it commits to the given node and consolidate receipts.
+
+The causes file lists the CIDs of the consolidate receipts the egress of the node was counted from, as
+pushed to the node for each of its batches, separated by whitespace, in any order.`,
+	Args:         cobra.ExactArgs(1),
+	SilenceUsage: true,
+	RunE:         verifyAttestation,
+}
+
+func init() {
+	verifyAttestationCmd.Flags().String(
+		"service-key",
+		"",
+		"did:key of the service signing key, required when the attestation is issued by a did:web",
+	)
+	verifyAttestationCmd.Flags().String(
+		"issuer",
+		"",
+		"DID the attestation must be issued by",
+	)
+	verifyAttestationCmd.Flags().String(
+		"node",
+		"",
+		"DID of the node the attestation must be for",
+	)
+	verifyAttestationCmd.Flags().String(
+		"causes",
+		"",
+		"Path to a file listing the consolidate receipt CIDs the attestation must commit to",
+	)
+
+	attestationCmd.AddCommand(verifyAttestationCmd)
+}
+
+func verifyAttestation(cmd *cobra.Command, args []string) error {
+	f, err := os.Open(args[0])
+	if err != nil {
+		return fmt.Errorf("opening receipt: %w", err)
+	}
+	defer f.Close()
+
+	msg, err := response.Decode(uhttp.NewResponse(0, f, nil))
+	if err != nil {
+		return fmt.Errorf("decoding receipt: %w", err)
+	}
+	if len(msg.Receipts()) != 1 {
+		return fmt.Errorf("expected 1 receipt, found %d", len(msg.Receipts()))
+	}
+
+	anyRcpt, ok, err := msg.Receipt(msg.Receipts()[0])
+	if err != nil {
+		return fmt.Errorf("reading receipt: %w", err)
+	}
+	if !ok {
+		return fmt.Errorf("receipt %s not found in message", msg.Receipts()[0])
+	}
+	rcpt, err := receipt.Rebind[payout.AttestOk, payout.AttestError](anyRcpt, payout.AttestOkType(), payout.AttestErrorType(), types.Converters...)
+	if err != nil {
+		return fmt.Errorf("receipt is not a payout attestation: %w", err)
+	}
+
+	if rcpt.Issuer() == nil {
+		return fmt.Errorf("receipt has no issuer")
+	}
+	issuer := rcpt.Issuer().DID()
+	if expected, _ := cmd.Flags().GetString("issuer"); expected != "" && issuer.String() != expected {
+		return fmt.Errorf("attestation is issued by %s, not %s", issuer, expected)
+	}
+
+	// did:web issuers sign with the key they resolve to, which must be provided to verify offline
+	key := issuer.String()
+	if serviceKey, _ := cmd.Flags().GetString("service-key"); serviceKey != "" {
+		key = serviceKey
+	}
+	verifier, err := ed25519verifier.Parse(key)
+	if err != nil {
+		return fmt.Errorf("parsing key of issuer %s, use --service-key to provide it: %w", issuer, err)
+	}
+
+	valid, err := rcpt.VerifySignature(verifier)
+	if err != nil {
+		return fmt.Errorf("verifying signature: %w", err)
+	}
+	if !valid {
+		return fmt.Errorf("invalid signature of %s with key %s", issuer, key)
+	}
+
+	attestation, failure := result.Unwrap(rcpt.Out())
+	if failure != (payout.AttestError{}) {
+		return fmt.Errorf("attestation failed: %s", failure.Message)
+	}
+
+	if expected, _ := cmd.Flags().GetString("node"); expected != "" {
+		node, err := did.Parse(expected)
+		if err != nil {
+			return fmt.Errorf("parsing node DID: %w", err)
+		}
+		if attestation.Node != node {
+			return fmt.Errorf("attestation is for node %s, not %s", attestation.Node, node)
+		}
+	}
+
+	out := cmd.OutOrStdout()
+	fmt.Fprintf(out, "attestation: %s\n", rcpt.Ran().Link())
+	fmt.Fprintf(out, "issuer:      %s (signature valid)\n", issuer)
+	fmt.Fprintf(out, "node:        %s\n", attestation.Node)
+	fmt.Fprintf(out, "month:       %s\n", attestation.Month.Format("2006-01"))
+	fmt.Fprintf(out, "egress:      %d bytes\n", attestation.TotalEgress)
+	fmt.Fprintf(out, "causes:      %d\n", attestation.Causes)
+	fmt.Fprintf(out, "root:        %s\n", hex.EncodeToString(attestation.Root))
+
+	causesPath, _ := cmd.Flags().GetString("causes")
+	if causesPath == "" {
+		return nil
+	}
+
+	causes, err := readCauses(causesPath)
+	if err != nil {
+		return err
+	}
+
+	root := payout.MerkleRoot(causes)
+	if !bytes.Equal(root, attestation.Root) {
+		return fmt.Errorf("causes do not match the attestation: root %s, attested %s", hex.EncodeToString(root), hex.EncodeToString(attestation.Root))
+	}
+	fmt.Fprintf(out, "causes match the attested root\n")
+
+	return nil
+}
+
+// readCauses parses the whitespace-separated CIDs in the file at the given path
+func readCauses(path string) ([]ucan.Link, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading causes: %w", err)
+	}
+
+	var causes []ucan.Link
+	for _, s := range strings.Fields(string(data)) {
+		c, err := cid.Parse(s)
+		if err != nil {
+			return nil, fmt.Errorf("parsing cause %q: %w", s, err)
+		}
+		causes = append(causes, cidlink.Link{Cid: c})
+	}
+
+	return causes, nil
+}
@@ -40,6 +40,7 @@ func init() {
 
 	// register all commands and their subcommands
 	rootCmd.AddCommand(startCmd)
+	rootCmd.AddCommand(attestationCmd)
 }
 
 func initConfig() {
@@ -2,7 +2,9 @@ package main
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"net/url"
 	"os"
 	"os/signal"
 	"syscall"
@@ -21,16 +23,33 @@ import (
 	"github.com/storacha/go-ucanto/principal/signer"
 	"github.com/storacha/go-ucanto/ucan"
 
+	"github.com/storacha/etracker/internal/anomaly"
+	"github.com/storacha/etracker/internal/billing"
+	"github.com/storacha/etracker/internal/callback"
 	"github.com/storacha/etracker/internal/config"
 	"github.com/storacha/etracker/internal/consolidator"
+	"github.com/storacha/etracker/internal/db/accountstats"
+	"github.com/storacha/etracker/internal/db/anomalies"
+	"github.com/storacha/etracker/internal/db/attestations"
+	"github.com/storacha/etracker/internal/db/callbacks"
 	"github.com/storacha/etracker/internal/db/consolidated"
 	"github.com/storacha/etracker/internal/db/consumer"
 	"github.com/storacha/etracker/internal/db/customer"
+	"github.com/storacha/etracker/internal/db/deliveries"
 	"github.com/storacha/etracker/internal/db/egress"
+	"github.com/storacha/etracker/internal/db/hourlystats"
+	"github.com/storacha/etracker/internal/db/ledger"
+	"github.com/storacha/etracker/internal/db/networkstats"
+	"github.com/storacha/etracker/internal/db/payouts"
 	"github.com/storacha/etracker/internal/db/spacestats"
+	"github.com/storacha/etracker/internal/db/statements"
 	"github.com/storacha/etracker/internal/db/storageproviders"
+	"github.com/storacha/etracker/internal/exclusion"
 	"github.com/storacha/etracker/internal/metrics"
 	"github.com/storacha/etracker/internal/presets"
+	"github.com/storacha/etracker/internal/pricing"
+	"github.com/storacha/etracker/internal/quota"
+	"github.com/storacha/etracker/internal/reconciler"
 	"github.com/storacha/etracker/internal/server"
 	"github.com/storacha/etracker/internal/service"
 )
@@ -76,6 +95,14 @@ func init() {
 	cobra.CheckErr(viper.BindEnv("admin_dashboard_password"))
 	cobra.CheckErr(viper.BindEnv("client_egress_usd_per_tib"))
 	cobra.CheckErr(viper.BindEnv("provider_egress_usd_per_tib"))
+	// JSON pricing plans of client egress, every space is priced at client_egress_usd_per_tib when unset
+	cobra.CheckErr(viper.BindEnv("client_egress_pricing"))
+	// JSON rate cards of node payouts, every node is paid provider_egress_usd_per_tib when unset
+	cobra.CheckErr(viper.BindEnv("provider_egress_rates"))
+	// JSON monthly egress limits of accounts, no account is limited when unset
+	cobra.CheckErr(viper.BindEnv("egress_quotas"))
+	// URL quota alerts are posted to, they are logged when unset
+	cobra.CheckErr(viper.BindEnv("egress_quota_webhook_url"))
 
 	startCmd.Flags().String(
 		"egress-table-name",
@@ -94,6 +121,30 @@ func init() {
 	cobra.CheckErr(viper.BindPFlag("egress_unprocessed_index_name", startCmd.Flags().Lookup("egress-unprocessed-index-name")))
 	cobra.CheckErr(viper.BindEnv("egress_unprocessed_index_name", "EGRESS_RECORDS_UNPROCESSED_INDEX_NAME"))
 
+	startCmd.Flags().String(
+		"egress-queue-index-name",
+		"",
+		"Name of the DynamoDB index to use for querying unprocessed egress records oldest first",
+	)
+	cobra.CheckErr(viper.BindPFlag("egress_queue_index_name", startCmd.Flags().Lookup("egress-queue-index-name")))
+	cobra.CheckErr(viper.BindEnv("egress_queue_index_name", "EGRESS_RECORDS_QUEUE_INDEX_NAME"))
+
+	startCmd.Flags().String(
+		"egress-cause-index-name",
+		"",
+		"Name of the DynamoDB index to use for querying egress records by the invocation that tracked them",
+	)
+	cobra.CheckErr(viper.BindPFlag("egress_cause_index_name", startCmd.Flags().Lookup("egress-cause-index-name")))
+	cobra.CheckErr(viper.BindEnv("egress_cause_index_name", "EGRESS_RECORDS_CAUSE_INDEX_NAME"))
+
+	startCmd.Flags().String(
+		"egress-node-index-name",
+		"",
+		"Name of the DynamoDB index to use for querying egress records by node",
+	)
+	cobra.CheckErr(viper.BindPFlag("egress_node_index_name", startCmd.Flags().Lookup("egress-node-index-name")))
+	cobra.CheckErr(viper.BindEnv("egress_node_index_name", "EGRESS_RECORDS_NODE_INDEX_NAME"))
+
 	startCmd.Flags().String(
 		"consolidated-table-name",
 		"",
@@ -110,6 +161,38 @@ func init() {
 	cobra.CheckErr(viper.BindPFlag("consolidated_node_stats_index_name", startCmd.Flags().Lookup("consolidated-node-stats-index-name")))
 	cobra.CheckErr(viper.BindEnv("consolidated_node_stats_index_name", "CONSOLIDATED_RECORDS_NODE_STATS_INDEX_NAME"))
 
+	startCmd.Flags().String(
+		"consolidated-held-index-name",
+		"",
+		"Name of the DynamoDB index to use for querying consolidated records held for review",
+	)
+	cobra.CheckErr(viper.BindPFlag("consolidated_held_index_name", startCmd.Flags().Lookup("consolidated-held-index-name")))
+	cobra.CheckErr(viper.BindEnv("consolidated_held_index_name", "CONSOLIDATED_RECORDS_HELD_INDEX_NAME"))
+
+	startCmd.Flags().String(
+		"consolidated-reviewed-index-name",
+		"",
+		"Name of the DynamoDB index to use for querying consolidated records by node and review time",
+	)
+	cobra.CheckErr(viper.BindPFlag("consolidated_reviewed_index_name", startCmd.Flags().Lookup("consolidated-reviewed-index-name")))
+	cobra.CheckErr(viper.BindEnv("consolidated_reviewed_index_name", "CONSOLIDATED_RECORDS_REVIEWED_INDEX_NAME"))
+
+	startCmd.Flags().String(
+		"consolidated-track-cause-index-name",
+		"",
+		"Name of the DynamoDB index to use for querying consolidated records by the invocation that tracked the batch",
+	)
+	cobra.CheckErr(viper.BindPFlag("consolidated_track_cause_index_name", startCmd.Flags().Lookup("consolidated-track-cause-index-name")))
+	cobra.CheckErr(viper.BindEnv("consolidated_track_cause_index_name", "CONSOLIDATED_RECORDS_TRACK_CAUSE_INDEX_NAME"))
+
+	startCmd.Flags().String(
+		"consolidated-batch-index-name",
+		"",
+		"Name of the DynamoDB index to use for querying consolidated records by batch",
+	)
+	cobra.CheckErr(viper.BindPFlag("consolidated_batch_index_name", startCmd.Flags().Lookup("consolidated-batch-index-name")))
+	cobra.CheckErr(viper.BindEnv("consolidated_batch_index_name", "CONSOLIDATED_RECORDS_BATCH_INDEX_NAME"))
+
 	startCmd.Flags().Int(
 		"consolidation-interval",
 		60*60,
@@ -124,7 +207,68 @@ func init() {
 	)
 	cobra.CheckErr(viper.BindPFlag("consolidation_batch_size", startCmd.Flags().Lookup("consolidation-batch-size")))
 
+	startCmd.Flags().Uint64(
+		"consolidation-max-range-length",
+		consolidator.DefaultMaxRangeLength,
+		"Maximum length in bytes of the range of a retrieval receipt, receipts claiming longer ranges are rejected",
+	)
+	cobra.CheckErr(viper.BindPFlag("consolidation_max_range_length", startCmd.Flags().Lookup("consolidation-max-range-length")))
+
 	cobra.CheckErr(viper.BindEnv("space_stats_table_name", "SPACE_STATS_TABLE_ID"))
+	cobra.CheckErr(viper.BindEnv("space_hourly_stats_table_name", "SPACE_HOURLY_STATS_TABLE_ID"))
+
+	startCmd.Flags().Int(
+		"hourly-stats-retention-days",
+		14,
+		"Number of days hourly space egress stats are kept before they expire",
+	)
+	cobra.CheckErr(viper.BindPFlag("hourly_stats_retention_days", startCmd.Flags().Lookup("hourly-stats-retention-days")))
+
+	cobra.CheckErr(viper.BindEnv("excluded_space_stats_table_name", "EXCLUDED_SPACE_STATS_TABLE_ID"))
+	cobra.CheckErr(viper.BindEnv("network_stats_table_name", "NETWORK_STATS_TABLE_ID"))
+	cobra.CheckErr(viper.BindEnv("account_stats_table_name", "ACCOUNT_STATS_TABLE_ID"))
+
+	startCmd.Flags().Int(
+		"account-stats-reconcile-interval",
+		24*60*60,
+		"Interval in seconds between reconciliations of account stats with the stats of the spaces of each account",
+	)
+	cobra.CheckErr(viper.BindPFlag("account_stats_reconcile_interval", startCmd.Flags().Lookup("account-stats-reconcile-interval")))
+
+	startCmd.Flags().Int(
+		"account-stats-reconcile-days",
+		62,
+		"Number of trailing days of account stats repaired on every reconciliation",
+	)
+	cobra.CheckErr(viper.BindPFlag("account_stats_reconcile_days", startCmd.Flags().Lookup("account-stats-reconcile-days")))
+
+	cobra.CheckErr(viper.BindEnv("statement_table_name", "STATEMENTS_TABLE_ID"))
+
+	startCmd.Flags().Int(
+		"billing-interval",
+		24*60*60,
+		"Interval in seconds between billing runs closing monthly statements and adjusting them with late egress",
+	)
+	cobra.CheckErr(viper.BindPFlag("billing_interval", startCmd.Flags().Lookup("billing-interval")))
+
+	startCmd.Flags().Int(
+		"billing-close-delay-hours",
+		48,
+		"Number of hours after the end of a month before its statements are closed",
+	)
+	cobra.CheckErr(viper.BindPFlag("billing_close_delay_hours", startCmd.Flags().Lookup("billing-close-delay-hours")))
+
+	startCmd.Flags().Int(
+		"billing-adjustment-months",
+		3,
+		"Number of months before the last closed one whose statements are adjusted with late egress",
+	)
+	cobra.CheckErr(viper.BindPFlag("billing_adjustment_months", startCmd.Flags().Lookup("billing-adjustment-months")))
+
+	cobra.CheckErr(viper.BindEnv("payout_table_name", "PAYOUTS_TABLE_ID"))
+	cobra.CheckErr(viper.BindEnv("attestation_table_name", "ATTESTATIONS_TABLE_ID"))
+	cobra.CheckErr(viper.BindEnv("attestation_cause_index_name", "ATTESTATIONS_CAUSE_INDEX_NAME"))
+	cobra.CheckErr(viper.BindEnv("ledger_table_name", "LEDGER_TABLE_ID"))
 
 	cobra.CheckErr(viper.BindEnv("storage_provider_table_name", "STORAGE_PROVIDER_TABLE_NAME"))
 	cobra.CheckErr(viper.BindEnv("storage_provider_table_region", "STORAGE_PROVIDER_TABLE_REGION"))
@@ -137,6 +281,99 @@ func init() {
 	cobra.CheckErr(viper.BindEnv("consumer_consumer_index_name", "CONSUMER_CONSUMER_INDEX_NAME"))
 	cobra.CheckErr(viper.BindEnv("consumer_customer_index_name", "CONSUMER_CUSTOMER_INDEX_NAME"))
 
+	cobra.CheckErr(viper.BindEnv("anomaly_table_name", "ANOMALY_FLAGS_TABLE_ID"))
+	cobra.CheckErr(viper.BindEnv("anomaly_node_index_name", "ANOMALY_FLAGS_NODE_INDEX_NAME"))
+
+	defaultAnomalyCfg := anomaly.DefaultConfig()
+
+	startCmd.Flags().Int(
+		"anomaly-baseline-days",
+		defaultAnomalyCfg.BaselineDays,
+		"Number of trailing days used to compute a node's egress baseline for anomaly detection",
+	)
+	cobra.CheckErr(viper.BindPFlag("anomaly_baseline_days", startCmd.Flags().Lookup("anomaly-baseline-days")))
+
+	startCmd.Flags().Int(
+		"anomaly-min-baseline-days",
+		defaultAnomalyCfg.MinBaselineDays,
+		"Minimum number of days of egress history a node needs before egress spikes are detected",
+	)
+	cobra.CheckErr(viper.BindPFlag("anomaly_min_baseline_days", startCmd.Flags().Lookup("anomaly-min-baseline-days")))
+
+	startCmd.Flags().Float64(
+		"anomaly-spike-stddevs",
+		defaultAnomalyCfg.SpikeStdDevs,
+		"Number of standard deviations above its baseline a node's daily egress must be to be flagged",
+	)
+	cobra.CheckErr(viper.BindPFlag("anomaly_spike_stddevs", startCmd.Flags().Lookup("anomaly-spike-stddevs")))
+
+	startCmd.Flags().Uint64(
+		"anomaly-min-node-egress",
+		defaultAnomalyCfg.MinNodeEgress,
+		"Egress in bytes below which a node's activity is never flagged as anomalous",
+	)
+	cobra.CheckErr(viper.BindPFlag("anomaly_min_node_egress", startCmd.Flags().Lookup("anomaly-min-node-egress")))
+
+	startCmd.Flags().Float64(
+		"anomaly-max-space-share",
+		defaultAnomalyCfg.MaxSpaceShare,
+		"Maximum share (0-1] of a node's egress in a consolidation cycle that a single space may receive",
+	)
+	cobra.CheckErr(viper.BindPFlag("anomaly_max_space_share", startCmd.Flags().Lookup("anomaly-max-space-share")))
+
+	startCmd.Flags().Int(
+		"anomaly-max-identical-ranges",
+		defaultAnomalyCfg.MaxIdenticalRanges,
+		"Maximum number of retrievals of the same byte range a node may report in a consolidation cycle",
+	)
+	cobra.CheckErr(viper.BindPFlag("anomaly_max_identical_ranges", startCmd.Flags().Lookup("anomaly-max-identical-ranges")))
+
+	startCmd.Flags().Int(
+		"hold-new-node-days",
+		0,
+		"Hold batches for review from nodes that started reporting egress less than this number of days ago (0 disables)",
+	)
+	cobra.CheckErr(viper.BindPFlag("hold_new_node_days", startCmd.Flags().Lookup("hold-new-node-days")))
+
+	startCmd.Flags().Uint64(
+		"hold-max-batch-egress",
+		0,
+		"Hold batches for review when their total egress in bytes is above this value (0 disables)",
+	)
+	cobra.CheckErr(viper.BindPFlag("hold_max_batch_egress", startCmd.Flags().Lookup("hold-max-batch-egress")))
+
+	startCmd.Flags().Int(
+		"hold-anomaly-window-hours",
+		0,
+		"Hold batches for review from nodes with anomaly flags raised within this number of hours (0 disables)",
+	)
+	cobra.CheckErr(viper.BindPFlag("hold_anomaly_window_hours", startCmd.Flags().Lookup("hold-anomaly-window-hours")))
+
+	cobra.CheckErr(viper.BindEnv("callback_table_name", "NODE_CALLBACKS_TABLE_ID"))
+	cobra.CheckErr(viper.BindEnv("callback_delivery_table_name", "CALLBACK_DELIVERIES_TABLE_ID"))
+
+	startCmd.Flags().String(
+		"callback-pending-index-name",
+		"",
+		"Name of the DynamoDB index to use for querying consolidation receipts pending delivery",
+	)
+	cobra.CheckErr(viper.BindPFlag("callback_pending_index_name", startCmd.Flags().Lookup("callback-pending-index-name")))
+	cobra.CheckErr(viper.BindEnv("callback_pending_index_name", "CALLBACK_DELIVERIES_PENDING_INDEX_NAME"))
+
+	startCmd.Flags().Int(
+		"callback-max-attempts",
+		5,
+		"Number of times the delivery of a consolidation receipt to a node's callback is attempted",
+	)
+	cobra.CheckErr(viper.BindPFlag("callback_max_attempts", startCmd.Flags().Lookup("callback-max-attempts")))
+
+	startCmd.Flags().Int(
+		"callback-initial-backoff",
+		10,
+		"Seconds to wait before retrying a failed callback delivery, doubled on every retry",
+	)
+	cobra.CheckErr(viper.BindPFlag("callback_initial_backoff", startCmd.Flags().Lookup("callback-initial-backoff")))
+
 	startCmd.Flags().StringSlice(
 		"known-providers",
 		presets.KnownProviders,
@@ -150,6 +387,13 @@ func init() {
 		"List of trusted authorities, identified by their DIDs (comma-separated)",
 	)
 	cobra.CheckErr(viper.BindPFlag("trusted_authorities", startCmd.Flags().Lookup("trusted-authorities")))
+
+	startCmd.Flags().StringSlice(
+		"excluded-spaces",
+		[]string{},
+		"List of spaces whose egress is neither billed nor paid for, such as monitoring spaces (comma-separated DIDs)",
+	)
+	cobra.CheckErr(viper.BindPFlag("excluded_spaces", startCmd.Flags().Lookup("excluded-spaces")))
 }
 
 func startService(cmd *cobra.Command, args []string) error {
@@ -181,10 +425,50 @@ func startService(cmd *cobra.Command, args []string) error {
 	dynamoClient := dynamodb.NewFromConfig(cfg.AWSConfig)
 
 	// Create database tables
-	egressTable := egress.NewDynamoEgressTable(dynamoClient, cfg.EgressTableName, cfg.EgressUnprocessedIndexName)
-	consolidatedTable := consolidated.NewDynamoConsolidatedTable(dynamoClient, cfg.ConsolidatedTableName, cfg.ConsolidatedNodeStatsIndexName)
+	egressTable := egress.NewDynamoEgressTable(dynamoClient, cfg.EgressTableName, cfg.EgressUnprocessedIndexName, cfg.EgressQueueIndexName, cfg.EgressCauseIndexName, cfg.EgressNodeIndexName)
+	consolidatedTable := consolidated.NewDynamoConsolidatedTable(dynamoClient, cfg.ConsolidatedTableName, cfg.ConsolidatedNodeStatsIndexName, cfg.ConsolidatedHeldIndexName, cfg.ConsolidatedTrackCauseIndexName, cfg.ConsolidatedBatchIndexName, cfg.ConsolidatedReviewedIndexName)
 	spaceStatsTable := spacestats.NewDynamoSpaceStatsTable(dynamoClient, cfg.SpaceStatsTableName)
 
+	// The tables of optional features are left unset when they are not configured, which turns the features off
+	var (
+		hourlyStatsTable        hourlystats.HourlyStatsTable
+		accountStatsTable       accountstats.AccountStatsTable
+		excludedSpaceStatsTable spacestats.SpaceStatsTable
+		networkStatsTable       networkstats.NetworkStatsTable
+		statementTable          statements.StatementTable
+		payoutTable             payouts.PayoutTable
+		attestationTable        attestations.AttestationTable
+		ledgerTable             ledger.LedgerTable
+		anomalyTable            anomalies.AnomalyTable
+	)
+	if cfg.SpaceHourlyStatsTableName != "" {
+		hourlyStatsTable = hourlystats.NewDynamoHourlyStatsTable(dynamoClient, cfg.SpaceHourlyStatsTableName, time.Duration(cfg.HourlyStatsRetentionDays)*24*time.Hour)
+	}
+	if cfg.AccountStatsTableName != "" {
+		accountStatsTable = accountstats.NewDynamoAccountStatsTable(dynamoClient, cfg.AccountStatsTableName)
+	}
+	if cfg.ExcludedSpaceStatsTableName != "" {
+		excludedSpaceStatsTable = spacestats.NewDynamoSpaceStatsTable(dynamoClient, cfg.ExcludedSpaceStatsTableName)
+	}
+	if cfg.NetworkStatsTableName != "" {
+		networkStatsTable = networkstats.NewDynamoNetworkStatsTable(dynamoClient, cfg.NetworkStatsTableName)
+	}
+	if cfg.StatementTableName != "" {
+		statementTable = statements.NewDynamoStatementTable(dynamoClient, cfg.StatementTableName)
+	}
+	if cfg.PayoutTableName != "" {
+		payoutTable = payouts.NewDynamoPayoutTable(dynamoClient, cfg.PayoutTableName)
+	}
+	if cfg.AttestationTableName != "" {
+		attestationTable = attestations.NewDynamoAttestationTable(dynamoClient, cfg.AttestationTableName, cfg.AttestationCauseIndexName)
+	}
+	if cfg.LedgerTableName != "" {
+		ledgerTable = ledger.NewDynamoLedgerTable(dynamoClient, cfg.LedgerTableName)
+	}
+	if cfg.AnomalyTableName != "" {
+		anomalyTable = anomalies.NewDynamoAnomalyTable(dynamoClient, cfg.AnomalyTableName, cfg.AnomalyNodeIndexName)
+	}
+
 	storageProviderCfg := cfg.AWSConfig.Copy()
 	storageProviderCfg.Region = cfg.StorageProviderTableRegion
 	storageProviderTable := storageproviders.NewDynamoStorageProviderTable(dynamodb.NewFromConfig(storageProviderCfg), cfg.StorageProviderTableName)
@@ -197,6 +481,27 @@ func startService(cmd *cobra.Command, args []string) error {
 	consumerCfg.Region = cfg.ConsumerTableRegion
 	consumerTable := consumer.NewDynamoConsumerTable(dynamodb.NewFromConfig(consumerCfg), cfg.ConsumerTableName, cfg.ConsumerConsumerIndexName, cfg.ConsumerCustomerIndexName)
 
+	// Unprocessed records tracked before the queue index existed are only listed for consolidation once added to it
+	added, err := egressTable.BackfillQueue(ctx)
+	if err != nil {
+		return fmt.Errorf("backfilling unprocessed egress records queue: %w", err)
+	}
+	if added > 0 {
+		log.Infof("added %d unprocessed egress records to the queue index", added)
+	}
+
+	// Records tracked before the cause index existed are only found by their track invocation once added to it.
+	// The whole table is read, so this runs in the background.
+	go func() {
+		updated, err := egressTable.BackfillCauseLink(ctx)
+		if err != nil {
+			log.Errorf("backfilling cause link of egress records: %v", err)
+		}
+		if updated > 0 {
+			log.Infof("added %d egress records to the cause index", updated)
+		}
+	}()
+
 	// Initialize metrics if metrics are configured
 	if cfg.MetricsAuthToken != "" {
 		if err := metrics.Init(cfg.MetricsEnvironment); err != nil {
@@ -214,6 +519,91 @@ func startService(cmd *cobra.Command, args []string) error {
 	}
 
 	// Create service
+	pricer := pricing.NewFlat(cfg.ClientEgressUSDPerTiB)
+	if cfg.ClientEgressPricing != "" {
+		pricingCfg, err := pricing.ParseConfig([]byte(cfg.ClientEgressPricing))
+		if err != nil {
+			return err
+		}
+		pricer, err = pricing.New(pricingCfg)
+		if err != nil {
+			return fmt.Errorf("creating pricing engine: %w", err)
+		}
+	}
+
+	payRates := pricing.FlatRateCards(cfg.ProviderEgressUSDPerTiB)
+	if cfg.ProviderEgressRates != "" {
+		ratesCfg, err := pricing.ParseRateCardConfig([]byte(cfg.ProviderEgressRates))
+		if err != nil {
+			return err
+		}
+		payRates, err = pricing.NewRateCards(ratesCfg)
+		if err != nil {
+			return fmt.Errorf("creating payout rate cards: %w", err)
+		}
+	}
+
+	var (
+		quotas       *quota.Quotas
+		quotaMonitor *quota.Monitor
+	)
+	if cfg.EgressQuotas != "" {
+		if accountStatsTable == nil {
+			return errors.New("egress quotas require the account stats table")
+		}
+		quotaCfg, err := quota.ParseConfig([]byte(cfg.EgressQuotas))
+		if err != nil {
+			return err
+		}
+		quotas, err = quota.New(quotaCfg, pricer, consumerTable, accountStatsTable)
+		if err != nil {
+			return fmt.Errorf("creating egress quotas: %w", err)
+		}
+
+		var sink quota.Sink = quota.LogSink{}
+		if cfg.EgressQuotaWebhookURL != "" {
+			webhookURL, err := url.Parse(cfg.EgressQuotaWebhookURL)
+			if err != nil {
+				return fmt.Errorf("parsing quota webhook URL: %w", err)
+			}
+			sink = quota.NewWebhookSink(webhookURL)
+		}
+		quotaMonitor = quota.NewMonitor(quotas, sink)
+	}
+
+	if len(cfg.ExcludedSpaces) > 0 && excludedSpaceStatsTable == nil {
+		return errors.New("excluded spaces require the excluded space stats table")
+	}
+	exclusions, err := exclusion.Parse(cfg.ExcludedSpaces, excludedSpaceStatsTable)
+	if err != nil {
+		return err
+	}
+
+	// Spaces are only accepted from known providers, which are the networks egress is broken down by
+	networks := make([]did.DID, 0, len(cfg.KnownProviders))
+	for _, provider := range cfg.KnownProviders {
+		network, err := did.Parse(provider)
+		if err != nil {
+			return fmt.Errorf("parsing known provider: %w", err)
+		}
+		networks = append(networks, network)
+	}
+
+	var (
+		callbackTable callbacks.CallbackTable
+		notifier      *callback.Notifier
+	)
+	if cfg.CallbackTableName != "" && cfg.CallbackDeliveryTableName != "" {
+		callbackTable = callbacks.NewDynamoCallbackTable(dynamoClient, cfg.CallbackTableName)
+		deliveryTable := deliveries.NewDynamoDeliveryTable(dynamoClient, cfg.CallbackDeliveryTableName, cfg.CallbackPendingIndexName)
+		notifier = callback.New(
+			callbackTable,
+			deliveryTable,
+			callback.WithMaxAttempts(cfg.CallbackMaxAttempts),
+			callback.WithBackoff(time.Duration(cfg.CallbackInitialBackoff)*time.Second, time.Hour),
+		)
+	}
+
 	svc, err := service.New(
 		id,
 		egressTable,
@@ -222,6 +612,19 @@ func startService(cmd *cobra.Command, args []string) error {
 		customerTable,
 		consumerTable,
 		spaceStatsTable,
+		service.WithHourlyStats(hourlyStatsTable),
+		service.WithAccountStats(accountStatsTable),
+		service.WithNetworkStats(networkStatsTable, networks),
+		service.WithAnomalies(anomalyTable),
+		service.WithCallbacks(callbackTable, notifier),
+		service.WithStatements(statementTable),
+		service.WithPayouts(payoutTable, attestationTable),
+		service.WithLedger(ledgerTable),
+		service.WithPricing(pricer),
+		service.WithPayRates(payRates),
+		service.WithQuotas(quotas),
+		service.WithQuotaMonitor(quotaMonitor),
+		service.WithExclusions(exclusions),
 	)
 	if err != nil {
 		return fmt.Errorf("creating service: %w", err)
@@ -264,6 +667,22 @@ func startService(cmd *cobra.Command, args []string) error {
 	interval := time.Duration(cfg.ConsolidationInterval) * time.Second
 	batchSize := cfg.ConsolidationBatchSize
 
+	var detector *anomaly.Detector
+	if anomalyTable != nil {
+		detector = anomaly.New(
+			anomaly.Config{
+				BaselineDays:       cfg.AnomalyBaselineDays,
+				MinBaselineDays:    cfg.AnomalyMinBaselineDays,
+				SpikeStdDevs:       cfg.AnomalySpikeStdDevs,
+				MinNodeEgress:      cfg.AnomalyMinNodeEgress,
+				MaxSpaceShare:      cfg.AnomalyMaxSpaceShare,
+				MaxIdenticalRanges: cfg.AnomalyMaxIdenticalRanges,
+			},
+			anomalyTable,
+			consolidatedTable,
+		)
+	}
+
 	cons, err := consolidator.New(
 		id,
 		egressTable,
@@ -275,6 +694,19 @@ func startService(cmd *cobra.Command, args []string) error {
 		batchSize,
 		presolver.ResolveDIDKey,
 		authProofs,
+		consolidator.WithAnomalyDetector(detector),
+		consolidator.WithNewNodeHold(time.Duration(cfg.HoldNewNodeDays)*24*time.Hour),
+		consolidator.WithBatchEgressHold(cfg.HoldMaxBatchEgress),
+		consolidator.WithAnomalyHold(anomalyTable, time.Duration(cfg.HoldAnomalyWindowHours)*time.Hour),
+		consolidator.WithNotifier(notifier),
+		consolidator.WithHourlyStats(hourlyStatsTable),
+		consolidator.WithAccountStats(accountStatsTable),
+		consolidator.WithPricing(pricer),
+		consolidator.WithNetworkStats(networkStatsTable),
+		consolidator.WithLedger(ledgerTable),
+		consolidator.WithQuotaMonitor(quotaMonitor),
+		consolidator.WithExclusions(exclusions),
+		consolidator.WithMaxRangeLength(cfg.ConsolidationMaxRangeLength),
 	)
 	if err != nil {
 		return fmt.Errorf("creating consolidator: %w", err)
@@ -283,6 +715,39 @@ func startService(cmd *cobra.Command, args []string) error {
 	// Start consolidator in a goroutine
 	go cons.Start(ctx)
 
+	var rec *reconciler.Reconciler
+	if accountStatsTable != nil {
+		rec = reconciler.New(
+			customerTable,
+			consumerTable,
+			spaceStatsTable,
+			accountStatsTable,
+			pricer,
+			time.Duration(cfg.AccountStatsReconcileInterval)*time.Second,
+			time.Duration(cfg.AccountStatsReconcileDays)*24*time.Hour,
+		)
+		go rec.Start(ctx)
+	}
+
+	var biller *billing.Biller
+	if statementTable != nil {
+		biller = billing.New(
+			customerTable,
+			consumerTable,
+			spaceStatsTable,
+			statementTable,
+			pricer,
+			time.Duration(cfg.BillingInterval)*time.Second,
+			time.Duration(cfg.BillingCloseDelayHours)*time.Hour,
+			cfg.BillingAdjustmentMonths,
+		)
+		go biller.Start(ctx)
+	}
+
+	if notifier != nil {
+		go notifier.Start(ctx)
+	}
+
 	// Multi-format principal parser that supports both Ed25519 and RSA keys
 	parsePrincipal := func(str string) (principal.Verifier, error) {
 		// Try Ed25519 first
@@ -305,7 +770,7 @@ func startService(cmd *cobra.Command, args []string) error {
 		cons,
 		server.WithMetricsEndpoint(cfg.MetricsAuthToken),
 		server.WithAdminCreds(cfg.AdminDashboardUser, cfg.AdminDashboardPassword),
-		server.WithPricing(cfg.ClientEgressUSDPerTiB, cfg.ProviderEgressUSDPerTiB),
+		server.WithPayRates(payRates),
 		server.WithPrincipalResolver(presolver),
 		server.WithPrincipalParser(parsePrincipal),
 		server.WithAuthorityProofs(authProofs...),
@@ -324,14 +789,28 @@ func startService(cmd *cobra.Command, args []string) error {
 		errCh <- server.ListenAndServe(fmt.Sprintf(":%d", cfg.Port))
 	}()
 
+	// Stop the background workers, of which those of unconfigured features were not started
+	stopWorkers := func() {
+		cons.Stop()
+		if rec != nil {
+			rec.Stop()
+		}
+		if biller != nil {
+			biller.Stop()
+		}
+		if notifier != nil {
+			notifier.Stop()
+		}
+	}
+
 	select {
 	case err := <-errCh:
 		log.Errorf("Server error: %v", err)
-		cons.Stop()
+		stopWorkers()
 		return err
 	case sig := <-sigCh:
 		log.Infof("Received signal %v, shutting down gracefully", sig)
-		cons.Stop()
+		stopWorkers()
 		cancel()
 		return nil
 	}
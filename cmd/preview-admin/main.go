@@ -5,15 +5,46 @@ import (
 	"fmt"
 	"log"
 	"net/http"
+	"net/url"
 	"time"
 
+	"github.com/ipfs/go-cid"
+	cidlink "github.com/ipld/go-ipld-prime/linking/cid"
 	"github.com/storacha/go-ucanto/did"
+	"github.com/storacha/go-ucanto/ucan"
 
+	"github.com/storacha/etracker/internal/db/anomalies"
+	"github.com/storacha/etracker/internal/db/callbacks"
+	"github.com/storacha/etracker/internal/db/consolidated"
+	"github.com/storacha/etracker/internal/db/ledger"
+	"github.com/storacha/etracker/internal/db/payouts"
+	"github.com/storacha/etracker/internal/db/statements"
 	"github.com/storacha/etracker/internal/db/storageproviders"
+	"github.com/storacha/etracker/internal/pricing"
+	"github.com/storacha/etracker/internal/quota"
 	"github.com/storacha/etracker/internal/service"
 	"github.com/storacha/etracker/web"
 )
 
+// mockPayRates pays the first mock node a rate of its own that was raised this month, and every other node $2.80
+// per TiB
+func mockPayRates() *pricing.RateCards {
+	thisMonth := time.Now().UTC().Format("2006-01") + "-01"
+	rates, err := pricing.NewRateCards(pricing.RateCardConfig{
+		Default: []pricing.RateConfig{{USDPerTiB: 2.80}},
+		Tiers: map[string][]pricing.RateConfig{
+			"gold": {{USDPerTiB: 2.80}, {From: thisMonth, USDPerTiB: 3.05}},
+		},
+		Nodes: map[string]pricing.NodeRateConfig{
+			"did:key:z6MkrZ1r5XBFZjBU34qyD8fueMbMRkKw17BZaq2ivKFjnz2z": {Tier: "gold"},
+		},
+	})
+	if err != nil {
+		panic(err)
+	}
+	return rates
+}
+
 // mockService implements a mock version of the service for preview purposes
 type mockService struct{}
 
@@ -95,8 +126,12 @@ func (m *mockService) GetAllProvidersStats(ctx context.Context, limit int, start
 	// Build providers with stats - with varied data
 	providersWithStats := make([]service.ProviderWithStats, 0, len(mockProviders))
 	multipliers := []float64{1.5, 5.0, 12.0, 0.3, 25.0} // Different traffic levels from ~15 TiB to ~1.25 PiB
+	rates := mockPayRates()
 	for i, provider := range mockProviders {
 		stats := m.getMockStats(provider.Provider, multipliers[i])
+		for _, period := range []*service.PeriodStats{&stats.PreviousMonth, &stats.CurrentMonth, &stats.CurrentWeek, &stats.CurrentDay} {
+			period.Cost = rates.Payout(provider.Provider, period.Egress, period.Period.From)
+		}
 		providersWithStats = append(providersWithStats, service.ProviderWithStats{
 			Provider:   provider,
 			Stats:      stats,
@@ -126,12 +161,28 @@ func (m *mockService) GetAllAccountsStats(ctx context.Context, limit int, startT
 	// Build accounts with stats - with varied data
 	accountsWithStats := make([]service.AccountStats, 0, len(mockAccounts))
 	multipliers := []float64{8.0, 3.5, 0.8, 15.0, 1.2, 20.0, 0.4} // Different usage levels from ~20 TiB to ~1 PiB
+	// Shares of their monthly quota used by the accounts, unlimited accounts have none
+	quotaShares := []float64{0.45, 0, 0.85, 1.1, 0, 0.6, 0}
+	plan := pricing.FlatPlan(pricing.DefaultPlanName, 10.00)
 	for i, account := range mockAccounts {
 		stats := m.getMockStats(account, multipliers[i])
+		for _, period := range []*service.PeriodStats{&stats.PreviousMonth, &stats.CurrentMonth, &stats.CurrentWeek, &stats.CurrentDay} {
+			period.Cost = plan.Price(period.Egress)
+		}
+		var usage *quota.Usage
+		if quotaShares[i] > 0 {
+			usage = &quota.Usage{
+				Account: account,
+				Month:   stats.CurrentMonth.Period.From,
+				Limit:   uint64(float64(stats.CurrentMonth.Egress) / quotaShares[i]),
+				Used:    stats.CurrentMonth.Egress,
+			}
+		}
 		accountsWithStats = append(accountsWithStats, service.AccountStats{
 			Account:    account,
 			Stats:      stats,
 			StatsError: nil,
+			Quota:      usage,
 		})
 	}
 
@@ -142,6 +193,277 @@ func (m *mockService) GetAllAccountsStats(ctx context.Context, limit int, startT
 	}, nil
 }
 
+func (m *mockService) GetAnomalies(ctx context.Context, limit int, startToken *string) (*service.GetAnomaliesResult, error) {
+	now := time.Now().UTC()
+	node := must(did.Parse("did:key:z6MkwCQm4mGfvAQJ9FzQb5nR5qZ7VHmGQG3dFfvGH5xnU3Rr"))
+	space := must(did.Parse("did:key:z6MkfQ7kBJpPFZzLvXHGmF2nqC9v8eUxPRjzUgVZYQxQz3Kk"))
+	cause := cidlink.Link{Cid: must(cid.Parse("bafyreigh2akiscaildcqabsyg3dfr6chu3fgpregiymsck7e7aqa4s52zy"))}
+
+	// Create mock anomaly flags
+	flags := []anomalies.Flag{
+		{
+			ID:         "node-egress-spike",
+			Kind:       anomalies.KindNodeEgressSpike,
+			Severity:   anomalies.SeverityCritical,
+			Node:       node,
+			Message:    "node egress today (5497558138880 bytes) is 9.3 standard deviations above its 14 day baseline (549755813888 bytes/day)",
+			Evidence:   []ucan.Link{cause},
+			DetectedAt: now.Add(-2 * time.Hour),
+		},
+		{
+			ID:         "space-egress-share",
+			Kind:       anomalies.KindSpaceEgressShare,
+			Severity:   anomalies.SeverityWarning,
+			Node:       node,
+			Space:      space,
+			Message:    "space received 97.2% of the 1099511627776 bytes served by the node in this cycle",
+			Evidence:   []ucan.Link{cause},
+			DetectedAt: now.Add(-26 * time.Hour),
+		},
+	}
+
+	// Simple pagination: no next token for mock data
+	return &service.GetAnomaliesResult{
+		Flags:     flags,
+		NextToken: nil,
+	}, nil
+}
+
+func (m *mockService) GetHeldBatches(ctx context.Context, limit int, startToken *string) (*service.GetHeldBatchesResult, error) {
+	now := time.Now().UTC()
+	node := must(did.Parse("did:key:z6MkwCQm4mGfvAQJ9FzQb5nR5qZ7VHmGQG3dFfvGH5xnU3Rr"))
+	space := must(did.Parse("did:key:z6MkfQ7kBJpPFZzLvXHGmF2nqC9v8eUxPRjzUgVZYQxQz3Kk"))
+	cause := cidlink.Link{Cid: must(cid.Parse("bafyreigh2akiscaildcqabsyg3dfr6chu3fgpregiymsck7e7aqa4s52zy"))}
+
+	// Create mock held batches
+	batches := []consolidated.ConsolidatedRecord{
+		{
+			Cause:       cause,
+			Node:        node,
+			ProcessedAt: now.Add(-3 * time.Hour),
+			Status:      consolidated.StatusHeld,
+			HeldEgress:  1099511627776,
+			HoldReasons: []string{
+				"batch egress of 1099511627776 bytes exceeds the limit of 549755813888 bytes",
+				"node has 2 anomaly flags raised since " + now.Add(-24*time.Hour).Format(time.DateTime),
+			},
+			SpaceEgress: map[did.DID]uint64{space: 1099511627776},
+		},
+	}
+
+	// Simple pagination: no next token for mock data
+	return &service.GetHeldBatchesResult{
+		Batches:   batches,
+		NextToken: nil,
+	}, nil
+}
+
+func (m *mockService) ApproveBatch(ctx context.Context, cause ucan.Link) error {
+	log.Printf("approved batch %s", cause)
+	return nil
+}
+
+func (m *mockService) RejectBatch(ctx context.Context, cause ucan.Link, reason string) error {
+	log.Printf("rejected batch %s: %s", cause, reason)
+	return nil
+}
+
+func (m *mockService) GetCallbacks(ctx context.Context, limit int, startToken *string) (*service.GetCallbacksResult, error) {
+	node := must(did.Parse("did:key:z6MkwCQm4mGfvAQJ9FzQb5nR5qZ7VHmGQG3dFfvGH5xnU3Rr"))
+
+	return &service.GetCallbacksResult{
+		Registrations: []callbacks.Registration{
+			{Node: node, Callback: must(url.Parse("https://node.example.com/egress/callback"))},
+		},
+	}, nil
+}
+
+func (m *mockService) SetCallback(ctx context.Context, node did.DID, callbackURL string) error {
+	log.Printf("set callback of %s to %q", node, callbackURL)
+	return nil
+}
+
+func (m *mockService) GetStatements(ctx context.Context, limit int, startToken *string) (*service.GetStatementsResult, error) {
+	now := time.Now().UTC()
+	account := must(did.Parse("did:mailto:example.com:alice"))
+	space := must(did.Parse("did:key:z6MkfQ7kBJpPFZzLvXHGmF2nqC9v8eUxPRjzUgVZYQxQz3Kk"))
+	month := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC).AddDate(0, -1, 0)
+
+	return &service.GetStatementsResult{
+		Statements: []statements.Statement{
+			{
+				Account:  account,
+				Month:    month,
+				ClosedAt: month.AddDate(0, 1, 2),
+				Plans:    []pricing.Plan{{Name: "pro", IncludedEgress: 1099511627776, Tiers: []pricing.Tier{{From: 0, USDPerTiB: 10.00}}}},
+				Lines:    []statements.Line{{Space: space, Plan: "pro", Egress: 2199023255552}},
+			},
+			{
+				Account:     account,
+				Month:       month.AddDate(0, -1, 0),
+				ClosedAt:    month.AddDate(0, 0, 2),
+				Plans:       []pricing.Plan{pricing.FlatPlan(pricing.DefaultPlanName, 10.00)},
+				Lines:       []statements.Line{{Space: space, Plan: pricing.DefaultPlanName, Egress: 1099511627776}},
+				Adjustments: []statements.Adjustment{{Space: space, Plan: pricing.DefaultPlanName, Egress: 109951162777, RecordedAt: month.AddDate(0, 0, 5)}},
+			},
+		},
+	}, nil
+}
+
+func (m *mockService) GetStatement(ctx context.Context, account did.DID, month time.Time) (*statements.Statement, error) {
+	result, err := m.GetStatements(ctx, 0, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, statement := range result.Statements {
+		if statement.Account == account && statement.Month.Equal(month) {
+			return &statement, nil
+		}
+	}
+	return nil, service.ErrStatementNotFound
+}
+
+func (m *mockService) GeneratePayoutRun(ctx context.Context, month time.Time) (*payouts.Run, error) {
+	log.Printf("generated payout run of %s", month.Format("2006-01"))
+	return m.GetPayoutRun(ctx, month)
+}
+
+func (m *mockService) FinalizePayoutRun(ctx context.Context, month time.Time) error {
+	log.Printf("finalized payout run of %s", month.Format("2006-01"))
+	return nil
+}
+
+func (m *mockService) GetPayoutRuns(ctx context.Context) ([]payouts.Run, error) {
+	now := time.Now().UTC()
+	month := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC).AddDate(0, -1, 0)
+
+	return []payouts.Run{
+		{Month: month, Status: payouts.StatusDraft, GeneratedAt: now.Add(-time.Hour), Providers: 1, TotalEgress: 1099511627776, TotalAmount: 3.05},
+		{Month: month.AddDate(0, -1, 0), Status: payouts.StatusFinalized, GeneratedAt: month.AddDate(0, 0, 1), FinalizedAt: month.AddDate(0, 0, 2), Providers: 1, TotalEgress: 549755813888, TotalAmount: 1.40},
+	}, nil
+}
+
+func (m *mockService) GetPayoutRun(ctx context.Context, month time.Time) (*payouts.Run, error) {
+	runs, err := m.GetPayoutRuns(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	node := must(did.Parse("did:key:z6MkwCQm4mGfvAQJ9FzQb5nR5qZ7VHmGQG3dFfvGH5xnU3Rr"))
+	cause := cidlink.Link{Cid: must(cid.Parse("bafyreigh2akiscaildcqabsyg3dfr6chu3fgpregiymsck7e7aqa4s52zy"))}
+	for _, run := range runs {
+		if run.Month.Equal(month) {
+			run.Lines = []payouts.Line{{
+				Provider:      node,
+				WalletAddress: "0x1234567890abcdef1234567890abcdef12345678",
+				OperatorEmail: "operator@example.com",
+				Egress:        run.TotalEgress,
+				RateCard:      "gold",
+				Amount:        run.TotalAmount,
+				Causes:        []ucan.Link{cause},
+			}}
+			if run.Status == payouts.StatusFinalized {
+				run.Lines[0].Attestation = cause
+			}
+			return &run, nil
+		}
+	}
+	return nil, service.ErrPayoutRunNotFound
+}
+
+func (m *mockService) AttestPayoutRun(ctx context.Context, month time.Time) (int, error) {
+	log.Printf("attested payout run of %s", month.Format("2006-01"))
+	return 0, nil
+}
+
+func (m *mockService) GetLedgerBalance(ctx context.Context, book ledger.Book, period service.Period, asOf time.Time) (*service.LedgerBalance, error) {
+	space := must(did.Parse("did:key:z6MkgnVHJr7ZExvG2yT8qgR9XEJHUkuGXp5f9hQ2QhDVmWdR"))
+	cause := cidlink.Link{Cid: must(cid.Parse("bafyreigh2akiscaildcqabsyg3dfr6chu3fgpregiymsck7e7aqa4s52zy"))}
+	credited := period.From.AddDate(0, 0, 3)
+
+	entries := []ledger.BookEntry{
+		{
+			Entry:       ledger.Entry{Book: book, Space: space, Egress: book.Sign() * 1099511627776},
+			Transaction: ledger.CreditID(cause),
+			Kind:        ledger.KindCredit,
+			Actor:       "did:web:etracker.storacha.network",
+			Cause:       cause,
+			EffectiveAt: credited,
+			PostedAt:    credited,
+		},
+		{
+			Entry:       ledger.Entry{Book: book, Egress: book.Sign() * -54975581388},
+			Transaction: ledger.AdjustmentID(book, credited.AddDate(0, 0, 2)),
+			Kind:        ledger.KindAdjustment,
+			Actor:       "alice",
+			Reason:      "duplicate receipts in batch",
+			Cause:       cause,
+			EffectiveAt: credited,
+			PostedAt:    credited.AddDate(0, 0, 2),
+		},
+	}
+
+	balance := &service.LedgerBalance{Book: book, Period: period, AsOf: asOf}
+	for _, e := range entries {
+		if !asOf.IsZero() && e.PostedAt.After(asOf) {
+			continue
+		}
+		egress := book.Sign() * e.Egress
+		balance.Egress += egress
+		if e.Kind == ledger.KindCredit {
+			balance.Credited += egress
+		} else {
+			balance.Adjusted += egress
+		}
+		balance.Entries = append(balance.Entries, e)
+	}
+	return balance, nil
+}
+
+func (m *mockService) PostLedgerAdjustment(ctx context.Context, adjustment service.LedgerAdjustment) (*ledger.Transaction, error) {
+	log.Printf("posted adjustment of %d bytes to %s by %s: %s", adjustment.Egress, adjustment.Book, adjustment.Actor, adjustment.Reason)
+	return &ledger.Transaction{Kind: ledger.KindAdjustment, Entries: []ledger.Entry{{Book: adjustment.Book, Egress: adjustment.Egress}}}, nil
+}
+
+func (m *mockService) ReverseBatch(ctx context.Context, cause ucan.Link, actor, reason string) (*ledger.Transaction, error) {
+	log.Printf("reversed batch %s by %s: %s", cause, actor, reason)
+	node := must(did.Parse("did:key:z6MkwCQm4mGfvAQJ9FzQb5nR5qZ7VHmGQG3dFfvGH5xnU3Rr"))
+	return &ledger.Transaction{Kind: ledger.KindReversal, Cause: cause, Entries: []ledger.Entry{{Book: ledger.NodeBook(node)}}}, nil
+}
+
+func (m *mockService) GetExcludedSpacesStats(ctx context.Context) ([]service.ExcludedSpaceStats, error) {
+	monitoring := must(did.Parse("did:key:z6MkpTHR8VNsBxYAAWHut2Geadd9jSwuBV8xRoAnwWsdvktH"))
+	synthetic := must(did.Parse("did:key:z6MkfE2xBbLNNEFdK4F6dSgyNdBmKRV2LqxQWnZLhtAY6dBf"))
+	return []service.ExcludedSpaceStats{
+		{Space: monitoring, Account: must(did.Parse("did:mailto:storacha.network:monitoring")), Stats: m.getMockStats(monitoring, 0.05)},
+		{Space: synthetic, Stats: m.getMockStats(synthetic, 0.01)},
+	}, nil
+}
+
+func (m *mockService) GetNetworkStats(ctx context.Context, period service.Period) (*service.NetworkStats, error) {
+	forge := must(did.Parse("did:web:up.forge.storacha.network"))
+	warm := must(did.Parse("did:web:staging.up.warm.storacha.network"))
+	stats := &service.NetworkStats{
+		Period:   period,
+		Networks: []did.DID{forge, warm},
+		Totals:   map[did.DID]uint64{},
+	}
+	// Mock data - forge serves most of the egress, staging a few percent of it
+	for day, i := period.From, 0; day.Before(period.To); day, i = day.AddDate(0, 0, 1), i+1 {
+		egress := map[did.DID]uint64{
+			forge: uint64(1759218604441) * uint64(10+i%7),
+			warm:  uint64(54975581388) * uint64(5+i%3),
+		}
+		for network, e := range egress {
+			stats.Totals[network] += e
+			stats.Total += e
+		}
+		stats.Days = append(stats.Days, service.NetworkDay{Date: day, Egress: egress})
+	}
+	return stats, nil
+}
+
 func must[T any](v T, err error) T {
 	if err != nil {
 		panic(err)
@@ -172,8 +494,10 @@ func main() {
 
 	// Wrap admin handler with authentication
 	// Use a default pricing value for preview (clients: $10 per TiB, providers: $2.80 per TiB)
-	adminHandler := web.BasicAuthMiddleware(web.AdminHandler(mockSvc, 10.00, 2.80), username, password)
+	adminHandler := web.BasicAuthMiddleware(web.AdminHandler(mockSvc, mockPayRates()), username, password)
 	mux.HandleFunc("/admin", adminHandler)
+	mux.HandleFunc("GET /admin/statements/{account}/{month}", web.BasicAuthMiddleware(web.StatementHandler(mockSvc), username, password))
+	mux.HandleFunc("GET /admin/payouts/{month}", web.BasicAuthMiddleware(web.PayoutHandler(mockSvc), username, password))
 	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
 		http.Redirect(w, r, "/admin", http.StatusFound)
 	})
@@ -61,6 +61,26 @@ module "app" {
     {
       name = "ETRACKER_PROVIDER_EGRESS_USD_PER_TIB"
       value = var.provider_egress_usd_per_tib
+    },
+    {
+      name = "ETRACKER_CLIENT_EGRESS_PRICING"
+      value = var.client_egress_pricing
+    },
+    {
+      name = "ETRACKER_PROVIDER_EGRESS_RATES"
+      value = var.provider_egress_rates
+    },
+    {
+      name = "ETRACKER_EGRESS_QUOTAS"
+      value = var.egress_quotas
+    },
+    {
+      name = "ETRACKER_EGRESS_QUOTA_WEBHOOK_URL"
+      value = var.egress_quota_webhook_url
+    },
+    {
+      name = "ETRACKER_EXCLUDED_SPACES"
+      value = var.excluded_spaces
     }
   ]
   image_tag = var.image_tag
@@ -88,6 +108,22 @@ module "app" {
           name = "unprocessedSince"
           type = "S"
         },
+        {
+          name = "causeLink"
+          type = "S"
+        },
+        {
+          name = "node"
+          type = "S"
+        },
+        {
+          name = "receivedAt"
+          type = "S"
+        },
+        {
+          name = "queue"
+          type = "S"
+        },
       ]
       hash_key = "batch"
       global_secondary_indexes = [
@@ -98,6 +134,25 @@ module "app" {
           projection_type = "INCLUDE"
           non_key_attributes = ["node","cause",]
         },
+        {
+          name = "queue"
+          hash_key = "queue"
+          range_key = "unprocessedSince"
+          projection_type = "INCLUDE"
+          non_key_attributes = ["node","cause",]
+        },
+        {
+          name = "cause"
+          hash_key = "causeLink"
+          projection_type = "KEYS_ONLY"
+        },
+        {
+          name = "node"
+          hash_key = "node"
+          range_key = "receivedAt"
+          projection_type = "INCLUDE"
+          non_key_attributes = ["causeLink","unprocessedSince",]
+        },
       ]
     },
     {
@@ -115,6 +170,22 @@ module "app" {
           name = "processedAt"
           type = "S"
         },
+        {
+          name = "heldSince"
+          type = "S"
+        },
+        {
+          name = "reviewedAt"
+          type = "S"
+        },
+        {
+          name = "trackCause"
+          type = "S"
+        },
+        {
+          name = "batch"
+          type = "S"
+        },
       ]
       hash_key = "cause"
       global_secondary_indexes = [
@@ -125,6 +196,30 @@ module "app" {
           projection_type = "INCLUDE"
           non_key_attributes = ["totalEgress",]
         },
+        {
+          name = "held"
+          hash_key = "cause"
+          range_key = "heldSince"
+          projection_type = "INCLUDE"
+          non_key_attributes = ["node","processedAt","status","heldEgress","holdReasons","spaceEgress",]
+        },
+        {
+          name = "reviewed"
+          hash_key = "node"
+          range_key = "reviewedAt"
+          projection_type = "INCLUDE"
+          non_key_attributes = ["processedAt","status","totalEgress",]
+        },
+        {
+          name = "track-cause"
+          hash_key = "trackCause"
+          projection_type = "KEYS_ONLY"
+        },
+        {
+          name = "batch"
+          hash_key = "batch"
+          projection_type = "KEYS_ONLY"
+        },
       ]
     },
     {
@@ -141,6 +236,205 @@ module "app" {
       ]
       hash_key = "space"
       range_key = "date"
+      ttl_attribute = "expiresAt"
+    },
+    {
+      name = "space-hourly-stats"
+      attributes = [
+        {
+          name = "space"
+          type = "S"
+        },
+        {
+          name = "hour"
+          type = "S"
+        },
+      ]
+      hash_key = "space"
+      range_key = "hour"
+      ttl_attribute = "expiresAt"
+    },
+    {
+      name = "excluded-space-stats"
+      attributes = [
+        {
+          name = "space"
+          type = "S"
+        },
+        {
+          name = "date"
+          type = "S"
+        },
+      ]
+      hash_key = "space"
+      range_key = "date"
+      ttl_attribute = "expiresAt"
+    },
+    {
+      name = "network-stats"
+      attributes = [
+        {
+          name = "network"
+          type = "S"
+        },
+        {
+          name = "date"
+          type = "S"
+        },
+      ]
+      hash_key = "network"
+      range_key = "date"
+      ttl_attribute = "expiresAt"
+    },
+    {
+      name = "account-stats"
+      attributes = [
+        {
+          name = "account"
+          type = "S"
+        },
+        {
+          name = "date"
+          type = "S"
+        },
+      ]
+      hash_key = "account"
+      range_key = "date"
+      ttl_attribute = "expiresAt"
+    },
+    {
+      name = "statements"
+      attributes = [
+        {
+          name = "account"
+          type = "S"
+        },
+        {
+          name = "month"
+          type = "S"
+        },
+      ]
+      hash_key = "account"
+      range_key = "month"
+    },
+    {
+      name = "payouts"
+      attributes = [
+        {
+          name = "month"
+          type = "S"
+        },
+        {
+          name = "item"
+          type = "S"
+        },
+      ]
+      hash_key = "month"
+      range_key = "item"
+    },
+    {
+      name = "attestations"
+      attributes = [
+        {
+          name = "node"
+          type = "S"
+        },
+        {
+          name = "month"
+          type = "S"
+        },
+        {
+          name = "cause"
+          type = "S"
+        },
+      ]
+      hash_key = "node"
+      range_key = "month"
+      global_secondary_indexes = [
+        {
+          name = "cause"
+          hash_key = "cause"
+          projection_type = "KEYS_ONLY"
+        },
+      ]
+    },
+    {
+      name = "ledger"
+      attributes = [
+        {
+          name = "book"
+          type = "S"
+        },
+        {
+          name = "entry"
+          type = "S"
+        },
+      ]
+      hash_key = "book"
+      range_key = "entry"
+    },
+    {
+      name = "anomaly-flags"
+      attributes = [
+        {
+          name = "id"
+          type = "S"
+        },
+        {
+          name = "node"
+          type = "S"
+        },
+        {
+          name = "detectedAt"
+          type = "S"
+        },
+      ]
+      hash_key = "id"
+      global_secondary_indexes = [
+        {
+          name = "node"
+          hash_key = "node"
+          range_key = "detectedAt"
+          projection_type = "ALL"
+        },
+      ]
+    },
+    {
+      name = "node-callbacks"
+      attributes = [
+        {
+          name = "node"
+          type = "S"
+        },
+      ]
+      hash_key = "node"
+    },
+    {
+      name = "callback-deliveries"
+      attributes = [
+        {
+          name = "cause"
+          type = "S"
+        },
+        {
+          name = "attempt"
+          type = "N"
+        },
+        {
+          name = "nextAttemptAt"
+          type = "S"
+        },
+      ]
+      hash_key = "cause"
+      range_key = "attempt"
+      global_secondary_indexes = [
+        {
+          name = "pending"
+          hash_key = "cause"
+          range_key = "nextAttemptAt"
+          projection_type = "ALL"
+        },
+      ]
     },
   ]
   buckets = [
@@ -75,4 +75,34 @@ variable "client_egress_usd_per_tib" {
 variable "provider_egress_usd_per_tib" {
   description = "Provider egress rate in USD per TiB"
   type        = string
+}
+
+variable "client_egress_pricing" {
+  description = "JSON pricing plans of client egress by subscription and provider, client_egress_usd_per_tib applies to every space when empty"
+  type        = string
+  default     = ""
+}
+
+variable "provider_egress_rates" {
+  description = "JSON payout rate cards of nodes by node and tier with effective dates, provider_egress_usd_per_tib applies to every node when empty"
+  type        = string
+  default     = ""
+}
+
+variable "egress_quotas" {
+  description = "JSON monthly egress limits of accounts by account and pricing plan, no account is limited when empty"
+  type        = string
+  default     = ""
+}
+
+variable "egress_quota_webhook_url" {
+  description = "URL egress quota alerts are posted to, alerts are logged when empty"
+  type        = string
+  default     = ""
+}
+
+variable "excluded_spaces" {
+  description = "Comma-separated DIDs of the spaces whose egress is neither billed nor paid for, such as monitoring spaces"
+  type        = string
+  default     = ""
 }
\ No newline at end of file
@@ -0,0 +1,335 @@
+package anomaly
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"slices"
+	"sync"
+	"time"
+
+	logging "github.com/ipfs/go-log/v2"
+	"github.com/storacha/go-libstoracha/capabilities/space/content"
+	"github.com/storacha/go-ucanto/did"
+	"github.com/storacha/go-ucanto/ucan"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+
+	"github.com/storacha/etracker/internal/db/anomalies"
+	"github.com/storacha/etracker/internal/db/consolidated"
+	"github.com/storacha/etracker/internal/metrics"
+)
+
+var log = logging.Logger("anomaly")
+
+// maxEvidence limits the number of batch causes stored with a single flag
+const maxEvidence = 100
+
+type Config struct {
+	// BaselineDays is the number of trailing days used to compute a node's egress baseline
+	BaselineDays int
+	// MinBaselineDays is the minimum number of days of history a node needs before spikes are detected
+	MinBaselineDays int
+	// SpikeStdDevs is how many standard deviations above the baseline a node's daily egress must be to be flagged
+	SpikeStdDevs float64
+	// MinNodeEgress is the egress (in bytes) below which a node's activity is never flagged
+	MinNodeEgress uint64
+	// MaxSpaceShare is the maximum share (0-1] of a node's egress in a cycle that a single space may receive
+	MaxSpaceShare float64
+	// MaxIdenticalRanges is the maximum number of retrievals of the very same byte range in a cycle
+	MaxIdenticalRanges int
+}
+
+func DefaultConfig() Config {
+	return Config{
+		BaselineDays:       14,
+		MinBaselineDays:    7,
+		SpikeStdDevs:       4,
+		MinNodeEgress:      1 << 30, // 1 GiB
+		MaxSpaceShare:      0.9,
+		MaxIdenticalRanges: 100,
+	}
+}
+
+// Detector looks for anomalous egress in the batches consolidated during a consolidation cycle.
+// Retrievals are fed to the detector with Observe as batches are consolidated, and Run evaluates
+// everything observed since the previous run before the batches of the cycle are committed, so that
+// the batches raising a flag can be held for review.
+type Detector struct {
+	cfg               Config
+	anomalyTable      anomalies.AnomalyTable
+	consolidatedTable consolidated.ConsolidatedTable
+
+	mu    sync.Mutex
+	nodes map[did.DID]*nodeActivity
+}
+
+type activity struct {
+	egress uint64
+	count  int
+	causes []ucan.Link
+}
+
+func (a *activity) add(cause ucan.Link, egress uint64) {
+	a.egress += egress
+	a.count++
+	if len(a.causes) < maxEvidence && !slices.ContainsFunc(a.causes, func(c ucan.Link) bool { return c.String() == cause.String() }) {
+		a.causes = append(a.causes, cause)
+	}
+}
+
+type rangeKey struct {
+	space  did.DID
+	digest string
+	start  uint64
+	end    uint64
+}
+
+type nodeActivity struct {
+	activity
+	spaces map[did.DID]*activity
+	ranges map[rangeKey]*activity
+	// batches holds the causes of every batch observed, unlike the evidence it is not limited
+	batches map[string]struct{}
+}
+
+func New(cfg Config, anomalyTable anomalies.AnomalyTable, consolidatedTable consolidated.ConsolidatedTable) *Detector {
+	return &Detector{
+		cfg:               cfg,
+		anomalyTable:      anomalyTable,
+		consolidatedTable: consolidatedTable,
+		nodes:             make(map[did.DID]*nodeActivity),
+	}
+}
+
+// Observe records a retrieval served by node as part of the batch consolidated by cause.
+// egress is the number of bytes counted for the retrieval after validation.
+func (d *Detector) Observe(node did.DID, cause ucan.Link, space did.DID, nb content.RetrieveCaveats, egress uint64) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	na, ok := d.nodes[node]
+	if !ok {
+		na = &nodeActivity{
+			spaces:  make(map[did.DID]*activity),
+			ranges:  make(map[rangeKey]*activity),
+			batches: make(map[string]struct{}),
+		}
+		d.nodes[node] = na
+	}
+	na.add(cause, egress)
+	na.batches[cause.String()] = struct{}{}
+
+	sa, ok := na.spaces[space]
+	if !ok {
+		sa = &activity{}
+		na.spaces[space] = sa
+	}
+	sa.add(cause, egress)
+
+	rk := rangeKey{space: space, digest: string(nb.Blob.Digest), start: nb.Range.Start, end: nb.Range.End}
+	ra, ok := na.ranges[rk]
+	if !ok {
+		ra = &activity{}
+		na.ranges[rk] = ra
+	}
+	ra.add(cause, egress)
+}
+
+// Run evaluates the activity observed since the last run, stores any flags raised and returns them.
+func (d *Detector) Run(ctx context.Context) ([]anomalies.Flag, error) {
+	d.mu.Lock()
+	nodes := d.nodes
+	d.nodes = make(map[did.DID]*nodeActivity)
+	d.mu.Unlock()
+
+	now := time.Now().UTC()
+
+	var flags []anomalies.Flag
+	var errs []error
+	for node, na := range nodes {
+		nodeFlags, err := d.evaluate(ctx, node, na, now)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("evaluating node %s: %w", node, err))
+		}
+
+		for _, flag := range nodeFlags {
+			if err := d.anomalyTable.Add(ctx, flag); err != nil {
+				errs = append(errs, fmt.Errorf("storing anomaly flag %s: %w", flag.ID, err))
+				continue
+			}
+
+			log.Warnw("Egress anomaly detected", "kind", flag.Kind, "severity", flag.Severity, "node", flag.Node, "message", flag.Message)
+
+			attrs := attribute.NewSet(
+				attribute.String("kind", string(flag.Kind)),
+				attribute.String("severity", string(flag.Severity)),
+			)
+			metrics.AnomaliesDetected.Add(ctx, 1, metric.WithAttributeSet(attrs))
+
+			flags = append(flags, flag)
+		}
+	}
+
+	return flags, errors.Join(errs...)
+}
+
+func (d *Detector) evaluate(ctx context.Context, node did.DID, na *nodeActivity, now time.Time) ([]anomalies.Flag, error) {
+	var flags []anomalies.Flag
+	day := now.Format("2006-01-02")
+
+	spike, err := d.detectSpike(ctx, node, na, now)
+	if err != nil {
+		return nil, err
+	}
+	if spike != nil {
+		flags = append(flags, *spike)
+	}
+
+	// The remaining checks only make sense when the node served a significant amount of data in this cycle
+	if na.egress < d.cfg.MinNodeEgress {
+		return flags, nil
+	}
+
+	for space, sa := range na.spaces {
+		share := float64(sa.egress) / float64(na.egress)
+		if share < d.cfg.MaxSpaceShare {
+			continue
+		}
+
+		flags = append(flags, anomalies.Flag{
+			ID:       fmt.Sprintf("%s:%s:%s:%s", anomalies.KindSpaceEgressShare, node, space, day),
+			Kind:     anomalies.KindSpaceEgressShare,
+			Severity: anomalies.SeverityWarning,
+			Node:     node,
+			Space:    space,
+			Message: fmt.Sprintf("space received %.1f%% of the %d bytes served by the node in this cycle",
+				share*100, na.egress),
+			Evidence:   sa.causes,
+			DetectedAt: now,
+		})
+	}
+
+	for rk, ra := range na.ranges {
+		if ra.count < d.cfg.MaxIdenticalRanges {
+			continue
+		}
+
+		severity := anomalies.SeverityWarning
+		if ra.count >= 10*d.cfg.MaxIdenticalRanges {
+			severity = anomalies.SeverityCritical
+		}
+
+		flags = append(flags, anomalies.Flag{
+			ID:       fmt.Sprintf("%s:%s:%s:%x:%d-%d:%s", anomalies.KindIdenticalRangeBurst, node, rk.space, rk.digest, rk.start, rk.end, day),
+			Kind:     anomalies.KindIdenticalRangeBurst,
+			Severity: severity,
+			Node:     node,
+			Space:    rk.space,
+			Message: fmt.Sprintf("byte range %d-%d of the same blob was retrieved %d times in this cycle",
+				rk.start, rk.end, ra.count),
+			Evidence:   ra.causes,
+			DetectedAt: now,
+		})
+	}
+
+	return flags, nil
+}
+
+// detectSpike compares the node's egress so far today, including the egress observed in this cycle which is not
+// committed yet, with its daily egress over the trailing baseline period.
+func (d *Detector) detectSpike(ctx context.Context, node did.DID, na *nodeActivity, now time.Time) (*anomalies.Flag, error) {
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	since := today.AddDate(0, 0, -d.cfg.BaselineDays)
+
+	current := na.egress
+	daily := make([]uint64, d.cfg.BaselineDays)
+	firstDay := d.cfg.BaselineDays
+	for record, err := range consolidated.GetCountedByNode(ctx, d.consolidatedTable, node, since) {
+		if err != nil {
+			return nil, fmt.Errorf("fetching node egress history: %w", err)
+		}
+
+		// batches consolidated by external invocations are committed right away, their egress was observed already
+		if record.Cause != nil {
+			if _, ok := na.batches[record.Cause.String()]; ok {
+				continue
+			}
+		}
+
+		// approved batches count on the day they were approved
+		countedAt := record.CountedAt()
+		if !countedAt.Before(today) {
+			current += record.TotalEgress
+			continue
+		}
+
+		idx := int(countedAt.Sub(since).Hours() / 24)
+		if idx < 0 || idx >= len(daily) {
+			continue
+		}
+		daily[idx] += record.TotalEgress
+		firstDay = min(firstDay, idx)
+	}
+
+	// Only consider days since the node started reporting egress
+	baseline := daily[min(firstDay, len(daily)):]
+	if len(baseline) < d.cfg.MinBaselineDays || current < d.cfg.MinNodeEgress {
+		return nil, nil
+	}
+
+	deviations, severe := spikeDeviations(baseline, current, d.cfg.SpikeStdDevs)
+	if deviations < d.cfg.SpikeStdDevs {
+		return nil, nil
+	}
+
+	severity := anomalies.SeverityWarning
+	if severe {
+		severity = anomalies.SeverityCritical
+	}
+
+	mean, _ := meanStdDev(baseline)
+
+	return &anomalies.Flag{
+		ID:       fmt.Sprintf("%s:%s:%s", anomalies.KindNodeEgressSpike, node, today.Format("2006-01-02")),
+		Kind:     anomalies.KindNodeEgressSpike,
+		Severity: severity,
+		Node:     node,
+		Message: fmt.Sprintf("node egress today (%d bytes) is %.1f standard deviations above its %d day baseline (%.0f bytes/day)",
+			current, deviations, len(baseline), mean),
+		Evidence:   na.causes,
+		DetectedAt: now,
+	}, nil
+}
+
+// spikeDeviations returns how many standard deviations current is above the mean of baseline, and whether it is
+// at least twice the given threshold. The standard deviation is floored at 10% of the mean so that perfectly
+// regular nodes are not flagged for small variations.
+func spikeDeviations(baseline []uint64, current uint64, threshold float64) (float64, bool) {
+	mean, stddev := meanStdDev(baseline)
+	stddev = max(stddev, mean/10, 1)
+
+	deviations := (float64(current) - mean) / stddev
+	return deviations, deviations >= 2*threshold
+}
+
+func meanStdDev(values []uint64) (float64, float64) {
+	if len(values) == 0 {
+		return 0, 0
+	}
+
+	var sum float64
+	for _, v := range values {
+		sum += float64(v)
+	}
+	mean := sum / float64(len(values))
+
+	var variance float64
+	for _, v := range values {
+		variance += (float64(v) - mean) * (float64(v) - mean)
+	}
+	variance /= float64(len(values))
+
+	return mean, math.Sqrt(variance)
+}
@@ -0,0 +1,270 @@
+package anomaly
+
+import (
+	"context"
+	"iter"
+	"testing"
+	"time"
+
+	"github.com/storacha/go-libstoracha/capabilities/space/content"
+	capegress "github.com/storacha/go-libstoracha/capabilities/space/egress"
+	"github.com/storacha/go-libstoracha/testutil"
+	"github.com/storacha/go-ucanto/did"
+	"github.com/storacha/go-ucanto/ucan"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/storacha/etracker/internal/db/anomalies"
+	"github.com/storacha/etracker/internal/db/consolidated"
+)
+
+type mockConsolidatedTable struct {
+	records []consolidated.ConsolidatedRecord
+}
+
+func (m *mockConsolidatedTable) Add(ctx context.Context, cause ucan.Link, trackCause ucan.Link, batch ucan.Link, node did.DID, totalEgress uint64, rcpt capegress.ConsolidateReceipt, spaceEgress map[did.DID]uint64) error {
+	return nil
+}
+
+func (m *mockConsolidatedTable) Get(ctx context.Context, cause ucan.Link) (*consolidated.ConsolidatedRecord, error) {
+	return nil, consolidated.ErrNotFound
+}
+
+func (m *mockConsolidatedTable) GetByTrackCause(ctx context.Context, trackCause ucan.Link) (*consolidated.ConsolidatedRecord, error) {
+	return nil, consolidated.ErrNotFound
+}
+
+func (m *mockConsolidatedTable) GetByBatch(ctx context.Context, batch ucan.Link) (*consolidated.ConsolidatedRecord, error) {
+	return nil, consolidated.ErrNotFound
+}
+
+func (m *mockConsolidatedTable) GetStatsByNode(ctx context.Context, node did.DID, since time.Time) iter.Seq2[consolidated.ConsolidatedRecord, error] {
+	return func(yield func(consolidated.ConsolidatedRecord, error) bool) {
+		for _, r := range m.records {
+			if r.Node == node && !r.ProcessedAt.Before(since) {
+				if !yield(r, nil) {
+					return
+				}
+			}
+		}
+	}
+}
+
+func (m *mockConsolidatedTable) GetReviewedByNode(ctx context.Context, node did.DID, since time.Time) iter.Seq2[consolidated.ConsolidatedRecord, error] {
+	return func(yield func(consolidated.ConsolidatedRecord, error) bool) {}
+}
+
+func (m *mockConsolidatedTable) GetFirstByNode(ctx context.Context, node did.DID) (*consolidated.ConsolidatedRecord, error) {
+	return nil, consolidated.ErrNotFound
+}
+
+func (m *mockConsolidatedTable) Hold(ctx context.Context, cause ucan.Link, trackCause ucan.Link, batch ucan.Link, node did.DID, totalEgress uint64, rcpt capegress.ConsolidateReceipt, reasons []string, spaceEgress map[did.DID]uint64) error {
+	return nil
+}
+
+func (m *mockConsolidatedTable) ListHeld(ctx context.Context, limit int, cursor *string) (*consolidated.ListHeldResult, error) {
+	return &consolidated.ListHeldResult{}, nil
+}
+
+func (m *mockConsolidatedTable) Approve(ctx context.Context, cause ucan.Link, approvedAt time.Time) error {
+	return consolidated.ErrNotHeld
+}
+
+func (m *mockConsolidatedTable) Reject(ctx context.Context, cause ucan.Link, reason string, rcpt capegress.ConsolidateReceipt) error {
+	return consolidated.ErrNotHeld
+}
+
+var _ consolidated.ConsolidatedTable = (*mockConsolidatedTable)(nil)
+
+func retrieval(t *testing.T, start, end uint64) content.RetrieveCaveats {
+	return content.RetrieveCaveats{
+		Blob:  content.BlobDigest{Digest: testutil.RandomMultihash(t)},
+		Range: content.Range{Start: start, End: end},
+	}
+}
+
+func TestDetector(t *testing.T) {
+	now := time.Date(2025, 6, 20, 12, 0, 0, 0, time.UTC)
+	today := time.Date(2025, 6, 20, 0, 0, 0, 0, time.UTC)
+
+	cfg := Config{
+		BaselineDays:       14,
+		MinBaselineDays:    7,
+		SpikeStdDevs:       4,
+		MinNodeEgress:      1000,
+		MaxSpaceShare:      0.9,
+		MaxIdenticalRanges: 5,
+	}
+
+	// history returns one consolidated record per day for the days before today
+	history := func(node did.DID, days int, egress uint64) []consolidated.ConsolidatedRecord {
+		records := make([]consolidated.ConsolidatedRecord, 0, days)
+		for i := 1; i <= days; i++ {
+			records = append(records, consolidated.ConsolidatedRecord{
+				Node:        node,
+				TotalEgress: egress,
+				ProcessedAt: today.AddDate(0, 0, -i).Add(time.Hour),
+			})
+		}
+		return records
+	}
+
+	t.Run("flags a node egress spike above its baseline", func(t *testing.T) {
+		node := testutil.RandomDID(t)
+		cause := testutil.RandomCID(t)
+
+		records := history(node, 14, 10_000)
+		// the batch was committed already by an external invocation, its egress is only counted once
+		records = append(records, consolidated.ConsolidatedRecord{Cause: cause, Node: node, TotalEgress: 100_000, ProcessedAt: today.Add(time.Hour)})
+
+		d := New(cfg, nil, &mockConsolidatedTable{records: records})
+		for range 10 {
+			d.Observe(node, cause, testutil.RandomDID(t), retrieval(t, 0, 9_999), 10_000)
+		}
+
+		flags, err := d.evaluate(context.Background(), node, d.nodes[node], now)
+		require.NoError(t, err)
+		require.Len(t, flags, 1)
+		assert.Equal(t, anomalies.KindNodeEgressSpike, flags[0].Kind)
+		assert.Equal(t, anomalies.SeverityCritical, flags[0].Severity)
+		assert.Equal(t, node, flags[0].Node)
+		assert.Equal(t, []ucan.Link{cause}, flags[0].Evidence)
+	})
+
+	t.Run("counts the egress of the cycle that is not committed yet", func(t *testing.T) {
+		node := testutil.RandomDID(t)
+		cause := testutil.RandomCID(t)
+
+		records := history(node, 14, 10_000)
+		records = append(records, consolidated.ConsolidatedRecord{Cause: testutil.RandomCID(t), Node: node, TotalEgress: 5_000, ProcessedAt: today.Add(time.Hour)})
+
+		d := New(cfg, nil, &mockConsolidatedTable{records: records})
+		for range 10 {
+			d.Observe(node, cause, testutil.RandomDID(t), retrieval(t, 0, 9_999), 10_000)
+		}
+
+		flags, err := d.evaluate(context.Background(), node, d.nodes[node], now)
+		require.NoError(t, err)
+		require.Len(t, flags, 1)
+		assert.Equal(t, anomalies.KindNodeEgressSpike, flags[0].Kind)
+		assert.Contains(t, flags[0].Message, "(105000 bytes)")
+		assert.Equal(t, []ucan.Link{cause}, flags[0].Evidence)
+	})
+
+	t.Run("does not flag a node within its baseline", func(t *testing.T) {
+		node := testutil.RandomDID(t)
+		cause := testutil.RandomCID(t)
+
+		records := history(node, 14, 10_000)
+		records = append(records, consolidated.ConsolidatedRecord{Cause: cause, Node: node, TotalEgress: 12_000, ProcessedAt: today.Add(time.Hour)})
+
+		d := New(cfg, nil, &mockConsolidatedTable{records: records})
+		for range 12 {
+			d.Observe(node, cause, testutil.RandomDID(t), retrieval(t, 0, 999), 1_000)
+		}
+
+		flags, err := d.evaluate(context.Background(), node, d.nodes[node], now)
+		require.NoError(t, err)
+		assert.Empty(t, flags)
+	})
+
+	t.Run("does not flag spikes for nodes without enough history", func(t *testing.T) {
+		node := testutil.RandomDID(t)
+		cause := testutil.RandomCID(t)
+
+		records := history(node, 3, 10_000)
+		records = append(records, consolidated.ConsolidatedRecord{Cause: cause, Node: node, TotalEgress: 1_000_000, ProcessedAt: today.Add(time.Hour)})
+
+		d := New(cfg, nil, &mockConsolidatedTable{records: records})
+		for range 100 {
+			d.Observe(node, cause, testutil.RandomDID(t), retrieval(t, 0, 9_999), 10_000)
+		}
+
+		flags, err := d.evaluate(context.Background(), node, d.nodes[node], now)
+		require.NoError(t, err)
+		assert.Empty(t, flags)
+	})
+
+	t.Run("flags a space receiving most of a node's egress", func(t *testing.T) {
+		node := testutil.RandomDID(t)
+		space := testutil.RandomDID(t)
+		cause := testutil.RandomCID(t)
+
+		d := New(cfg, nil, &mockConsolidatedTable{})
+		for range 19 {
+			d.Observe(node, cause, space, retrieval(t, 0, 999), 1_000)
+		}
+		d.Observe(node, cause, testutil.RandomDID(t), retrieval(t, 0, 999), 1_000)
+
+		flags, err := d.evaluate(context.Background(), node, d.nodes[node], now)
+		require.NoError(t, err)
+		require.Len(t, flags, 1)
+		assert.Equal(t, anomalies.KindSpaceEgressShare, flags[0].Kind)
+		assert.Equal(t, space, flags[0].Space)
+	})
+
+	t.Run("flags bursts of identical ranges", func(t *testing.T) {
+		node := testutil.RandomDID(t)
+		causes := []ucan.Link{testutil.RandomCID(t), testutil.RandomCID(t)}
+		nb := retrieval(t, 0, 99)
+
+		d := New(cfg, nil, &mockConsolidatedTable{})
+		for i := range 6 {
+			d.Observe(node, causes[i%2], testutil.RandomDID(t), nb, 100)
+		}
+		// spread the rest of the egress so no single space dominates
+		for range 20 {
+			d.Observe(node, causes[0], testutil.RandomDID(t), retrieval(t, 0, 99), 100)
+		}
+
+		flags, err := d.evaluate(context.Background(), node, d.nodes[node], now)
+		require.NoError(t, err)
+		assert.Empty(t, flags, "identical ranges in different spaces are not a burst")
+
+		space := testutil.RandomDID(t)
+		d = New(cfg, nil, &mockConsolidatedTable{})
+		for i := range 6 {
+			d.Observe(node, causes[i%2], space, nb, 100)
+		}
+		for range 60 {
+			d.Observe(node, causes[0], testutil.RandomDID(t), retrieval(t, 0, 99), 100)
+		}
+
+		flags, err = d.evaluate(context.Background(), node, d.nodes[node], now)
+		require.NoError(t, err)
+		require.Len(t, flags, 1)
+		assert.Equal(t, anomalies.KindIdenticalRangeBurst, flags[0].Kind)
+		assert.Equal(t, anomalies.SeverityWarning, flags[0].Severity)
+		assert.ElementsMatch(t, causes, flags[0].Evidence)
+	})
+
+	t.Run("ignores nodes serving little data", func(t *testing.T) {
+		node := testutil.RandomDID(t)
+		space := testutil.RandomDID(t)
+		nb := retrieval(t, 0, 9)
+
+		d := New(cfg, nil, &mockConsolidatedTable{})
+		for range 50 {
+			d.Observe(node, testutil.RandomCID(t), space, nb, 10)
+		}
+
+		flags, err := d.evaluate(context.Background(), node, d.nodes[node], now)
+		require.NoError(t, err)
+		assert.Empty(t, flags)
+	})
+}
+
+func TestSpikeDeviations(t *testing.T) {
+	deviations, severe := spikeDeviations([]uint64{100, 100, 100, 100}, 100, 4)
+	assert.Zero(t, deviations)
+	assert.False(t, severe)
+
+	// standard deviation is floored at 10% of the mean
+	deviations, severe = spikeDeviations([]uint64{100, 100, 100, 100}, 150, 4)
+	assert.InDelta(t, 5, deviations, 0.001)
+	assert.False(t, severe)
+
+	deviations, severe = spikeDeviations([]uint64{90, 110, 90, 110}, 300, 4)
+	assert.InDelta(t, 20, deviations, 0.001)
+	assert.True(t, severe)
+}
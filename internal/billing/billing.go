@@ -0,0 +1,308 @@
+package billing
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	logging "github.com/ipfs/go-log/v2"
+	"github.com/storacha/go-ucanto/did"
+
+	"github.com/storacha/etracker/internal/db/consumer"
+	"github.com/storacha/etracker/internal/db/customer"
+	"github.com/storacha/etracker/internal/db/spacestats"
+	"github.com/storacha/etracker/internal/db/statements"
+	"github.com/storacha/etracker/internal/pricing"
+)
+
+var log = logging.Logger("billing")
+
+const customersPageSize = 100
+
+// Biller closes the calendar months of every account into immutable statements, billing the egress of the
+// spaces the account owns at closing time. Egress recorded for a month after it was closed is added to its
+// statement as adjustments, for the spaces the account owned at closing time only.
+type Biller struct {
+	customerTable    customer.CustomerTable
+	consumerTable    consumer.ConsumerTable
+	spaceStatsTable  spacestats.SpaceStatsTable
+	statementTable   statements.StatementTable
+	pricer           *pricing.Engine
+	interval         time.Duration
+	closeDelay       time.Duration
+	adjustmentMonths int
+	stopCh           chan struct{}
+}
+
+// New creates a biller that runs every interval. Months are closed once closeDelay has passed since they
+// ended, and the statements of the adjustmentMonths months before the last closed one keep being adjusted.
+func New(
+	customerTable customer.CustomerTable,
+	consumerTable consumer.ConsumerTable,
+	spaceStatsTable spacestats.SpaceStatsTable,
+	statementTable statements.StatementTable,
+	pricer *pricing.Engine,
+	interval time.Duration,
+	closeDelay time.Duration,
+	adjustmentMonths int,
+) *Biller {
+	return &Biller{
+		customerTable:    customerTable,
+		consumerTable:    consumerTable,
+		spaceStatsTable:  spaceStatsTable,
+		statementTable:   statementTable,
+		pricer:           pricer,
+		interval:         interval,
+		closeDelay:       closeDelay,
+		adjustmentMonths: adjustmentMonths,
+		stopCh:           make(chan struct{}),
+	}
+}
+
+// Start bills the accounts right away and then on every interval
+func (b *Biller) Start(ctx context.Context) {
+	ticker := time.NewTicker(b.interval)
+	defer ticker.Stop()
+
+	log.Infof("Biller started with interval: %v", b.interval)
+
+	if err := b.Run(ctx); err != nil {
+		log.Errorf("Billing error: %v", err)
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			log.Info("Biller stopping due to context cancellation")
+			return
+		case <-b.stopCh:
+			log.Info("Biller stopping")
+			return
+		case <-ticker.C:
+			if err := b.Run(ctx); err != nil {
+				log.Errorf("Billing error: %v", err)
+			}
+		}
+	}
+}
+
+func (b *Biller) Stop() {
+	close(b.stopCh)
+}
+
+// Run closes the last closable month of every account and adjusts their recent statements. Accounts failing
+// to be billed are skipped and reported in the returned error.
+func (b *Biller) Run(ctx context.Context) error {
+	month := lastClosableMonth(time.Now().UTC(), b.closeDelay)
+
+	log.Infof("Starting billing of %s", month.Format("2006-01"))
+
+	var errs []error
+	var cursor *string
+	for {
+		result, err := b.customerTable.List(ctx, customersPageSize, cursor)
+		if err != nil {
+			return errors.Join(append(errs, fmt.Errorf("listing customers: %w", err))...)
+		}
+
+		for _, account := range result.Customers {
+			if err := b.BillAccount(ctx, account, month); err != nil {
+				errs = append(errs, fmt.Errorf("billing account %s: %w", account, err))
+			}
+		}
+
+		if result.Cursor == nil {
+			break
+		}
+		cursor = result.Cursor
+	}
+
+	log.Infof("Billing finished with %d failed accounts", len(errs))
+
+	return errors.Join(errs...)
+}
+
+// BillAccount closes the given month for the account if it is not closed yet, and adjusts the statements of
+// the months before it.
+func (b *Biller) BillAccount(ctx context.Context, account did.DID, month time.Time) error {
+	closed := true
+	if err := b.CloseMonth(ctx, account, month); err != nil {
+		if !errors.Is(err, statements.ErrAlreadyClosed) {
+			return fmt.Errorf("closing %s: %w", month.Format("2006-01"), err)
+		}
+		closed = false
+	}
+
+	for i := range b.adjustmentMonths + 1 {
+		// a statement closed in this run is up to date
+		if i == 0 && closed {
+			continue
+		}
+
+		m := month.AddDate(0, -i, 0)
+		if err := b.AdjustStatement(ctx, account, m); err != nil {
+			return fmt.Errorf("adjusting %s: %w", m.Format("2006-01"), err)
+		}
+	}
+
+	return nil
+}
+
+// CloseMonth stores the statement of the account for the month starting on the given day, billing the egress
+// of the spaces it currently owns with the plan each space is priced with. It returns statements.ErrAlreadyClosed if the month was closed before.
+func (b *Biller) CloseMonth(ctx context.Context, account did.DID, month time.Time) error {
+	_, err := b.statementTable.Get(ctx, account, month)
+	if err == nil {
+		return statements.ErrAlreadyClosed
+	}
+	if !errors.Is(err, statements.ErrNotFound) {
+		return fmt.Errorf("getting statement: %w", err)
+	}
+
+	spaces, err := b.consumerTable.ListByCustomer(ctx, account)
+	if err != nil {
+		return fmt.Errorf("listing spaces: %w", err)
+	}
+
+	egress, err := b.monthlyEgress(ctx, spaces, month)
+	if err != nil {
+		return err
+	}
+
+	lines := make([]statements.Line, 0, len(egress))
+	for space, e := range egress {
+		if e == 0 {
+			continue
+		}
+		plan, err := b.pricer.PlanForSpace(ctx, b.consumerTable, space)
+		if err != nil {
+			return fmt.Errorf("resolving plan of space %s: %w", space, err)
+		}
+		lines = append(lines, statements.Line{Space: space, Plan: plan.Name, Egress: e})
+	}
+	slices.SortFunc(lines, func(a, b statements.Line) int {
+		return strings.Compare(a.Space.String(), b.Space.String())
+	})
+
+	// the spaces owned at closing time are the ones late egress of the month is adjusted for, even when a
+	// space moves to another account afterwards
+	owned := slices.Clone(spaces)
+	slices.SortFunc(owned, func(a, b did.DID) int {
+		return strings.Compare(a.String(), b.String())
+	})
+
+	statement := statements.Statement{
+		Account:  account,
+		Month:    month,
+		ClosedAt: time.Now().UTC(),
+		Plans:    b.pricer.Plans(),
+		Lines:    lines,
+		Spaces:   owned,
+	}
+	if err := b.statementTable.Put(ctx, statement); err != nil {
+		return err
+	}
+
+	log.Infow("Closed statement", "account", account, "month", month.Format("2006-01"), "egress", statement.Total())
+
+	return nil
+}
+
+// AdjustStatement adds an adjustment to the statement of the account for the month starting on the given day
+// for every space whose egress differs from what was billed for it. The spaces billed in the statement and the
+// ones the account owned when the month was closed are checked, spaces it acquired afterwards are billed by the
+// statement of the account that owned them then. Adjustments are priced with the plan the space was billed
+// with, or the plan it is priced with now if it was not billed. Months that were not closed are ignored.
+func (b *Biller) AdjustStatement(ctx context.Context, account did.DID, month time.Time) error {
+	statement, err := b.statementTable.Get(ctx, account, month)
+	if err != nil {
+		if errors.Is(err, statements.ErrNotFound) {
+			return nil
+		}
+		return fmt.Errorf("getting statement: %w", err)
+	}
+
+	spaces := slices.Clone(statement.Spaces)
+	billed := statement.Billed()
+	for space := range billed {
+		if !slices.Contains(spaces, space) {
+			spaces = append(spaces, space)
+		}
+	}
+
+	egress, err := b.monthlyEgress(ctx, spaces, month)
+	if err != nil {
+		return err
+	}
+
+	plans := statement.BilledPlans()
+	now := time.Now().UTC()
+	var adjustments []statements.Adjustment
+	for _, space := range spaces {
+		diff := int64(egress[space]) - billed[space]
+		if diff == 0 {
+			continue
+		}
+
+		plan, ok := plans[space]
+		if !ok {
+			plan, err = b.planOf(ctx, *statement, space)
+			if err != nil {
+				return err
+			}
+		}
+		adjustments = append(adjustments, statements.Adjustment{Space: space, Plan: plan, Egress: diff, RecordedAt: now})
+	}
+	if len(adjustments) == 0 {
+		return nil
+	}
+	slices.SortFunc(adjustments, func(a, b statements.Adjustment) int {
+		return strings.Compare(a.Space.String(), b.Space.String())
+	})
+
+	if err := b.statementTable.AddAdjustments(ctx, account, month, adjustments); err != nil {
+		return err
+	}
+
+	log.Infow("Adjusted statement", "account", account, "month", month.Format("2006-01"), "adjustments", len(adjustments))
+
+	return nil
+}
+
+// planOf returns the plan a space that was not billed in the statement is priced with. The plans of a statement
+// are fixed when it is closed, spaces whose plan was configured afterwards are priced with its default plan.
+func (b *Biller) planOf(ctx context.Context, statement statements.Statement, space did.DID) (string, error) {
+	plan, err := b.pricer.PlanForSpace(ctx, b.consumerTable, space)
+	if err != nil {
+		return "", fmt.Errorf("resolving plan of space %s: %w", space, err)
+	}
+
+	if slices.ContainsFunc(statement.Plans, func(p pricing.Plan) bool { return p.Name == plan.Name }) || len(statement.Plans) == 0 {
+		return plan.Name, nil
+	}
+	return statement.Plans[0].Name, nil
+}
+
+// monthlyEgress returns the egress of each space in the month starting on the given day
+func (b *Biller) monthlyEgress(ctx context.Context, spaces []did.DID, month time.Time) (map[did.DID]uint64, error) {
+	egress := make(map[did.DID]uint64, len(spaces))
+	for _, space := range spaces {
+		monthlyStats, err := b.spaceStatsTable.GetMonthlyStats(ctx, space, month, month)
+		if err != nil {
+			return nil, fmt.Errorf("getting monthly stats of space %s: %w", space, err)
+		}
+		for _, stat := range monthlyStats {
+			egress[space] += stat.Egress
+		}
+	}
+	return egress, nil
+}
+
+// lastClosableMonth returns the first day of the latest month that ended at least closeDelay ago
+func lastClosableMonth(now time.Time, closeDelay time.Duration) time.Time {
+	t := now.Add(-closeDelay)
+	return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.UTC).AddDate(0, -1, 0)
+}
@@ -0,0 +1,343 @@
+package billing
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/storacha/go-libstoracha/testutil"
+	"github.com/storacha/go-ucanto/did"
+	"github.com/stretchr/testify/require"
+
+	"github.com/storacha/etracker/internal/db/consumer"
+	"github.com/storacha/etracker/internal/db/customer"
+	"github.com/storacha/etracker/internal/db/spacestats"
+	"github.com/storacha/etracker/internal/db/statements"
+	"github.com/storacha/etracker/internal/pricing"
+)
+
+type mockCustomerTable struct {
+	customers []did.DID
+}
+
+func (m *mockCustomerTable) List(ctx context.Context, limit int, cursor *string) (*customer.ListResult, error) {
+	return &customer.ListResult{Customers: m.customers}, nil
+}
+
+func (m *mockCustomerTable) Has(ctx context.Context, customerDID did.DID) (bool, error) {
+	return false, fmt.Errorf("not implemented")
+}
+
+type mockConsumerTable struct {
+	spaces    map[did.DID][]did.DID
+	consumers map[string]consumer.Consumer
+}
+
+func (m *mockConsumerTable) Get(ctx context.Context, consumerID string) (consumer.Consumer, error) {
+	c, ok := m.consumers[consumerID]
+	if !ok {
+		return consumer.Consumer{}, consumer.ErrNotFound
+	}
+	return c, nil
+}
+
+func (m *mockConsumerTable) ListByCustomer(ctx context.Context, customerID did.DID) ([]did.DID, error) {
+	return m.spaces[customerID], nil
+}
+
+type mockSpaceStatsTable struct {
+	egress map[did.DID]map[time.Time]uint64
+}
+
+func (m *mockSpaceStatsTable) Record(ctx context.Context, space did.DID, egress uint64, at time.Time, id string) error {
+	return fmt.Errorf("not implemented")
+}
+
+func (m *mockSpaceStatsTable) GetDailyStats(ctx context.Context, space did.DID, from time.Time, to time.Time) ([]spacestats.DailyStats, error) {
+	return nil, fmt.Errorf("not implemented")
+}
+
+func (m *mockSpaceStatsTable) GetMonthlyStats(ctx context.Context, space did.DID, from time.Time, to time.Time) ([]spacestats.MonthlyStats, error) {
+	var stats []spacestats.MonthlyStats
+	for month, egress := range m.egress[space] {
+		if !month.Before(from) && !month.After(to) {
+			stats = append(stats, spacestats.MonthlyStats{Month: month, Egress: egress})
+		}
+	}
+	return stats, nil
+}
+
+type mockStatementTable struct {
+	statements map[string]statements.Statement
+}
+
+func (m *mockStatementTable) Put(ctx context.Context, statement statements.Statement) error {
+	key := statement.Account.String() + statement.Month.Format("2006-01")
+	if _, ok := m.statements[key]; ok {
+		return statements.ErrAlreadyClosed
+	}
+	m.statements[key] = statement
+	return nil
+}
+
+func (m *mockStatementTable) Get(ctx context.Context, account did.DID, month time.Time) (*statements.Statement, error) {
+	statement, ok := m.statements[account.String()+month.Format("2006-01")]
+	if !ok {
+		return nil, statements.ErrNotFound
+	}
+	return &statement, nil
+}
+
+func (m *mockStatementTable) AddAdjustments(ctx context.Context, account did.DID, month time.Time, adjustments []statements.Adjustment) error {
+	key := account.String() + month.Format("2006-01")
+	statement, ok := m.statements[key]
+	if !ok {
+		return statements.ErrNotFound
+	}
+	statement.Adjustments = append(statement.Adjustments, adjustments...)
+	m.statements[key] = statement
+	return nil
+}
+
+func (m *mockStatementTable) ListByAccount(ctx context.Context, account did.DID) ([]statements.Statement, error) {
+	return nil, fmt.Errorf("not implemented")
+}
+
+func (m *mockStatementTable) List(ctx context.Context, limit int, cursor *string) (*statements.ListResult, error) {
+	return nil, fmt.Errorf("not implemented")
+}
+
+func TestBillAccount(t *testing.T) {
+	march := time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC)
+	february := march.AddDate(0, -1, 0)
+
+	account := testutil.RandomDID(t)
+	space1 := testutil.RandomDID(t)
+	space2 := testutil.RandomDID(t)
+	space3 := testutil.RandomDID(t)
+
+	consumerTable := &mockConsumerTable{spaces: map[did.DID][]did.DID{account: {space1, space2}}}
+	spaceStatsTable := &mockSpaceStatsTable{egress: map[did.DID]map[time.Time]uint64{
+		space1: {march: 1 << 40, february: 100},
+		space2: {march: 1 << 39},
+	}}
+	statementTable := &mockStatementTable{statements: make(map[string]statements.Statement)}
+
+	b := New(&mockCustomerTable{customers: []did.DID{account}}, consumerTable, spaceStatsTable, statementTable, pricing.NewFlat(10), time.Hour, 0, 1)
+
+	require.NoError(t, b.BillAccount(context.Background(), account, march))
+
+	statement, err := statementTable.Get(context.Background(), account, march)
+	require.NoError(t, err)
+	require.ElementsMatch(t, []statements.Line{{Space: space1, Plan: "default", Egress: 1 << 40}, {Space: space2, Plan: "default", Egress: 1 << 39}}, statement.Lines)
+	require.Empty(t, statement.Adjustments)
+	require.Equal(t, 15.0, statement.Amount())
+
+	// months before the first statement are not backfilled
+	_, err = statementTable.Get(context.Background(), account, february)
+	require.ErrorIs(t, err, statements.ErrNotFound)
+
+	t.Run("late egress is added as adjustments", func(t *testing.T) {
+		// space2 moved to another account, space3 joined with egress of its own and space1 got late egress.
+		// space3 was not owned by the account when the month was closed, its egress is not billed here.
+		consumerTable.spaces[account] = []did.DID{space1, space3}
+		spaceStatsTable.egress[space1][march] += 1 << 30
+		spaceStatsTable.egress[space3] = map[time.Time]uint64{march: 1 << 20}
+
+		b.pricer = pricing.NewFlat(20)
+		require.NoError(t, b.BillAccount(context.Background(), account, march))
+
+		statement, err := statementTable.Get(context.Background(), account, march)
+		require.NoError(t, err)
+		require.Len(t, statement.Lines, 2)
+		require.Equal(t, []pricing.Plan{pricing.FlatPlan("default", 10)}, statement.Plans)
+		require.Equal(t, []statements.Adjustment{
+			{Space: space1, Plan: "default", Egress: 1 << 30, RecordedAt: statement.Adjustments[0].RecordedAt},
+		}, statement.Adjustments)
+		require.Equal(t, int64(1<<40+1<<39+1<<30), statement.AdjustedTotal())
+
+		// the statement is only adjusted once for the same late egress
+		require.NoError(t, b.BillAccount(context.Background(), account, march))
+		statement, err = statementTable.Get(context.Background(), account, march)
+		require.NoError(t, err)
+		require.Len(t, statement.Adjustments, 1)
+	})
+
+	t.Run("removed egress is adjusted negatively", func(t *testing.T) {
+		spaceStatsTable.egress[space1][march] -= 1 << 30
+		require.NoError(t, b.AdjustStatement(context.Background(), account, march))
+
+		statement, err := statementTable.Get(context.Background(), account, march)
+		require.NoError(t, err)
+		require.Len(t, statement.Adjustments, 2)
+		require.Equal(t, int64(-(1 << 30)), statement.Adjustments[1].Egress)
+	})
+}
+
+func TestBillAccountMovedSpaces(t *testing.T) {
+	march := time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC)
+
+	seller := testutil.RandomDID(t)
+	buyer := testutil.RandomDID(t)
+	billedSpace := testutil.RandomDID(t)
+	idleSpace := testutil.RandomDID(t)
+
+	// idleSpace had no egress yet when the month was closed
+	consumerTable := &mockConsumerTable{spaces: map[did.DID][]did.DID{seller: {billedSpace, idleSpace}}}
+	spaceStatsTable := &mockSpaceStatsTable{egress: map[did.DID]map[time.Time]uint64{
+		billedSpace: {march: 1 << 30},
+	}}
+	statementTable := &mockStatementTable{statements: make(map[string]statements.Statement)}
+
+	b := New(&mockCustomerTable{customers: []did.DID{seller, buyer}}, consumerTable, spaceStatsTable, statementTable, pricing.NewFlat(10), time.Hour, 0, 1)
+	require.NoError(t, b.BillAccount(context.Background(), seller, march))
+	require.NoError(t, b.BillAccount(context.Background(), buyer, march))
+
+	// both spaces move to the buyer after the month was closed and get late egress for it
+	consumerTable.spaces = map[did.DID][]did.DID{buyer: {billedSpace, idleSpace}}
+	spaceStatsTable.egress[billedSpace][march] += 1 << 20
+	spaceStatsTable.egress[idleSpace] = map[time.Time]uint64{march: 1 << 10}
+
+	require.NoError(t, b.BillAccount(context.Background(), seller, march))
+	require.NoError(t, b.BillAccount(context.Background(), buyer, march))
+
+	statement, err := statementTable.Get(context.Background(), seller, march)
+	require.NoError(t, err)
+	require.ElementsMatch(t, []did.DID{billedSpace, idleSpace}, statement.Spaces)
+	require.Equal(t, int64(1<<30+1<<20+1<<10), statement.AdjustedTotal())
+
+	// the late egress of the month is billed once, to the account that owned the spaces then
+	statement, err = statementTable.Get(context.Background(), buyer, march)
+	require.NoError(t, err)
+	require.Empty(t, statement.Lines)
+	require.Empty(t, statement.Adjustments)
+}
+
+func TestBillAccountPlans(t *testing.T) {
+	march := time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC)
+
+	account := testutil.RandomDID(t)
+	space1 := testutil.RandomDID(t)
+	space2 := testutil.RandomDID(t)
+	space3 := testutil.RandomDID(t)
+	network := testutil.RandomDID(t)
+
+	pricer, err := pricing.New(pricing.Config{
+		Default: pricing.FlatPlan("", 10),
+		Plans: map[string]pricing.Plan{
+			"pro": {IncludedEgress: 1 << 40, Tiers: []pricing.Tier{{From: 0, USDPerTiB: 8}, {From: 4 << 40, USDPerTiB: 4}}},
+		},
+		Providers: map[string]pricing.Plan{
+			network.String(): pricing.FlatPlan("network", 5),
+		},
+	})
+	require.NoError(t, err)
+
+	consumerTable := &mockConsumerTable{
+		spaces: map[did.DID][]did.DID{account: {space1, space2, space3}},
+		consumers: map[string]consumer.Consumer{
+			space1.String(): {ID: space1, Subscription: "pro"},
+			space2.String(): {ID: space2, Subscription: "pro"},
+			space3.String(): {ID: space3, Provider: network},
+		},
+	}
+	spaceStatsTable := &mockSpaceStatsTable{egress: map[did.DID]map[time.Time]uint64{
+		space1: {march: 2 << 40},
+		space2: {march: 1 << 40},
+		space3: {march: 1 << 40},
+	}}
+	statementTable := &mockStatementTable{statements: make(map[string]statements.Statement)}
+
+	b := New(&mockCustomerTable{customers: []did.DID{account}}, consumerTable, spaceStatsTable, statementTable, pricer, time.Hour, 0, 0)
+	require.NoError(t, b.BillAccount(context.Background(), account, march))
+
+	statement, err := statementTable.Get(context.Background(), account, march)
+	require.NoError(t, err)
+	require.Equal(t, []string{"default", "network", "pro"}, []string{statement.Plans[0].Name, statement.Plans[1].Name, statement.Plans[2].Name})
+
+	// the allowance of the pro plan is shared by its spaces: 2 of its 3 TiB are charged at $8
+	require.Equal(t, 16.0+5.0, statement.Amount())
+	require.InDeltaSlice(t, []float64{16 * 2.0 / 3, 16 * 1.0 / 3, 5}, lineAmounts(statement, space1, space2, space3), 1e-9)
+
+	t.Run("adjustments crossing a tier boundary are priced at the margin", func(t *testing.T) {
+		// space2 moves to the default plan, its late egress is still priced with the plan it was billed with
+		consumerTable.consumers[space2.String()] = consumer.Consumer{ID: space2}
+		spaceStatsTable.egress[space2][march] += 2 << 40
+
+		require.NoError(t, b.AdjustStatement(context.Background(), account, march))
+
+		statement, err := statementTable.Get(context.Background(), account, march)
+		require.NoError(t, err)
+		require.Len(t, statement.Adjustments, 1)
+		require.Equal(t, "pro", statement.Adjustments[0].Plan)
+
+		// the first TiB of late egress completes the $8 tier, the second one is charged at $4
+		require.Equal(t, []float64{12}, statement.AdjustmentAmounts())
+		require.Equal(t, 33.0, statement.AdjustedAmount())
+	})
+}
+
+// lineAmounts returns the amounts of the lines of the given spaces, in order
+func lineAmounts(statement *statements.Statement, spaces ...did.DID) []float64 {
+	amounts := statement.LineAmounts()
+	var out []float64
+	for _, space := range spaces {
+		for i, l := range statement.Lines {
+			if l.Space == space {
+				out = append(out, amounts[i])
+			}
+		}
+	}
+	return out
+}
+
+func TestLastClosableMonth(t *testing.T) {
+	now := time.Date(2025, 4, 2, 12, 0, 0, 0, time.UTC)
+
+	require.Equal(t, time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC), lastClosableMonth(now, 24*time.Hour))
+	require.Equal(t, time.Date(2025, 2, 1, 0, 0, 0, 0, time.UTC), lastClosableMonth(now, 48*time.Hour))
+}
+
+func TestExport(t *testing.T) {
+	account := testutil.RandomDID(t)
+	space := testutil.RandomDID(t)
+	closedAt := time.Date(2025, 4, 3, 0, 0, 0, 0, time.UTC)
+
+	statement := statements.Statement{
+		Account:     account,
+		Month:       time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC),
+		ClosedAt:    closedAt,
+		Plans:       []pricing.Plan{pricing.FlatPlan("default", 10)},
+		Lines:       []statements.Line{{Space: space, Plan: "default", Egress: 1 << 40}},
+		Adjustments: []statements.Adjustment{{Space: space, Plan: "default", Egress: -(1 << 39), RecordedAt: closedAt.AddDate(0, 0, 1)}},
+	}
+
+	t.Run("JSON", func(t *testing.T) {
+		var buf bytes.Buffer
+		require.NoError(t, WriteJSON(&buf, statement))
+
+		var out statementJSON
+		require.NoError(t, json.Unmarshal(buf.Bytes(), &out))
+		require.Equal(t, "2025-03", out.Month)
+		require.Equal(t, 10.0, out.AmountUSD)
+		require.Equal(t, -5.0, out.Adjustments[0].AmountUSD)
+		require.Equal(t, 5.0, out.AdjustedAmountUSD)
+	})
+
+	t.Run("CSV", func(t *testing.T) {
+		var buf bytes.Buffer
+		require.NoError(t, WriteCSV(&buf, statement))
+
+		rows, err := csv.NewReader(&buf).ReadAll()
+		require.NoError(t, err)
+		require.Len(t, rows, 5)
+		require.Equal(t, []string{account.String(), "2025-03", "line", space.String(), "default", "1099511627776", "10.00", "2025-04-03T00:00:00Z"}, rows[1])
+		require.Equal(t, "total", rows[2][2])
+		require.Equal(t, []string{"adjustment", "-549755813888", "-5.00"}, []string{rows[3][2], rows[3][5], rows[3][6]})
+		require.Equal(t, []string{"adjusted_total", "549755813888", "5.00"}, []string{rows[4][2], rows[4][5], rows[4][6]})
+	})
+}
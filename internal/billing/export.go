@@ -0,0 +1,134 @@
+package billing
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"time"
+
+	"github.com/storacha/etracker/internal/db/statements"
+	"github.com/storacha/etracker/internal/pricing"
+)
+
+type statementJSON struct {
+	Account           string           `json:"account"`
+	Month             string           `json:"month"`
+	ClosedAt          time.Time        `json:"closedAt"`
+	Plans             []pricing.Plan   `json:"plans"`
+	Lines             []lineJSON       `json:"lines"`
+	Total             uint64           `json:"total"`
+	AmountUSD         float64          `json:"amountUSD"`
+	Adjustments       []adjustmentJSON `json:"adjustments"`
+	AdjustedTotal     int64            `json:"adjustedTotal"`
+	AdjustedAmountUSD float64          `json:"adjustedAmountUSD"`
+}
+
+type lineJSON struct {
+	Space     string  `json:"space"`
+	Plan      string  `json:"plan"`
+	Egress    uint64  `json:"egress"`
+	AmountUSD float64 `json:"amountUSD"`
+}
+
+type adjustmentJSON struct {
+	Space      string    `json:"space"`
+	Plan       string    `json:"plan"`
+	Egress     int64     `json:"egress"`
+	AmountUSD  float64   `json:"amountUSD"`
+	RecordedAt time.Time `json:"recordedAt"`
+}
+
+// WriteJSON writes the statement as JSON, including the plans it was priced with. Amounts are rounded to the cent.
+func WriteJSON(w io.Writer, statement statements.Statement) error {
+	out := statementJSON{
+		Account:           statement.Account.String(),
+		Month:             statement.Month.Format("2006-01"),
+		ClosedAt:          statement.ClosedAt.UTC(),
+		Plans:             statement.Plans,
+		Lines:             make([]lineJSON, 0, len(statement.Lines)),
+		Total:             statement.Total(),
+		AmountUSD:         cents(statement.Amount()),
+		Adjustments:       make([]adjustmentJSON, 0, len(statement.Adjustments)),
+		AdjustedTotal:     statement.AdjustedTotal(),
+		AdjustedAmountUSD: cents(statement.AdjustedAmount()),
+	}
+	lineAmounts := statement.LineAmounts()
+	for i, l := range statement.Lines {
+		out.Lines = append(out.Lines, lineJSON{
+			Space:     l.Space.String(),
+			Plan:      l.Plan,
+			Egress:    l.Egress,
+			AmountUSD: cents(lineAmounts[i]),
+		})
+	}
+	adjustmentAmounts := statement.AdjustmentAmounts()
+	for i, a := range statement.Adjustments {
+		out.Adjustments = append(out.Adjustments, adjustmentJSON{
+			Space:      a.Space.String(),
+			Plan:       a.Plan,
+			Egress:     a.Egress,
+			AmountUSD:  cents(adjustmentAmounts[i]),
+			RecordedAt: a.RecordedAt.UTC(),
+		})
+	}
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(out); err != nil {
+		return fmt.Errorf("encoding statement: %w", err)
+	}
+
+	return nil
+}
+
+// WriteCSV writes the statement as CSV, one row per line and adjustment followed by the totals. Line amounts are
+// the share of the amount of their plan in proportion to their egress, adjustment amounts are the change they
+// caused to the amount of the statement.
+func WriteCSV(w io.Writer, statement statements.Statement) error {
+	account := statement.Account.String()
+	month := statement.Month.Format("2006-01")
+
+	rows := [][]string{{"account", "month", "type", "space", "plan", "egress_bytes", "amount_usd", "recorded_at"}}
+	lineAmounts := statement.LineAmounts()
+	for i, l := range statement.Lines {
+		rows = append(rows, []string{
+			account, month, "line", l.Space.String(), l.Plan, strconv.FormatUint(l.Egress, 10),
+			formatAmount(lineAmounts[i]), statement.ClosedAt.UTC().Format(time.RFC3339),
+		})
+	}
+	rows = append(rows, []string{
+		account, month, "total", "", "", strconv.FormatUint(statement.Total(), 10),
+		formatAmount(statement.Amount()), statement.ClosedAt.UTC().Format(time.RFC3339),
+	})
+	adjustmentAmounts := statement.AdjustmentAmounts()
+	for i, a := range statement.Adjustments {
+		rows = append(rows, []string{
+			account, month, "adjustment", a.Space.String(), a.Plan, strconv.FormatInt(a.Egress, 10),
+			formatAmount(adjustmentAmounts[i]), a.RecordedAt.UTC().Format(time.RFC3339),
+		})
+	}
+	if len(statement.Adjustments) > 0 {
+		rows = append(rows, []string{
+			account, month, "adjusted_total", "", "", strconv.FormatInt(statement.AdjustedTotal(), 10),
+			formatAmount(statement.AdjustedAmount()), "",
+		})
+	}
+
+	cw := csv.NewWriter(w)
+	if err := cw.WriteAll(rows); err != nil {
+		return fmt.Errorf("writing statement CSV: %w", err)
+	}
+
+	return nil
+}
+
+func cents(usd float64) float64 {
+	return math.Round(usd*100) / 100
+}
+
+func formatAmount(usd float64) string {
+	return strconv.FormatFloat(cents(usd), 'f', 2, 64)
+}
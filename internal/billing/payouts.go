@@ -0,0 +1,107 @@
+package billing
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/storacha/etracker/internal/db/payouts"
+)
+
+type payoutRunJSON struct {
+	Month       string           `json:"month"`
+	Status      string           `json:"status"`
+	GeneratedAt time.Time        `json:"generatedAt"`
+	FinalizedAt *time.Time       `json:"finalizedAt,omitempty"`
+	Lines       []payoutLineJSON `json:"lines"`
+	TotalEgress uint64           `json:"totalEgress"`
+	AmountUSD   float64          `json:"amountUSD"`
+}
+
+type payoutLineJSON struct {
+	Provider      string   `json:"provider"`
+	WalletAddress string   `json:"walletAddress"`
+	OperatorEmail string   `json:"operatorEmail"`
+	RateCard      string   `json:"rateCard,omitempty"`
+	Egress        uint64   `json:"egress"`
+	AmountUSD     float64  `json:"amountUSD"`
+	Causes        []string `json:"causes"`
+	Attestation   string   `json:"attestation,omitempty"`
+}
+
+// WritePayoutJSON writes the payout run as JSON. Amounts are rounded to the cent.
+func WritePayoutJSON(w io.Writer, run payouts.Run) error {
+	out := payoutRunJSON{
+		Month:       run.Month.Format("2006-01"),
+		Status:      string(run.Status),
+		GeneratedAt: run.GeneratedAt.UTC(),
+		Lines:       make([]payoutLineJSON, 0, len(run.Lines)),
+		TotalEgress: run.TotalEgress,
+		AmountUSD:   cents(run.TotalAmount),
+	}
+	if !run.FinalizedAt.IsZero() {
+		finalizedAt := run.FinalizedAt.UTC()
+		out.FinalizedAt = &finalizedAt
+	}
+	for _, l := range run.Lines {
+		causes := make([]string, 0, len(l.Causes))
+		for _, c := range l.Causes {
+			causes = append(causes, c.String())
+		}
+		line := payoutLineJSON{
+			Provider:      l.Provider.String(),
+			WalletAddress: l.WalletAddress,
+			OperatorEmail: l.OperatorEmail,
+			RateCard:      l.RateCard,
+			Egress:        l.Egress,
+			AmountUSD:     cents(l.Amount),
+			Causes:        causes,
+		}
+		if l.Attestation != nil {
+			line.Attestation = l.Attestation.String()
+		}
+		out.Lines = append(out.Lines, line)
+	}
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(out); err != nil {
+		return fmt.Errorf("encoding payout run: %w", err)
+	}
+
+	return nil
+}
+
+// WritePayoutCSV writes the payout run as CSV, one row per provider. The causes of a line are separated by spaces.
+// The rate card column names the rate card the line was paid with, the attestation column is empty for lines that
+// were not attested.
+func WritePayoutCSV(w io.Writer, run payouts.Run) error {
+	month := run.Month.Format("2006-01")
+
+	rows := [][]string{{"month", "provider", "operator_email", "wallet_address", "egress_bytes", "rate_card", "amount_usd", "attestation", "causes"}}
+	for _, l := range run.Lines {
+		causes := make([]string, 0, len(l.Causes))
+		for _, c := range l.Causes {
+			causes = append(causes, c.String())
+		}
+		attestation := ""
+		if l.Attestation != nil {
+			attestation = l.Attestation.String()
+		}
+		rows = append(rows, []string{
+			month, l.Provider.String(), l.OperatorEmail, l.WalletAddress, strconv.FormatUint(l.Egress, 10), l.RateCard,
+			formatAmount(l.Amount), attestation, strings.Join(causes, " "),
+		})
+	}
+
+	cw := csv.NewWriter(w)
+	if err := cw.WriteAll(rows); err != nil {
+		return fmt.Errorf("writing payout run CSV: %w", err)
+	}
+
+	return nil
+}
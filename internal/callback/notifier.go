@@ -0,0 +1,399 @@
+package callback
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"sync"
+	"syscall"
+	"time"
+
+	logging "github.com/ipfs/go-log/v2"
+	"github.com/ipld/go-ipld-prime/datamodel"
+	"github.com/storacha/go-ucanto/core/invocation"
+	"github.com/storacha/go-ucanto/core/message"
+	"github.com/storacha/go-ucanto/core/receipt"
+	"github.com/storacha/go-ucanto/core/receipt/ran"
+	"github.com/storacha/go-ucanto/did"
+	"github.com/storacha/go-ucanto/transport/car/response"
+	"github.com/storacha/go-ucanto/ucan"
+
+	"github.com/storacha/etracker/internal/db/callbacks"
+	"github.com/storacha/etracker/internal/db/deliveries"
+)
+
+var log = logging.Logger("callback")
+
+// FactKey is the key of the `space/egress/track` invocation fact nodes can use to pass a callback URL for the batch
+const FactKey = "callback"
+
+// ErrAddressNotAllowed is returned when a callback resolves to an address receipts are not delivered to, such as
+// loopback, link-local and private addresses
+var ErrAddressNotAllowed = errors.New("callback address not allowed")
+
+// duePageSize is the maximum number of receipts delivered on each run
+const duePageSize = 100
+
+// Receipt is a receipt that can be pushed to a callback
+type Receipt interface {
+	Ran() ran.Ran
+	Archive() io.Reader
+}
+
+// Notifier pushes consolidation receipts to the callback URLs of the nodes that tracked the batches.
+// Receipts are stored in the delivery table until delivered, so that pending deliveries survive restarts.
+// They are delivered in the background, retried with exponential backoff and every attempt is logged.
+type Notifier struct {
+	callbackTable  callbacks.CallbackTable
+	deliveryTable  deliveries.DeliveryTable
+	httpClient     *http.Client
+	interval       time.Duration
+	maxAttempts    int
+	initialBackoff time.Duration
+	maxBackoff     time.Duration
+	stopCh         chan struct{}
+}
+
+type Option func(*Notifier)
+
+// WithMaxAttempts sets the number of times a delivery is attempted before giving up.
+func WithMaxAttempts(maxAttempts int) Option {
+	return func(n *Notifier) {
+		n.maxAttempts = maxAttempts
+	}
+}
+
+// WithBackoff sets the delay before the first retry, doubled on every subsequent retry up to maxBackoff.
+func WithBackoff(initialBackoff, maxBackoff time.Duration) Option {
+	return func(n *Notifier) {
+		n.initialBackoff = initialBackoff
+		n.maxBackoff = maxBackoff
+	}
+}
+
+// WithInterval sets how often receipts due for delivery are looked up.
+func WithInterval(interval time.Duration) Option {
+	return func(n *Notifier) {
+		n.interval = interval
+	}
+}
+
+// WithHTTPClient sets the client used to deliver receipts. The default client only connects to public addresses.
+func WithHTTPClient(client *http.Client) Option {
+	return func(n *Notifier) {
+		n.httpClient = client
+	}
+}
+
+func New(callbackTable callbacks.CallbackTable, deliveryTable deliveries.DeliveryTable, opts ...Option) *Notifier {
+	n := &Notifier{
+		callbackTable:  callbackTable,
+		deliveryTable:  deliveryTable,
+		httpClient:     publicClient(30 * time.Second),
+		interval:       10 * time.Second,
+		maxAttempts:    5,
+		initialBackoff: 10 * time.Second,
+		maxBackoff:     10 * time.Minute,
+		stopCh:         make(chan struct{}),
+	}
+
+	for _, opt := range opts {
+		opt(n)
+	}
+
+	return n
+}
+
+// Notify schedules the delivery of the consolidation receipt of a batch tracked by trackInv, which may be nil
+// if the invocation is not available. The callback passed in the track invocation takes precedence over the
+// one registered for the node. Nothing is delivered if there is no callback for the batch.
+func (n *Notifier) Notify(ctx context.Context, node did.DID, trackInv invocation.Invocation, rcpt Receipt) error {
+	callbackURL, err := n.resolveCallback(ctx, node, trackInv)
+	if err != nil {
+		return err
+	}
+
+	if callbackURL == nil {
+		return nil
+	}
+
+	archive, err := io.ReadAll(rcpt.Archive())
+	if err != nil {
+		return fmt.Errorf("reading receipt archive: %w", err)
+	}
+
+	err = n.deliveryTable.Schedule(ctx, deliveries.Pending{
+		Cause:         rcpt.Ran().Link(),
+		Node:          node,
+		URL:           callbackURL.String(),
+		Receipt:       archive,
+		Attempt:       1,
+		NextAttemptAt: time.Now(),
+	})
+	if err != nil {
+		return fmt.Errorf("scheduling delivery: %w", err)
+	}
+
+	return nil
+}
+
+// Start delivers the receipts due right away and then on every interval
+func (n *Notifier) Start(ctx context.Context) {
+	ticker := time.NewTicker(n.interval)
+	defer ticker.Stop()
+
+	log.Infof("Notifier started with interval: %v", n.interval)
+
+	if err := n.Run(ctx); err != nil {
+		log.Errorf("Delivery error: %v", err)
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			log.Info("Notifier stopping due to context cancellation")
+			return
+		case <-n.stopCh:
+			log.Info("Notifier stopping")
+			return
+		case <-ticker.C:
+			if err := n.Run(ctx); err != nil {
+				log.Errorf("Delivery error: %v", err)
+			}
+		}
+	}
+}
+
+func (n *Notifier) Stop() {
+	close(n.stopCh)
+}
+
+// Run attempts the delivery of every receipt due. Failed deliveries are scheduled again with exponential
+// backoff until they run out of attempts.
+func (n *Notifier) Run(ctx context.Context) error {
+	due, err := n.deliveryTable.ListDue(ctx, time.Now(), duePageSize)
+	if err != nil {
+		return fmt.Errorf("listing due deliveries: %w", err)
+	}
+
+	var wg sync.WaitGroup
+	errs := make([]error, len(due))
+	for i, pending := range due {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs[i] = n.deliver(ctx, pending)
+		}()
+	}
+	wg.Wait()
+
+	return errors.Join(errs...)
+}
+
+func (n *Notifier) resolveCallback(ctx context.Context, node did.DID, trackInv invocation.Invocation) (*url.URL, error) {
+	if trackInv != nil {
+		callbackURL, err := callbackFromFacts(trackInv.Facts())
+		if err != nil {
+			return nil, fmt.Errorf("reading callback from track invocation: %w", err)
+		}
+
+		if callbackURL != nil {
+			return callbackURL, nil
+		}
+	}
+
+	callbackURL, err := n.callbackTable.Get(ctx, node)
+	if err != nil {
+		if errors.Is(err, callbacks.ErrNotFound) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("getting callback of node: %w", err)
+	}
+
+	return callbackURL, nil
+}
+
+// callbackFromFacts returns the callback URL in the given invocation facts, if any
+func callbackFromFacts(facts []ucan.Fact) (*url.URL, error) {
+	for _, fact := range facts {
+		value, ok := fact[FactKey]
+		if !ok {
+			continue
+		}
+
+		var str string
+		switch v := value.(type) {
+		case string:
+			str = v
+		case datamodel.Node:
+			s, err := v.AsString()
+			if err != nil {
+				return nil, fmt.Errorf("callback fact is not a string: %w", err)
+			}
+			str = s
+		default:
+			return nil, fmt.Errorf("callback fact is not a string")
+		}
+
+		// nodes pick these freely, unlike the callbacks registered by admins
+		u, err := ParseURL(str)
+		if err != nil {
+			return nil, err
+		}
+		if u.Scheme != "https" {
+			return nil, fmt.Errorf("callback URL passed in a track invocation must be an https URL")
+		}
+		return u, nil
+	}
+
+	return nil, nil
+}
+
+// ParseURL parses a callback URL, which must be an absolute http or https URL
+func ParseURL(str string) (*url.URL, error) {
+	u, err := url.Parse(str)
+	if err != nil {
+		return nil, fmt.Errorf("parsing callback URL: %w", err)
+	}
+
+	if (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, fmt.Errorf("callback URL must be an absolute http or https URL")
+	}
+
+	return u, nil
+}
+
+// publicClient returns an HTTP client that only connects to public addresses. Addresses are checked once host
+// names are resolved, right before connecting, so that neither DNS nor redirects can point deliveries at the
+// internal network.
+func publicClient(timeout time.Duration) *http.Client {
+	dialer := &net.Dialer{
+		Timeout: 10 * time.Second,
+		Control: func(network, address string, _ syscall.RawConn) error {
+			addrPort, err := netip.ParseAddrPort(address)
+			if err != nil {
+				return fmt.Errorf("parsing callback address: %w", err)
+			}
+			if addr := addrPort.Addr().Unmap(); !addr.IsGlobalUnicast() || addr.IsPrivate() {
+				return fmt.Errorf("%w: %s", ErrAddressNotAllowed, addr)
+			}
+			return nil
+		},
+	}
+
+	return &http.Client{
+		Timeout: timeout,
+		Transport: &http.Transport{
+			DialContext:         dialer.DialContext,
+			TLSHandshakeTimeout: 10 * time.Second,
+			MaxIdleConns:        100,
+			IdleConnTimeout:     90 * time.Second,
+		},
+	}
+}
+
+// deliver makes one attempt to deliver a pending receipt, and either completes it or schedules the next attempt
+func (n *Notifier) deliver(ctx context.Context, pending deliveries.Pending) error {
+	statusCode, err := n.post(ctx, pending.URL, pending.Receipt)
+
+	delivery := deliveries.Delivery{
+		Cause:       pending.Cause,
+		Attempt:     pending.Attempt,
+		Node:        pending.Node,
+		URL:         pending.URL,
+		StatusCode:  statusCode,
+		Delivered:   err == nil,
+		AttemptedAt: time.Now(),
+	}
+	if err != nil {
+		delivery.Error = err.Error()
+	}
+
+	if logErr := n.deliveryTable.Add(ctx, delivery); logErr != nil {
+		log.Errorf("logging delivery of receipt for %s: %v", pending.Cause, logErr)
+	}
+
+	if err == nil {
+		log.Debugw("Delivered consolidation receipt", "cause", pending.Cause, "node", pending.Node, "attempt", pending.Attempt)
+		return n.complete(ctx, pending)
+	}
+
+	log.Warnw("Failed to deliver consolidation receipt", "cause", pending.Cause, "node", pending.Node, "attempt", pending.Attempt, "error", err)
+
+	if pending.Attempt >= n.maxAttempts {
+		log.Errorw("Giving up delivering consolidation receipt", "cause", pending.Cause, "node", pending.Node, "attempts", pending.Attempt)
+		return n.complete(ctx, pending)
+	}
+
+	pending.NextAttemptAt = time.Now().Add(n.backoff(pending.Attempt))
+	pending.Attempt++
+	if err := n.deliveryTable.Schedule(ctx, pending); err != nil {
+		return fmt.Errorf("scheduling delivery of receipt for %s: %w", pending.Cause, err)
+	}
+
+	return nil
+}
+
+func (n *Notifier) complete(ctx context.Context, pending deliveries.Pending) error {
+	if err := n.deliveryTable.Complete(ctx, pending.Cause); err != nil {
+		return fmt.Errorf("completing delivery of receipt for %s: %w", pending.Cause, err)
+	}
+	return nil
+}
+
+// backoff returns the delay before the attempt following the given one, doubled after every attempt up to
+// maxBackoff
+func (n *Notifier) backoff(attempt int) time.Duration {
+	backoff := n.initialBackoff
+	for i := 1; i < attempt && backoff < n.maxBackoff; i++ {
+		backoff *= 2
+	}
+	return min(backoff, n.maxBackoff)
+}
+
+// post sends the receipt to the callback URL in the same CAR format used by ucanto responses
+func (n *Notifier) post(ctx context.Context, callbackURL string, archive []byte) (int, error) {
+	rcpt, err := receipt.Extract(archive)
+	if err != nil {
+		return 0, fmt.Errorf("extracting receipt: %w", err)
+	}
+
+	msg, err := message.Build(nil, []receipt.AnyReceipt{rcpt})
+	if err != nil {
+		return 0, fmt.Errorf("building message: %w", err)
+	}
+
+	res, err := response.Encode(msg)
+	if err != nil {
+		return 0, fmt.Errorf("encoding receipt message: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, callbackURL, res.Body())
+	if err != nil {
+		return 0, fmt.Errorf("creating request: %w", err)
+	}
+
+	for key, vals := range res.Headers() {
+		for _, v := range vals {
+			req.Header.Add(key, v)
+		}
+	}
+
+	resp, err := n.httpClient.Do(req)
+	if err != nil {
+		return 0, fmt.Errorf("sending request: %w", err)
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, resp.Body)
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return resp.StatusCode, fmt.Errorf("unexpected status code %d", resp.StatusCode)
+	}
+
+	return resp.StatusCode, nil
+}
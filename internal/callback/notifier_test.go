@@ -0,0 +1,329 @@
+package callback
+
+import (
+	"context"
+	"crypto/tls"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/ipld/go-ipld-prime/datamodel"
+	"github.com/ipld/go-ipld-prime/node/basicnode"
+	capegress "github.com/storacha/go-libstoracha/capabilities/space/egress"
+	"github.com/storacha/go-libstoracha/testutil"
+	"github.com/storacha/go-ucanto/core/delegation"
+	"github.com/storacha/go-ucanto/core/invocation"
+	"github.com/storacha/go-ucanto/core/receipt"
+	"github.com/storacha/go-ucanto/core/receipt/ran"
+	"github.com/storacha/go-ucanto/core/result"
+	"github.com/storacha/go-ucanto/did"
+	"github.com/storacha/go-ucanto/ucan"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/storacha/etracker/internal/db/callbacks"
+	"github.com/storacha/etracker/internal/db/deliveries"
+)
+
+type mockCallbackTable struct {
+	callbacks map[did.DID]*url.URL
+}
+
+func (m *mockCallbackTable) Get(ctx context.Context, node did.DID) (*url.URL, error) {
+	u, ok := m.callbacks[node]
+	if !ok {
+		return nil, callbacks.ErrNotFound
+	}
+	return u, nil
+}
+
+func (m *mockCallbackTable) Put(ctx context.Context, node did.DID, callback *url.URL) error {
+	m.callbacks[node] = callback
+	return nil
+}
+
+func (m *mockCallbackTable) Delete(ctx context.Context, node did.DID) error {
+	delete(m.callbacks, node)
+	return nil
+}
+
+func (m *mockCallbackTable) List(ctx context.Context, limit int, cursor *string) (*callbacks.ListResult, error) {
+	return &callbacks.ListResult{}, nil
+}
+
+var _ callbacks.CallbackTable = (*mockCallbackTable)(nil)
+
+type mockDeliveryTable struct {
+	mu         sync.Mutex
+	deliveries []deliveries.Delivery
+	pending    map[string]deliveries.Pending
+}
+
+func (m *mockDeliveryTable) Add(ctx context.Context, delivery deliveries.Delivery) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.deliveries = append(m.deliveries, delivery)
+	return nil
+}
+
+func (m *mockDeliveryTable) ListByCause(ctx context.Context, cause ucan.Link) ([]deliveries.Delivery, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var list []deliveries.Delivery
+	for _, d := range m.deliveries {
+		if d.Cause.String() == cause.String() {
+			list = append(list, d)
+		}
+	}
+	return list, nil
+}
+
+func (m *mockDeliveryTable) Schedule(ctx context.Context, pending deliveries.Pending) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.pending == nil {
+		m.pending = make(map[string]deliveries.Pending)
+	}
+	m.pending[pending.Cause.String()] = pending
+	return nil
+}
+
+func (m *mockDeliveryTable) ListDue(ctx context.Context, now time.Time, limit int) ([]deliveries.Pending, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var due []deliveries.Pending
+	for _, p := range m.pending {
+		if !p.NextAttemptAt.After(now) && len(due) < limit {
+			due = append(due, p)
+		}
+	}
+	return due, nil
+}
+
+func (m *mockDeliveryTable) Complete(ctx context.Context, cause ucan.Link) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.pending, cause.String())
+	return nil
+}
+
+var _ deliveries.DeliveryTable = (*mockDeliveryTable)(nil)
+
+type callbackFact string
+
+func (f callbackFact) ToIPLD() (map[string]datamodel.Node, error) {
+	return map[string]datamodel.Node{FactKey: basicnode.NewString(string(f))}, nil
+}
+
+// callbackServer fails the first failures requests and counts the receipts it receives
+func callbackServer(t *testing.T, failures int32) (*httptest.Server, *atomic.Int32) {
+	var requests, received atomic.Int32
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if requests.Add(1) <= failures {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+
+		assert.Equal(t, http.MethodPost, r.Method)
+		assert.Contains(t, r.Header.Get("Content-Type"), "car")
+		received.Add(1)
+		w.WriteHeader(http.StatusOK)
+	}))
+	t.Cleanup(srv.Close)
+
+	return srv, &received
+}
+
+func TestNotify(t *testing.T) {
+	id := testutil.RandomSigner(t)
+	node := testutil.RandomSigner(t)
+
+	newTrackInv := func(t *testing.T, opts ...delegation.Option) invocation.Invocation {
+		opts = append(opts, delegation.WithNoExpiration())
+		inv, err := capegress.Track.Invoke(node, id, node.DID().String(), capegress.TrackCaveats{
+			Receipts: testutil.RandomCID(t),
+			Endpoint: testutil.Must(url.Parse("https://node.example.com/receipts/{cid}"))(t),
+		}, opts...)
+		require.NoError(t, err)
+		return inv
+	}
+
+	newReceipt := func(t *testing.T) capegress.ConsolidateReceipt {
+		anyRcpt, err := receipt.Issue(id, result.Ok[capegress.ConsolidateOk, capegress.ConsolidateError](capegress.ConsolidateOk{TotalEgress: 100}), ran.FromLink(testutil.RandomCID(t)))
+		require.NoError(t, err)
+
+		reader, err := capegress.NewConsolidateReceiptReader()
+		require.NoError(t, err)
+
+		rcpt, err := reader.Read(anyRcpt.Root().Link(), anyRcpt.Blocks())
+		require.NoError(t, err)
+		return rcpt
+	}
+
+	// test servers listen on loopback addresses, which the default client refuses to connect to
+	testClient := &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{InsecureSkipVerify: true}}}
+
+	newNotifier := func(callbackTable *mockCallbackTable, deliveryTable *mockDeliveryTable) *Notifier {
+		return New(callbackTable, deliveryTable, WithMaxAttempts(3), WithBackoff(time.Millisecond, time.Millisecond), WithHTTPClient(testClient))
+	}
+
+	// run waits for the backoff of failed deliveries to pass and delivers the receipts due
+	run := func(t *testing.T, n *Notifier) {
+		time.Sleep(2 * time.Millisecond)
+		require.NoError(t, n.Run(context.Background()))
+	}
+
+	t.Run("retries until the receipt is delivered", func(t *testing.T) {
+		srv, received := callbackServer(t, 1)
+		deliveryTable := &mockDeliveryTable{}
+		n := newNotifier(&mockCallbackTable{callbacks: map[did.DID]*url.URL{}}, deliveryTable)
+
+		rcpt := newReceipt(t)
+		trackInv := newTrackInv(t, delegation.WithFacts([]ucan.FactBuilder{callbackFact(srv.URL)}))
+		require.NoError(t, n.Notify(context.Background(), node.DID(), trackInv, rcpt))
+
+		run(t, n)
+		require.Zero(t, received.Load())
+		require.Len(t, deliveryTable.pending, 1)
+
+		run(t, n)
+		require.Equal(t, int32(1), received.Load())
+		require.Empty(t, deliveryTable.pending)
+
+		log, err := deliveryTable.ListByCause(context.Background(), rcpt.Ran().Link())
+		require.NoError(t, err)
+		require.Len(t, log, 2)
+		assert.False(t, log[0].Delivered)
+		assert.Equal(t, http.StatusServiceUnavailable, log[0].StatusCode)
+		assert.True(t, log[1].Delivered)
+		assert.Equal(t, 2, log[1].Attempt)
+		assert.Equal(t, srv.URL, log[1].URL)
+	})
+
+	t.Run("pending deliveries survive restarts", func(t *testing.T) {
+		srv, received := callbackServer(t, 1)
+		callbackTable := &mockCallbackTable{callbacks: map[did.DID]*url.URL{}}
+		deliveryTable := &mockDeliveryTable{}
+
+		rcpt := newReceipt(t)
+		trackInv := newTrackInv(t, delegation.WithFacts([]ucan.FactBuilder{callbackFact(srv.URL)}))
+		require.NoError(t, newNotifier(callbackTable, deliveryTable).Notify(context.Background(), node.DID(), trackInv, rcpt))
+		run(t, newNotifier(callbackTable, deliveryTable))
+		run(t, newNotifier(callbackTable, deliveryTable))
+
+		require.Equal(t, int32(1), received.Load())
+		require.Empty(t, deliveryTable.pending)
+	})
+
+	t.Run("failed deliveries are retried after the backoff", func(t *testing.T) {
+		srv, received := callbackServer(t, 1)
+		deliveryTable := &mockDeliveryTable{}
+		n := New(&mockCallbackTable{callbacks: map[did.DID]*url.URL{}}, deliveryTable, WithBackoff(time.Hour, time.Hour), WithHTTPClient(testClient))
+
+		rcpt := newReceipt(t)
+		trackInv := newTrackInv(t, delegation.WithFacts([]ucan.FactBuilder{callbackFact(srv.URL)}))
+		require.NoError(t, n.Notify(context.Background(), node.DID(), trackInv, rcpt))
+
+		run(t, n)
+		run(t, n)
+		require.Zero(t, received.Load())
+
+		pending := deliveryTable.pending[rcpt.Ran().Link().String()]
+		assert.Equal(t, 2, pending.Attempt)
+		assert.WithinDuration(t, time.Now().Add(time.Hour), pending.NextAttemptAt, time.Minute)
+	})
+
+	t.Run("track invocation callback takes precedence", func(t *testing.T) {
+		registered, registeredReceived := callbackServer(t, 0)
+		passed, passedReceived := callbackServer(t, 0)
+		n := newNotifier(&mockCallbackTable{callbacks: map[did.DID]*url.URL{
+			node.DID(): testutil.Must(url.Parse(registered.URL))(t),
+		}}, &mockDeliveryTable{})
+
+		trackInv := newTrackInv(t, delegation.WithFacts([]ucan.FactBuilder{callbackFact(passed.URL)}))
+		require.NoError(t, n.Notify(context.Background(), node.DID(), trackInv, newReceipt(t)))
+
+		run(t, n)
+		assert.Equal(t, int32(1), passedReceived.Load())
+		assert.Zero(t, registeredReceived.Load())
+	})
+
+	t.Run("falls back to the callback registered for the node", func(t *testing.T) {
+		srv, received := callbackServer(t, 0)
+		n := newNotifier(&mockCallbackTable{callbacks: map[did.DID]*url.URL{
+			node.DID(): testutil.Must(url.Parse(srv.URL))(t),
+		}}, &mockDeliveryTable{})
+
+		require.NoError(t, n.Notify(context.Background(), node.DID(), nil, newReceipt(t)))
+
+		run(t, n)
+		assert.Equal(t, int32(1), received.Load())
+	})
+
+	t.Run("gives up after the maximum number of attempts", func(t *testing.T) {
+		srv, received := callbackServer(t, 10)
+		deliveryTable := &mockDeliveryTable{}
+		n := newNotifier(&mockCallbackTable{callbacks: map[did.DID]*url.URL{
+			node.DID(): testutil.Must(url.Parse(srv.URL))(t),
+		}}, deliveryTable)
+
+		rcpt := newReceipt(t)
+		require.NoError(t, n.Notify(context.Background(), node.DID(), newTrackInv(t), rcpt))
+
+		for range 4 {
+			run(t, n)
+		}
+
+		assert.Zero(t, received.Load())
+		assert.Empty(t, deliveryTable.pending)
+		log, err := deliveryTable.ListByCause(context.Background(), rcpt.Ran().Link())
+		require.NoError(t, err)
+		assert.Len(t, log, 3)
+	})
+
+	t.Run("nothing is delivered without a callback", func(t *testing.T) {
+		deliveryTable := &mockDeliveryTable{}
+		n := newNotifier(&mockCallbackTable{callbacks: map[did.DID]*url.URL{}}, deliveryTable)
+
+		require.NoError(t, n.Notify(context.Background(), node.DID(), newTrackInv(t), newReceipt(t)))
+		run(t, n)
+		assert.Empty(t, deliveryTable.pending)
+		assert.Empty(t, deliveryTable.deliveries)
+	})
+
+	t.Run("rejects callbacks that are not http URLs", func(t *testing.T) {
+		n := newNotifier(&mockCallbackTable{callbacks: map[did.DID]*url.URL{}}, &mockDeliveryTable{})
+
+		trackInv := newTrackInv(t, delegation.WithFacts([]ucan.FactBuilder{callbackFact("file:///etc/passwd")}))
+		require.Error(t, n.Notify(context.Background(), node.DID(), trackInv, newReceipt(t)))
+	})
+
+	t.Run("rejects callbacks passed in track invocations that are not https URLs", func(t *testing.T) {
+		n := newNotifier(&mockCallbackTable{callbacks: map[did.DID]*url.URL{}}, &mockDeliveryTable{})
+
+		trackInv := newTrackInv(t, delegation.WithFacts([]ucan.FactBuilder{callbackFact("http://node.example.com/callback")}))
+		require.Error(t, n.Notify(context.Background(), node.DID(), trackInv, newReceipt(t)))
+	})
+
+	t.Run("does not deliver to internal addresses", func(t *testing.T) {
+		srv, received := callbackServer(t, 0)
+		deliveryTable := &mockDeliveryTable{}
+		n := New(&mockCallbackTable{callbacks: map[did.DID]*url.URL{}}, deliveryTable, WithMaxAttempts(1))
+
+		rcpt := newReceipt(t)
+		trackInv := newTrackInv(t, delegation.WithFacts([]ucan.FactBuilder{callbackFact(srv.URL)}))
+		require.NoError(t, n.Notify(context.Background(), node.DID(), trackInv, rcpt))
+		run(t, n)
+
+		log, err := deliveryTable.ListByCause(context.Background(), rcpt.Ran().Link())
+		require.NoError(t, err)
+		require.Len(t, log, 1)
+		assert.False(t, log[0].Delivered)
+		assert.Contains(t, log[0].Error, ErrAddressNotAllowed.Error())
+		assert.Zero(t, received.Load())
+	})
+}
@@ -0,0 +1,98 @@
+package quota
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/ipld/go-ipld-prime/datamodel"
+	"github.com/storacha/go-libstoracha/capabilities/types"
+	"github.com/storacha/go-ucanto/core/ipld"
+	"github.com/storacha/go-ucanto/core/receipt"
+	"github.com/storacha/go-ucanto/core/result/failure"
+	"github.com/storacha/go-ucanto/core/schema"
+	"github.com/storacha/go-ucanto/ucan"
+	"github.com/storacha/go-ucanto/validator"
+)
+
+// GetAbility gets the usage of the monthly egress quota of an account. The resource is the DID of the account.
+const GetAbility = "account/quota/get"
+
+type GetCaveats struct{}
+
+func (gc GetCaveats) ToIPLD() (datamodel.Node, error) {
+	return ipld.WrapWithRecovery(&gc, GetCaveatsType(), types.Converters...)
+}
+
+var GetCaveatsReader = schema.Struct[GetCaveats](GetCaveatsType(), nil, types.Converters...)
+
+// GetOk is the egress of the account in the current calendar month against its monthly limit, in bytes.
+// Month is the first day of the month.
+type GetOk struct {
+	Month time.Time
+	Limit uint64
+	Used  uint64
+}
+
+func (gok GetOk) ToIPLD() (datamodel.Node, error) {
+	return ipld.WrapWithRecovery(&gok, GetOkType(), types.Converters...)
+}
+
+var GetOkReader = schema.Struct[GetOk](GetOkType(), nil, types.Converters...)
+
+type GetError struct {
+	ErrorName string
+	Message   string
+}
+
+const AccountNotFoundErrorName = "AccountNotFound"
+
+// NoQuotaErrorName is returned for accounts without a monthly egress limit
+const NoQuotaErrorName = "NoQuota"
+
+func NewAccountNotFoundError(msg string) GetError {
+	return GetError{
+		ErrorName: AccountNotFoundErrorName,
+		Message:   msg,
+	}
+}
+
+func NewNoQuotaError(msg string) GetError {
+	return GetError{
+		ErrorName: NoQuotaErrorName,
+		Message:   msg,
+	}
+}
+
+func (ge GetError) Name() string {
+	return ge.ErrorName
+}
+
+func (ge GetError) Error() string {
+	return ge.Message
+}
+
+func (ge GetError) ToIPLD() (datamodel.Node, error) {
+	return ipld.WrapWithRecovery(&ge, GetErrorType(), types.Converters...)
+}
+
+type GetReceipt receipt.Receipt[GetOk, GetError]
+type GetReceiptReader receipt.ReceiptReader[GetOk, GetError]
+
+func NewGetReceiptReader() (GetReceiptReader, error) {
+	return receipt.NewReceiptReaderFromTypes[GetOk, GetError](GetOkType(), GetErrorType(), types.Converters...)
+}
+
+var Get = validator.NewCapability(
+	GetAbility,
+	schema.DIDString(),
+	GetCaveatsReader,
+	getDerives,
+)
+
+func getDerives(claimed, delegated ucan.Capability[GetCaveats]) failure.Failure {
+	if claimed.With() != delegated.With() {
+		return failure.FromError(fmt.Errorf("can not derive %s with %s from %s", claimed.Can(), claimed.With(), delegated.With()))
+	}
+
+	return nil
+}
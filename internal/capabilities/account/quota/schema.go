@@ -0,0 +1,34 @@
+package quota
+
+import (
+	_ "embed"
+	"fmt"
+
+	"github.com/ipld/go-ipld-prime/schema"
+	captypes "github.com/storacha/go-libstoracha/capabilities/types"
+)
+
+//go:embed quota.ipldsch
+var quotaSchema []byte
+
+var quotaTS = mustLoadTS()
+
+func mustLoadTS() *schema.TypeSystem {
+	ts, err := captypes.LoadSchemaBytes(quotaSchema)
+	if err != nil {
+		panic(fmt.Errorf("loading account quota schema: %w", err))
+	}
+	return ts
+}
+
+func GetCaveatsType() schema.Type {
+	return quotaTS.TypeByName("GetCaveats")
+}
+
+func GetOkType() schema.Type {
+	return quotaTS.TypeByName("GetOk")
+}
+
+func GetErrorType() schema.Type {
+	return quotaTS.TypeByName("GetError")
+}
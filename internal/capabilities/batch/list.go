@@ -0,0 +1,135 @@
+package batch
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/ipld/go-ipld-prime/datamodel"
+	"github.com/storacha/go-libstoracha/capabilities/types"
+	"github.com/storacha/go-ucanto/core/ipld"
+	"github.com/storacha/go-ucanto/core/receipt"
+	"github.com/storacha/go-ucanto/core/result/failure"
+	"github.com/storacha/go-ucanto/core/schema"
+	"github.com/storacha/go-ucanto/ucan"
+	"github.com/storacha/go-ucanto/validator"
+)
+
+// ListAbility lists the batches tracked for a storage node. The resource is the DID of the node.
+const ListAbility = "egress/batch/list"
+
+// Period is a time range batches are filtered by, based on the time they were received.
+// From is inclusive and To is exclusive.
+type Period struct {
+	From time.Time
+	To   time.Time
+}
+
+// ListCaveats filters and paginates the list of batches. All caveats are optional.
+// A nil `Period` lists batches received at any time.
+type ListCaveats struct {
+	Period *Period
+	Limit  *int64
+	Cursor *string
+}
+
+func (lc ListCaveats) ToIPLD() (datamodel.Node, error) {
+	return ipld.WrapWithRecovery(&lc, ListCaveatsType(), types.Converters...)
+}
+
+var ListCaveatsReader = schema.Struct[ListCaveats](ListCaveatsType(), nil, types.Converters...)
+
+// Batch describes a batch of retrieval receipts tracked by a node.
+// Cause is the `space/egress/track` invocation the batch was tracked with, unknown for batches tracked
+// before it was recorded. Status is one of unprocessed, processing, consolidated, failed or held.
+// ProcessedAt is only set for batches that were consolidated.
+// TotalEgress is the egress in bytes the batch was consolidated to and Rejected is the number of
+// retrieval receipts in the batch that were not counted.
+type Batch struct {
+	Batch       ucan.Link
+	Cause       ucan.Link
+	Status      string
+	ReceivedAt  time.Time
+	ProcessedAt *time.Time
+	TotalEgress uint64
+	Rejected    uint64
+}
+
+// ListOk contains a page of batches, most recently received first.
+// Cursor is set when there are more batches to list.
+type ListOk struct {
+	Batches []Batch
+	Cursor  *string
+}
+
+func (lok ListOk) ToIPLD() (datamodel.Node, error) {
+	return ipld.WrapWithRecovery(&lok, ListOkType(), types.Converters...)
+}
+
+var ListOkReader = schema.Struct[ListOk](ListOkType(), nil, types.Converters...)
+
+type ListError struct {
+	ErrorName string
+	Message   string
+}
+
+const PeriodNotAcceptableErrorName = "PeriodNotAcceptable"
+
+func NewPeriodNotAcceptableError(msg string) ListError {
+	return ListError{
+		ErrorName: PeriodNotAcceptableErrorName,
+		Message:   msg,
+	}
+}
+
+func NewListError(msg string) ListError {
+	return ListError{
+		ErrorName: "ListError",
+		Message:   msg,
+	}
+}
+
+func (le ListError) Name() string {
+	return le.ErrorName
+}
+
+func (le ListError) Error() string {
+	return le.Message
+}
+
+func (le ListError) ToIPLD() (datamodel.Node, error) {
+	return ipld.WrapWithRecovery(&le, ListErrorType(), types.Converters...)
+}
+
+type ListReceipt receipt.Receipt[ListOk, ListError]
+type ListReceiptReader receipt.ReceiptReader[ListOk, ListError]
+
+func NewListReceiptReader() (ListReceiptReader, error) {
+	return receipt.NewReceiptReaderFromTypes[ListOk, ListError](ListOkType(), ListErrorType(), types.Converters...)
+}
+
+var List = validator.NewCapability(
+	ListAbility,
+	schema.DIDString(),
+	ListCaveatsReader,
+	listDerives,
+)
+
+func listDerives(claimed, delegated ucan.Capability[ListCaveats]) failure.Failure {
+	if claimed.With() != delegated.With() {
+		return failure.FromError(fmt.Errorf("can not derive %s with %s from %s", claimed.Can(), claimed.With(), delegated.With()))
+	}
+
+	if delegated.Nb().Period != nil {
+		if claimed.Nb().Period == nil {
+			return failure.FromError(fmt.Errorf("constraint violation: violates imposed period constraint %v because it doesn't have a period constraint", delegated.Nb().Period))
+		}
+		if claimed.Nb().Period.From.Before(delegated.Nb().Period.From) {
+			return failure.FromError(fmt.Errorf("constraint violation: violates imposed period constraint because it requests dates before %s", delegated.Nb().Period.From))
+		}
+		if claimed.Nb().Period.To.After(delegated.Nb().Period.To) {
+			return failure.FromError(fmt.Errorf("constraint violation: violates imposed period constraint because it requests dates after %s", delegated.Nb().Period.To))
+		}
+	}
+
+	return nil
+}
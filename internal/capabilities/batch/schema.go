@@ -0,0 +1,34 @@
+package batch
+
+import (
+	_ "embed"
+	"fmt"
+
+	"github.com/ipld/go-ipld-prime/schema"
+	captypes "github.com/storacha/go-libstoracha/capabilities/types"
+)
+
+//go:embed batch.ipldsch
+var batchSchema []byte
+
+var batchTS = mustLoadTS()
+
+func mustLoadTS() *schema.TypeSystem {
+	ts, err := captypes.LoadSchemaBytes(batchSchema)
+	if err != nil {
+		panic(fmt.Errorf("loading batch schema: %w", err))
+	}
+	return ts
+}
+
+func ListCaveatsType() schema.Type {
+	return batchTS.TypeByName("ListCaveats")
+}
+
+func ListOkType() schema.Type {
+	return batchTS.TypeByName("ListOk")
+}
+
+func ListErrorType() schema.Type {
+	return batchTS.TypeByName("ListError")
+}
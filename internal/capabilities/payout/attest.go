@@ -0,0 +1,102 @@
+package payout
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/ipld/go-ipld-prime/datamodel"
+	"github.com/storacha/go-libstoracha/capabilities/types"
+	"github.com/storacha/go-ucanto/core/ipld"
+	"github.com/storacha/go-ucanto/core/receipt"
+	"github.com/storacha/go-ucanto/core/result/failure"
+	"github.com/storacha/go-ucanto/core/schema"
+	"github.com/storacha/go-ucanto/did"
+	"github.com/storacha/go-ucanto/ucan"
+	"github.com/storacha/go-ucanto/validator"
+)
+
+// AttestAbility attests the egress a storage node is paid for in a month. The resource is the DID of the node.
+// It is invoked by the service on itself when a payout run is finalized, the receipt is the attestation.
+const AttestAbility = "egress/payout/attest"
+
+// AttestCaveats commits to the egress paid for in the month starting at `Month`.
+// `Causes` is the number of consolidate receipts the egress was counted from and `Root` the Merkle root of
+// their CIDs, see MerkleRoot.
+type AttestCaveats struct {
+	Month       time.Time
+	TotalEgress uint64
+	Causes      uint64
+	Root        []byte
+}
+
+func (ac AttestCaveats) ToIPLD() (datamodel.Node, error) {
+	return ipld.WrapWithRecovery(&ac, AttestCaveatsType(), types.Converters...)
+}
+
+var AttestCaveatsReader = schema.Struct[AttestCaveats](AttestCaveatsType(), nil, types.Converters...)
+
+// AttestOk repeats the commitment of the invocation along with the node it was made for, so that the
+// attestation can be checked from the receipt alone.
+type AttestOk struct {
+	Node        did.DID
+	Month       time.Time
+	TotalEgress uint64
+	Causes      uint64
+	Root        []byte
+}
+
+func (aok AttestOk) ToIPLD() (datamodel.Node, error) {
+	return ipld.WrapWithRecovery(&aok, AttestOkType(), types.Converters...)
+}
+
+var AttestOkReader = schema.Struct[AttestOk](AttestOkType(), nil, types.Converters...)
+
+type AttestError struct {
+	ErrorName string
+	Message   string
+}
+
+func NewAttestError(msg string) AttestError {
+	return AttestError{
+		ErrorName: "AttestError",
+		Message:   msg,
+	}
+}
+
+func (ae AttestError) Name() string {
+	return ae.ErrorName
+}
+
+func (ae AttestError) Error() string {
+	return ae.Message
+}
+
+func (ae AttestError) ToIPLD() (datamodel.Node, error) {
+	return ipld.WrapWithRecovery(&ae, AttestErrorType(), types.Converters...)
+}
+
+type AttestReceipt receipt.Receipt[AttestOk, AttestError]
+type AttestReceiptReader receipt.ReceiptReader[AttestOk, AttestError]
+
+func NewAttestReceiptReader() (AttestReceiptReader, error) {
+	return receipt.NewReceiptReaderFromTypes[AttestOk, AttestError](AttestOkType(), AttestErrorType(), types.Converters...)
+}
+
+var Attest = validator.NewCapability(
+	AttestAbility,
+	schema.DIDString(),
+	AttestCaveatsReader,
+	attestDerives,
+)
+
+func attestDerives(claimed, delegated ucan.Capability[AttestCaveats]) failure.Failure {
+	if claimed.With() != delegated.With() {
+		return failure.FromError(fmt.Errorf("can not derive %s with %s from %s", claimed.Can(), claimed.With(), delegated.With()))
+	}
+
+	if !claimed.Nb().Month.Equal(delegated.Nb().Month) {
+		return failure.FromError(fmt.Errorf("constraint violation: month %s violates imposed month constraint %s", claimed.Nb().Month, delegated.Nb().Month))
+	}
+
+	return nil
+}
@@ -0,0 +1,63 @@
+package payout
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"slices"
+
+	"github.com/storacha/go-ucanto/ucan"
+)
+
+const (
+	leafPrefix = 0x00
+	nodePrefix = 0x01
+)
+
+// MerkleRoot returns the root of the binary SHA-256 Merkle tree whose leaves are the binary CIDs of the
+// given consolidate receipts, sorted bytewise and without duplicates, so that the root does not depend on the
+// order the receipts are listed in.
+//
+// Leaves are hashed as sha256(0x00 || cid) and inner nodes as sha256(0x01 || left || right). The last node
+// of a level with an odd number of nodes is promoted to the next level as is. The root of no receipts is
+// sha256 of the empty string.
+func MerkleRoot(receipts []ucan.Link) []byte {
+	leaves := make([][]byte, 0, len(receipts))
+	for _, c := range receipts {
+		leaves = append(leaves, []byte(c.Binary()))
+	}
+	slices.SortFunc(leaves, bytes.Compare)
+	leaves = slices.CompactFunc(leaves, bytes.Equal)
+
+	if len(leaves) == 0 {
+		root := sha256.Sum256(nil)
+		return root[:]
+	}
+
+	level := make([][]byte, 0, len(leaves))
+	for _, leaf := range leaves {
+		level = append(level, hash(leafPrefix, leaf))
+	}
+
+	for len(level) > 1 {
+		next := make([][]byte, 0, (len(level)+1)/2)
+		for i := 0; i < len(level); i += 2 {
+			if i+1 == len(level) {
+				next = append(next, level[i])
+				continue
+			}
+			next = append(next, hash(nodePrefix, level[i], level[i+1]))
+		}
+		level = next
+	}
+
+	return level[0]
+}
+
+func hash(prefix byte, data ...[]byte) []byte {
+	h := sha256.New()
+	h.Write([]byte{prefix})
+	for _, d := range data {
+		h.Write(d)
+	}
+	return h.Sum(nil)
+}
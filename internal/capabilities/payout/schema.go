@@ -0,0 +1,34 @@
+package payout
+
+import (
+	_ "embed"
+	"fmt"
+
+	"github.com/ipld/go-ipld-prime/schema"
+	captypes "github.com/storacha/go-libstoracha/capabilities/types"
+)
+
+//go:embed payout.ipldsch
+var payoutSchema []byte
+
+var payoutTS = mustLoadTS()
+
+func mustLoadTS() *schema.TypeSystem {
+	ts, err := captypes.LoadSchemaBytes(payoutSchema)
+	if err != nil {
+		panic(fmt.Errorf("loading payout schema: %w", err))
+	}
+	return ts
+}
+
+func AttestCaveatsType() schema.Type {
+	return payoutTS.TypeByName("AttestCaveats")
+}
+
+func AttestOkType() schema.Type {
+	return payoutTS.TypeByName("AttestOk")
+}
+
+func AttestErrorType() schema.Type {
+	return payoutTS.TypeByName("AttestError")
+}
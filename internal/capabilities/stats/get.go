@@ -0,0 +1,123 @@
+package stats
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/ipld/go-ipld-prime/datamodel"
+	"github.com/storacha/go-libstoracha/capabilities/types"
+	"github.com/storacha/go-ucanto/core/ipld"
+	"github.com/storacha/go-ucanto/core/receipt"
+	"github.com/storacha/go-ucanto/core/result/failure"
+	"github.com/storacha/go-ucanto/core/schema"
+	"github.com/storacha/go-ucanto/ucan"
+	"github.com/storacha/go-ucanto/validator"
+)
+
+// GetAbility gets the consolidated egress of a storage node. The resource is the DID of the node.
+const GetAbility = "egress/stats/get"
+
+type Period struct {
+	From time.Time
+	To   time.Time
+}
+
+// GetCaveats optionally restricts the daily breakdown to a period.
+// A nil `Period` returns the breakdown from the first day of the previous month to now.
+type GetCaveats struct {
+	Period *Period
+}
+
+func (gc GetCaveats) ToIPLD() (datamodel.Node, error) {
+	return ipld.WrapWithRecovery(&gc, GetCaveatsType(), types.Converters...)
+}
+
+var GetCaveatsReader = schema.Struct[GetCaveats](GetCaveatsType(), nil, types.Converters...)
+
+type PeriodStats struct {
+	Egress uint64
+	Period Period
+}
+
+type DailyStats struct {
+	Date   time.Time
+	Egress uint64
+}
+
+// GetOk contains the totals shown on the admin dashboard for the node, plus the total and daily egress
+// consolidated in the requested period.
+type GetOk struct {
+	PreviousMonth PeriodStats
+	CurrentMonth  PeriodStats
+	CurrentWeek   PeriodStats
+	CurrentDay    PeriodStats
+	Period        Period
+	Total         uint64
+	DailyStats    []DailyStats
+}
+
+func (gok GetOk) ToIPLD() (datamodel.Node, error) {
+	return ipld.WrapWithRecovery(&gok, GetOkType(), types.Converters...)
+}
+
+var GetOkReader = schema.Struct[GetOk](GetOkType(), nil, types.Converters...)
+
+type GetError struct {
+	ErrorName string
+	Message   string
+}
+
+const PeriodNotAcceptableErrorName = "PeriodNotAcceptable"
+
+func NewPeriodNotAcceptableError(msg string) GetError {
+	return GetError{
+		ErrorName: PeriodNotAcceptableErrorName,
+		Message:   msg,
+	}
+}
+
+func (ge GetError) Name() string {
+	return ge.ErrorName
+}
+
+func (ge GetError) Error() string {
+	return ge.Message
+}
+
+func (ge GetError) ToIPLD() (datamodel.Node, error) {
+	return ipld.WrapWithRecovery(&ge, GetErrorType(), types.Converters...)
+}
+
+type GetReceipt receipt.Receipt[GetOk, GetError]
+type GetReceiptReader receipt.ReceiptReader[GetOk, GetError]
+
+func NewGetReceiptReader() (GetReceiptReader, error) {
+	return receipt.NewReceiptReaderFromTypes[GetOk, GetError](GetOkType(), GetErrorType(), types.Converters...)
+}
+
+var Get = validator.NewCapability(
+	GetAbility,
+	schema.DIDString(),
+	GetCaveatsReader,
+	getDerives,
+)
+
+func getDerives(claimed, delegated ucan.Capability[GetCaveats]) failure.Failure {
+	if claimed.With() != delegated.With() {
+		return failure.FromError(fmt.Errorf("can not derive %s with %s from %s", claimed.Can(), claimed.With(), delegated.With()))
+	}
+
+	if delegated.Nb().Period != nil {
+		if claimed.Nb().Period == nil {
+			return failure.FromError(fmt.Errorf("constraint violation: violates imposed period constraint %v because it doesn't have a period constraint", delegated.Nb().Period))
+		}
+		if claimed.Nb().Period.From.Before(delegated.Nb().Period.From) {
+			return failure.FromError(fmt.Errorf("constraint violation: violates imposed period constraint because it requests dates before %s", delegated.Nb().Period.From))
+		}
+		if claimed.Nb().Period.To.After(delegated.Nb().Period.To) {
+			return failure.FromError(fmt.Errorf("constraint violation: violates imposed period constraint because it requests dates after %s", delegated.Nb().Period.To))
+		}
+	}
+
+	return nil
+}
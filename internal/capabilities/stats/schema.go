@@ -0,0 +1,34 @@
+package stats
+
+import (
+	_ "embed"
+	"fmt"
+
+	"github.com/ipld/go-ipld-prime/schema"
+	captypes "github.com/storacha/go-libstoracha/capabilities/types"
+)
+
+//go:embed stats.ipldsch
+var statsSchema []byte
+
+var statsTS = mustLoadTS()
+
+func mustLoadTS() *schema.TypeSystem {
+	ts, err := captypes.LoadSchemaBytes(statsSchema)
+	if err != nil {
+		panic(fmt.Errorf("loading stats schema: %w", err))
+	}
+	return ts
+}
+
+func GetCaveatsType() schema.Type {
+	return statsTS.TypeByName("GetCaveats")
+}
+
+func GetOkType() schema.Type {
+	return statsTS.TypeByName("GetOk")
+}
+
+func GetErrorType() schema.Type {
+	return statsTS.TypeByName("GetError")
+}
@@ -10,33 +10,77 @@ import (
 )
 
 type Config struct {
-	Port                           int        `mapstructure:"port" validate:"required,min=1,max=65535"`
-	PrivateKey                     string     `mapstructure:"private_key" validate:"required"`
-	DID                            string     `mapstructure:"did" validate:"startswith=did:web:"`
-	MetricsEnvironment             string     `mapstructure:"metrics_environment"`
-	MetricsAuthToken               string     `mapstructure:"metrics_auth_token"`
-	AdminDashboardUser             string     `mapstructure:"admin_dashboard_user"`
-	AdminDashboardPassword         string     `mapstructure:"admin_dashboard_password"`
-	ClientEgressUSDPerTiB          float64    `mapstructure:"client_egress_usd_per_tib"`
-	ProviderEgressUSDPerTiB        float64    `mapstructure:"provider_egress_usd_per_tib"`
-	AWSConfig                      aws.Config `mapstructure:"aws_config"`
-	EgressTableName                string     `mapstructure:"egress_table_name" validate:"required"`
-	EgressUnprocessedIndexName     string     `mapstructure:"egress_unprocessed_index_name" validate:"required"`
-	ConsolidatedTableName          string     `mapstructure:"consolidated_table_name" validate:"required"`
-	ConsolidatedNodeStatsIndexName string     `mapstructure:"consolidated_node_stats_index_name" validate:"required"`
-	ConsolidationInterval          int        `mapstructure:"consolidation_interval" validate:"min=300"`
-	ConsolidationBatchSize         int        `mapstructure:"consolidation_batch_size" validate:"min=1"`
-	SpaceStatsTableName            string     `mapstructure:"space_stats_table_name" validate:"required"`
-	StorageProviderTableName       string     `mapstructure:"storage_provider_table_name" validate:"required"`
-	StorageProviderTableRegion     string     `mapstructure:"storage_provider_table_region" validate:"required"`
-	CustomerTableName              string     `mapstructure:"customer_table_name" validate:"required"`
-	CustomerTableRegion            string     `mapstructure:"customer_table_region" validate:"required"`
-	ConsumerTableName              string     `mapstructure:"consumer_table_name" validate:"required"`
-	ConsumerTableRegion            string     `mapstructure:"consumer_table_region" validate:"required"`
-	ConsumerConsumerIndexName      string     `mapstructure:"consumer_consumer_index_name" validate:"required"`
-	ConsumerCustomerIndexName      string     `mapstructure:"consumer_customer_index_name" validate:"required"`
-	KnownProviders                 []string   `mapstructure:"known_providers" validate:"dive,startswith=did:web:"`
-	TrustedAuthorities             []string   `mapstructure:"trusted_authorities" validate:"dive,startswith=did:web:"`
+	Port                            int        `mapstructure:"port" validate:"required,min=1,max=65535"`
+	PrivateKey                      string     `mapstructure:"private_key" validate:"required"`
+	DID                             string     `mapstructure:"did" validate:"startswith=did:web:"`
+	MetricsEnvironment              string     `mapstructure:"metrics_environment"`
+	MetricsAuthToken                string     `mapstructure:"metrics_auth_token"`
+	AdminDashboardUser              string     `mapstructure:"admin_dashboard_user"`
+	AdminDashboardPassword          string     `mapstructure:"admin_dashboard_password"`
+	ClientEgressUSDPerTiB           float64    `mapstructure:"client_egress_usd_per_tib"`
+	ProviderEgressUSDPerTiB         float64    `mapstructure:"provider_egress_usd_per_tib"`
+	ClientEgressPricing             string     `mapstructure:"client_egress_pricing"`
+	ProviderEgressRates             string     `mapstructure:"provider_egress_rates"`
+	EgressQuotas                    string     `mapstructure:"egress_quotas"`
+	EgressQuotaWebhookURL           string     `mapstructure:"egress_quota_webhook_url"`
+	AWSConfig                       aws.Config `mapstructure:"aws_config"`
+	EgressTableName                 string     `mapstructure:"egress_table_name" validate:"required"`
+	EgressUnprocessedIndexName      string     `mapstructure:"egress_unprocessed_index_name" validate:"required"`
+	EgressQueueIndexName            string     `mapstructure:"egress_queue_index_name" validate:"required"`
+	EgressCauseIndexName            string     `mapstructure:"egress_cause_index_name" validate:"required"`
+	EgressNodeIndexName             string     `mapstructure:"egress_node_index_name" validate:"required"`
+	ConsolidatedTableName           string     `mapstructure:"consolidated_table_name" validate:"required"`
+	ConsolidatedNodeStatsIndexName  string     `mapstructure:"consolidated_node_stats_index_name" validate:"required"`
+	ConsolidatedHeldIndexName       string     `mapstructure:"consolidated_held_index_name" validate:"required"`
+	ConsolidatedReviewedIndexName   string     `mapstructure:"consolidated_reviewed_index_name" validate:"required"`
+	ConsolidatedTrackCauseIndexName string     `mapstructure:"consolidated_track_cause_index_name" validate:"required"`
+	ConsolidatedBatchIndexName      string     `mapstructure:"consolidated_batch_index_name" validate:"required"`
+	ConsolidationInterval           int        `mapstructure:"consolidation_interval" validate:"min=300"`
+	ConsolidationBatchSize          int        `mapstructure:"consolidation_batch_size" validate:"min=1"`
+	ConsolidationMaxRangeLength     uint64     `mapstructure:"consolidation_max_range_length" validate:"min=1"`
+	SpaceStatsTableName             string     `mapstructure:"space_stats_table_name" validate:"required"`
+	SpaceHourlyStatsTableName       string     `mapstructure:"space_hourly_stats_table_name"`
+	HourlyStatsRetentionDays        int        `mapstructure:"hourly_stats_retention_days" validate:"min=1"`
+	ExcludedSpaceStatsTableName     string     `mapstructure:"excluded_space_stats_table_name"`
+	NetworkStatsTableName           string     `mapstructure:"network_stats_table_name"`
+	AccountStatsTableName           string     `mapstructure:"account_stats_table_name"`
+	AccountStatsReconcileInterval   int        `mapstructure:"account_stats_reconcile_interval" validate:"min=300"`
+	AccountStatsReconcileDays       int        `mapstructure:"account_stats_reconcile_days" validate:"min=1"`
+	StatementTableName              string     `mapstructure:"statement_table_name"`
+	BillingInterval                 int        `mapstructure:"billing_interval" validate:"min=300"`
+	BillingCloseDelayHours          int        `mapstructure:"billing_close_delay_hours" validate:"min=0"`
+	BillingAdjustmentMonths         int        `mapstructure:"billing_adjustment_months" validate:"min=0"`
+	PayoutTableName                 string     `mapstructure:"payout_table_name"`
+	AttestationTableName            string     `mapstructure:"attestation_table_name"`
+	AttestationCauseIndexName       string     `mapstructure:"attestation_cause_index_name" validate:"required_with=AttestationTableName"`
+	LedgerTableName                 string     `mapstructure:"ledger_table_name"`
+	StorageProviderTableName        string     `mapstructure:"storage_provider_table_name" validate:"required"`
+	StorageProviderTableRegion      string     `mapstructure:"storage_provider_table_region" validate:"required"`
+	CustomerTableName               string     `mapstructure:"customer_table_name" validate:"required"`
+	CustomerTableRegion             string     `mapstructure:"customer_table_region" validate:"required"`
+	ConsumerTableName               string     `mapstructure:"consumer_table_name" validate:"required"`
+	ConsumerTableRegion             string     `mapstructure:"consumer_table_region" validate:"required"`
+	ConsumerConsumerIndexName       string     `mapstructure:"consumer_consumer_index_name" validate:"required"`
+	ConsumerCustomerIndexName       string     `mapstructure:"consumer_customer_index_name" validate:"required"`
+	AnomalyTableName                string     `mapstructure:"anomaly_table_name"`
+	AnomalyNodeIndexName            string     `mapstructure:"anomaly_node_index_name" validate:"required_with=AnomalyTableName"`
+	AnomalyBaselineDays             int        `mapstructure:"anomaly_baseline_days" validate:"min=1"`
+	AnomalyMinBaselineDays          int        `mapstructure:"anomaly_min_baseline_days" validate:"min=1,ltefield=AnomalyBaselineDays"`
+	AnomalySpikeStdDevs             float64    `mapstructure:"anomaly_spike_stddevs" validate:"gt=0"`
+	AnomalyMinNodeEgress            uint64     `mapstructure:"anomaly_min_node_egress"`
+	AnomalyMaxSpaceShare            float64    `mapstructure:"anomaly_max_space_share" validate:"gt=0,lte=1"`
+	AnomalyMaxIdenticalRanges       int        `mapstructure:"anomaly_max_identical_ranges" validate:"min=1"`
+	HoldNewNodeDays                 int        `mapstructure:"hold_new_node_days" validate:"min=0"`
+	HoldMaxBatchEgress              uint64     `mapstructure:"hold_max_batch_egress"`
+	HoldAnomalyWindowHours          int        `mapstructure:"hold_anomaly_window_hours" validate:"min=0"`
+	CallbackTableName               string     `mapstructure:"callback_table_name"`
+	CallbackDeliveryTableName       string     `mapstructure:"callback_delivery_table_name"`
+	CallbackPendingIndexName        string     `mapstructure:"callback_pending_index_name" validate:"required_with=CallbackDeliveryTableName"`
+	CallbackMaxAttempts             int        `mapstructure:"callback_max_attempts" validate:"min=1"`
+	CallbackInitialBackoff          int        `mapstructure:"callback_initial_backoff" validate:"min=1"`
+	KnownProviders                  []string   `mapstructure:"known_providers" validate:"dive,startswith=did:web:"`
+	TrustedAuthorities              []string   `mapstructure:"trusted_authorities" validate:"dive,startswith=did:web:"`
+	ExcludedSpaces                  []string   `mapstructure:"excluded_spaces" validate:"dive,startswith=did:"`
 }
 
 func Load(ctx context.Context) (*Config, error) {
@@ -2,6 +2,7 @@ package consolidator
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"iter"
 	"net/http"
@@ -32,22 +33,49 @@ import (
 	"go.opentelemetry.io/otel/attribute"
 	"go.opentelemetry.io/otel/metric"
 
+	"github.com/storacha/etracker/internal/anomaly"
+	"github.com/storacha/etracker/internal/callback"
+	"github.com/storacha/etracker/internal/db/accountstats"
 	"github.com/storacha/etracker/internal/db/consolidated"
 	"github.com/storacha/etracker/internal/db/consumer"
 	"github.com/storacha/etracker/internal/db/egress"
+	"github.com/storacha/etracker/internal/db/hourlystats"
+	"github.com/storacha/etracker/internal/db/ledger"
+	"github.com/storacha/etracker/internal/db/networkstats"
 	"github.com/storacha/etracker/internal/db/spacestats"
+	"github.com/storacha/etracker/internal/exclusion"
 	"github.com/storacha/etracker/internal/metrics"
+	"github.com/storacha/etracker/internal/pricing"
+	"github.com/storacha/etracker/internal/quota"
+	"github.com/storacha/etracker/internal/rollup"
 )
 
 var log = logging.Logger("consolidator")
 
 var ErrNotFound = consolidated.ErrNotFound
 
+const (
+	// unprocessedPageSize is the number of unprocessed records fetched per request to the egress table
+	unprocessedPageSize = 1000
+	// unprocessedWindow bounds the number of unprocessed records a cycle selects batches from
+	unprocessedWindow = 10 * unprocessedPageSize
+	// DefaultMaxRangeLength is the maximum length in bytes of the range of a retrieval receipt unless configured
+	// otherwise
+	DefaultMaxRangeLength = 4 << 30
+)
+
+// ErrPendingReview is returned when the batch was consolidated but is held for review
+var ErrPendingReview = errors.New("batch is pending review")
+
 type Consolidator struct {
 	id                    principal.Signer
 	egressTable           egress.EgressTable
 	consolidatedTable     consolidated.ConsolidatedTable
 	spaceStatsTable       spacestats.SpaceStatsTable
+	hourlyStatsTable      hourlystats.HourlyStatsTable
+	accountStatsTable     accountstats.AccountStatsTable
+	networkStatsTable     networkstats.NetworkStatsTable
+	ledgerTable           ledger.LedgerTable
 	consumerTable         consumer.ConsumerTable
 	knownProviders        []string
 	ucantoSrv             ucanto.ServerView[ucanto.Service]
@@ -55,9 +83,95 @@ type Consolidator struct {
 	httpClient            *http.Client
 	interval              time.Duration
 	batchSize             int
+	detector              *anomaly.Detector
+	notifier              *callback.Notifier
+	quotaMonitor          *quota.Monitor
+	exclusions            *exclusion.List
+	pricer                *pricing.Engine
+	holdRules             holdRules
+	maxRangeLength        uint64
 	stopCh                chan struct{}
 }
 
+type Option func(*Consolidator)
+
+// WithAnomalyDetector feeds consolidated retrievals to the given detector and runs it after each consolidation cycle.
+func WithAnomalyDetector(detector *anomaly.Detector) Option {
+	return func(c *Consolidator) {
+		c.detector = detector
+	}
+}
+
+// WithNotifier pushes the receipts of consolidated batches to the callbacks of the nodes that tracked them.
+func WithNotifier(notifier *callback.Notifier) Option {
+	return func(c *Consolidator) {
+		c.notifier = notifier
+	}
+}
+
+// WithHourlyStats records the egress of spaces per hour alongside the daily stats.
+func WithHourlyStats(hourlyStatsTable hourlystats.HourlyStatsTable) Option {
+	return func(c *Consolidator) {
+		c.hourlyStatsTable = hourlyStatsTable
+	}
+}
+
+// WithAccountStats records the egress of the accounts owning the spaces per day alongside the space stats.
+func WithAccountStats(accountStatsTable accountstats.AccountStatsTable) Option {
+	return func(c *Consolidator) {
+		c.accountStatsTable = accountStatsTable
+	}
+}
+
+// WithNetworkStats records the egress of the upload services (networks) the spaces were provisioned through per
+// day alongside the space stats.
+func WithNetworkStats(networkStatsTable networkstats.NetworkStatsTable) Option {
+	return func(c *Consolidator) {
+		c.networkStatsTable = networkStatsTable
+	}
+}
+
+// WithLedger posts the credit of every consolidated batch, held or not, to the egress ledger.
+func WithLedger(ledgerTable ledger.LedgerTable) Option {
+	return func(c *Consolidator) {
+		c.ledgerTable = ledgerTable
+	}
+}
+
+// WithQuotaMonitor feeds the egress recorded for accounts to the given monitor and runs it after each consolidation
+// cycle. It has no effect unless account stats are recorded.
+func WithQuotaMonitor(monitor *quota.Monitor) Option {
+	return func(c *Consolidator) {
+		c.quotaMonitor = monitor
+	}
+}
+
+// WithExclusions leaves the egress of the listed spaces out of the consolidated egress of batches, so that it is
+// neither billed nor paid for, and records it in the stats table of the list instead.
+func WithExclusions(exclusions *exclusion.List) Option {
+	return func(c *Consolidator) {
+		c.exclusions = exclusions
+	}
+}
+
+// WithMaxRangeLength rejects retrieval receipts claiming ranges longer than maxLength bytes, which can't have
+// been served from any blob. Zero keeps DefaultMaxRangeLength.
+func WithMaxRangeLength(maxLength uint64) Option {
+	return func(c *Consolidator) {
+		if maxLength > 0 {
+			c.maxRangeLength = maxLength
+		}
+	}
+}
+
+// WithPricing attributes the egress recorded for accounts to the pricing plans of the spaces it was served for.
+// Without it, all of it is attributed to the default plan.
+func WithPricing(pricer *pricing.Engine) Option {
+	return func(c *Consolidator) {
+		c.pricer = pricer
+	}
+}
+
 func New(
 	id principal.Signer,
 	egressTable egress.EgressTable,
@@ -69,6 +183,7 @@ func New(
 	batchSize int,
 	presolver validator.PrincipalResolverFunc,
 	authProofs []delegation.Delegation,
+	opts ...Option,
 ) (*Consolidator, error) {
 	retrieveValidationCtx := validator.NewValidationContext(
 		id.Verifier(),
@@ -98,9 +213,14 @@ func New(
 		httpClient:            &http.Client{Timeout: 30 * time.Second},
 		interval:              interval,
 		batchSize:             batchSize,
+		maxRangeLength:        DefaultMaxRangeLength,
 		stopCh:                make(chan struct{}),
 	}
 
+	for _, opt := range opts {
+		opt(c)
+	}
+
 	ucantoSrv, err := ucanto.NewServer(
 		id,
 		ucanto.WithServiceMethod(capegress.ConsolidateAbility, ucanto.Provide(capegress.Consolidate, c.ucanConsolidateHandler)),
@@ -149,106 +269,472 @@ func (c *Consolidator) Consolidate(ctx context.Context) error {
 		metrics.ConsolidationRunDuration.Record(ctx, durationMs)
 	}()
 
-	// Get unprocessed records
-	records, err := c.egressTable.GetUnprocessed(ctx, c.batchSize)
+	unprocessed, err := c.listUnprocessed(ctx)
 	if err != nil {
-		return fmt.Errorf("fetching unprocessed records: %w", err)
+		return err
 	}
 
-	if len(records) == 0 {
+	// the window holds the oldest batches, batches outside of it are considered in the next cycles
+	oldest, ok := oldestWaitingSince(unprocessed)
+	if !ok {
+		metrics.OldestUnprocessedBatchAge.Record(ctx, 0)
 		log.Info("No unprocessed records found")
+		// batches consolidated by external invocations since the previous cycle may still need evaluating
+		c.runQuotaMonitor(ctx)
 		return nil
 	}
+	metrics.OldestUnprocessedBatchAge.Record(ctx, int64(time.Since(oldest).Seconds()))
+
+	records := selectBatches(unprocessed, c.batchSize)
 
-	log.Infof("Processing %d unprocessed records", len(records))
+	log.Infof("Processing %d of %d listed unprocessed records", len(records), len(unprocessed))
 
 	// Process each record (each record represents a batch of receipts for a single node)
-	successfulRecords := make([]egress.EgressRecord, 0, len(records))
+	results := make([]batchResult, 0, len(records))
+	processed := 0
 	for _, record := range records {
-		var rcpt capegress.ConsolidateReceipt
-		totalEgress := uint64(0)
-
-		bLog := log.With("node", record.Node, "batch", record.Batch.String())
-
-		// According to the spec, consolidation happens as a result of a `space/egress/consolidate` invocation.
-		// We use the consolidator's own ucanto server to invoke the consolidate capability on itself.
-		consolidateInv, err := capegress.Consolidate.Invoke(
-			c.id,
-			c.id,
-			c.id.DID().String(),
-			capegress.ConsolidateCaveats{
-				Cause: record.Cause.Link(),
-			},
-			delegation.WithNoExpiration(),
-		)
+		rLog := log.With("node", record.Node, "batch", record.Batch.String())
+
+		// A previous cycle may have stored the consolidated record and been interrupted before the batch
+		// was fully counted and marked as processed, finish it instead of consolidating it again
+		recovered, err := c.recoverBatch(ctx, record)
 		if err != nil {
-			bLog.Errorf("generating consolidation invocation: %v", err)
+			rLog.Errorf("Failed to recover consolidated batch: %v", err)
 			continue
 		}
-
-		var attachErr error
-		for blk, err := range record.Cause.Blocks() {
-			if err != nil {
-				attachErr = err
-				break
+		if recovered {
+			if err := c.markAsProcessed(ctx, record); err != nil {
+				rLog.Error(err)
+				continue
 			}
+			rLog.Info("Recovered consolidated batch")
+			processed++
+			continue
+		}
 
-			if err := consolidateInv.Attach(blk); err != nil {
-				attachErr = err
-				break
-			}
+		res, err := c.consolidateBatch(ctx, record)
+		if err != nil {
+			rLog.Error(err)
+			continue
+		}
+
+		results = append(results, *res)
+	}
+
+	// Run anomaly detection before committing results so that batches from flagged nodes can be held for review
+	c.runDetector(ctx)
+
+	for _, res := range results {
+		rLog := log.With("node", res.record.Node, "batch", res.record.Batch.String())
+
+		err := c.commitBatch(ctx, res)
+		if errors.Is(err, consolidated.ErrAlreadyExists) {
+			// The batch was consolidated concurrently by an external invocation, make sure it is counted
+			_, err = c.recoverBatch(ctx, res.record)
+		}
+		if err != nil {
+			// The batch stays unprocessed and is recovered on the next cycle
+			rLog.Errorf("Failed to commit consolidation result: %v", err)
+			continue
 		}
-		if attachErr != nil {
-			bLog.Errorf("attaching blocks to consolidation invocation: %v", attachErr)
+
+		if err := c.markAsProcessed(ctx, res.record); err != nil {
+			rLog.Error(err)
 			continue
 		}
+		processed++
+	}
+
+	c.runQuotaMonitor(ctx)
+
+	log.Infof("Consolidation cycle completed. Processed %d records (%d successful)", len(records), processed)
+
+	return nil
+}
+
+func (c *Consolidator) runDetector(ctx context.Context) {
+	if c.detector == nil {
+		return
+	}
+	flags, err := c.detector.Run(ctx)
+	if err != nil {
+		log.Errorf("Anomaly detection error: %v", err)
+	}
+	if len(flags) > 0 {
+		log.Warnf("Anomaly detection raised %d flags", len(flags))
+	}
+}
+
+func (c *Consolidator) runQuotaMonitor(ctx context.Context) {
+	if c.quotaMonitor == nil {
+		return
+	}
+	events, err := c.quotaMonitor.Run(ctx)
+	if err != nil {
+		log.Errorf("Quota evaluation error: %v", err)
+	}
+	if len(events) > 0 {
+		log.Warnf("Accounts crossed %d egress quota thresholds", len(events))
+	}
+}
+
+// ConsolidateCause consolidates the batch tracked by the given `space/egress/track` invocation right away,
+// instead of waiting for the next consolidation cycle. Batches are only consolidated once: if the batch was
+// consolidated already, the existing receipt is returned.
+// It returns ErrNotFound if no batch was tracked by cause and ErrPendingReview if the batch is held for review.
+func (c *Consolidator) ConsolidateCause(ctx context.Context, cause ucan.Link) (capegress.ConsolidateReceipt, error) {
+	// The consolidator's own consolidate invocation for a cause is deterministic, and so is the key of the consolidated record
+	consolidateInv, err := c.newConsolidateInvocation(cause)
+	if err != nil {
+		return nil, fmt.Errorf("generating consolidation invocation: %w", err)
+	}
+
+	rcpt, err := c.getConsolidateReceipt(ctx, consolidateInv.Link())
+	if !errors.Is(err, ErrNotFound) {
+		return rcpt, err
+	}
+
+	record, err := c.egressTable.GetByCause(ctx, cause)
+	if err != nil {
+		if errors.Is(err, egress.ErrNotFound) {
+			return nil, ErrNotFound
+		}
+		return nil, fmt.Errorf("fetching egress record: %w", err)
+	}
+
+	res, err := c.consolidateBatch(ctx, *record)
+	if err != nil {
+		return nil, err
+	}
+
+	err = c.commitBatch(ctx, *res)
+	switch {
+	case errors.Is(err, consolidated.ErrAlreadyExists):
+		// consolidated concurrently, whoever committed first marks the record as processed
+	case err != nil:
+		return nil, fmt.Errorf("committing consolidation result: %w", err)
+	default:
+		if err := c.markAsProcessed(ctx, *record); err != nil {
+			return nil, err
+		}
+	}
+
+	return c.getConsolidateReceipt(ctx, consolidateInv.Link())
+}
 
-		rcpt, err = c.execConsolidateInvocation(ctx, consolidateInv)
+func (c *Consolidator) newConsolidateInvocation(cause ucan.Link) (invocation.IssuedInvocation, error) {
+	return capegress.Consolidate.Invoke(
+		c.id,
+		c.id,
+		c.id.DID().String(),
+		capegress.ConsolidateCaveats{
+			Cause: cause,
+		},
+		delegation.WithNoExpiration(),
+	)
+}
+
+// getByOwnConsolidateInvocation looks up the record of the batch tracked by the given track invocation under the
+// key of the consolidator's own consolidate invocation for it. Batches consolidated before their records stored
+// the track invocation can only be found by it this way.
+func (c *Consolidator) getByOwnConsolidateInvocation(ctx context.Context, trackCause ucan.Link) (*consolidated.ConsolidatedRecord, error) {
+	consolidateInv, err := c.newConsolidateInvocation(trackCause)
+	if err != nil {
+		return nil, fmt.Errorf("generating consolidation invocation: %w", err)
+	}
+
+	return c.consolidatedTable.Get(ctx, consolidateInv.Link())
+}
+
+// getConsolidateReceipt returns the receipt stored for the given consolidate invocation
+func (c *Consolidator) getConsolidateReceipt(ctx context.Context, consolidateInv ucan.Link) (capegress.ConsolidateReceipt, error) {
+	anyRcpt, err := c.GetReceipt(ctx, consolidateInv)
+	if err != nil {
+		return nil, err
+	}
+
+	reader, err := capegress.NewConsolidateReceiptReader()
+	if err != nil {
+		return nil, fmt.Errorf("constructing receipt reader: %w", err)
+	}
+
+	return reader.Read(anyRcpt.Root().Link(), anyRcpt.Blocks())
+}
+
+// batchResult is the outcome of consolidating a single batch, pending to be committed
+type batchResult struct {
+	record      egress.EgressRecord
+	inv         invocation.Invocation
+	rcpt        capegress.ConsolidateReceipt
+	totalEgress uint64
+	spaceEgress map[did.DID]uint64
+	// excludedEgress is the egress of excluded spaces, which is not part of totalEgress
+	excludedEgress map[did.DID]uint64
+}
+
+// consolidateBatch invokes `space/egress/consolidate` for the given record and returns the result.
+// No egress is counted until the result is committed.
+func (c *Consolidator) consolidateBatch(ctx context.Context, record egress.EgressRecord) (*batchResult, error) {
+	bLog := log.With("node", record.Node, "batch", record.Batch.String())
+
+	// According to the spec, consolidation happens as a result of a `space/egress/consolidate` invocation.
+	// We use the consolidator's own ucanto server to invoke the consolidate capability on itself.
+	consolidateInv, err := c.newConsolidateInvocation(record.Cause.Link())
+	if err != nil {
+		return nil, fmt.Errorf("generating consolidation invocation: %w", err)
+	}
+
+	for blk, err := range record.Cause.Blocks() {
 		if err != nil {
-			bLog.Errorf("executing consolidation invocation: %v", err)
+			return nil, fmt.Errorf("attaching blocks to consolidation invocation: %w", err)
+		}
 
-			rcpt, err = c.issueErrorReceipt(consolidateInv, capegress.NewConsolidateError(err.Error()))
-			if err != nil {
-				bLog.Errorf("issuing error receipt: %v", err)
-				continue
+		if err := consolidateInv.Attach(blk); err != nil {
+			return nil, fmt.Errorf("attaching blocks to consolidation invocation: %w", err)
+		}
+	}
+
+	// the handler reports the egress of each space through the context
+	spaceEgress := make(map[did.DID]uint64)
+	excludedEgress := make(map[did.DID]uint64)
+	rcpt, err := c.execConsolidateInvocation(withExcludedEgress(withSpaceEgress(ctx, spaceEgress), excludedEgress), consolidateInv)
+	if err != nil {
+		bLog.Errorf("executing consolidation invocation: %v", err)
+
+		rcpt, err = c.issueErrorReceipt(consolidateInv, capegress.NewConsolidateError(err.Error()))
+		if err != nil {
+			return nil, fmt.Errorf("issuing error receipt: %w", err)
+		}
+	}
+
+	res := &batchResult{
+		record: record,
+		inv:    consolidateInv,
+		rcpt:   rcpt,
+	}
+
+	o, x := result.Unwrap(rcpt.Out())
+	var emptyErr capegress.ConsolidateError
+	if x != emptyErr {
+		bLog.Errorf("consolidation error: %s", x.Message)
+	} else {
+		res.totalEgress = o.TotalEgress
+		res.spaceEgress = spaceEgress
+		res.excludedEgress = excludedEgress
+	}
+
+	return res, nil
+}
+
+// commitBatch stores the consolidated record for a batch and counts its egress, unless the batch
+// must be held for review. Held batches are counted when an admin approves them.
+func (c *Consolidator) commitBatch(ctx context.Context, res batchResult) error {
+	bLog := log.With("node", res.record.Node, "batch", res.record.Batch.String())
+
+	now := time.Now().UTC()
+
+	// Excluded egress is recorded before the consolidated record is stored, which is what marks the batch as
+	// consolidated, recording it again when the batch is retried is a no-op
+	if err := c.recordExcludedEgress(ctx, res.inv.Link(), res.excludedEgress, now); err != nil {
+		return fmt.Errorf("recording excluded egress: %w", err)
+	}
+
+	reasons, err := c.holdReasons(ctx, res.record.Node, res.totalEgress, now)
+	if err != nil {
+		return fmt.Errorf("evaluating hold rules: %w", err)
+	}
+
+	if len(reasons) > 0 {
+		if err := c.consolidatedTable.Hold(ctx, res.inv.Link(), res.record.Cause.Link(), res.record.Batch, res.record.Node, res.totalEgress, res.rcpt, reasons, res.spaceEgress); err != nil {
+			return fmt.Errorf("adding held consolidated record: %w", err)
+		}
+
+		if err := c.postCredit(ctx, res.inv.Link(), res.record.Node, res.totalEgress, res.spaceEgress, now); err != nil {
+			return fmt.Errorf("posting ledger credit: %w", err)
+		}
+
+		nodeAttr := attribute.String("node", res.record.Node.String())
+		metrics.HeldBatchesPerNode.Add(ctx, 1, metric.WithAttributeSet(attribute.NewSet(nodeAttr)))
+
+		bLog.Warnw("Held batch for review", "egress", res.totalEgress, "reasons", reasons)
+		return nil
+	}
+
+	// Store consolidated record (one per batch). It is the source of node stats, and keeps the egress of each
+	// space so that it can be counted again if counting is interrupted.
+	if err := c.consolidatedTable.Add(ctx, res.inv.Link(), res.record.Cause.Link(), res.record.Batch, res.record.Node, res.totalEgress, res.rcpt, res.spaceEgress); err != nil {
+		return fmt.Errorf("adding consolidated record: %w", err)
+	}
+
+	if err := c.countSpaceEgress(ctx, res.inv.Link(), res.spaceEgress, now); err != nil {
+		return fmt.Errorf("counting space egress: %w", err)
+	}
+
+	if err := c.postCredit(ctx, res.inv.Link(), res.record.Node, res.totalEgress, res.spaceEgress, now); err != nil {
+		return fmt.Errorf("posting ledger credit: %w", err)
+	}
+
+	// Increment consolidated bytes counter for this node
+	nodeAttr := attribute.String("node", res.record.Node.String())
+	metrics.ConsolidatedBytesPerNode.Add(ctx, int64(res.totalEgress), metric.WithAttributeSet(attribute.NewSet(nodeAttr)))
+
+	bLog.Infof("Consolidated %d bytes", res.totalEgress)
+
+	if c.notifier != nil {
+		if err := c.notifier.Notify(ctx, res.record.Node, res.record.Cause, res.rcpt); err != nil {
+			bLog.Errorf("Failed to notify consolidation result: %v", err)
+		}
+	}
+
+	return nil
+}
+
+// recoverBatch counts the egress of the batch of the record if it was consolidated already. Counting is
+// idempotent, so egress already counted before is not counted twice. It returns false if there is no
+// consolidated record for the batch yet.
+func (c *Consolidator) recoverBatch(ctx context.Context, record egress.EgressRecord) (bool, error) {
+	consolidateInv, err := c.newConsolidateInvocation(record.Cause.Link())
+	if err != nil {
+		return false, fmt.Errorf("generating consolidation invocation: %w", err)
+	}
+
+	existing, err := c.consolidatedTable.Get(ctx, consolidateInv.Link())
+	if err != nil {
+		if errors.Is(err, consolidated.ErrNotFound) {
+			return false, nil
+		}
+		return false, fmt.Errorf("fetching consolidated record: %w", err)
+	}
+
+	// held batches are credited to the ledger too, rejecting them reverses the credit
+	totalEgress := existing.TotalEgress
+	if existing.Status != consolidated.StatusCounted {
+		totalEgress = existing.HeldEgress
+	}
+	if err := c.postCredit(ctx, existing.Cause, existing.Node, totalEgress, existing.SpaceEgress, existing.ProcessedAt); err != nil {
+		return false, fmt.Errorf("posting ledger credit: %w", err)
+	}
+
+	// held batches are counted on approval, rejected ones never
+	if existing.Status != consolidated.StatusCounted {
+		return true, nil
+	}
+
+	if err := c.countSpaceEgress(ctx, existing.Cause, existing.SpaceEgress, existing.ProcessedAt); err != nil {
+		return false, fmt.Errorf("counting space egress: %w", err)
+	}
+
+	// counting may have been interrupted before the receipt was pushed, receipts are delivered at least once
+	if c.notifier != nil && existing.Receipt != nil {
+		if err := c.notifier.Notify(ctx, existing.Node, record.Cause, existing.Receipt); err != nil {
+			log.Errorw("Failed to notify consolidation result", "node", existing.Node, "batch", record.Batch.String(), "error", err)
+		}
+	}
+
+	return true, nil
+}
+
+// countSpaceEgress adds the egress of each space of a consolidated batch to the space stats and to the hourly,
+// account and network stats, at most once per batch in each of them. It fails if any stats could not be
+// recorded, so that the batch is retried.
+func (c *Consolidator) countSpaceEgress(ctx context.Context, cause ucan.Link, spaceEgress map[did.DID]uint64, at time.Time) error {
+	var errs []error
+	for space, egress := range spaceEgress {
+		err := c.spaceStatsTable.Record(ctx, space, egress, at, cause.String())
+		if err != nil && !errors.Is(err, spacestats.ErrAlreadyRecorded) {
+			errs = append(errs, fmt.Errorf("recording space stats of %s: %w", space, err))
+		}
+
+		if c.hourlyStatsTable != nil {
+			err := c.hourlyStatsTable.Record(ctx, space, egress, at, cause.String())
+			if err != nil && !errors.Is(err, hourlystats.ErrAlreadyRecorded) {
+				errs = append(errs, fmt.Errorf("recording hourly space stats of %s: %w", space, err))
 			}
 		}
+	}
 
-		o, x := result.Unwrap(rcpt.Out())
-		var emptyErr capegress.ConsolidateError
-		if x != emptyErr {
-			bLog.Errorf("consolidation error: %s", x.Message)
-		} else {
-			totalEgress = o.TotalEgress
+	if c.accountStatsTable != nil || c.networkStatsTable != nil {
+		if err := c.recordRollupStats(ctx, cause, spaceEgress, at); err != nil {
+			errs = append(errs, fmt.Errorf("recording account and network stats: %w", err))
 		}
+	}
 
-		// Store consolidated record (one per batch)
-		if err := c.consolidatedTable.Add(ctx, consolidateInv.Link(), record.Node, totalEgress, rcpt); err != nil {
-			bLog.Errorf("Failed to add consolidated record: %v", err)
-			continue
+	return errors.Join(errs...)
+}
+
+// recordExcludedEgress records the egress of excluded spaces in the stats table of the exclusion list
+func (c *Consolidator) recordExcludedEgress(ctx context.Context, cause ucan.Link, excludedEgress map[did.DID]uint64, at time.Time) error {
+	var errs []error
+	for space, egress := range excludedEgress {
+		err := c.exclusions.StatsTable().Record(ctx, space, egress, at, cause.String())
+		if err != nil && !errors.Is(err, spacestats.ErrAlreadyRecorded) {
+			errs = append(errs, fmt.Errorf("recording excluded egress of %s: %w", space, err))
 		}
+	}
+	return errors.Join(errs...)
+}
 
-		successfulRecords = append(successfulRecords, record)
+// postCredit posts the credit of a consolidated batch to the ledger, unless it was posted already
+func (c *Consolidator) postCredit(ctx context.Context, cause ucan.Link, node did.DID, totalEgress uint64, spaceEgress map[did.DID]uint64, at time.Time) error {
+	if c.ledgerTable == nil {
+		return nil
+	}
 
-		// Increment consolidated bytes counter for this node
-		nodeAttr := attribute.String("node", record.Node.String())
-		metrics.ConsolidatedBytesPerNode.Add(ctx, int64(totalEgress), metric.WithAttributeSet(attribute.NewSet(nodeAttr)))
+	accounts := make(map[did.DID]did.DID, len(spaceEgress))
+	for space := range spaceEgress {
+		record, err := c.consumerTable.Get(ctx, space.String())
+		if err != nil {
+			if errors.Is(err, consumer.ErrNotFound) {
+				continue
+			}
+			return fmt.Errorf("getting consumer %s: %w", space, err)
+		}
+		if record.Customer != did.Undef {
+			accounts[space] = record.Customer
+		}
+	}
 
-		bLog.Infof("Consolidated %d bytes", totalEgress)
+	tx := ledger.NewCredit(c.id.DID().String(), cause, node, totalEgress, spaceEgress, accounts, at)
+	tx.PostedAt = time.Now().UTC()
+	if err := c.ledgerTable.Post(ctx, tx); err != nil && !errors.Is(err, ledger.ErrAlreadyPosted) {
+		return err
 	}
 
-	// Mark records as processed
-	if err := c.egressTable.MarkAsProcessed(ctx, successfulRecords); err != nil {
-		return fmt.Errorf("marking records as processed: %w", err)
+	return nil
+}
+
+// listUnprocessed lists the unprocessedWindow oldest unprocessed records, oldest first, so that however large
+// the backlog is every record is eventually selected and the age of the oldest one is known.
+func (c *Consolidator) listUnprocessed(ctx context.Context) ([]egress.EgressRecord, error) {
+	var unprocessed []egress.EgressRecord
+	for record, err := range c.egressTable.ListUnprocessed(ctx, unprocessedPageSize) {
+		if err != nil {
+			return nil, fmt.Errorf("fetching unprocessed records: %w", err)
+		}
+
+		unprocessed = append(unprocessed, record)
+		if len(unprocessed) == unprocessedWindow {
+			break
+		}
 	}
 
-	metrics.UnprocessedBatches.Add(ctx, int64(-len(successfulRecords)))
+	return unprocessed, nil
+}
 
-	log.Infof("Consolidation cycle completed. Processed %d records (%d successful)", len(records), len(successfulRecords))
+func (c *Consolidator) markAsProcessed(ctx context.Context, record egress.EgressRecord) error {
+	if err := c.egressTable.MarkAsProcessed(ctx, []egress.EgressRecord{record}); err != nil {
+		return fmt.Errorf("marking record as processed: %w", err)
+	}
 
+	metrics.UnprocessedBatches.Add(ctx, -1)
 	return nil
 }
 
+// recordRollupStats adds the egress of each space to the account and network stats, at most once per batch
+func (c *Consolidator) recordRollupStats(ctx context.Context, cause ucan.Link, spaceEgress map[did.DID]uint64, at time.Time) error {
+	return rollup.New(c.consumerTable, c.accountStatsTable, c.networkStatsTable, c.pricer, c.quotaMonitor).Record(ctx, cause, spaceEgress, at)
+}
+
 func (c *Consolidator) execConsolidateInvocation(ctx context.Context, inv invocation.Invocation) (capegress.ConsolidateReceipt, error) {
 	conn, err := client.NewConnection(c.id, c.ucantoSrv)
 	if err != nil {
@@ -335,6 +821,10 @@ func (c *Consolidator) ucanConsolidateHandler(
 
 	// Process each receipt in the batch
 	totalEgress := uint64(0)
+	var rcptErrors []capegress.ReceiptError
+	ranges := newRangeValidator(c.maxRangeLength)
+	spaceEgress := spaceEgressFromContext(ctx)
+	excludedEgress := excludedEgressFromContext(ctx)
 
 	for rcpt, err := range receipts {
 		if err != nil {
@@ -345,25 +835,54 @@ func (c *Consolidator) ucanConsolidateHandler(
 		cap, err := validateRetrievalReceipt(ctx, requesterNode, rcpt, c.retrieveValidationCtx, c.consumerTable, c.knownProviders)
 		if err != nil {
 			log.Warnf("Invalid receipt: %v", err)
+			rcptErrors = append(rcptErrors, newReceiptError(rcpt, InvalidReceiptErrorName, err.Error()))
+			continue
+		}
+
+		space, err := extractSpace(cap)
+		if err != nil {
+			log.Warnf("Failed to extract space from receipt: %v", err)
+			rcptErrors = append(rcptErrors, newReceiptError(rcpt, InvalidReceiptErrorName, err.Error()))
 			continue
 		}
 
-		space, size, err := extractProperties(cap)
+		size, issues, err := ranges.check(rcpt.Ran().Link(), cap.Nb())
 		if err != nil {
-			log.Warnf("Failed to extract size from receipt: %v", err)
+			log.Warnf("Rejected receipt range: %v", err)
+			rcptErrors = append(rcptErrors, newReceiptError(rcpt, errorName(err), err.Error()))
+			continue
+		}
+
+		for _, issue := range issues {
+			log.Warnf("Flagged receipt range: %v", issue)
+			rcptErrors = append(rcptErrors, newReceiptError(rcpt, issue.Name(), issue.Error()))
+		}
+
+		// The egress of excluded spaces is recorded separately when the consolidation result is committed
+		if c.exclusions.Contains(space) {
+			if excludedEgress != nil && size > 0 {
+				excludedEgress[space] += size
+			}
 			continue
 		}
 
-		// Record space stats
-		if err := c.spaceStatsTable.Record(ctx, space, size); err != nil {
-			log.Errorf("Failed to record space stats: %v", err)
-			// Continue processing even if stats recording fails
+		if c.detector != nil {
+			c.detector.Observe(requesterNode, inv.Link(), space, cap.Nb(), size)
+		}
+
+		if size == 0 {
+			continue
+		}
+
+		// Space stats are recorded when the consolidation result is committed
+		if spaceEgress != nil {
+			spaceEgress[space] += size
 		}
 
 		totalEgress += size
 	}
 
-	return result.Ok[capegress.ConsolidateOk, capegress.ConsolidateError](capegress.ConsolidateOk{TotalEgress: totalEgress}), nil, nil
+	return result.Ok[capegress.ConsolidateOk, capegress.ConsolidateError](capegress.ConsolidateOk{TotalEgress: totalEgress, Errors: rcptErrors}), nil, nil
 }
 
 func (c *Consolidator) fetchReceipts(ctx context.Context, endpoint *url.URL, batchCID ucan.Link) (iter.Seq2[receipt.AnyReceipt, error], error) {
@@ -506,22 +1025,93 @@ func validateRetrievalReceipt(
 	return auth.Capability(), nil
 }
 
-func extractProperties(cap ucan.Capability[content.RetrieveCaveats]) (did.DID, uint64, error) {
+func extractSpace(cap ucan.Capability[content.RetrieveCaveats]) (did.DID, error) {
 	space, err := did.Parse(string(cap.With()))
 	if err != nil {
-		return did.Undef, 0, fmt.Errorf("parsing space from with %s: %w", cap.With(), err)
+		return did.Undef, fmt.Errorf("parsing space from with %s: %w", cap.With(), err)
+	}
+
+	return space, nil
+}
+
+const InvalidReceiptErrorName = "InvalidReceipt"
+
+// errorName returns the name of a named error, or InvalidReceiptErrorName if the error is not named.
+func errorName(err error) string {
+	var named interface{ Name() string }
+	if errors.As(err, &named) {
+		return named.Name()
+	}
+
+	return InvalidReceiptErrorName
+}
+
+func newReceiptError(rcpt receipt.AnyReceipt, name, msg string) capegress.ReceiptError {
+	return capegress.ReceiptError{
+		Name:    name,
+		Message: msg,
+		Receipt: rcpt.Root().Link(),
 	}
+}
+
+type spaceEgressKey struct{}
 
-	size := cap.Nb().Range.End - cap.Nb().Range.Start + 1
+// withSpaceEgress returns a context through which the consolidate handler reports the egress counted for each space
+func withSpaceEgress(ctx context.Context, spaceEgress map[did.DID]uint64) context.Context {
+	return context.WithValue(ctx, spaceEgressKey{}, spaceEgress)
+}
+
+func spaceEgressFromContext(ctx context.Context) map[did.DID]uint64 {
+	spaceEgress, _ := ctx.Value(spaceEgressKey{}).(map[did.DID]uint64)
+	return spaceEgress
+}
+
+type excludedEgressKey struct{}
 
-	return space, size, nil
+// withExcludedEgress returns a context through which the consolidate handler reports the egress of excluded spaces
+func withExcludedEgress(ctx context.Context, excludedEgress map[did.DID]uint64) context.Context {
+	return context.WithValue(ctx, excludedEgressKey{}, excludedEgress)
 }
 
-func (c *Consolidator) GetReceipt(ctx context.Context, cause ucan.Link) (receipt.AnyReceipt, error) {
-	consRecord, err := c.consolidatedTable.Get(ctx, cause)
+func excludedEgressFromContext(ctx context.Context) map[did.DID]uint64 {
+	excludedEgress, _ := ctx.Value(excludedEgressKey{}).(map[did.DID]uint64)
+	return excludedEgress
+}
+
+// GetReceipt returns the consolidation receipt of a batch. The batch can be identified by the consolidate invocation,
+// the `space/egress/track` invocation that tracked it or the batch CID itself.
+func (c *Consolidator) GetReceipt(ctx context.Context, cid ucan.Link) (receipt.AnyReceipt, error) {
+	consRecord, err := c.getConsolidatedRecord(ctx, cid)
 	if err != nil {
 		return nil, err
 	}
 
+	// The outcome of held batches is not known until they are reviewed
+	if consRecord.Status == consolidated.StatusHeld {
+		return nil, ErrPendingReview
+	}
+
 	return consRecord.Receipt, nil
 }
+
+// getConsolidatedRecord looks up a consolidated record by consolidate invocation, track invocation or batch CID, in that order
+func (c *Consolidator) getConsolidatedRecord(ctx context.Context, cid ucan.Link) (*consolidated.ConsolidatedRecord, error) {
+	lookups := []func(context.Context, ucan.Link) (*consolidated.ConsolidatedRecord, error){
+		c.consolidatedTable.Get,
+		c.consolidatedTable.GetByTrackCause,
+		c.consolidatedTable.GetByBatch,
+		c.getByOwnConsolidateInvocation,
+	}
+
+	for _, lookup := range lookups {
+		record, err := lookup(ctx, cid)
+		if err == nil {
+			return record, nil
+		}
+		if !errors.Is(err, consolidated.ErrNotFound) {
+			return nil, err
+		}
+	}
+
+	return nil, ErrNotFound
+}
@@ -9,8 +9,10 @@ import (
 
 	"github.com/ipfs/go-cid"
 	"github.com/ipld/go-ipld-prime"
+	"github.com/storacha/etracker/internal/db/consolidated"
 	"github.com/storacha/etracker/internal/db/consumer"
 	"github.com/storacha/go-libstoracha/capabilities/space/content"
+	capegress "github.com/storacha/go-libstoracha/capabilities/space/egress"
 	ucancap "github.com/storacha/go-libstoracha/capabilities/ucan"
 	"github.com/storacha/go-libstoracha/testutil"
 	"github.com/storacha/go-ucanto/core/dag/blockstore"
@@ -418,3 +420,68 @@ func tamperReceiptResult(t *testing.T, rcpt receipt.AnyReceipt) {
 	rcptData = reflect.NewAt(rcptData.Type(), unsafe.Pointer(rcptData.UnsafeAddr())).Elem()
 	rcptData.Set(reflect.ValueOf(&receiptModel))
 }
+
+func TestGetReceipt(t *testing.T) {
+	id := testutil.RandomSigner(t)
+
+	newRecord := func(status consolidated.Status) consolidated.ConsolidatedRecord {
+		cause := testutil.RandomCID(t)
+		rcpt, err := receipt.Issue(id, result.Ok[capegress.ConsolidateOk, capegress.ConsolidateError](capegress.ConsolidateOk{}), ran.FromLink(cause))
+		require.NoError(t, err)
+
+		return consolidated.ConsolidatedRecord{
+			Cause:      cause,
+			TrackCause: testutil.RandomCID(t),
+			Batch:      testutil.RandomCID(t),
+			Receipt:    rcpt,
+			Status:     status,
+		}
+	}
+
+	counted := newRecord(consolidated.StatusCounted)
+	held := newRecord(consolidated.StatusHeld)
+
+	consolidatedTable := &mockConsolidatedTable{records: []consolidated.ConsolidatedRecord{counted, held}}
+	c := &Consolidator{
+		id:                id,
+		consolidatedTable: consolidatedTable,
+	}
+
+	for name, cid := range map[string]ucan.Link{
+		"by consolidate invocation": counted.Cause,
+		"by track invocation":       counted.TrackCause,
+		"by batch":                  counted.Batch,
+	} {
+		t.Run(name, func(t *testing.T) {
+			rcpt, err := c.GetReceipt(context.Background(), cid)
+			require.NoError(t, err)
+			assert.Equal(t, counted.Receipt.Root().Link(), rcpt.Root().Link())
+		})
+	}
+
+	t.Run("by track invocation of records that did not store it", func(t *testing.T) {
+		trackCause := testutil.RandomCID(t)
+		consolidateInv, err := c.newConsolidateInvocation(trackCause)
+		require.NoError(t, err)
+
+		legacy := newRecord(consolidated.StatusCounted)
+		legacy.Cause = consolidateInv.Link()
+		legacy.TrackCause = nil
+		legacy.Batch = nil
+		consolidatedTable.records = append(consolidatedTable.records, legacy)
+
+		rcpt, err := c.GetReceipt(context.Background(), trackCause)
+		require.NoError(t, err)
+		assert.Equal(t, legacy.Receipt.Root().Link(), rcpt.Root().Link())
+	})
+
+	t.Run("held batches are pending review", func(t *testing.T) {
+		_, err := c.GetReceipt(context.Background(), held.Batch)
+		require.ErrorIs(t, err, ErrPendingReview)
+	})
+
+	t.Run("unknown CID", func(t *testing.T) {
+		_, err := c.GetReceipt(context.Background(), testutil.RandomCID(t))
+		require.ErrorIs(t, err, ErrNotFound)
+	})
+}
@@ -0,0 +1,88 @@
+package consolidator
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/storacha/go-ucanto/did"
+
+	"github.com/storacha/etracker/internal/db/anomalies"
+	"github.com/storacha/etracker/internal/db/consolidated"
+)
+
+// holdRules decide which consolidated batches are held for manual review instead of being counted right away.
+// A zero value disables the corresponding rule.
+type holdRules struct {
+	// newNodePeriod holds batches from nodes whose first batch was consolidated less than this long ago
+	newNodePeriod time.Duration
+	// maxBatchEgress holds batches whose total egress is above this number of bytes
+	maxBatchEgress uint64
+	// anomalyWindow holds batches from nodes with anomaly flags raised within this window
+	anomalyWindow time.Duration
+	anomalyTable  anomalies.AnomalyTable
+}
+
+// WithNewNodeHold holds batches from nodes that started reporting egress less than period ago.
+func WithNewNodeHold(period time.Duration) Option {
+	return func(c *Consolidator) {
+		c.holdRules.newNodePeriod = period
+	}
+}
+
+// WithBatchEgressHold holds batches with a total egress above maxEgress bytes.
+func WithBatchEgressHold(maxEgress uint64) Option {
+	return func(c *Consolidator) {
+		c.holdRules.maxBatchEgress = maxEgress
+	}
+}
+
+// WithAnomalyHold holds batches from nodes that had anomaly flags raised within the given window.
+func WithAnomalyHold(anomalyTable anomalies.AnomalyTable, window time.Duration) Option {
+	return func(c *Consolidator) {
+		c.holdRules.anomalyTable = anomalyTable
+		c.holdRules.anomalyWindow = window
+	}
+}
+
+// holdReasons returns the reasons why a batch from node with the given total egress must be held for review.
+// No reasons are returned if the batch can be counted right away.
+func (c *Consolidator) holdReasons(ctx context.Context, node did.DID, totalEgress uint64, now time.Time) ([]string, error) {
+	// there is nothing to review in batches that didn't produce any egress
+	if totalEgress == 0 {
+		return nil, nil
+	}
+
+	var reasons []string
+
+	if c.holdRules.maxBatchEgress > 0 && totalEgress > c.holdRules.maxBatchEgress {
+		reasons = append(reasons, fmt.Sprintf("batch egress of %d bytes exceeds the limit of %d bytes", totalEgress, c.holdRules.maxBatchEgress))
+	}
+
+	if c.holdRules.newNodePeriod > 0 {
+		first, err := c.consolidatedTable.GetFirstByNode(ctx, node)
+		if err != nil && !errors.Is(err, consolidated.ErrNotFound) {
+			return nil, fmt.Errorf("fetching first consolidated record of node: %w", err)
+		}
+
+		if first == nil {
+			reasons = append(reasons, "first batch from the node")
+		} else if first.ProcessedAt.After(now.Add(-c.holdRules.newNodePeriod)) {
+			reasons = append(reasons, fmt.Sprintf("node started reporting egress on %s", first.ProcessedAt.UTC().Format(time.DateOnly)))
+		}
+	}
+
+	if c.holdRules.anomalyTable != nil && c.holdRules.anomalyWindow > 0 {
+		flags, err := c.holdRules.anomalyTable.ListByNode(ctx, node, now.Add(-c.holdRules.anomalyWindow))
+		if err != nil {
+			return nil, fmt.Errorf("fetching anomaly flags of node: %w", err)
+		}
+
+		if len(flags) > 0 {
+			reasons = append(reasons, fmt.Sprintf("node has %d anomaly flags raised since %s", len(flags), now.Add(-c.holdRules.anomalyWindow).UTC().Format(time.DateTime)))
+		}
+	}
+
+	return reasons, nil
+}
@@ -0,0 +1,266 @@
+package consolidator
+
+import (
+	"context"
+	"iter"
+	"testing"
+	"time"
+
+	"github.com/storacha/go-libstoracha/capabilities/space/content"
+	capegress "github.com/storacha/go-libstoracha/capabilities/space/egress"
+	"github.com/storacha/go-libstoracha/testutil"
+	"github.com/storacha/go-ucanto/did"
+	"github.com/storacha/go-ucanto/ucan"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/storacha/etracker/internal/anomaly"
+	"github.com/storacha/etracker/internal/db/anomalies"
+	"github.com/storacha/etracker/internal/db/consolidated"
+	"github.com/storacha/etracker/internal/metrics"
+)
+
+type mockConsolidatedTable struct {
+	first   map[did.DID]time.Time
+	records []consolidated.ConsolidatedRecord
+}
+
+func (m *mockConsolidatedTable) Add(ctx context.Context, cause ucan.Link, trackCause ucan.Link, batch ucan.Link, node did.DID, totalEgress uint64, rcpt capegress.ConsolidateReceipt, spaceEgress map[did.DID]uint64) error {
+	return nil
+}
+
+func (m *mockConsolidatedTable) Get(ctx context.Context, cause ucan.Link) (*consolidated.ConsolidatedRecord, error) {
+	return m.find(func(r consolidated.ConsolidatedRecord) bool { return r.Cause == cause })
+}
+
+func (m *mockConsolidatedTable) GetByTrackCause(ctx context.Context, trackCause ucan.Link) (*consolidated.ConsolidatedRecord, error) {
+	return m.find(func(r consolidated.ConsolidatedRecord) bool { return r.TrackCause == trackCause })
+}
+
+func (m *mockConsolidatedTable) GetByBatch(ctx context.Context, batch ucan.Link) (*consolidated.ConsolidatedRecord, error) {
+	return m.find(func(r consolidated.ConsolidatedRecord) bool { return r.Batch == batch })
+}
+
+func (m *mockConsolidatedTable) find(match func(consolidated.ConsolidatedRecord) bool) (*consolidated.ConsolidatedRecord, error) {
+	for _, r := range m.records {
+		if match(r) {
+			return &r, nil
+		}
+	}
+	return nil, consolidated.ErrNotFound
+}
+
+func (m *mockConsolidatedTable) GetStatsByNode(ctx context.Context, node did.DID, since time.Time) iter.Seq2[consolidated.ConsolidatedRecord, error] {
+	return func(yield func(consolidated.ConsolidatedRecord, error) bool) {
+		for _, r := range m.records {
+			if r.Node == node && !r.ProcessedAt.Before(since) {
+				if !yield(r, nil) {
+					return
+				}
+			}
+		}
+	}
+}
+
+func (m *mockConsolidatedTable) GetReviewedByNode(ctx context.Context, node did.DID, since time.Time) iter.Seq2[consolidated.ConsolidatedRecord, error] {
+	return func(yield func(consolidated.ConsolidatedRecord, error) bool) {}
+}
+
+func (m *mockConsolidatedTable) GetFirstByNode(ctx context.Context, node did.DID) (*consolidated.ConsolidatedRecord, error) {
+	processedAt, ok := m.first[node]
+	if !ok {
+		return nil, consolidated.ErrNotFound
+	}
+
+	return &consolidated.ConsolidatedRecord{Node: node, ProcessedAt: processedAt}, nil
+}
+
+func (m *mockConsolidatedTable) Hold(ctx context.Context, cause ucan.Link, trackCause ucan.Link, batch ucan.Link, node did.DID, totalEgress uint64, rcpt capegress.ConsolidateReceipt, reasons []string, spaceEgress map[did.DID]uint64) error {
+	return nil
+}
+
+func (m *mockConsolidatedTable) ListHeld(ctx context.Context, limit int, cursor *string) (*consolidated.ListHeldResult, error) {
+	return &consolidated.ListHeldResult{}, nil
+}
+
+func (m *mockConsolidatedTable) Approve(ctx context.Context, cause ucan.Link, approvedAt time.Time) error {
+	return consolidated.ErrNotHeld
+}
+
+func (m *mockConsolidatedTable) Reject(ctx context.Context, cause ucan.Link, reason string, rcpt capegress.ConsolidateReceipt) error {
+	return consolidated.ErrNotHeld
+}
+
+var _ consolidated.ConsolidatedTable = (*mockConsolidatedTable)(nil)
+
+type mockAnomalyTable struct {
+	flags []anomalies.Flag
+}
+
+func (m *mockAnomalyTable) Add(ctx context.Context, flag anomalies.Flag) error {
+	m.flags = append(m.flags, flag)
+	return nil
+}
+
+func (m *mockAnomalyTable) List(ctx context.Context, limit int, cursor *string) (*anomalies.ListResult, error) {
+	return &anomalies.ListResult{Flags: m.flags}, nil
+}
+
+func (m *mockAnomalyTable) ListByNode(ctx context.Context, node did.DID, since time.Time) ([]anomalies.Flag, error) {
+	var flags []anomalies.Flag
+	for _, f := range m.flags {
+		if f.Node == node && !f.DetectedAt.Before(since) {
+			flags = append(flags, f)
+		}
+	}
+	return flags, nil
+}
+
+var _ anomalies.AnomalyTable = (*mockAnomalyTable)(nil)
+
+func TestHoldReasons(t *testing.T) {
+	now := time.Date(2025, 6, 20, 12, 0, 0, 0, time.UTC)
+
+	newNode := testutil.RandomDID(t)
+	oldNode := testutil.RandomDID(t)
+	flaggedNode := testutil.RandomDID(t)
+
+	consolidatedTable := &mockConsolidatedTable{
+		first: map[did.DID]time.Time{
+			newNode:     now.AddDate(0, 0, -2),
+			oldNode:     now.AddDate(0, -3, 0),
+			flaggedNode: now.AddDate(0, -3, 0),
+		},
+	}
+
+	anomalyTable := &mockAnomalyTable{
+		flags: []anomalies.Flag{
+			{Node: flaggedNode, DetectedAt: now.Add(-time.Hour)},
+			{Node: oldNode, DetectedAt: now.AddDate(0, 0, -5)},
+		},
+	}
+
+	newConsolidator := func(opts ...Option) *Consolidator {
+		c := &Consolidator{consolidatedTable: consolidatedTable}
+		for _, opt := range opts {
+			opt(c)
+		}
+		return c
+	}
+
+	t.Run("no rules configured", func(t *testing.T) {
+		c := newConsolidator()
+
+		reasons, err := c.holdReasons(context.Background(), newNode, 1<<40, now)
+		require.NoError(t, err)
+		assert.Empty(t, reasons)
+	})
+
+	t.Run("batches without egress are never held", func(t *testing.T) {
+		c := newConsolidator(
+			WithNewNodeHold(7*24*time.Hour),
+			WithBatchEgressHold(1),
+			WithAnomalyHold(anomalyTable, 24*time.Hour),
+		)
+
+		reasons, err := c.holdReasons(context.Background(), flaggedNode, 0, now)
+		require.NoError(t, err)
+		assert.Empty(t, reasons)
+	})
+
+	t.Run("new node", func(t *testing.T) {
+		c := newConsolidator(WithNewNodeHold(7 * 24 * time.Hour))
+
+		reasons, err := c.holdReasons(context.Background(), newNode, 100, now)
+		require.NoError(t, err)
+		assert.Len(t, reasons, 1)
+
+		reasons, err = c.holdReasons(context.Background(), testutil.RandomDID(t), 100, now)
+		require.NoError(t, err)
+		assert.Equal(t, []string{"first batch from the node"}, reasons)
+
+		reasons, err = c.holdReasons(context.Background(), oldNode, 100, now)
+		require.NoError(t, err)
+		assert.Empty(t, reasons)
+	})
+
+	t.Run("oversize batch", func(t *testing.T) {
+		c := newConsolidator(WithBatchEgressHold(1000))
+
+		reasons, err := c.holdReasons(context.Background(), oldNode, 1001, now)
+		require.NoError(t, err)
+		assert.Len(t, reasons, 1)
+
+		reasons, err = c.holdReasons(context.Background(), oldNode, 1000, now)
+		require.NoError(t, err)
+		assert.Empty(t, reasons)
+	})
+
+	t.Run("anomaly flags within window", func(t *testing.T) {
+		c := newConsolidator(WithAnomalyHold(anomalyTable, 24*time.Hour))
+
+		reasons, err := c.holdReasons(context.Background(), flaggedNode, 100, now)
+		require.NoError(t, err)
+		assert.Len(t, reasons, 1)
+
+		// the flag for this node is older than the window
+		reasons, err = c.holdReasons(context.Background(), oldNode, 100, now)
+		require.NoError(t, err)
+		assert.Empty(t, reasons)
+	})
+
+	t.Run("reasons accumulate", func(t *testing.T) {
+		c := newConsolidator(
+			WithNewNodeHold(7*24*time.Hour),
+			WithBatchEgressHold(1000),
+			WithAnomalyHold(anomalyTable, 24*time.Hour),
+		)
+
+		reasons, err := c.holdReasons(context.Background(), flaggedNode, 5000, now)
+		require.NoError(t, err)
+		assert.Len(t, reasons, 2)
+	})
+}
+
+func TestAnomalyHoldOfSpikingCycle(t *testing.T) {
+	require.NoError(t, metrics.Init("test"))
+
+	now := time.Now().UTC()
+	today := now.Truncate(24 * time.Hour)
+	node := testutil.RandomDID(t)
+
+	consolidatedTable := &mockConsolidatedTable{first: map[did.DID]time.Time{node: now.AddDate(0, -3, 0)}}
+	for i := 1; i <= 14; i++ {
+		consolidatedTable.records = append(consolidatedTable.records, consolidated.ConsolidatedRecord{
+			Cause:       testutil.RandomCID(t),
+			Node:        node,
+			TotalEgress: 1 << 30,
+			ProcessedAt: today.AddDate(0, 0, -i).Add(time.Hour),
+		})
+	}
+	anomalyTable := &mockAnomalyTable{}
+
+	c := &Consolidator{
+		consolidatedTable: consolidatedTable,
+		detector:          anomaly.New(anomaly.DefaultConfig(), anomalyTable, consolidatedTable),
+	}
+	WithAnomalyHold(anomalyTable, 24*time.Hour)(c)
+
+	// the batch is observed while it is consolidated, none of its egress is committed yet
+	cause := testutil.RandomCID(t)
+	for range 10 {
+		nb := content.RetrieveCaveats{
+			Blob:  content.BlobDigest{Digest: testutil.RandomMultihash(t)},
+			Range: content.Range{Start: 0, End: 1<<30 - 1},
+		}
+		c.detector.Observe(node, cause, testutil.RandomDID(t), nb, 1<<30)
+	}
+
+	c.runDetector(context.Background())
+	require.Len(t, anomalyTable.flags, 1)
+	require.Equal(t, anomalies.KindNodeEgressSpike, anomalyTable.flags[0].Kind)
+
+	reasons, err := c.holdReasons(context.Background(), node, 10<<30, time.Now().UTC())
+	require.NoError(t, err)
+	require.Len(t, reasons, 1)
+}
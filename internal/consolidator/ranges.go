@@ -0,0 +1,117 @@
+package consolidator
+
+import (
+	"cmp"
+	"fmt"
+	"slices"
+
+	"github.com/storacha/go-libstoracha/capabilities/space/content"
+	"github.com/storacha/go-ucanto/ucan"
+)
+
+const (
+	ReversedRangeErrorName    = "ReversedRange"
+	RangeTooLargeErrorName    = "RangeTooLarge"
+	OverlappingRangeErrorName = "OverlappingRange"
+)
+
+// rangeIssue describes a problem found in the byte range claimed by a retrieval receipt.
+type rangeIssue struct {
+	name string
+	msg  string
+}
+
+func (ri rangeIssue) Name() string {
+	return ri.name
+}
+
+func (ri rangeIssue) Error() string {
+	return ri.msg
+}
+
+// rangeValidator checks the byte ranges claimed by the retrieval receipts of a single batch.
+// It rejects reversed ranges and ranges longer than maxLength, and makes sure overlapping ranges of the same
+// blob claimed for the same retrieval invocation are only counted once.
+type rangeValidator struct {
+	maxLength uint64
+	counted   map[string][]content.Range // disjoint ranges already counted, sorted by start offset
+}
+
+func newRangeValidator(maxLength uint64) *rangeValidator {
+	return &rangeValidator{
+		maxLength: maxLength,
+		counted:   make(map[string][]content.Range),
+	}
+}
+
+// check returns the number of bytes that should be counted as egress for the retrieval invocation inv.
+// Ranges that must be rejected are reported as an error. Ranges that are counted, maybe partially, but
+// deserve attention (because they overlap with ranges already seen) are reported as issues.
+func (v *rangeValidator) check(inv ucan.Link, nb content.RetrieveCaveats) (uint64, []rangeIssue, error) {
+	rng := nb.Range
+	if rng.End < rng.Start {
+		return 0, nil, rangeIssue{ReversedRangeErrorName, fmt.Sprintf("range end %d is before range start %d", rng.End, rng.Start)}
+	}
+
+	// compared before adding 1 to the difference, which would overflow for the range 0-MaxUint64
+	if rng.End-rng.Start >= v.maxLength {
+		return 0, nil, rangeIssue{RangeTooLargeErrorName, fmt.Sprintf("range %d-%d is longer than the maximum of %d bytes", rng.Start, rng.End, v.maxLength)}
+	}
+
+	var issues []rangeIssue
+
+	key := inv.String() + "/" + string(nb.Blob.Digest)
+	counted := v.counted[key]
+
+	length := rng.End - rng.Start + 1
+	overlap := overlapLength(counted, rng)
+	if overlap > 0 {
+		issues = append(issues, rangeIssue{OverlappingRangeErrorName, fmt.Sprintf("range %d-%d overlaps ranges already counted in this batch, %d bytes deduplicated", rng.Start, rng.End, overlap)})
+	}
+
+	v.counted[key] = mergeRange(counted, rng)
+
+	return length - overlap, issues, nil
+}
+
+// overlapLength returns how many bytes of rng are already covered by the disjoint ranges in counted.
+func overlapLength(counted []content.Range, rng content.Range) uint64 {
+	var overlap uint64
+	for _, c := range counted {
+		if c.Start > rng.End {
+			break
+		}
+		if c.End < rng.Start {
+			continue
+		}
+
+		start := max(c.Start, rng.Start)
+		end := min(c.End, rng.End)
+		overlap += end - start + 1
+	}
+
+	return overlap
+}
+
+// mergeRange adds rng to the sorted, disjoint ranges in counted, merging any ranges that overlap or touch it.
+func mergeRange(counted []content.Range, rng content.Range) []content.Range {
+	merged := make([]content.Range, 0, len(counted)+1)
+	for _, c := range counted {
+		switch {
+		case c.End < rng.Start && rng.Start-c.End > 1:
+			merged = append(merged, c)
+		case c.Start > rng.End && c.Start-rng.End > 1:
+			merged = append(merged, c)
+		default:
+			rng.Start = min(rng.Start, c.Start)
+			rng.End = max(rng.End, c.End)
+		}
+	}
+
+	merged = append(merged, rng)
+	slices.SortFunc(merged, func(a, b content.Range) int {
+		return cmp.Compare(a.Start, b.Start)
+	})
+
+	return merged
+}
@@ -0,0 +1,141 @@
+package consolidator
+
+import (
+	"math"
+	"testing"
+
+	mh "github.com/multiformats/go-multihash"
+	"github.com/storacha/go-libstoracha/capabilities/space/content"
+	"github.com/storacha/go-libstoracha/testutil"
+	"github.com/storacha/go-ucanto/ucan"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRangeValidator(t *testing.T) {
+	inv := testutil.RandomCID(t)
+	blob := testutil.RandomMultihash(t)
+
+	caveats := func(digest mh.Multihash, start, end uint64) content.RetrieveCaveats {
+		return content.RetrieveCaveats{
+			Blob:  content.BlobDigest{Digest: digest},
+			Range: content.Range{Start: start, End: end},
+		}
+	}
+
+	t.Run("counts a valid range in full", func(t *testing.T) {
+		v := newRangeValidator(DefaultMaxRangeLength)
+
+		size, issues, err := v.check(inv, caveats(blob, 10, 19))
+		require.NoError(t, err)
+		assert.Empty(t, issues)
+		assert.Equal(t, uint64(10), size)
+	})
+
+	t.Run("rejects a reversed range", func(t *testing.T) {
+		v := newRangeValidator(DefaultMaxRangeLength)
+
+		size, _, err := v.check(inv, caveats(blob, 20, 10))
+		require.Error(t, err)
+		assert.Equal(t, ReversedRangeErrorName, errorName(err))
+		assert.Zero(t, size)
+	})
+
+	t.Run("rejects a range whose length overflows", func(t *testing.T) {
+		v := newRangeValidator(DefaultMaxRangeLength)
+
+		_, _, err := v.check(inv, caveats(blob, 0, math.MaxUint64))
+		require.Error(t, err)
+		assert.Equal(t, RangeTooLargeErrorName, errorName(err))
+	})
+
+	t.Run("rejects a huge range that does not overflow", func(t *testing.T) {
+		v := newRangeValidator(DefaultMaxRangeLength)
+
+		size, _, err := v.check(inv, caveats(blob, 0, 1<<63))
+		require.Error(t, err)
+		assert.Equal(t, RangeTooLargeErrorName, errorName(err))
+		assert.Zero(t, size)
+	})
+
+	t.Run("rejects ranges longer than the configured maximum", func(t *testing.T) {
+		v := newRangeValidator(100)
+
+		size, _, err := v.check(inv, caveats(blob, 1000, 1099))
+		require.NoError(t, err)
+		assert.Equal(t, uint64(100), size)
+
+		_, _, err = v.check(inv, caveats(blob, 1000, 1100))
+		require.Error(t, err)
+		assert.Equal(t, RangeTooLargeErrorName, errorName(err))
+	})
+
+	t.Run("deduplicates overlapping ranges for the same invocation and blob", func(t *testing.T) {
+		v := newRangeValidator(DefaultMaxRangeLength)
+
+		size, issues, err := v.check(inv, caveats(blob, 0, 99))
+		require.NoError(t, err)
+		assert.Empty(t, issues)
+		assert.Equal(t, uint64(100), size)
+
+		// 50-149 overlaps 0-99 by 50 bytes
+		size, issues, err = v.check(inv, caveats(blob, 50, 149))
+		require.NoError(t, err)
+		require.Len(t, issues, 1)
+		assert.Equal(t, OverlappingRangeErrorName, issues[0].Name())
+		assert.Equal(t, uint64(50), size)
+
+		// 200-299 doesn't overlap anything counted so far
+		size, issues, err = v.check(inv, caveats(blob, 200, 299))
+		require.NoError(t, err)
+		assert.Empty(t, issues)
+		assert.Equal(t, uint64(100), size)
+
+		// 120-249 overlaps 0-149 by 30 bytes and 200-299 by 50 bytes
+		size, issues, err = v.check(inv, caveats(blob, 120, 249))
+		require.NoError(t, err)
+		require.Len(t, issues, 1)
+		assert.Equal(t, uint64(50), size)
+
+		// the whole range 0-299 has been counted now
+		size, issues, err = v.check(inv, caveats(blob, 0, 299))
+		require.NoError(t, err)
+		require.Len(t, issues, 1)
+		assert.Zero(t, size)
+	})
+
+	t.Run("does not deduplicate ranges for different blobs or invocations", func(t *testing.T) {
+		v := newRangeValidator(DefaultMaxRangeLength)
+		otherBlob := testutil.RandomMultihash(t)
+		otherInv := testutil.RandomCID(t)
+
+		for _, tc := range []struct {
+			inv    ucan.Link
+			digest mh.Multihash
+		}{
+			{inv, blob},
+			{inv, otherBlob},
+			{otherInv, blob},
+		} {
+			size, issues, err := v.check(tc.inv, caveats(tc.digest, 0, 99))
+			require.NoError(t, err)
+			assert.Empty(t, issues)
+			assert.Equal(t, uint64(100), size)
+		}
+	})
+}
+
+func TestMergeRange(t *testing.T) {
+	merged := mergeRange(nil, content.Range{Start: 10, End: 19})
+	merged = mergeRange(merged, content.Range{Start: 40, End: 49})
+	merged = mergeRange(merged, content.Range{Start: 0, End: 4})
+	assert.Equal(t, []content.Range{{Start: 0, End: 4}, {Start: 10, End: 19}, {Start: 40, End: 49}}, merged)
+
+	// adjacent ranges are merged
+	merged = mergeRange(merged, content.Range{Start: 5, End: 9})
+	assert.Equal(t, []content.Range{{Start: 0, End: 19}, {Start: 40, End: 49}}, merged)
+
+	// a range spanning several counted ranges swallows them
+	merged = mergeRange(merged, content.Range{Start: 15, End: 45})
+	assert.Equal(t, []content.Range{{Start: 0, End: 49}}, merged)
+}
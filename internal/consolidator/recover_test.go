@@ -0,0 +1,194 @@
+package consolidator
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"testing"
+	"time"
+
+	capegress "github.com/storacha/go-libstoracha/capabilities/space/egress"
+	"github.com/storacha/go-libstoracha/testutil"
+	"github.com/storacha/go-ucanto/core/invocation"
+	"github.com/storacha/go-ucanto/did"
+	"github.com/stretchr/testify/require"
+
+	"github.com/storacha/etracker/internal/db/consolidated"
+	"github.com/storacha/etracker/internal/db/egress"
+	"github.com/storacha/etracker/internal/db/spacestats"
+	"github.com/storacha/etracker/internal/exclusion"
+)
+
+// mockSpaceStatsTable records egress once per space and id, like the real table
+type mockSpaceStatsTable struct {
+	egress   map[did.DID]uint64
+	recorded map[string]bool
+	failing  did.DID
+}
+
+func (m *mockSpaceStatsTable) Record(ctx context.Context, space did.DID, egress uint64, at time.Time, id string) error {
+	if space == m.failing {
+		return fmt.Errorf("throttled")
+	}
+
+	key := space.String() + id
+	if m.recorded[key] {
+		return spacestats.ErrAlreadyRecorded
+	}
+	m.recorded[key] = true
+	m.egress[space] += egress
+	return nil
+}
+
+func (m *mockSpaceStatsTable) GetDailyStats(ctx context.Context, space did.DID, from time.Time, to time.Time) ([]spacestats.DailyStats, error) {
+	return nil, fmt.Errorf("not implemented")
+}
+
+func (m *mockSpaceStatsTable) GetMonthlyStats(ctx context.Context, space did.DID, from time.Time, to time.Time) ([]spacestats.MonthlyStats, error) {
+	return nil, fmt.Errorf("not implemented")
+}
+
+var _ spacestats.SpaceStatsTable = (*mockSpaceStatsTable)(nil)
+
+func TestRecoverBatch(t *testing.T) {
+	id := testutil.RandomSigner(t)
+	node := testutil.RandomSigner(t)
+	space1 := testutil.RandomDID(t)
+	space2 := testutil.RandomDID(t)
+
+	endpoint, err := url.Parse("https://node.example/receipts/{cid}")
+	require.NoError(t, err)
+
+	trackInv, err := invocation.Invoke(
+		node,
+		id,
+		capegress.Track.New(
+			node.DID().String(),
+			capegress.TrackCaveats{
+				Receipts: testutil.RandomCID(t),
+				Endpoint: endpoint,
+			},
+		),
+	)
+	require.NoError(t, err)
+
+	record := egress.EgressRecord{
+		Batch: testutil.RandomCID(t),
+		Node:  node.DID(),
+		Cause: trackInv,
+	}
+
+	newConsolidator := func(status consolidated.Status) (*Consolidator, *mockSpaceStatsTable) {
+		c := &Consolidator{id: id}
+		consolidateInv, err := c.newConsolidateInvocation(trackInv.Link())
+		require.NoError(t, err)
+
+		spaceStatsTable := &mockSpaceStatsTable{
+			egress:   make(map[did.DID]uint64),
+			recorded: make(map[string]bool),
+		}
+		c.spaceStatsTable = spaceStatsTable
+		c.consolidatedTable = &mockConsolidatedTable{records: []consolidated.ConsolidatedRecord{{
+			Cause:       consolidateInv.Link(),
+			Node:        node.DID(),
+			Status:      status,
+			ProcessedAt: time.Now(),
+			SpaceEgress: map[did.DID]uint64{space1: 100, space2: 200},
+		}}}
+		return c, spaceStatsTable
+	}
+
+	t.Run("counts the egress of an interrupted batch once", func(t *testing.T) {
+		c, spaceStatsTable := newConsolidator(consolidated.StatusCounted)
+
+		// the first attempt counted space1 only
+		spaceStatsTable.failing = space2
+		_, err := c.recoverBatch(context.Background(), record)
+		require.Error(t, err)
+
+		spaceStatsTable.failing = did.Undef
+		for range 2 {
+			recovered, err := c.recoverBatch(context.Background(), record)
+			require.NoError(t, err)
+			require.True(t, recovered)
+		}
+
+		require.Equal(t, map[did.DID]uint64{space1: 100, space2: 200}, spaceStatsTable.egress)
+	})
+
+	t.Run("does not count held batches", func(t *testing.T) {
+		c, spaceStatsTable := newConsolidator(consolidated.StatusHeld)
+
+		recovered, err := c.recoverBatch(context.Background(), record)
+		require.NoError(t, err)
+		require.True(t, recovered)
+		require.Empty(t, spaceStatsTable.egress)
+	})
+
+	t.Run("ignores batches not consolidated yet", func(t *testing.T) {
+		c, _ := newConsolidator(consolidated.StatusCounted)
+		c.consolidatedTable = &mockConsolidatedTable{}
+
+		recovered, err := c.recoverBatch(context.Background(), record)
+		require.NoError(t, err)
+		require.False(t, recovered)
+	})
+}
+
+func TestCommitExcludedEgress(t *testing.T) {
+	id := testutil.RandomSigner(t)
+	node := testutil.RandomSigner(t)
+	space := testutil.RandomDID(t)
+	monitoring := testutil.RandomDID(t)
+
+	endpoint, err := url.Parse("https://node.example/receipts/{cid}")
+	require.NoError(t, err)
+
+	trackInv, err := invocation.Invoke(
+		node,
+		id,
+		capegress.Track.New(
+			node.DID().String(),
+			capegress.TrackCaveats{
+				Receipts: testutil.RandomCID(t),
+				Endpoint: endpoint,
+			},
+		),
+	)
+	require.NoError(t, err)
+
+	spaceStatsTable := &mockSpaceStatsTable{egress: make(map[did.DID]uint64), recorded: make(map[string]bool)}
+	excludedStatsTable := &mockSpaceStatsTable{egress: make(map[did.DID]uint64), recorded: make(map[string]bool)}
+	c := &Consolidator{
+		id:                id,
+		spaceStatsTable:   spaceStatsTable,
+		consolidatedTable: &mockConsolidatedTable{},
+		exclusions:        exclusion.New([]did.DID{monitoring}, excludedStatsTable),
+	}
+
+	consolidateInv, err := c.newConsolidateInvocation(trackInv.Link())
+	require.NoError(t, err)
+
+	res := batchResult{
+		record:         egress.EgressRecord{Batch: testutil.RandomCID(t), Node: node.DID(), Cause: trackInv},
+		inv:            consolidateInv,
+		totalEgress:    100,
+		spaceEgress:    map[did.DID]uint64{space: 100},
+		excludedEgress: map[did.DID]uint64{monitoring: 50},
+	}
+
+	// the batch stays unprocessed and is retried when the excluded egress cannot be recorded
+	excludedStatsTable.failing = monitoring
+	require.Error(t, c.commitBatch(context.Background(), res))
+	require.Empty(t, spaceStatsTable.egress)
+
+	excludedStatsTable.failing = did.Undef
+	for range 2 {
+		require.NoError(t, c.recordExcludedEgress(context.Background(), consolidateInv.Link(), res.excludedEgress, time.Now()))
+	}
+	require.Equal(t, map[did.DID]uint64{monitoring: 50}, excludedStatsTable.egress)
+
+	require.True(t, c.exclusions.Contains(monitoring))
+	require.False(t, c.exclusions.Contains(space))
+	require.False(t, (*exclusion.List)(nil).Contains(monitoring))
+}
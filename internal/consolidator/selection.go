@@ -0,0 +1,72 @@
+package consolidator
+
+import (
+	"cmp"
+	"slices"
+	"time"
+
+	"github.com/storacha/go-ucanto/did"
+
+	"github.com/storacha/etracker/internal/db/egress"
+)
+
+// selectBatches picks up to n records to consolidate, oldest first and round-robin across nodes, so that old
+// batches are not starved and a single node tracking many batches can't take the whole cycle.
+// Every round takes the oldest remaining record of each node, nodes ordered by the age of that record.
+func selectBatches(records []egress.EgressRecord, n int) []egress.EgressRecord {
+	byNode := make(map[did.DID][]egress.EgressRecord)
+	for _, record := range records {
+		byNode[record.Node] = append(byNode[record.Node], record)
+	}
+
+	queues := make([][]egress.EgressRecord, 0, len(byNode))
+	for _, queue := range byNode {
+		slices.SortFunc(queue, compareAge)
+		queues = append(queues, queue)
+	}
+
+	selected := make([]egress.EgressRecord, 0, min(n, len(records)))
+	for len(selected) < n && len(queues) > 0 {
+		slices.SortFunc(queues, func(a, b []egress.EgressRecord) int {
+			return compareAge(a[0], b[0])
+		})
+
+		remaining := queues[:0]
+		for _, queue := range queues {
+			if len(selected) == n {
+				break
+			}
+			selected = append(selected, queue[0])
+			if len(queue) > 1 {
+				remaining = append(remaining, queue[1:])
+			}
+		}
+		queues = remaining
+	}
+
+	return selected
+}
+
+// compareAge orders records by the time they started waiting for consolidation, breaking ties by batch so the
+// order is deterministic
+func compareAge(a, b egress.EgressRecord) int {
+	return cmp.Or(
+		waitingSince(a).Compare(waitingSince(b)),
+		cmp.Compare(a.Batch.String(), b.Batch.String()),
+	)
+}
+
+func waitingSince(record egress.EgressRecord) time.Time {
+	if record.UnprocessedSince.IsZero() {
+		return record.ReceivedAt
+	}
+	return record.UnprocessedSince
+}
+
+// oldestWaitingSince returns the time the oldest of the records started waiting for consolidation
+func oldestWaitingSince(records []egress.EgressRecord) (time.Time, bool) {
+	if len(records) == 0 {
+		return time.Time{}, false
+	}
+	return waitingSince(slices.MinFunc(records, compareAge)), true
+}
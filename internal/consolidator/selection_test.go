@@ -0,0 +1,158 @@
+package consolidator
+
+import (
+	"context"
+	"fmt"
+	"iter"
+	"net/url"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/storacha/go-libstoracha/testutil"
+	"github.com/storacha/go-ucanto/core/invocation"
+	"github.com/storacha/go-ucanto/did"
+	"github.com/storacha/go-ucanto/ucan"
+	"github.com/stretchr/testify/require"
+
+	"github.com/storacha/etracker/internal/db/egress"
+)
+
+func TestSelectBatches(t *testing.T) {
+	base := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	chatty := testutil.RandomDID(t)
+	quiet := testutil.RandomDID(t)
+	other := testutil.RandomDID(t)
+
+	record := func(node did.DID, minutes int) egress.EgressRecord {
+		return egress.EgressRecord{
+			Batch:            testutil.RandomCID(t),
+			Node:             node,
+			UnprocessedSince: base.Add(time.Duration(minutes) * time.Minute),
+		}
+	}
+
+	// the chatty node tracked many batches, the oldest of all is from the other node
+	records := []egress.EgressRecord{
+		record(chatty, 5), record(chatty, 1), record(chatty, 2), record(chatty, 3), record(chatty, 4),
+		record(quiet, 10),
+		record(other, 0), record(other, 20),
+	}
+
+	t.Run("takes one batch per node per round, oldest first", func(t *testing.T) {
+		selected := selectBatches(records, 5)
+
+		require.Len(t, selected, 5)
+		// first round: oldest batch of each node, ordered by age
+		require.Equal(t, other, selected[0].Node)
+		require.Equal(t, chatty, selected[1].Node)
+		require.Equal(t, quiet, selected[2].Node)
+		// second round: quiet has no batches left
+		require.Equal(t, chatty, selected[3].Node)
+		require.Equal(t, base.Add(2*time.Minute), selected[3].UnprocessedSince)
+		require.Equal(t, other, selected[4].Node)
+	})
+
+	t.Run("selects every record when there are fewer than requested", func(t *testing.T) {
+		selected := selectBatches(records, 100)
+
+		require.Len(t, selected, len(records))
+		var chattyTimes []time.Time
+		for _, r := range selected {
+			if r.Node == chatty {
+				chattyTimes = append(chattyTimes, r.UnprocessedSince)
+			}
+		}
+		require.IsNonDecreasing(t, chattyTimes)
+	})
+
+	t.Run("reports the oldest record", func(t *testing.T) {
+		oldest, ok := oldestWaitingSince(records)
+		require.True(t, ok)
+		require.Equal(t, base, oldest)
+
+		_, ok = oldestWaitingSince(nil)
+		require.False(t, ok)
+	})
+}
+
+// mockEgressTable lists its unprocessed records oldest first, like the queue index does, counting the pages
+// that were fetched
+type mockEgressTable struct {
+	unprocessed []egress.EgressRecord
+	pages       int
+}
+
+func (m *mockEgressTable) Record(ctx context.Context, batch ucan.Link, node did.DID, endpoint *url.URL, cause invocation.Invocation) error {
+	return fmt.Errorf("not implemented")
+}
+
+func (m *mockEgressTable) GetByCause(ctx context.Context, cause ucan.Link) (*egress.EgressRecord, error) {
+	return nil, egress.ErrNotFound
+}
+
+func (m *mockEgressTable) ListUnprocessed(ctx context.Context, pageSize int) iter.Seq2[egress.EgressRecord, error] {
+	return func(yield func(egress.EgressRecord, error) bool) {
+		records := slices.Clone(m.unprocessed)
+		slices.SortFunc(records, func(a, b egress.EgressRecord) int {
+			return a.UnprocessedSince.Compare(b.UnprocessedSince)
+		})
+		for i, record := range records {
+			if i%pageSize == 0 {
+				m.pages++
+			}
+			if !yield(record, nil) {
+				return
+			}
+		}
+	}
+}
+
+func (m *mockEgressTable) MarkAsProcessed(ctx context.Context, records []egress.EgressRecord) error {
+	return fmt.Errorf("not implemented")
+}
+
+func (m *mockEgressTable) CountUnprocessedBatches(ctx context.Context) (int64, error) {
+	return int64(len(m.unprocessed)), nil
+}
+
+func (m *mockEgressTable) ListByNode(ctx context.Context, node did.DID, from, to time.Time, limit int, cursor *string) (*egress.ListByNodeResult, error) {
+	return nil, fmt.Errorf("not implemented")
+}
+
+var _ egress.EgressTable = (*mockEgressTable)(nil)
+
+func TestListUnprocessed(t *testing.T) {
+	base := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	t.Run("lists the oldest records of a large backlog", func(t *testing.T) {
+		egressTable := &mockEgressTable{}
+		// the newest records were tracked first
+		for i := range 2*unprocessedWindow + unprocessedPageSize/2 {
+			egressTable.unprocessed = append(egressTable.unprocessed, egress.EgressRecord{UnprocessedSince: base.Add(-time.Duration(i) * time.Second)})
+		}
+		c := &Consolidator{egressTable: egressTable}
+
+		unprocessed, err := c.listUnprocessed(context.Background())
+		require.NoError(t, err)
+		require.Len(t, unprocessed, unprocessedWindow)
+		require.Equal(t, unprocessedWindow/unprocessedPageSize, egressTable.pages)
+
+		oldest := base.Add(-time.Duration(2*unprocessedWindow+unprocessedPageSize/2-1) * time.Second)
+		require.Equal(t, oldest, unprocessed[0].UnprocessedSince)
+		require.Equal(t, oldest.Add(unprocessedWindow*time.Second-time.Second), unprocessed[len(unprocessed)-1].UnprocessedSince)
+	})
+
+	t.Run("lists every record of a small backlog", func(t *testing.T) {
+		egressTable := &mockEgressTable{}
+		for i := range unprocessedPageSize / 2 {
+			egressTable.unprocessed = append(egressTable.unprocessed, egress.EgressRecord{UnprocessedSince: base.Add(time.Duration(i) * time.Second)})
+		}
+		c := &Consolidator{egressTable: egressTable}
+
+		unprocessed, err := c.listUnprocessed(context.Background())
+		require.NoError(t, err)
+		require.Len(t, unprocessed, unprocessedPageSize/2)
+		require.Equal(t, base, unprocessed[0].UnprocessedSince)
+	})
+}
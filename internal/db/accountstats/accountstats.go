@@ -0,0 +1,32 @@
+package accountstats
+
+import (
+	"context"
+	"time"
+
+	"github.com/storacha/go-ucanto/did"
+
+	"github.com/storacha/etracker/internal/db/markers"
+)
+
+// ErrAlreadyRecorded is returned when egress of the plan was recorded for the account under the same id before
+var ErrAlreadyRecorded = markers.ErrAlreadyRecorded
+
+type DailyStats struct {
+	Date   time.Time
+	Egress uint64
+	// Plans is the egress of the day by pricing plan of the spaces it was served for. Egress recorded before it
+	// was attributed to plans is missing from it.
+	Plans map[string]uint64
+}
+
+// AccountStatsTable holds the daily egress of accounts, the sum of the daily egress of their spaces
+type AccountStatsTable interface {
+	// Record adds egress served for spaces on the given plan to the daily stats of the account for the day of
+	// the given time. Egress is recorded at most once per account, plan and id, later calls return
+	// ErrAlreadyRecorded.
+	Record(ctx context.Context, account did.DID, plan string, egress uint64, at time.Time, id string) error
+	// Put overwrites the daily stats of the account for the day of the given time with the egress of each plan
+	Put(ctx context.Context, account did.DID, plans map[string]uint64, at time.Time) error
+	GetDailyStats(ctx context.Context, account did.DID, from time.Time, to time.Time) ([]DailyStats, error)
+}
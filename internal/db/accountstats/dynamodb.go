@@ -0,0 +1,179 @@
+package accountstats
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/storacha/go-ucanto/did"
+
+	"github.com/storacha/etracker/internal/db/markers"
+)
+
+var _ AccountStatsTable = (*DynamoAccountStatsTable)(nil)
+
+const dateFormat = "2006-01-02"
+
+// planPrefix prefixes the attributes holding the egress of each plan, top level attributes can be incremented
+// with ADD on items that don't exist yet, unlike the entries of a map attribute
+const planPrefix = "egress:"
+
+type DynamoAccountStatsTable struct {
+	client    *dynamodb.Client
+	tableName string
+}
+
+func NewDynamoAccountStatsTable(client *dynamodb.Client, tableName string) *DynamoAccountStatsTable {
+	return &DynamoAccountStatsTable{client, tableName}
+}
+
+func (d *DynamoAccountStatsTable) Record(ctx context.Context, account did.DID, plan string, egress uint64, at time.Time, id string) error {
+	// the egress of every plan is recorded separately, so the marker is per plan
+	marker := markers.Put(d.tableName, "account", map[string]types.AttributeValue{
+		"account": &types.AttributeValueMemberS{Value: account.String()},
+		"date":    &types.AttributeValueMemberS{Value: markers.Key(id + "#" + plan)},
+	}, time.Now())
+	// ADD atomically increments egress, creating the item if it doesn't exist
+	daily := types.TransactWriteItem{
+		Update: &types.Update{
+			TableName:        aws.String(d.tableName),
+			Key:              d.key(account, at),
+			UpdateExpression: aws.String("ADD egress :egress, #plan :egress"),
+			ExpressionAttributeNames: map[string]string{
+				"#plan": planPrefix + plan,
+			},
+			ExpressionAttributeValues: map[string]types.AttributeValue{
+				":egress": &types.AttributeValueMemberN{Value: fmt.Sprintf("%d", egress)},
+			},
+		},
+	}
+
+	if err := markers.Record(ctx, d.client, marker, daily); err != nil {
+		if errors.Is(err, ErrAlreadyRecorded) {
+			return err
+		}
+		return fmt.Errorf("recording account stats: %w", err)
+	}
+
+	return nil
+}
+
+func (d *DynamoAccountStatsTable) Put(ctx context.Context, account did.DID, plans map[string]uint64, at time.Time) error {
+	// the whole item is replaced so that plans without egress anymore are dropped
+	item := d.key(account, at)
+	var egress uint64
+	for plan, planEgress := range plans {
+		item[planPrefix+plan] = &types.AttributeValueMemberN{Value: fmt.Sprintf("%d", planEgress)}
+		egress += planEgress
+	}
+	item["egress"] = &types.AttributeValueMemberN{Value: fmt.Sprintf("%d", egress)}
+
+	_, err := d.client.PutItem(ctx, &dynamodb.PutItemInput{
+		TableName: aws.String(d.tableName),
+		Item:      item,
+	})
+	if err != nil {
+		return fmt.Errorf("putting account stats: %w", err)
+	}
+
+	return nil
+}
+
+func (d *DynamoAccountStatsTable) GetDailyStats(ctx context.Context, account did.DID, from time.Time, to time.Time) ([]DailyStats, error) {
+	stats := make([]DailyStats, 0)
+	var exclusiveStartKey map[string]types.AttributeValue
+
+	for {
+		input := &dynamodb.QueryInput{
+			TableName:              aws.String(d.tableName),
+			KeyConditionExpression: aws.String("account = :account AND #date BETWEEN :from AND :to"),
+			ExpressionAttributeNames: map[string]string{
+				"#date": "date",
+			},
+			ExpressionAttributeValues: map[string]types.AttributeValue{
+				":account": &types.AttributeValueMemberS{Value: account.String()},
+				":from":    &types.AttributeValueMemberS{Value: from.UTC().Format(dateFormat)},
+				":to":      &types.AttributeValueMemberS{Value: to.UTC().Format(dateFormat)},
+			},
+		}
+
+		if exclusiveStartKey != nil {
+			input.ExclusiveStartKey = exclusiveStartKey
+		}
+
+		result, err := d.client.Query(ctx, input)
+		if err != nil {
+			return nil, fmt.Errorf("querying account stats: %w", err)
+		}
+
+		for _, item := range result.Items {
+			stat, err := d.unmarshalDailyStats(item)
+			if err != nil {
+				return nil, err
+			}
+			stats = append(stats, stat)
+		}
+
+		if result.LastEvaluatedKey == nil {
+			break
+		}
+		exclusiveStartKey = result.LastEvaluatedKey
+	}
+
+	return stats, nil
+}
+
+func (d *DynamoAccountStatsTable) key(account did.DID, at time.Time) map[string]types.AttributeValue {
+	return map[string]types.AttributeValue{
+		"account": &types.AttributeValueMemberS{Value: account.String()},
+		"date":    &types.AttributeValueMemberS{Value: at.UTC().Format(dateFormat)},
+	}
+}
+
+// accountStatsRecord is the internal struct for unmarshaling from DynamoDB
+type accountStatsRecord struct {
+	Date   string `dynamodbav:"date"`
+	Egress uint64 `dynamodbav:"egress"`
+}
+
+func (d *DynamoAccountStatsTable) unmarshalDailyStats(item map[string]types.AttributeValue) (DailyStats, error) {
+	var record accountStatsRecord
+	if err := attributevalue.UnmarshalMap(item, &record); err != nil {
+		return DailyStats{}, fmt.Errorf("unmarshaling account stats record: %w", err)
+	}
+
+	date, err := time.Parse(dateFormat, record.Date)
+	if err != nil {
+		return DailyStats{}, fmt.Errorf("parsing date: %w", err)
+	}
+
+	plans := make(map[string]uint64)
+	for name, value := range item {
+		plan, ok := strings.CutPrefix(name, planPrefix)
+		if !ok {
+			continue
+		}
+		n, ok := value.(*types.AttributeValueMemberN)
+		if !ok {
+			return DailyStats{}, fmt.Errorf("egress of plan %s is not a number", plan)
+		}
+		egress, err := strconv.ParseUint(n.Value, 10, 64)
+		if err != nil {
+			return DailyStats{}, fmt.Errorf("parsing egress of plan %s: %w", plan, err)
+		}
+		plans[plan] = egress
+	}
+
+	return DailyStats{
+		Date:   date,
+		Egress: record.Egress,
+		Plans:  plans,
+	}, nil
+}
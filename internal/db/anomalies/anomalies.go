@@ -0,0 +1,53 @@
+package anomalies
+
+import (
+	"context"
+	"time"
+
+	"github.com/storacha/go-ucanto/did"
+	"github.com/storacha/go-ucanto/ucan"
+)
+
+type Kind string
+
+const (
+	// KindNodeEgressSpike flags a node whose egress jumps well above its trailing baseline
+	KindNodeEgressSpike Kind = "node-egress-spike"
+	// KindSpaceEgressShare flags a single space receiving an implausible share of a node's egress
+	KindSpaceEgressShare Kind = "space-egress-share"
+	// KindIdenticalRangeBurst flags a burst of retrievals for the very same byte range
+	KindIdenticalRangeBurst Kind = "identical-range-burst"
+)
+
+type Severity string
+
+const (
+	SeverityWarning  Severity = "warning"
+	SeverityCritical Severity = "critical"
+)
+
+type Flag struct {
+	ID       string
+	Kind     Kind
+	Severity Severity
+	Node     did.DID
+	// Space is only set for flags that concern a single space
+	Space   did.DID
+	Message string
+	// Evidence holds the causes (consolidate invocations) of the batches that triggered the flag
+	Evidence   []ucan.Link
+	DetectedAt time.Time
+}
+
+type ListResult struct {
+	Flags  []Flag
+	Cursor *string
+}
+
+type AnomalyTable interface {
+	// Add stores a flag. Adding a flag with an ID that already exists adds the evidence to the existing flag and
+	// updates its message and detection time, its severity is only ever raised.
+	Add(ctx context.Context, flag Flag) error
+	List(ctx context.Context, limit int, cursor *string) (*ListResult, error)
+	ListByNode(ctx context.Context, node did.DID, since time.Time) ([]Flag, error)
+}
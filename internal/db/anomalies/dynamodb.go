@@ -0,0 +1,299 @@
+package anomalies
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/ipfs/go-cid"
+	cidlink "github.com/ipld/go-ipld-prime/linking/cid"
+	"github.com/storacha/go-ucanto/did"
+	"github.com/storacha/go-ucanto/ucan"
+)
+
+var _ AnomalyTable = (*DynamoAnomalyTable)(nil)
+
+// maxEvidence bounds the number of batch causes stored with a flag raised again and again, to keep its item well
+// below the DynamoDB item size limit
+const maxEvidence = 1000
+
+type DynamoAnomalyTable struct {
+	client        *dynamodb.Client
+	tableName     string
+	nodeIndexName string
+}
+
+func NewDynamoAnomalyTable(client *dynamodb.Client, tableName string, nodeIndexName string) *DynamoAnomalyTable {
+	return &DynamoAnomalyTable{client, tableName, nodeIndexName}
+}
+
+func (d *DynamoAnomalyTable) Add(ctx context.Context, flag Flag) error {
+	record := newAnomalyRecord(flag)
+
+	evidence := make([]types.AttributeValue, 0, len(record.Evidence))
+	for _, cause := range record.Evidence {
+		evidence = append(evidence, &types.AttributeValueMemberS{Value: cause})
+	}
+
+	set := []string{"#kind = :kind", "#node = :node", "#message = :message", "#detectedAt = :detectedAt"}
+	names := map[string]string{
+		"#kind":       "kind",
+		"#node":       "node",
+		"#message":    "message",
+		"#detectedAt": "detectedAt",
+		"#severity":   "severity",
+	}
+	values := map[string]types.AttributeValue{
+		":kind":       &types.AttributeValueMemberS{Value: record.Kind},
+		":node":       &types.AttributeValueMemberS{Value: record.Node},
+		":message":    &types.AttributeValueMemberS{Value: record.Message},
+		":detectedAt": &types.AttributeValueMemberS{Value: record.DetectedAt.Format(time.RFC3339Nano)},
+		":severity":   &types.AttributeValueMemberS{Value: record.Severity},
+	}
+
+	// a flag raised again with a lower severity keeps the severity it was raised with
+	if flag.Severity == SeverityCritical {
+		set = append(set, "#severity = :severity")
+	} else {
+		set = append(set, "#severity = if_not_exists(#severity, :severity)")
+	}
+
+	if record.Space != "" {
+		set = append(set, "#space = :space")
+		names["#space"] = "space"
+		values[":space"] = &types.AttributeValueMemberS{Value: record.Space}
+	}
+
+	update := func(set []string, condition *string) error {
+		_, err := d.client.UpdateItem(ctx, &dynamodb.UpdateItemInput{
+			TableName: aws.String(d.tableName),
+			Key: map[string]types.AttributeValue{
+				"id": &types.AttributeValueMemberS{Value: flag.ID},
+			},
+			UpdateExpression:          aws.String("SET " + strings.Join(set, ", ")),
+			ConditionExpression:       condition,
+			ExpressionAttributeNames:  names,
+			ExpressionAttributeValues: values,
+		})
+		return err
+	}
+
+	// The evidence of a flag raised again is appended to the evidence it was raised with, until there is plenty
+	names["#evidence"] = "evidence"
+	values[":evidence"] = &types.AttributeValueMemberL{Value: evidence}
+	values[":empty"] = &types.AttributeValueMemberL{Value: []types.AttributeValue{}}
+	values[":maxEvidence"] = &types.AttributeValueMemberN{Value: strconv.Itoa(maxEvidence)}
+	err := update(
+		append(set, "#evidence = list_append(if_not_exists(#evidence, :empty), :evidence)"),
+		aws.String("attribute_not_exists(#evidence) OR size(#evidence) < :maxEvidence"),
+	)
+
+	var condErr *types.ConditionalCheckFailedException
+	if errors.As(err, &condErr) {
+		delete(names, "#evidence")
+		delete(values, ":evidence")
+		delete(values, ":empty")
+		delete(values, ":maxEvidence")
+		err = update(set, nil)
+	}
+	if err != nil {
+		return fmt.Errorf("storing anomaly flag: %w", err)
+	}
+
+	return nil
+}
+
+func (d *DynamoAnomalyTable) List(ctx context.Context, limit int, cursor *string) (*ListResult, error) {
+	input := &dynamodb.ScanInput{
+		TableName: aws.String(d.tableName),
+		Limit:     aws.Int32(int32(limit)),
+	}
+
+	// Decode cursor if provided
+	if cursor != nil && *cursor != "" {
+		exclusiveStartKey, err := decodeToken(*cursor)
+		if err != nil {
+			return nil, fmt.Errorf("decoding cursor: %w", err)
+		}
+		input.ExclusiveStartKey = exclusiveStartKey
+	}
+
+	result, err := d.client.Scan(ctx, input)
+	if err != nil {
+		return nil, fmt.Errorf("scanning anomaly flags: %w", err)
+	}
+
+	flags := make([]Flag, 0, len(result.Items))
+	for _, item := range result.Items {
+		flag, err := d.unmarshalFlag(item)
+		if err != nil {
+			return nil, err
+		}
+		flags = append(flags, *flag)
+	}
+
+	// Encode nextCursor if there are more results
+	var nextCursor *string
+	if result.LastEvaluatedKey != nil {
+		token, err := encodeToken(result.LastEvaluatedKey)
+		if err != nil {
+			return nil, fmt.Errorf("encoding cursor: %w", err)
+		}
+		nextCursor = aws.String(token)
+	}
+
+	return &ListResult{
+		Flags:  flags,
+		Cursor: nextCursor,
+	}, nil
+}
+
+func (d *DynamoAnomalyTable) ListByNode(ctx context.Context, node did.DID, since time.Time) ([]Flag, error) {
+	flags := make([]Flag, 0)
+	var exclusiveStartKey map[string]types.AttributeValue
+
+	// Keep querying until we get all results (handle pagination)
+	for {
+		input := &dynamodb.QueryInput{
+			TableName:              aws.String(d.tableName),
+			IndexName:              aws.String(d.nodeIndexName),
+			KeyConditionExpression: aws.String("node = :node AND detectedAt >= :since"),
+			ExpressionAttributeValues: map[string]types.AttributeValue{
+				":node":  &types.AttributeValueMemberS{Value: node.String()},
+				":since": &types.AttributeValueMemberS{Value: since.UTC().Format(time.RFC3339)},
+			},
+		}
+
+		// Set the pagination token if we have one
+		if exclusiveStartKey != nil {
+			input.ExclusiveStartKey = exclusiveStartKey
+		}
+
+		result, err := d.client.Query(ctx, input)
+		if err != nil {
+			return nil, fmt.Errorf("querying anomaly flags by node: %w", err)
+		}
+
+		for _, item := range result.Items {
+			flag, err := d.unmarshalFlag(item)
+			if err != nil {
+				return nil, err
+			}
+			flags = append(flags, *flag)
+		}
+
+		// Check if there are more results to fetch
+		if result.LastEvaluatedKey == nil {
+			break
+		}
+		exclusiveStartKey = result.LastEvaluatedKey
+	}
+
+	return flags, nil
+}
+
+// anomalyRecord is the internal struct for marshaling to and unmarshaling from DynamoDB
+type anomalyRecord struct {
+	ID         string    `dynamodbav:"id"`
+	Kind       string    `dynamodbav:"kind"`
+	Severity   string    `dynamodbav:"severity"`
+	Node       string    `dynamodbav:"node"`
+	Space      string    `dynamodbav:"space,omitempty"`
+	Message    string    `dynamodbav:"message"`
+	Evidence   []string  `dynamodbav:"evidence"`
+	DetectedAt time.Time `dynamodbav:"detectedAt"`
+}
+
+func newAnomalyRecord(flag Flag) *anomalyRecord {
+	evidence := make([]string, 0, len(flag.Evidence))
+	for _, cause := range flag.Evidence {
+		evidence = append(evidence, cause.String())
+	}
+
+	space := ""
+	if flag.Space != did.Undef {
+		space = flag.Space.String()
+	}
+
+	return &anomalyRecord{
+		ID:         flag.ID,
+		Kind:       string(flag.Kind),
+		Severity:   string(flag.Severity),
+		Node:       flag.Node.String(),
+		Space:      space,
+		Message:    flag.Message,
+		Evidence:   evidence,
+		DetectedAt: flag.DetectedAt.UTC(),
+	}
+}
+
+func (d *DynamoAnomalyTable) unmarshalFlag(item map[string]types.AttributeValue) (*Flag, error) {
+	var record anomalyRecord
+	if err := attributevalue.UnmarshalMap(item, &record); err != nil {
+		return nil, fmt.Errorf("unmarshaling anomaly flag: %w", err)
+	}
+
+	node, err := did.Parse(record.Node)
+	if err != nil {
+		return nil, fmt.Errorf("parsing node DID: %w", err)
+	}
+
+	space := did.Undef
+	if record.Space != "" {
+		space, err = did.Parse(record.Space)
+		if err != nil {
+			return nil, fmt.Errorf("parsing space DID: %w", err)
+		}
+	}
+
+	evidence := make([]ucan.Link, 0, len(record.Evidence))
+	for _, e := range record.Evidence {
+		c, err := cid.Decode(e)
+		if err != nil {
+			return nil, fmt.Errorf("parsing evidence CID: %w", err)
+		}
+		evidence = append(evidence, cidlink.Link{Cid: c})
+	}
+
+	return &Flag{
+		ID:         record.ID,
+		Kind:       Kind(record.Kind),
+		Severity:   Severity(record.Severity),
+		Node:       node,
+		Space:      space,
+		Message:    record.Message,
+		Evidence:   evidence,
+		DetectedAt: record.DetectedAt,
+	}, nil
+}
+
+// encodeToken encodes a DynamoDB LastEvaluatedKey from a table scan into a base64 string token.
+// The table only has a hash key, so the token is just the encoded flag ID.
+func encodeToken(key map[string]types.AttributeValue) (string, error) {
+	id, ok := key["id"].(*types.AttributeValueMemberS)
+	if !ok {
+		return "", fmt.Errorf("unexpected last evaluated key")
+	}
+
+	return base64.URLEncoding.EncodeToString([]byte(id.Value)), nil
+}
+
+// decodeToken decodes a base64 string token into a DynamoDB ExclusiveStartKey
+func decodeToken(token string) (map[string]types.AttributeValue, error) {
+	id, err := base64.URLEncoding.DecodeString(token)
+	if err != nil {
+		return nil, fmt.Errorf("decoding base64 token: %w", err)
+	}
+
+	return map[string]types.AttributeValue{
+		"id": &types.AttributeValueMemberS{Value: string(id)},
+	}, nil
+}
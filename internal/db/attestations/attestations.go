@@ -0,0 +1,37 @@
+package attestations
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/storacha/go-ucanto/core/receipt"
+	"github.com/storacha/go-ucanto/did"
+	"github.com/storacha/go-ucanto/ucan"
+)
+
+// Attestation is the signed receipt committing to the egress a node is paid for in a month
+type Attestation struct {
+	Node did.DID
+	// Month is the first day of the attested month
+	Month time.Time
+	// Cause is the attest invocation the receipt was issued for
+	Cause    ucan.Link
+	Receipt  receipt.AnyReceipt
+	IssuedAt time.Time
+}
+
+var (
+	ErrNotFound      = errors.New("attestation not found")
+	ErrAlreadyIssued = errors.New("attestation already issued")
+)
+
+type AttestationTable interface {
+	// Put stores the attestation of the node for the month. Attestations are immutable, it returns
+	// ErrAlreadyIssued if the node has one for the month already.
+	Put(ctx context.Context, attestation Attestation) error
+	// Get returns the attestation of the node for the month of the given time, or ErrNotFound if there is none
+	Get(ctx context.Context, node did.DID, month time.Time) (*Attestation, error)
+	// GetByCause returns the attestation issued for the given attest invocation, or ErrNotFound if there is none
+	GetByCause(ctx context.Context, cause ucan.Link) (*Attestation, error)
+}
@@ -0,0 +1,180 @@
+package attestations
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/ipfs/go-cid"
+	cidlink "github.com/ipld/go-ipld-prime/linking/cid"
+	"github.com/storacha/go-ucanto/core/receipt"
+	"github.com/storacha/go-ucanto/did"
+	"github.com/storacha/go-ucanto/ucan"
+
+	"github.com/storacha/etracker/internal/db/paging"
+)
+
+var _ AttestationTable = (*DynamoAttestationTable)(nil)
+
+const monthFormat = "2006-01"
+
+type DynamoAttestationTable struct {
+	client         *dynamodb.Client
+	tableName      string
+	causeIndexName string
+}
+
+func NewDynamoAttestationTable(client *dynamodb.Client, tableName string, causeIndexName string) *DynamoAttestationTable {
+	return &DynamoAttestationTable{client, tableName, causeIndexName}
+}
+
+func (d *DynamoAttestationTable) Put(ctx context.Context, attestation Attestation) error {
+	// binary values must be base64-encoded before sending them to DynamoDB
+	archBytes, err := io.ReadAll(attestation.Receipt.Archive())
+	if err != nil {
+		return fmt.Errorf("reading receipt archive: %w", err)
+	}
+	rcptBytes := make([]byte, base64.StdEncoding.EncodedLen(len(archBytes)))
+	base64.StdEncoding.Encode(rcptBytes, archBytes)
+
+	item, err := attributevalue.MarshalMap(attestationRecord{
+		Node:     attestation.Node.String(),
+		Month:    attestation.Month.UTC().Format(monthFormat),
+		Cause:    attestation.Cause.String(),
+		Receipt:  rcptBytes,
+		IssuedAt: attestation.IssuedAt.UTC(),
+	})
+	if err != nil {
+		return fmt.Errorf("serializing attestation: %w", err)
+	}
+
+	_, err = d.client.PutItem(ctx, &dynamodb.PutItemInput{
+		TableName:           aws.String(d.tableName),
+		Item:                item,
+		ConditionExpression: aws.String("attribute_not_exists(node)"),
+	})
+	if err != nil {
+		var condErr *types.ConditionalCheckFailedException
+		if errors.As(err, &condErr) {
+			return ErrAlreadyIssued
+		}
+
+		return fmt.Errorf("storing attestation: %w", err)
+	}
+
+	return nil
+}
+
+func (d *DynamoAttestationTable) Get(ctx context.Context, node did.DID, month time.Time) (*Attestation, error) {
+	result, err := d.client.GetItem(ctx, &dynamodb.GetItemInput{
+		TableName: aws.String(d.tableName),
+		Key: map[string]types.AttributeValue{
+			"node":  &types.AttributeValueMemberS{Value: node.String()},
+			"month": &types.AttributeValueMemberS{Value: month.UTC().Format(monthFormat)},
+		},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("getting attestation: %w", err)
+	}
+
+	if result.Item == nil {
+		return nil, ErrNotFound
+	}
+
+	return unmarshalAttestation(result.Item)
+}
+
+// GetByCause looks up the key of the attestation in the cause index, which projects keys only
+func (d *DynamoAttestationTable) GetByCause(ctx context.Context, cause ucan.Link) (*Attestation, error) {
+	item, ok, err := paging.First(ctx, d.client, &dynamodb.QueryInput{
+		TableName:              aws.String(d.tableName),
+		IndexName:              aws.String(d.causeIndexName),
+		KeyConditionExpression: aws.String("cause = :cause"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":cause": &types.AttributeValueMemberS{Value: cause.String()},
+		},
+		Limit: aws.Int32(1),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("querying attestations by cause: %w", err)
+	}
+
+	if !ok {
+		return nil, ErrNotFound
+	}
+
+	var key struct {
+		Node  string `dynamodbav:"node"`
+		Month string `dynamodbav:"month"`
+	}
+	if err := attributevalue.UnmarshalMap(item, &key); err != nil {
+		return nil, fmt.Errorf("unmarshaling attestation key: %w", err)
+	}
+
+	node, err := did.Parse(key.Node)
+	if err != nil {
+		return nil, fmt.Errorf("parsing node DID: %w", err)
+	}
+	month, err := time.Parse(monthFormat, key.Month)
+	if err != nil {
+		return nil, fmt.Errorf("parsing month: %w", err)
+	}
+
+	return d.Get(ctx, node, month)
+}
+
+type attestationRecord struct {
+	Node     string    `dynamodbav:"node"`
+	Month    string    `dynamodbav:"month"`
+	Cause    string    `dynamodbav:"cause"`
+	Receipt  []byte    `dynamodbav:"receipt"`
+	IssuedAt time.Time `dynamodbav:"issuedAt"`
+}
+
+func unmarshalAttestation(item map[string]types.AttributeValue) (*Attestation, error) {
+	var record attestationRecord
+	if err := attributevalue.UnmarshalMap(item, &record); err != nil {
+		return nil, fmt.Errorf("unmarshaling attestation: %w", err)
+	}
+
+	node, err := did.Parse(record.Node)
+	if err != nil {
+		return nil, fmt.Errorf("parsing node DID: %w", err)
+	}
+
+	month, err := time.Parse(monthFormat, record.Month)
+	if err != nil {
+		return nil, fmt.Errorf("parsing month: %w", err)
+	}
+
+	c, err := cid.Decode(record.Cause)
+	if err != nil {
+		return nil, fmt.Errorf("parsing cause CID: %w", err)
+	}
+
+	archBytes := make([]byte, base64.StdEncoding.DecodedLen(len(record.Receipt)))
+	n, err := base64.StdEncoding.Decode(archBytes, record.Receipt)
+	if err != nil {
+		return nil, fmt.Errorf("decoding receipt archive: %w", err)
+	}
+
+	rcpt, err := receipt.Extract(archBytes[:n])
+	if err != nil {
+		return nil, fmt.Errorf("extracting receipt: %w", err)
+	}
+
+	return &Attestation{
+		Node:     node,
+		Month:    month,
+		Cause:    cidlink.Link{Cid: c},
+		Receipt:  rcpt,
+		IssuedAt: record.IssuedAt,
+	}, nil
+}
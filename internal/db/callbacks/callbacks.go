@@ -0,0 +1,32 @@
+package callbacks
+
+import (
+	"context"
+	"errors"
+	"net/url"
+
+	"github.com/storacha/go-ucanto/did"
+)
+
+// Registration is the URL consolidation results of a node are pushed to
+type Registration struct {
+	Node     did.DID
+	Callback *url.URL
+}
+
+type ListResult struct {
+	Registrations []Registration
+	Cursor        *string
+}
+
+var ErrNotFound = errors.New("callback not found")
+
+type CallbackTable interface {
+	// Get returns the callback URL registered for the node, or ErrNotFound if there is none
+	Get(ctx context.Context, node did.DID) (*url.URL, error)
+	// Put registers the callback URL of the node, replacing any previous one
+	Put(ctx context.Context, node did.DID, callback *url.URL) error
+	// Delete removes the callback URL registered for the node
+	Delete(ctx context.Context, node did.DID) error
+	List(ctx context.Context, limit int, cursor *string) (*ListResult, error)
+}
@@ -0,0 +1,178 @@
+package callbacks
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"net/url"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/storacha/go-ucanto/did"
+)
+
+var _ CallbackTable = (*DynamoCallbackTable)(nil)
+
+type DynamoCallbackTable struct {
+	client    *dynamodb.Client
+	tableName string
+}
+
+func NewDynamoCallbackTable(client *dynamodb.Client, tableName string) *DynamoCallbackTable {
+	return &DynamoCallbackTable{client, tableName}
+}
+
+func (d *DynamoCallbackTable) Get(ctx context.Context, node did.DID) (*url.URL, error) {
+	result, err := d.client.GetItem(ctx, &dynamodb.GetItemInput{
+		TableName: aws.String(d.tableName),
+		Key: map[string]types.AttributeValue{
+			"node": &types.AttributeValueMemberS{Value: node.String()},
+		},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("getting callback: %w", err)
+	}
+
+	if result.Item == nil {
+		return nil, ErrNotFound
+	}
+
+	registration, err := unmarshalRegistration(result.Item)
+	if err != nil {
+		return nil, err
+	}
+
+	return registration.Callback, nil
+}
+
+func (d *DynamoCallbackTable) Put(ctx context.Context, node did.DID, callback *url.URL) error {
+	item, err := attributevalue.MarshalMap(callbackRecord{
+		Node:     node.String(),
+		Callback: callback.String(),
+	})
+	if err != nil {
+		return fmt.Errorf("serializing callback: %w", err)
+	}
+
+	_, err = d.client.PutItem(ctx, &dynamodb.PutItemInput{
+		TableName: aws.String(d.tableName),
+		Item:      item,
+	})
+	if err != nil {
+		return fmt.Errorf("storing callback: %w", err)
+	}
+
+	return nil
+}
+
+func (d *DynamoCallbackTable) Delete(ctx context.Context, node did.DID) error {
+	_, err := d.client.DeleteItem(ctx, &dynamodb.DeleteItemInput{
+		TableName: aws.String(d.tableName),
+		Key: map[string]types.AttributeValue{
+			"node": &types.AttributeValueMemberS{Value: node.String()},
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("deleting callback: %w", err)
+	}
+
+	return nil
+}
+
+func (d *DynamoCallbackTable) List(ctx context.Context, limit int, cursor *string) (*ListResult, error) {
+	input := &dynamodb.ScanInput{
+		TableName: aws.String(d.tableName),
+		Limit:     aws.Int32(int32(limit)),
+	}
+
+	// Decode cursor if provided
+	if cursor != nil && *cursor != "" {
+		exclusiveStartKey, err := decodeToken(*cursor)
+		if err != nil {
+			return nil, fmt.Errorf("decoding cursor: %w", err)
+		}
+		input.ExclusiveStartKey = exclusiveStartKey
+	}
+
+	result, err := d.client.Scan(ctx, input)
+	if err != nil {
+		return nil, fmt.Errorf("scanning callbacks: %w", err)
+	}
+
+	registrations := make([]Registration, 0, len(result.Items))
+	for _, item := range result.Items {
+		registration, err := unmarshalRegistration(item)
+		if err != nil {
+			return nil, err
+		}
+		registrations = append(registrations, *registration)
+	}
+
+	// Encode nextCursor if there are more results
+	var nextCursor *string
+	if result.LastEvaluatedKey != nil {
+		token, err := encodeToken(result.LastEvaluatedKey)
+		if err != nil {
+			return nil, fmt.Errorf("encoding cursor: %w", err)
+		}
+		nextCursor = aws.String(token)
+	}
+
+	return &ListResult{
+		Registrations: registrations,
+		Cursor:        nextCursor,
+	}, nil
+}
+
+// callbackRecord is the internal struct for marshaling to and unmarshaling from DynamoDB
+type callbackRecord struct {
+	Node     string `dynamodbav:"node"`
+	Callback string `dynamodbav:"callback"`
+}
+
+func unmarshalRegistration(item map[string]types.AttributeValue) (*Registration, error) {
+	var record callbackRecord
+	if err := attributevalue.UnmarshalMap(item, &record); err != nil {
+		return nil, fmt.Errorf("unmarshaling callback: %w", err)
+	}
+
+	node, err := did.Parse(record.Node)
+	if err != nil {
+		return nil, fmt.Errorf("parsing node DID: %w", err)
+	}
+
+	callback, err := url.Parse(record.Callback)
+	if err != nil {
+		return nil, fmt.Errorf("parsing callback URL: %w", err)
+	}
+
+	return &Registration{
+		Node:     node,
+		Callback: callback,
+	}, nil
+}
+
+// encodeToken encodes a DynamoDB LastEvaluatedKey from a table scan into a base64 string token.
+// The table only has a hash key, so the token is just the encoded node DID.
+func encodeToken(key map[string]types.AttributeValue) (string, error) {
+	node, ok := key["node"].(*types.AttributeValueMemberS)
+	if !ok {
+		return "", fmt.Errorf("unexpected last evaluated key")
+	}
+
+	return base64.URLEncoding.EncodeToString([]byte(node.Value)), nil
+}
+
+// decodeToken decodes a base64 string token into a DynamoDB ExclusiveStartKey
+func decodeToken(token string) (map[string]types.AttributeValue, error) {
+	node, err := base64.URLEncoding.DecodeString(token)
+	if err != nil {
+		return nil, fmt.Errorf("decoding base64 token: %w", err)
+	}
+
+	return map[string]types.AttributeValue{
+		"node": &types.AttributeValueMemberS{Value: string(node)},
+	}, nil
+}
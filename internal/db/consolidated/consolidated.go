@@ -3,6 +3,7 @@ package consolidated
 import (
 	"context"
 	"errors"
+	"iter"
 	"time"
 
 	capegress "github.com/storacha/go-libstoracha/capabilities/space/egress"
@@ -11,18 +12,122 @@ import (
 	"github.com/storacha/go-ucanto/ucan"
 )
 
+// Status is the state of a consolidated batch
+type Status string
+
+const (
+	// StatusCounted batches were counted towards node and space totals as soon as they were consolidated
+	StatusCounted Status = "counted"
+	// StatusHeld batches were consolidated but are not counted until an admin reviews them
+	StatusHeld Status = "held"
+	// StatusApproved batches were held and then counted after an admin approved them
+	StatusApproved Status = "approved"
+	// StatusRejected batches were held and then rejected by an admin, they are never counted
+	StatusRejected Status = "rejected"
+)
+
 type ConsolidatedRecord struct {
-	Cause       ucan.Link
+	Cause ucan.Link
+	// TrackCause is the `space/egress/track` invocation the batch was tracked by
+	TrackCause ucan.Link
+	// Batch is the CID of the batch of retrieval receipts
+	Batch       ucan.Link
 	Node        did.DID
 	TotalEgress uint64
 	Receipt     receipt.AnyReceipt
 	ProcessedAt time.Time
+	Status      Status
+	// HeldEgress is the egress pending review for held batches
+	HeldEgress uint64
+	// HoldReasons describes why the batch was held for review
+	HoldReasons []string
+	// SpaceEgress is the breakdown of the egress per space, for held batches applied to space stats on approval
+	SpaceEgress map[did.DID]uint64
+	// ReviewedAt is when an admin approved or rejected a held batch
+	ReviewedAt time.Time
+	// ApprovedAt is when an admin approved a held batch, its egress is counted from then on
+	ApprovedAt time.Time
+	// ReviewReason is the reason given by the admin when rejecting a held batch
+	ReviewReason string
 }
 
-var ErrNotFound = errors.New("record not found")
+// CountedAt is when the egress of the record was counted: when it was approved for batches held for review, when
+// it was consolidated for the others
+func (r ConsolidatedRecord) CountedAt() time.Time {
+	if r.Status == StatusApproved {
+		return r.ApprovedAt
+	}
+	return r.ProcessedAt
+}
+
+type ListHeldResult struct {
+	Records []ConsolidatedRecord
+	Cursor  *string
+}
+
+var (
+	ErrNotFound      = errors.New("record not found")
+	ErrAlreadyExists = errors.New("record already exists")
+	ErrNotHeld       = errors.New("record is not held for review")
+)
 
 type ConsolidatedTable interface {
-	Add(ctx context.Context, cause ucan.Link, node did.DID, totalEgress uint64, rcpt capegress.ConsolidateReceipt) error
+	// Add stores a consolidated record along with the egress of each space, so it can be counted again if
+	// counting is interrupted. It returns ErrAlreadyExists if there is a record for the cause already.
+	Add(ctx context.Context, cause ucan.Link, trackCause ucan.Link, batch ucan.Link, node did.DID, totalEgress uint64, rcpt capegress.ConsolidateReceipt, spaceEgress map[did.DID]uint64) error
 	Get(ctx context.Context, cause ucan.Link) (*ConsolidatedRecord, error)
-	GetStatsByNode(ctx context.Context, node did.DID, since time.Time) ([]ConsolidatedRecord, error)
+	// GetByTrackCause returns the record of the batch tracked by the given `space/egress/track` invocation, or ErrNotFound if there is none
+	GetByTrackCause(ctx context.Context, trackCause ucan.Link) (*ConsolidatedRecord, error)
+	// GetByBatch returns the record of the given batch, or ErrNotFound if there is none
+	GetByBatch(ctx context.Context, batch ucan.Link) (*ConsolidatedRecord, error)
+	// GetStatsByNode yields every record of the given node processed since the given time
+	GetStatsByNode(ctx context.Context, node did.DID, since time.Time) iter.Seq2[ConsolidatedRecord, error]
+	// GetReviewedByNode yields every record of the given node approved or rejected since the given time
+	GetReviewedByNode(ctx context.Context, node did.DID, since time.Time) iter.Seq2[ConsolidatedRecord, error]
+	// GetFirstByNode returns the earliest consolidated record for the given node, or ErrNotFound if there is none
+	GetFirstByNode(ctx context.Context, node did.DID) (*ConsolidatedRecord, error)
+	// Hold stores a consolidated record without counting its egress until it is approved.
+	// It returns ErrAlreadyExists if there is a record for the cause already.
+	Hold(ctx context.Context, cause ucan.Link, trackCause ucan.Link, batch ucan.Link, node did.DID, totalEgress uint64, rcpt capegress.ConsolidateReceipt, reasons []string, spaceEgress map[did.DID]uint64) error
+	// ListHeld lists the records held for review
+	ListHeld(ctx context.Context, limit int, cursor *string) (*ListHeldResult, error)
+	// Approve counts the egress of a held record from the given time on. It returns ErrNotHeld if the record is
+	// not held.
+	Approve(ctx context.Context, cause ucan.Link, approvedAt time.Time) error
+	// Reject replaces the receipt of a held record with the given error receipt. It returns ErrNotHeld if the record is not held.
+	Reject(ctx context.Context, cause ucan.Link, reason string, rcpt capegress.ConsolidateReceipt) error
+}
+
+// GetCountedByNode yields every record of the given node whose egress was counted since the given time, which
+// for batches held for review is when they were approved. Held and rejected records have no egress counted and
+// are not yielded.
+func GetCountedByNode(ctx context.Context, table ConsolidatedTable, node did.DID, since time.Time) iter.Seq2[ConsolidatedRecord, error] {
+	return func(yield func(ConsolidatedRecord, error) bool) {
+		for record, err := range table.GetReviewedByNode(ctx, node, since) {
+			if err != nil {
+				yield(ConsolidatedRecord{}, err)
+				return
+			}
+			if record.Status != StatusApproved {
+				continue
+			}
+			if !yield(record, nil) {
+				return
+			}
+		}
+
+		// batches are approved after they were consolidated, so those consolidated since were yielded already
+		for record, err := range table.GetStatsByNode(ctx, node, since) {
+			if err != nil {
+				yield(ConsolidatedRecord{}, err)
+				return
+			}
+			if record.Status == StatusApproved || record.TotalEgress == 0 {
+				continue
+			}
+			if !yield(record, nil) {
+				return
+			}
+		}
+	}
 }
@@ -3,8 +3,11 @@ package consolidated
 import (
 	"context"
 	"encoding/base64"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"iter"
 	"time"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
@@ -17,26 +20,67 @@ import (
 	"github.com/storacha/go-ucanto/core/receipt"
 	"github.com/storacha/go-ucanto/did"
 	"github.com/storacha/go-ucanto/ucan"
+
+	"github.com/storacha/etracker/internal/db/paging"
 )
 
 var _ ConsolidatedTable = (*DynamoConsolidatedTable)(nil)
 
 type DynamoConsolidatedTable struct {
-	client             *dynamodb.Client
-	tableName          string
-	nodeStatsIndexName string
+	client              *dynamodb.Client
+	tableName           string
+	nodeStatsIndexName  string
+	heldIndexName       string
+	trackCauseIndexName string
+	batchIndexName      string
+	reviewedIndexName   string
 }
 
-func NewDynamoConsolidatedTable(client *dynamodb.Client, tableName string, nodeStatsIndexName string) *DynamoConsolidatedTable {
-	return &DynamoConsolidatedTable{client, tableName, nodeStatsIndexName}
+func NewDynamoConsolidatedTable(client *dynamodb.Client, tableName string, nodeStatsIndexName string, heldIndexName string, trackCauseIndexName string, batchIndexName string, reviewedIndexName string) *DynamoConsolidatedTable {
+	return &DynamoConsolidatedTable{client, tableName, nodeStatsIndexName, heldIndexName, trackCauseIndexName, batchIndexName, reviewedIndexName}
 }
 
-func (d *DynamoConsolidatedTable) Add(ctx context.Context, cause ucan.Link, node did.DID, totalEgress uint64, rcpt capegress.ConsolidateReceipt) error {
-	record, err := newConsolidatedRecord(cause, node, totalEgress, rcpt)
+func (d *DynamoConsolidatedTable) Add(ctx context.Context, cause ucan.Link, trackCause ucan.Link, batch ucan.Link, node did.DID, totalEgress uint64, rcpt capegress.ConsolidateReceipt, spaceEgress map[did.DID]uint64) error {
+	record, err := newConsolidatedRecord(cause, trackCause, batch, node, totalEgress, rcpt)
 	if err != nil {
 		return fmt.Errorf("creating consolidated record: %w", err)
 	}
 
+	record.SpaceEgress = marshalSpaceEgress(spaceEgress)
+
+	return d.put(ctx, record)
+}
+
+func (d *DynamoConsolidatedTable) Hold(ctx context.Context, cause ucan.Link, trackCause ucan.Link, batch ucan.Link, node did.DID, totalEgress uint64, rcpt capegress.ConsolidateReceipt, reasons []string, spaceEgress map[did.DID]uint64) error {
+	record, err := newConsolidatedRecord(cause, trackCause, batch, node, 0, rcpt)
+	if err != nil {
+		return fmt.Errorf("creating consolidated record: %w", err)
+	}
+
+	// Held records are stored with no total egress so they are not counted in node stats until approved.
+	// The heldSince attribute makes them show up in the sparse held index.
+	record.Status = string(StatusHeld)
+	record.HeldEgress = totalEgress
+	record.HoldReasons = reasons
+	record.HeldSince = &record.ProcessedAt
+	record.SpaceEgress = marshalSpaceEgress(spaceEgress)
+
+	return d.put(ctx, record)
+}
+
+func marshalSpaceEgress(spaceEgress map[did.DID]uint64) map[string]uint64 {
+	if len(spaceEgress) == 0 {
+		return nil
+	}
+
+	marshaled := make(map[string]uint64, len(spaceEgress))
+	for space, egress := range spaceEgress {
+		marshaled[space.String()] = egress
+	}
+	return marshaled
+}
+
+func (d *DynamoConsolidatedTable) put(ctx context.Context, record *consolidatedRecord) error {
 	item, err := attributevalue.MarshalMap(record)
 	if err != nil {
 		return fmt.Errorf("serializing consolidated record: %w", err)
@@ -48,6 +92,11 @@ func (d *DynamoConsolidatedTable) Add(ctx context.Context, cause ucan.Link, node
 		ConditionExpression: aws.String("attribute_not_exists(cause)"),
 	})
 	if err != nil {
+		var condErr *types.ConditionalCheckFailedException
+		if errors.As(err, &condErr) {
+			return ErrAlreadyExists
+		}
+
 		return fmt.Errorf("storing consolidated record: %w", err)
 	}
 
@@ -72,19 +121,157 @@ func (d *DynamoConsolidatedTable) Get(ctx context.Context, cause ucan.Link) (*Co
 	return d.unmarshalRecord(result.Item)
 }
 
-func (d *DynamoConsolidatedTable) GetStatsByNode(ctx context.Context, node did.DID, since time.Time) ([]ConsolidatedRecord, error) {
-	// Query the index (partition key: node, range key: processedAt)
+func (d *DynamoConsolidatedTable) GetByTrackCause(ctx context.Context, trackCause ucan.Link) (*ConsolidatedRecord, error) {
+	return d.getByIndex(ctx, d.trackCauseIndexName, "trackCause", trackCause)
+}
+
+func (d *DynamoConsolidatedTable) GetByBatch(ctx context.Context, batch ucan.Link) (*ConsolidatedRecord, error) {
+	return d.getByIndex(ctx, d.batchIndexName, "batch", batch)
+}
+
+// getByIndex fetches the record whose attr has the given value, using an index that projects keys only
+func (d *DynamoConsolidatedTable) getByIndex(ctx context.Context, indexName string, attr string, value ucan.Link) (*ConsolidatedRecord, error) {
+	item, ok, err := paging.First(ctx, d.client, &dynamodb.QueryInput{
+		TableName:              aws.String(d.tableName),
+		IndexName:              aws.String(indexName),
+		KeyConditionExpression: aws.String("#attr = :value"),
+		ExpressionAttributeNames: map[string]string{
+			"#attr": attr,
+		},
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":value": &types.AttributeValueMemberS{Value: value.String()},
+		},
+		Limit: aws.Int32(1),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("querying consolidated records by %s: %w", attr, err)
+	}
+
+	if !ok {
+		return nil, ErrNotFound
+	}
+
+	var key struct {
+		Cause string `dynamodbav:"cause"`
+	}
+	if err := attributevalue.UnmarshalMap(item, &key); err != nil {
+		return nil, fmt.Errorf("unmarshaling consolidated record key: %w", err)
+	}
+
+	c, err := cid.Decode(key.Cause)
+	if err != nil {
+		return nil, fmt.Errorf("parsing cause CID: %w", err)
+	}
+
+	return d.Get(ctx, cidlink.Link{Cid: c})
+}
+
+func (d *DynamoConsolidatedTable) GetStatsByNode(ctx context.Context, node did.DID, since time.Time) iter.Seq2[ConsolidatedRecord, error] {
+	return func(yield func(ConsolidatedRecord, error) bool) {
+		// Query the index (partition key: node, range key: processedAt)
+		items := paging.Query(ctx, d.client, &dynamodb.QueryInput{
+			TableName:              aws.String(d.tableName),
+			IndexName:              aws.String(d.nodeStatsIndexName),
+			KeyConditionExpression: aws.String("node = :node AND processedAt >= :since"),
+			ExpressionAttributeValues: map[string]types.AttributeValue{
+				":node":  &types.AttributeValueMemberS{Value: node.String()},
+				":since": &types.AttributeValueMemberS{Value: since.UTC().Format(time.RFC3339)},
+			},
+		})
+
+		for item, err := range items {
+			if err != nil {
+				yield(ConsolidatedRecord{}, fmt.Errorf("querying consolidated records by node: %w", err))
+				return
+			}
+
+			record, err := d.unmarshalRecord(item)
+			if err != nil {
+				yield(ConsolidatedRecord{}, err)
+				return
+			}
+
+			if !yield(*record, nil) {
+				return
+			}
+		}
+	}
+}
+
+func (d *DynamoConsolidatedTable) GetReviewedByNode(ctx context.Context, node did.DID, since time.Time) iter.Seq2[ConsolidatedRecord, error] {
+	return func(yield func(ConsolidatedRecord, error) bool) {
+		// Query the sparse index (partition key: node, range key: reviewedAt) which only contains reviewed records
+		items := paging.Query(ctx, d.client, &dynamodb.QueryInput{
+			TableName:              aws.String(d.tableName),
+			IndexName:              aws.String(d.reviewedIndexName),
+			KeyConditionExpression: aws.String("node = :node AND reviewedAt >= :since"),
+			ExpressionAttributeValues: map[string]types.AttributeValue{
+				":node":  &types.AttributeValueMemberS{Value: node.String()},
+				":since": &types.AttributeValueMemberS{Value: since.UTC().Format(time.RFC3339)},
+			},
+		})
+
+		for item, err := range items {
+			if err != nil {
+				yield(ConsolidatedRecord{}, fmt.Errorf("querying reviewed records by node: %w", err))
+				return
+			}
+
+			record, err := d.unmarshalRecord(item)
+			if err != nil {
+				yield(ConsolidatedRecord{}, err)
+				return
+			}
+
+			if !yield(*record, nil) {
+				return
+			}
+		}
+	}
+}
+
+func (d *DynamoConsolidatedTable) GetFirstByNode(ctx context.Context, node did.DID) (*ConsolidatedRecord, error) {
 	result, err := d.client.Query(ctx, &dynamodb.QueryInput{
 		TableName:              aws.String(d.tableName),
 		IndexName:              aws.String(d.nodeStatsIndexName),
-		KeyConditionExpression: aws.String("node = :node AND processedAt >= :since"),
+		KeyConditionExpression: aws.String("node = :node"),
 		ExpressionAttributeValues: map[string]types.AttributeValue{
-			":node":  &types.AttributeValueMemberS{Value: node.String()},
-			":since": &types.AttributeValueMemberS{Value: since.UTC().Format(time.RFC3339)},
+			":node": &types.AttributeValueMemberS{Value: node.String()},
 		},
+		ScanIndexForward: aws.Bool(true),
+		Limit:            aws.Int32(1),
 	})
 	if err != nil {
-		return nil, fmt.Errorf("querying consolidated records by node: %w", err)
+		return nil, fmt.Errorf("querying first consolidated record by node: %w", err)
+	}
+
+	if len(result.Items) == 0 {
+		return nil, ErrNotFound
+	}
+
+	return d.unmarshalRecord(result.Items[0])
+}
+
+func (d *DynamoConsolidatedTable) ListHeld(ctx context.Context, limit int, cursor *string) (*ListHeldResult, error) {
+	// Scan the sparse index which only contains held items (items with heldSince attribute)
+	input := &dynamodb.ScanInput{
+		TableName: aws.String(d.tableName),
+		IndexName: aws.String(d.heldIndexName),
+		Limit:     aws.Int32(int32(limit)),
+	}
+
+	// Decode cursor if provided
+	if cursor != nil && *cursor != "" {
+		exclusiveStartKey, err := decodeHeldToken(*cursor)
+		if err != nil {
+			return nil, fmt.Errorf("decoding cursor: %w", err)
+		}
+		input.ExclusiveStartKey = exclusiveStartKey
+	}
+
+	result, err := d.client.Scan(ctx, input)
+	if err != nil {
+		return nil, fmt.Errorf("scanning held records from index: %w", err)
 	}
 
 	records := make([]ConsolidatedRecord, 0, len(result.Items))
@@ -96,37 +283,138 @@ func (d *DynamoConsolidatedTable) GetStatsByNode(ctx context.Context, node did.D
 		records = append(records, *record)
 	}
 
-	return records, nil
+	// Encode nextCursor if there are more results
+	var nextCursor *string
+	if result.LastEvaluatedKey != nil {
+		token, err := encodeHeldToken(result.LastEvaluatedKey)
+		if err != nil {
+			return nil, fmt.Errorf("encoding cursor: %w", err)
+		}
+		nextCursor = aws.String(token)
+	}
+
+	return &ListHeldResult{
+		Records: records,
+		Cursor:  nextCursor,
+	}, nil
 }
 
-type consolidatedRecord struct {
-	Cause       string    `dynamodbav:"cause"`
-	Node        string    `dynamodbav:"node"`
-	TotalEgress uint64    `dynamodbav:"totalEgress"`
-	Receipt     []byte    `dynamodbav:"receipt"`
-	ProcessedAt time.Time `dynamodbav:"processedAt"`
+func (d *DynamoConsolidatedTable) Approve(ctx context.Context, cause ucan.Link, approvedAt time.Time) error {
+	// Count the held egress and remove heldSince to exclude the item from the sparse index
+	_, err := d.client.UpdateItem(ctx, &dynamodb.UpdateItemInput{
+		TableName: aws.String(d.tableName),
+		Key: map[string]types.AttributeValue{
+			"cause": &types.AttributeValueMemberS{Value: cause.String()},
+		},
+		UpdateExpression:    aws.String("SET totalEgress = heldEgress, #status = :approved, reviewedAt = :approvedAt, approvedAt = :approvedAt REMOVE heldSince"),
+		ConditionExpression: aws.String("#status = :held"),
+		ExpressionAttributeNames: map[string]string{
+			"#status": "status",
+		},
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":approved":   &types.AttributeValueMemberS{Value: string(StatusApproved)},
+			":held":       &types.AttributeValueMemberS{Value: string(StatusHeld)},
+			":approvedAt": &types.AttributeValueMemberS{Value: approvedAt.UTC().Format(time.RFC3339Nano)},
+		},
+	})
+	if err != nil {
+		var condErr *types.ConditionalCheckFailedException
+		if errors.As(err, &condErr) {
+			return ErrNotHeld
+		}
+
+		return fmt.Errorf("approving consolidated record: %w", err)
+	}
+
+	return nil
 }
 
-func newConsolidatedRecord(cause ucan.Link, node did.DID, totalEgress uint64, rcpt capegress.ConsolidateReceipt) (*consolidatedRecord, error) {
-	// binary values must be base64-encoded before sending them to DynamoDB
-	arch := rcpt.Archive()
-	archBytes, err := io.ReadAll(arch)
+func (d *DynamoConsolidatedTable) Reject(ctx context.Context, cause ucan.Link, reason string, rcpt capegress.ConsolidateReceipt) error {
+	rcptBytes, err := encodeReceipt(rcpt)
 	if err != nil {
-		return nil, fmt.Errorf("reading receipt archive: %w", err)
+		return err
 	}
 
-	rcptBytes := make([]byte, base64.StdEncoding.EncodedLen(len(archBytes)))
-	base64.StdEncoding.Encode(rcptBytes, archBytes)
+	_, err = d.client.UpdateItem(ctx, &dynamodb.UpdateItemInput{
+		TableName: aws.String(d.tableName),
+		Key: map[string]types.AttributeValue{
+			"cause": &types.AttributeValueMemberS{Value: cause.String()},
+		},
+		UpdateExpression:    aws.String("SET receipt = :receipt, #status = :rejected, reviewReason = :reason, reviewedAt = :now REMOVE heldSince"),
+		ConditionExpression: aws.String("#status = :held"),
+		ExpressionAttributeNames: map[string]string{
+			"#status": "status",
+		},
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":receipt":  &types.AttributeValueMemberB{Value: rcptBytes},
+			":rejected": &types.AttributeValueMemberS{Value: string(StatusRejected)},
+			":held":     &types.AttributeValueMemberS{Value: string(StatusHeld)},
+			":reason":   &types.AttributeValueMemberS{Value: reason},
+			":now":      &types.AttributeValueMemberS{Value: time.Now().UTC().Format(time.RFC3339Nano)},
+		},
+	})
+	if err != nil {
+		var condErr *types.ConditionalCheckFailedException
+		if errors.As(err, &condErr) {
+			return ErrNotHeld
+		}
+
+		return fmt.Errorf("rejecting consolidated record: %w", err)
+	}
+
+	return nil
+}
+
+type consolidatedRecord struct {
+	Cause        string            `dynamodbav:"cause"`
+	TrackCause   string            `dynamodbav:"trackCause,omitempty"`
+	Batch        string            `dynamodbav:"batch,omitempty"`
+	Node         string            `dynamodbav:"node"`
+	TotalEgress  uint64            `dynamodbav:"totalEgress"`
+	Receipt      []byte            `dynamodbav:"receipt"`
+	ProcessedAt  time.Time         `dynamodbav:"processedAt"`
+	Status       string            `dynamodbav:"status,omitempty"`
+	HeldEgress   uint64            `dynamodbav:"heldEgress,omitempty"`
+	HoldReasons  []string          `dynamodbav:"holdReasons,omitempty"`
+	SpaceEgress  map[string]uint64 `dynamodbav:"spaceEgress,omitempty"`
+	HeldSince    *time.Time        `dynamodbav:"heldSince,omitempty"`
+	ReviewedAt   *time.Time        `dynamodbav:"reviewedAt,omitempty"`
+	ApprovedAt   *time.Time        `dynamodbav:"approvedAt,omitempty"`
+	ReviewReason string            `dynamodbav:"reviewReason,omitempty"`
+}
+
+func newConsolidatedRecord(cause ucan.Link, trackCause ucan.Link, batch ucan.Link, node did.DID, totalEgress uint64, rcpt capegress.ConsolidateReceipt) (*consolidatedRecord, error) {
+	rcptBytes, err := encodeReceipt(rcpt)
+	if err != nil {
+		return nil, err
+	}
 
 	return &consolidatedRecord{
 		Cause:       cause.String(),
+		TrackCause:  trackCause.String(),
+		Batch:       batch.String(),
 		Node:        node.String(),
 		TotalEgress: totalEgress,
 		Receipt:     rcptBytes,
 		ProcessedAt: time.Now().UTC(),
+		Status:      string(StatusCounted),
 	}, nil
 }
 
+func encodeReceipt(rcpt capegress.ConsolidateReceipt) ([]byte, error) {
+	// binary values must be base64-encoded before sending them to DynamoDB
+	arch := rcpt.Archive()
+	archBytes, err := io.ReadAll(arch)
+	if err != nil {
+		return nil, fmt.Errorf("reading receipt archive: %w", err)
+	}
+
+	rcptBytes := make([]byte, base64.StdEncoding.EncodedLen(len(archBytes)))
+	base64.StdEncoding.Encode(rcptBytes, archBytes)
+
+	return rcptBytes, nil
+}
+
 func (d *DynamoConsolidatedTable) unmarshalRecord(item map[string]types.AttributeValue) (*ConsolidatedRecord, error) {
 	var record consolidatedRecord
 	if err := attributevalue.UnmarshalMap(item, &record); err != nil {
@@ -148,6 +436,23 @@ func (d *DynamoConsolidatedTable) unmarshalRecord(item map[string]types.Attribut
 	}
 	cause := cidlink.Link{Cid: c}
 
+	// Records stored before they could be looked up by track invocation or batch don't have them
+	var trackCause, batch ucan.Link
+	if record.TrackCause != "" {
+		c, err := cid.Decode(record.TrackCause)
+		if err != nil {
+			return nil, fmt.Errorf("parsing track cause CID: %w", err)
+		}
+		trackCause = cidlink.Link{Cid: c}
+	}
+	if record.Batch != "" {
+		c, err := cid.Decode(record.Batch)
+		if err != nil {
+			return nil, fmt.Errorf("parsing batch CID: %w", err)
+		}
+		batch = cidlink.Link{Cid: c}
+	}
+
 	// Receipt may not be present in index projections (e.g., node-stats)
 	var rcpt receipt.AnyReceipt
 	if record.Receipt != nil {
@@ -162,11 +467,88 @@ func (d *DynamoConsolidatedTable) unmarshalRecord(item map[string]types.Attribut
 		}
 	}
 
+	// Records stored before batches could be held have no status
+	status := StatusCounted
+	if record.Status != "" {
+		status = Status(record.Status)
+	}
+
+	var spaceEgress map[did.DID]uint64
+	if record.SpaceEgress != nil {
+		spaceEgress = make(map[did.DID]uint64, len(record.SpaceEgress))
+		for s, egress := range record.SpaceEgress {
+			space, err := did.Parse(s)
+			if err != nil {
+				return nil, fmt.Errorf("parsing space DID: %w", err)
+			}
+			spaceEgress[space] = egress
+		}
+	}
+
+	var reviewedAt time.Time
+	if record.ReviewedAt != nil {
+		reviewedAt = *record.ReviewedAt
+	}
+
+	// batches approved before approvedAt was stored were approved when they were reviewed
+	var approvedAt time.Time
+	switch {
+	case record.ApprovedAt != nil:
+		approvedAt = *record.ApprovedAt
+	case status == StatusApproved:
+		approvedAt = reviewedAt
+	}
+
 	return &ConsolidatedRecord{
-		Node:        node,
-		Cause:       cause,
-		TotalEgress: record.TotalEgress,
-		Receipt:     rcpt,
-		ProcessedAt: record.ProcessedAt,
+		Node:         node,
+		Cause:        cause,
+		TrackCause:   trackCause,
+		Batch:        batch,
+		TotalEgress:  record.TotalEgress,
+		Receipt:      rcpt,
+		ProcessedAt:  record.ProcessedAt,
+		Status:       status,
+		HeldEgress:   record.HeldEgress,
+		HoldReasons:  record.HoldReasons,
+		SpaceEgress:  spaceEgress,
+		ReviewedAt:   reviewedAt,
+		ApprovedAt:   approvedAt,
+		ReviewReason: record.ReviewReason,
 	}, nil
 }
+
+// heldKey is the key of an item in the held index
+type heldKey struct {
+	Cause     string `dynamodbav:"cause" json:"cause"`
+	HeldSince string `dynamodbav:"heldSince" json:"heldSince"`
+}
+
+// encodeHeldToken encodes a DynamoDB LastEvaluatedKey from a held index scan into a base64 string token
+func encodeHeldToken(key map[string]types.AttributeValue) (string, error) {
+	var hk heldKey
+	if err := attributevalue.UnmarshalMap(key, &hk); err != nil {
+		return "", fmt.Errorf("unmarshaling last evaluated key: %w", err)
+	}
+
+	data, err := json.Marshal(hk)
+	if err != nil {
+		return "", err
+	}
+
+	return base64.URLEncoding.EncodeToString(data), nil
+}
+
+// decodeHeldToken decodes a base64 string token into a DynamoDB ExclusiveStartKey for the held index
+func decodeHeldToken(token string) (map[string]types.AttributeValue, error) {
+	data, err := base64.URLEncoding.DecodeString(token)
+	if err != nil {
+		return nil, fmt.Errorf("decoding base64 token: %w", err)
+	}
+
+	var hk heldKey
+	if err := json.Unmarshal(data, &hk); err != nil {
+		return nil, fmt.Errorf("unmarshaling token: %w", err)
+	}
+
+	return attributevalue.MarshalMap(hk)
+}
@@ -2,13 +2,17 @@ package consumer
 
 import (
 	"context"
+	"errors"
 
 	"github.com/storacha/go-ucanto/did"
 )
 
+var ErrNotFound = errors.New("consumer not found")
+
 type Consumer struct {
 	ID           did.DID
 	Provider     did.DID
+	Customer     did.DID
 	Subscription string
 }
 
@@ -39,7 +39,7 @@ func (d *DynamoConsumerTable) Get(ctx context.Context, consumerID string) (Consu
 	}
 
 	if len(result.Items) == 0 {
-		return Consumer{}, fmt.Errorf("consumer not found: %s", consumerID)
+		return Consumer{}, fmt.Errorf("%w: %s", ErrNotFound, consumerID)
 	}
 
 	consumer, err := d.unmarshalConsumer(result.Items[0])
@@ -99,6 +99,7 @@ func (d *DynamoConsumerTable) ListByCustomer(ctx context.Context, customerID did
 type consumerRecord struct {
 	Consumer     string `dynamodbav:"consumer"`
 	Provider     string `dynamodbav:"provider,omitempty"`
+	Customer     string `dynamodbav:"customer,omitempty"`
 	Subscription string `dynamodbav:"subscription,omitempty"`
 }
 
@@ -121,9 +122,18 @@ func (d *DynamoConsumerTable) unmarshalConsumer(item map[string]types.AttributeV
 		}
 	}
 
+	customerDID := did.Undef
+	if record.Customer != "" {
+		customerDID, err = did.Parse(record.Customer)
+		if err != nil {
+			return nil, fmt.Errorf("parsing customer DID: %w", err)
+		}
+	}
+
 	return &Consumer{
 		ID:           consumerDID,
 		Provider:     providerDID,
+		Customer:     customerDID,
 		Subscription: record.Subscription,
 	}, nil
 }
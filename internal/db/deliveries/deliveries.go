@@ -0,0 +1,49 @@
+package deliveries
+
+import (
+	"context"
+	"time"
+
+	"github.com/storacha/go-ucanto/did"
+	"github.com/storacha/go-ucanto/ucan"
+)
+
+// Delivery is an attempt to push a consolidation receipt to a node's callback URL
+type Delivery struct {
+	// Cause is the consolidate invocation the delivered receipt was issued for
+	Cause   ucan.Link
+	Attempt int
+	Node    did.DID
+	URL     string
+	// StatusCode is the HTTP status returned by the callback, 0 if no response was received
+	StatusCode  int
+	Error       string
+	Delivered   bool
+	AttemptedAt time.Time
+}
+
+// Pending is a receipt waiting to be delivered to a node's callback URL
+type Pending struct {
+	// Cause is the consolidate invocation the receipt was issued for
+	Cause ucan.Link
+	Node  did.DID
+	URL   string
+	// Receipt is the CAR archive of the receipt
+	Receipt []byte
+	// Attempt is the number of the next delivery attempt, starting at 1
+	Attempt       int
+	NextAttemptAt time.Time
+}
+
+type DeliveryTable interface {
+	// Add logs a delivery attempt
+	Add(ctx context.Context, delivery Delivery) error
+	// ListByCause returns the delivery attempts of the receipt for the given consolidate invocation, in order
+	ListByCause(ctx context.Context, cause ucan.Link) ([]Delivery, error)
+	// Schedule stores a receipt pending delivery, replacing the one pending for the same cause if any
+	Schedule(ctx context.Context, pending Pending) error
+	// ListDue returns up to limit receipts pending delivery whose next attempt is due at the given time
+	ListDue(ctx context.Context, now time.Time, limit int) ([]Pending, error)
+	// Complete removes the receipt pending delivery for the given cause, once delivered or given up on
+	Complete(ctx context.Context, cause ucan.Link) error
+}
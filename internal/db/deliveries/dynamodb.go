@@ -0,0 +1,274 @@
+package deliveries
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/ipfs/go-cid"
+	cidlink "github.com/ipld/go-ipld-prime/linking/cid"
+	"github.com/storacha/go-ucanto/did"
+	"github.com/storacha/go-ucanto/ucan"
+)
+
+var _ DeliveryTable = (*DynamoDeliveryTable)(nil)
+
+// pendingAttempt is the attempt number of the item holding the receipt pending delivery for a cause, logged
+// attempts start at 1
+const pendingAttempt = 0
+
+type DynamoDeliveryTable struct {
+	client           *dynamodb.Client
+	tableName        string
+	pendingIndexName string
+}
+
+func NewDynamoDeliveryTable(client *dynamodb.Client, tableName string, pendingIndexName string) *DynamoDeliveryTable {
+	return &DynamoDeliveryTable{client, tableName, pendingIndexName}
+}
+
+func (d *DynamoDeliveryTable) Add(ctx context.Context, delivery Delivery) error {
+	item, err := attributevalue.MarshalMap(newDeliveryRecord(delivery))
+	if err != nil {
+		return fmt.Errorf("serializing delivery: %w", err)
+	}
+
+	_, err = d.client.PutItem(ctx, &dynamodb.PutItemInput{
+		TableName: aws.String(d.tableName),
+		Item:      item,
+	})
+	if err != nil {
+		return fmt.Errorf("storing delivery: %w", err)
+	}
+
+	return nil
+}
+
+func (d *DynamoDeliveryTable) ListByCause(ctx context.Context, cause ucan.Link) ([]Delivery, error) {
+	deliveries := make([]Delivery, 0)
+	var exclusiveStartKey map[string]types.AttributeValue
+
+	// Keep querying until we get all results (handle pagination)
+	for {
+		input := &dynamodb.QueryInput{
+			TableName:              aws.String(d.tableName),
+			KeyConditionExpression: aws.String("cause = :cause AND attempt > :pending"),
+			ExpressionAttributeValues: map[string]types.AttributeValue{
+				":cause":   &types.AttributeValueMemberS{Value: cause.String()},
+				":pending": &types.AttributeValueMemberN{Value: strconv.Itoa(pendingAttempt)},
+			},
+		}
+
+		// Set the pagination token if we have one
+		if exclusiveStartKey != nil {
+			input.ExclusiveStartKey = exclusiveStartKey
+		}
+
+		result, err := d.client.Query(ctx, input)
+		if err != nil {
+			return nil, fmt.Errorf("querying deliveries by cause: %w", err)
+		}
+
+		for _, item := range result.Items {
+			delivery, err := unmarshalDelivery(item)
+			if err != nil {
+				return nil, err
+			}
+			deliveries = append(deliveries, *delivery)
+		}
+
+		// Check if there are more results to fetch
+		if result.LastEvaluatedKey == nil {
+			break
+		}
+		exclusiveStartKey = result.LastEvaluatedKey
+	}
+
+	return deliveries, nil
+}
+
+func (d *DynamoDeliveryTable) Schedule(ctx context.Context, pending Pending) error {
+	item, err := attributevalue.MarshalMap(newPendingRecord(pending))
+	if err != nil {
+		return fmt.Errorf("serializing pending delivery: %w", err)
+	}
+
+	_, err = d.client.PutItem(ctx, &dynamodb.PutItemInput{
+		TableName: aws.String(d.tableName),
+		Item:      item,
+	})
+	if err != nil {
+		return fmt.Errorf("storing pending delivery: %w", err)
+	}
+
+	return nil
+}
+
+func (d *DynamoDeliveryTable) ListDue(ctx context.Context, now time.Time, limit int) ([]Pending, error) {
+	pending := make([]Pending, 0)
+	var exclusiveStartKey map[string]types.AttributeValue
+
+	// Scan the sparse index which only contains pending items (items with nextAttemptAt attribute).
+	// Scan limits apply before filtering, so keep scanning until enough due items are found.
+	for len(pending) < limit {
+		input := &dynamodb.ScanInput{
+			TableName:        aws.String(d.tableName),
+			IndexName:        aws.String(d.pendingIndexName),
+			FilterExpression: aws.String("nextAttemptAt <= :now"),
+			ExpressionAttributeValues: map[string]types.AttributeValue{
+				":now": &types.AttributeValueMemberS{Value: now.UTC().Format(time.RFC3339Nano)},
+			},
+		}
+
+		if exclusiveStartKey != nil {
+			input.ExclusiveStartKey = exclusiveStartKey
+		}
+
+		result, err := d.client.Scan(ctx, input)
+		if err != nil {
+			return nil, fmt.Errorf("scanning pending deliveries from index: %w", err)
+		}
+
+		for _, item := range result.Items {
+			p, err := unmarshalPending(item)
+			if err != nil {
+				return nil, err
+			}
+			pending = append(pending, *p)
+		}
+
+		if result.LastEvaluatedKey == nil {
+			break
+		}
+		exclusiveStartKey = result.LastEvaluatedKey
+	}
+
+	if len(pending) > limit {
+		pending = pending[:limit]
+	}
+
+	return pending, nil
+}
+
+func (d *DynamoDeliveryTable) Complete(ctx context.Context, cause ucan.Link) error {
+	_, err := d.client.DeleteItem(ctx, &dynamodb.DeleteItemInput{
+		TableName: aws.String(d.tableName),
+		Key: map[string]types.AttributeValue{
+			"cause":   &types.AttributeValueMemberS{Value: cause.String()},
+			"attempt": &types.AttributeValueMemberN{Value: strconv.Itoa(pendingAttempt)},
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("deleting pending delivery: %w", err)
+	}
+
+	return nil
+}
+
+// deliveryRecord is the internal struct for marshaling to and unmarshaling from DynamoDB
+type deliveryRecord struct {
+	Cause       string    `dynamodbav:"cause"`
+	Attempt     int       `dynamodbav:"attempt"`
+	Node        string    `dynamodbav:"node"`
+	URL         string    `dynamodbav:"url"`
+	StatusCode  int       `dynamodbav:"statusCode,omitempty"`
+	Error       string    `dynamodbav:"error,omitempty"`
+	Delivered   bool      `dynamodbav:"delivered"`
+	AttemptedAt time.Time `dynamodbav:"attemptedAt"`
+}
+
+func newDeliveryRecord(delivery Delivery) *deliveryRecord {
+	return &deliveryRecord{
+		Cause:       delivery.Cause.String(),
+		Attempt:     delivery.Attempt,
+		Node:        delivery.Node.String(),
+		URL:         delivery.URL,
+		StatusCode:  delivery.StatusCode,
+		Error:       delivery.Error,
+		Delivered:   delivery.Delivered,
+		AttemptedAt: delivery.AttemptedAt.UTC(),
+	}
+}
+
+func unmarshalDelivery(item map[string]types.AttributeValue) (*Delivery, error) {
+	var record deliveryRecord
+	if err := attributevalue.UnmarshalMap(item, &record); err != nil {
+		return nil, fmt.Errorf("unmarshaling delivery: %w", err)
+	}
+
+	c, err := cid.Decode(record.Cause)
+	if err != nil {
+		return nil, fmt.Errorf("parsing cause CID: %w", err)
+	}
+
+	node, err := did.Parse(record.Node)
+	if err != nil {
+		return nil, fmt.Errorf("parsing node DID: %w", err)
+	}
+
+	return &Delivery{
+		Cause:       cidlink.Link{Cid: c},
+		Attempt:     record.Attempt,
+		Node:        node,
+		URL:         record.URL,
+		StatusCode:  record.StatusCode,
+		Error:       record.Error,
+		Delivered:   record.Delivered,
+		AttemptedAt: record.AttemptedAt,
+	}, nil
+}
+
+// pendingRecord is the internal struct for marshaling pending deliveries to and unmarshaling them from DynamoDB.
+// The nextAttemptAt attribute makes them show up in the sparse pending index.
+type pendingRecord struct {
+	Cause         string    `dynamodbav:"cause"`
+	Attempt       int       `dynamodbav:"attempt"`
+	Node          string    `dynamodbav:"node"`
+	URL           string    `dynamodbav:"url"`
+	Receipt       []byte    `dynamodbav:"receipt"`
+	NextAttempt   int       `dynamodbav:"nextAttempt"`
+	NextAttemptAt time.Time `dynamodbav:"nextAttemptAt"`
+}
+
+func newPendingRecord(pending Pending) *pendingRecord {
+	return &pendingRecord{
+		Cause:         pending.Cause.String(),
+		Attempt:       pendingAttempt,
+		Node:          pending.Node.String(),
+		URL:           pending.URL,
+		Receipt:       pending.Receipt,
+		NextAttempt:   pending.Attempt,
+		NextAttemptAt: pending.NextAttemptAt.UTC(),
+	}
+}
+
+func unmarshalPending(item map[string]types.AttributeValue) (*Pending, error) {
+	var record pendingRecord
+	if err := attributevalue.UnmarshalMap(item, &record); err != nil {
+		return nil, fmt.Errorf("unmarshaling pending delivery: %w", err)
+	}
+
+	c, err := cid.Decode(record.Cause)
+	if err != nil {
+		return nil, fmt.Errorf("parsing cause CID: %w", err)
+	}
+
+	node, err := did.Parse(record.Node)
+	if err != nil {
+		return nil, fmt.Errorf("parsing node DID: %w", err)
+	}
+
+	return &Pending{
+		Cause:         cidlink.Link{Cid: c},
+		Node:          node,
+		URL:           record.URL,
+		Receipt:       record.Receipt,
+		Attempt:       record.NextAttempt,
+		NextAttemptAt: record.NextAttemptAt,
+	}, nil
+}
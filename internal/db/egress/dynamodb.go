@@ -3,8 +3,11 @@ package egress
 import (
 	"context"
 	"encoding/base64"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"iter"
 	"net/url"
 	"time"
 
@@ -18,18 +21,27 @@ import (
 	"github.com/storacha/go-ucanto/core/invocation"
 	"github.com/storacha/go-ucanto/did"
 	"github.com/storacha/go-ucanto/ucan"
+
+	"github.com/storacha/etracker/internal/db/paging"
 )
 
 var _ EgressTable = (*DynamoEgressTable)(nil)
 
+// unprocessedQueue is the queue attribute of unprocessed records. The queue index is keyed on it and on
+// unprocessedSince, so that unprocessed records can be read in the order they started waiting.
+const unprocessedQueue = "unprocessed"
+
 type DynamoEgressTable struct {
 	client               *dynamodb.Client
 	tableName            string
 	unprocessedIndexName string
+	queueIndexName       string
+	causeIndexName       string
+	nodeIndexName        string
 }
 
-func NewDynamoEgressTable(client *dynamodb.Client, tableName string, unprocessedIndexName string) *DynamoEgressTable {
-	return &DynamoEgressTable{client, tableName, unprocessedIndexName}
+func NewDynamoEgressTable(client *dynamodb.Client, tableName string, unprocessedIndexName string, queueIndexName string, causeIndexName string, nodeIndexName string) *DynamoEgressTable {
+	return &DynamoEgressTable{client, tableName, unprocessedIndexName, queueIndexName, causeIndexName, nodeIndexName}
 }
 
 func (d *DynamoEgressTable) Record(ctx context.Context, batch ucan.Link, node did.DID, endpoint *url.URL, cause invocation.Invocation) error {
@@ -54,39 +66,168 @@ func (d *DynamoEgressTable) Record(ctx context.Context, batch ucan.Link, node di
 	return nil
 }
 
-func (d *DynamoEgressTable) GetUnprocessed(ctx context.Context, limit int) ([]EgressRecord, error) {
-	// Scan the sparse index which only contains unprocessed items (items with unprocessedSince attribute)
-	result, err := d.client.Scan(ctx, &dynamodb.ScanInput{
+func (d *DynamoEgressTable) GetByCause(ctx context.Context, cause ucan.Link) (*EgressRecord, error) {
+	// The cause index only projects keys, the record is then fetched from the table
+	result, err := d.client.Query(ctx, &dynamodb.QueryInput{
+		TableName:              aws.String(d.tableName),
+		IndexName:              aws.String(d.causeIndexName),
+		KeyConditionExpression: aws.String("causeLink = :cause"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":cause": &types.AttributeValueMemberS{Value: cause.String()},
+		},
+		Limit: aws.Int32(1),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("querying egress records by cause: %w", err)
+	}
+
+	if len(result.Items) == 0 {
+		return nil, ErrNotFound
+	}
+
+	item, err := d.client.GetItem(ctx, &dynamodb.GetItemInput{
 		TableName: aws.String(d.tableName),
-		IndexName: aws.String(d.unprocessedIndexName),
-		Limit:     aws.Int32(int32(limit)),
+		Key: map[string]types.AttributeValue{
+			"batch": result.Items[0]["batch"],
+		},
 	})
 	if err != nil {
-		return nil, fmt.Errorf("scanning unprocessed records from index: %w", err)
+		return nil, fmt.Errorf("getting egress record: %w", err)
 	}
 
-	var unprocessed []EgressRecord
-	for _, item := range result.Items {
-		record, err := d.unmarshalRecord(item)
+	if item.Item == nil {
+		return nil, ErrNotFound
+	}
+
+	return d.unmarshalRecord(item.Item)
+}
+
+func (d *DynamoEgressTable) ListUnprocessed(ctx context.Context, pageSize int) iter.Seq2[EgressRecord, error] {
+	return func(yield func(EgressRecord, error) bool) {
+		// The queue index only contains unprocessed items, in a single partition sorted by unprocessedSince
+		for item, err := range paging.Query(ctx, d.client, &dynamodb.QueryInput{
+			TableName:              aws.String(d.tableName),
+			IndexName:              aws.String(d.queueIndexName),
+			KeyConditionExpression: aws.String("#queue = :queue"),
+			ExpressionAttributeNames: map[string]string{
+				"#queue": "queue",
+			},
+			ExpressionAttributeValues: map[string]types.AttributeValue{
+				":queue": &types.AttributeValueMemberS{Value: unprocessedQueue},
+			},
+			ScanIndexForward: aws.Bool(true),
+			Limit:            aws.Int32(int32(pageSize)),
+		}) {
+			if err != nil {
+				yield(EgressRecord{}, fmt.Errorf("querying unprocessed records from index: %w", err))
+				return
+			}
+
+			record, err := d.unmarshalRecord(item)
+			if err != nil {
+				yield(EgressRecord{}, err)
+				return
+			}
+
+			if !yield(*record, nil) {
+				return
+			}
+		}
+	}
+}
+
+// BackfillQueue adds the unprocessed records tracked before the queue index existed to it, so that they are
+// listed by ListUnprocessed. It returns the number of records added.
+func (d *DynamoEgressTable) BackfillQueue(ctx context.Context) (int, error) {
+	added := 0
+	for item, err := range paging.Scan(ctx, d.client, &dynamodb.ScanInput{
+		TableName: aws.String(d.tableName),
+		IndexName: aws.String(d.unprocessedIndexName),
+	}) {
 		if err != nil {
-			return nil, fmt.Errorf("unmarshaling egress record: %w", err)
+			return added, fmt.Errorf("scanning unprocessed records from index: %w", err)
 		}
 
-		unprocessed = append(unprocessed, *record)
+		// records processed since the index was scanned must stay out of the queue
+		_, err := d.client.UpdateItem(ctx, &dynamodb.UpdateItemInput{
+			TableName:           aws.String(d.tableName),
+			Key:                 map[string]types.AttributeValue{"batch": item["batch"]},
+			UpdateExpression:    aws.String("SET #queue = :queue"),
+			ConditionExpression: aws.String("attribute_exists(unprocessedSince) AND attribute_not_exists(#queue)"),
+			ExpressionAttributeNames: map[string]string{
+				"#queue": "queue",
+			},
+			ExpressionAttributeValues: map[string]types.AttributeValue{
+				":queue": &types.AttributeValueMemberS{Value: unprocessedQueue},
+			},
+		})
+		if err != nil {
+			var condErr *types.ConditionalCheckFailedException
+			if errors.As(err, &condErr) {
+				continue
+			}
+			return added, fmt.Errorf("adding unprocessed record to queue: %w", err)
+		}
+		added++
+	}
+
+	return added, nil
+}
+
+// BackfillCauseLink sets the cause link of the records tracked before the cause index existed, so that they can be
+// found by GetByCause. It reads the whole table, and can be dropped once it ran against every deployment. It
+// returns the number of records updated.
+func (d *DynamoEgressTable) BackfillCauseLink(ctx context.Context) (int, error) {
+	updated := 0
+	for item, err := range paging.Scan(ctx, d.client, &dynamodb.ScanInput{
+		TableName:            aws.String(d.tableName),
+		FilterExpression:     aws.String("attribute_not_exists(causeLink)"),
+		ProjectionExpression: aws.String("batch, cause"),
+	}) {
+		if err != nil {
+			return updated, fmt.Errorf("scanning records without cause link: %w", err)
+		}
+
+		var record egressRecord
+		if err := attributevalue.UnmarshalMap(item, &record); err != nil {
+			return updated, fmt.Errorf("unmarshaling egress record: %w", err)
+		}
+
+		cause, err := decodeCause(record.Cause)
+		if err != nil {
+			return updated, fmt.Errorf("decoding cause of batch %s: %w", record.Batch, err)
+		}
+
+		_, err = d.client.UpdateItem(ctx, &dynamodb.UpdateItemInput{
+			TableName:           aws.String(d.tableName),
+			Key:                 map[string]types.AttributeValue{"batch": item["batch"]},
+			UpdateExpression:    aws.String("SET causeLink = :cause"),
+			ConditionExpression: aws.String("attribute_exists(batch)"),
+			ExpressionAttributeValues: map[string]types.AttributeValue{
+				":cause": &types.AttributeValueMemberS{Value: cause.Link().String()},
+			},
+		})
+		if err != nil {
+			return updated, fmt.Errorf("setting cause link of batch %s: %w", record.Batch, err)
+		}
+		updated++
 	}
 
-	return unprocessed, nil
+	return updated, nil
 }
 
 func (d *DynamoEgressTable) MarkAsProcessed(ctx context.Context, records []EgressRecord) error {
 	for _, record := range records {
-		// Remove unprocessedSince to exclude item from the sparse index
+		// Remove unprocessedSince and queue to exclude item from the sparse indexes
 		_, err := d.client.UpdateItem(ctx, &dynamodb.UpdateItemInput{
 			TableName: aws.String(d.tableName),
 			Key: map[string]types.AttributeValue{
 				"batch": &types.AttributeValueMemberS{Value: record.Batch.String()},
 			},
-			UpdateExpression: aws.String("REMOVE unprocessedSince"),
+			UpdateExpression: aws.String("REMOVE unprocessedSince, #queue"),
+			ExpressionAttributeNames: map[string]string{
+				"#queue": "queue",
+			},
 		})
 		if err != nil {
 			return fmt.Errorf("marking record as processed (batch=%s): %w", record.Batch.String(), err)
@@ -126,13 +267,109 @@ func (d *DynamoEgressTable) CountUnprocessedBatches(ctx context.Context) (int64,
 	return totalCount, nil
 }
 
+func (d *DynamoEgressTable) ListByNode(ctx context.Context, node did.DID, from, to time.Time, limit int, cursor *string) (*ListByNodeResult, error) {
+	keyCondition := "node = :node"
+	values := map[string]types.AttributeValue{
+		":node": &types.AttributeValueMemberS{Value: node.String()},
+	}
+
+	// receivedAt is stored as RFC3339, which sorts lexicographically
+	var filter *string
+	switch {
+	case !from.IsZero() && !to.IsZero():
+		// BETWEEN is inclusive, the end of the range is not
+		keyCondition += " AND receivedAt BETWEEN :from AND :to"
+		filter = aws.String("receivedAt < :to")
+	case !from.IsZero():
+		keyCondition += " AND receivedAt >= :from"
+	case !to.IsZero():
+		keyCondition += " AND receivedAt < :to"
+	}
+	if !from.IsZero() {
+		values[":from"] = &types.AttributeValueMemberS{Value: from.UTC().Format(time.RFC3339Nano)}
+	}
+	if !to.IsZero() {
+		values[":to"] = &types.AttributeValueMemberS{Value: to.UTC().Format(time.RFC3339Nano)}
+	}
+
+	// The node index projects everything needed for the summary but the cause archive, which is not read
+	input := &dynamodb.QueryInput{
+		TableName:                 aws.String(d.tableName),
+		IndexName:                 aws.String(d.nodeIndexName),
+		KeyConditionExpression:    aws.String(keyCondition),
+		FilterExpression:          filter,
+		ExpressionAttributeValues: values,
+		ScanIndexForward:          aws.Bool(false),
+		Limit:                     aws.Int32(int32(limit)),
+	}
+
+	if cursor != nil && *cursor != "" {
+		exclusiveStartKey, err := decodeNodeToken(*cursor)
+		if err != nil {
+			return nil, fmt.Errorf("decoding cursor: %w", err)
+		}
+		input.ExclusiveStartKey = exclusiveStartKey
+	}
+
+	result, err := d.client.Query(ctx, input)
+	if err != nil {
+		return nil, fmt.Errorf("querying egress records by node: %w", err)
+	}
+
+	batches := make([]NodeBatch, 0, len(result.Items))
+	for _, item := range result.Items {
+		var record egressRecord
+		if err := attributevalue.UnmarshalMap(item, &record); err != nil {
+			return nil, fmt.Errorf("unmarshaling egress record: %w", err)
+		}
+
+		batch, err := cid.Decode(record.Batch)
+		if err != nil {
+			return nil, fmt.Errorf("parsing batch CID: %w", err)
+		}
+
+		nb := NodeBatch{
+			Batch:      cidlink.Link{Cid: batch},
+			ReceivedAt: record.ReceivedAt,
+			Processed:  record.UnprocessedSince.IsZero(),
+		}
+
+		// records stored before the cause link was added to the table don't have it
+		if record.CauseLink != "" {
+			cause, err := cid.Decode(record.CauseLink)
+			if err != nil {
+				return nil, fmt.Errorf("parsing cause CID: %w", err)
+			}
+			nb.Cause = cidlink.Link{Cid: cause}
+		}
+
+		batches = append(batches, nb)
+	}
+
+	var nextCursor *string
+	if result.LastEvaluatedKey != nil {
+		token, err := encodeNodeToken(result.LastEvaluatedKey)
+		if err != nil {
+			return nil, fmt.Errorf("encoding cursor: %w", err)
+		}
+		nextCursor = aws.String(token)
+	}
+
+	return &ListByNodeResult{
+		Batches: batches,
+		Cursor:  nextCursor,
+	}, nil
+}
+
 type egressRecord struct {
 	Batch            string    `dynamodbav:"batch"`
 	Node             string    `dynamodbav:"node"`
 	Endpoint         string    `dynamodbav:"endpoint"`
 	Cause            []byte    `dynamodbav:"cause"`
+	CauseLink        string    `dynamodbav:"causeLink,omitempty"`
 	ReceivedAt       time.Time `dynamodbav:"receivedAt"`
 	UnprocessedSince time.Time `dynamodbav:"unprocessedSince,omitempty"`
+	Queue            string    `dynamodbav:"queue,omitempty"`
 }
 
 func newRecord(batch ucan.Link, node did.DID, endpoint *url.URL, cause invocation.Invocation) (*egressRecord, error) {
@@ -155,8 +392,10 @@ func newRecord(batch ucan.Link, node did.DID, endpoint *url.URL, cause invocatio
 		Node:             node.String(),
 		Endpoint:         endpointStr,
 		Cause:            causeBytes,
+		CauseLink:        cause.Link().String(),
 		ReceivedAt:       receivedAt,
 		UnprocessedSince: receivedAt,
+		Queue:            unprocessedQueue,
 	}, nil
 }
 
@@ -177,8 +416,25 @@ func (d *DynamoEgressTable) unmarshalRecord(item map[string]types.AttributeValue
 	}
 	batch := cidlink.Link{Cid: c}
 
-	archBytes := make([]byte, base64.StdEncoding.DecodedLen(len(record.Cause)))
-	if _, err := base64.StdEncoding.Decode(archBytes, record.Cause); err != nil {
+	cause, err := decodeCause(record.Cause)
+	if err != nil {
+		return nil, err
+	}
+
+	return &EgressRecord{
+		Batch:            batch,
+		Node:             node,
+		Endpoint:         record.Endpoint,
+		Cause:            cause,
+		ReceivedAt:       record.ReceivedAt,
+		UnprocessedSince: record.UnprocessedSince,
+	}, nil
+}
+
+// decodeCause decodes the base64-encoded archive of the invocation a record was tracked by
+func decodeCause(causeBytes []byte) (delegation.Delegation, error) {
+	archBytes := make([]byte, base64.StdEncoding.DecodedLen(len(causeBytes)))
+	if _, err := base64.StdEncoding.Decode(archBytes, causeBytes); err != nil {
 		return nil, fmt.Errorf("decoding cause archive: %w", err)
 	}
 
@@ -187,11 +443,42 @@ func (d *DynamoEgressTable) unmarshalRecord(item map[string]types.AttributeValue
 		return nil, fmt.Errorf("extracting cause: %w", err)
 	}
 
-	return &EgressRecord{
-		Batch:      batch,
-		Node:       node,
-		Endpoint:   record.Endpoint,
-		Cause:      cause,
-		ReceivedAt: record.ReceivedAt,
-	}, nil
+	return cause, nil
+}
+
+// nodeKey is the key of an item in the node index
+type nodeKey struct {
+	Batch      string `dynamodbav:"batch" json:"batch"`
+	Node       string `dynamodbav:"node" json:"node"`
+	ReceivedAt string `dynamodbav:"receivedAt" json:"receivedAt"`
+}
+
+// encodeNodeToken encodes a DynamoDB LastEvaluatedKey from a node index query into a base64 string token
+func encodeNodeToken(key map[string]types.AttributeValue) (string, error) {
+	var nk nodeKey
+	if err := attributevalue.UnmarshalMap(key, &nk); err != nil {
+		return "", fmt.Errorf("unmarshaling last evaluated key: %w", err)
+	}
+
+	data, err := json.Marshal(nk)
+	if err != nil {
+		return "", err
+	}
+
+	return base64.URLEncoding.EncodeToString(data), nil
+}
+
+// decodeNodeToken decodes a base64 string token into a DynamoDB ExclusiveStartKey for the node index
+func decodeNodeToken(token string) (map[string]types.AttributeValue, error) {
+	data, err := base64.URLEncoding.DecodeString(token)
+	if err != nil {
+		return nil, fmt.Errorf("decoding base64 token: %w", err)
+	}
+
+	var nk nodeKey
+	if err := json.Unmarshal(data, &nk); err != nil {
+		return nil, fmt.Errorf("unmarshaling token: %w", err)
+	}
+
+	return attributevalue.MarshalMap(nk)
 }
@@ -2,6 +2,8 @@ package egress
 
 import (
 	"context"
+	"errors"
+	"iter"
 	"net/url"
 	"time"
 
@@ -16,11 +18,37 @@ type EgressRecord struct {
 	Endpoint   string
 	Cause      invocation.Invocation
 	ReceivedAt time.Time
+	// UnprocessedSince is when the record started waiting for consolidation, zero once processed
+	UnprocessedSince time.Time
 }
 
+// NodeBatch is the summary of a batch tracked by a node, as listed by ListByNode
+type NodeBatch struct {
+	Batch ucan.Link
+	// Cause is the link of the `space/egress/track` invocation the batch was tracked with
+	Cause      ucan.Link
+	ReceivedAt time.Time
+	// Processed is true once the batch was picked up by the consolidator
+	Processed bool
+}
+
+type ListByNodeResult struct {
+	Batches []NodeBatch
+	Cursor  *string
+}
+
+var ErrNotFound = errors.New("egress record not found")
+
 type EgressTable interface {
 	Record(ctx context.Context, batch ucan.Link, node did.DID, endpoint *url.URL, cause invocation.Invocation) error
-	GetUnprocessed(ctx context.Context, limit int) ([]EgressRecord, error)
+	// GetByCause returns the record tracked by the given `space/egress/track` invocation, or ErrNotFound if there is none
+	GetByCause(ctx context.Context, cause ucan.Link) (*EgressRecord, error)
+	// ListUnprocessed yields the records not processed yet, oldest first by the time they started waiting,
+	// fetching at most pageSize records per request
+	ListUnprocessed(ctx context.Context, pageSize int) iter.Seq2[EgressRecord, error]
 	MarkAsProcessed(ctx context.Context, records []EgressRecord) error
 	CountUnprocessedBatches(ctx context.Context) (int64, error)
+	// ListByNode lists the batches tracked by the given node and received in [from, to), most recent first.
+	// Zero from or to leave the range open on that side.
+	ListByNode(ctx context.Context, node did.DID, from, to time.Time, limit int, cursor *string) (*ListByNodeResult, error)
 }
@@ -0,0 +1,139 @@
+package hourlystats
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/storacha/go-ucanto/did"
+
+	"github.com/storacha/etracker/internal/db/markers"
+)
+
+var _ HourlyStatsTable = (*DynamoHourlyStatsTable)(nil)
+
+const hourFormat = "2006-01-02T15"
+
+// DynamoHourlyStatsTable stores hourly buckets with an expiresAt attribute holding the epoch second after
+// which the bucket is deleted by DynamoDB's time to live, which must be enabled on expiresAt for the table.
+// Deletion is not immediate, so expired buckets are also filtered out when read.
+type DynamoHourlyStatsTable struct {
+	client    *dynamodb.Client
+	tableName string
+	retention time.Duration
+}
+
+func NewDynamoHourlyStatsTable(client *dynamodb.Client, tableName string, retention time.Duration) *DynamoHourlyStatsTable {
+	return &DynamoHourlyStatsTable{client, tableName, retention}
+}
+
+func (d *DynamoHourlyStatsTable) Record(ctx context.Context, space did.DID, egress uint64, at time.Time, id string) error {
+	hour := at.UTC().Truncate(time.Hour)
+	expiresAt := hour.Add(time.Hour + d.retention).Unix()
+
+	marker := markers.Put(d.tableName, "space", map[string]types.AttributeValue{
+		"space": &types.AttributeValueMemberS{Value: space.String()},
+		"hour":  &types.AttributeValueMemberS{Value: markers.Key(id)},
+	}, time.Now())
+	// ADD atomically increments egress, creating the bucket if it doesn't exist
+	bucket := types.TransactWriteItem{
+		Update: &types.Update{
+			TableName: aws.String(d.tableName),
+			Key: map[string]types.AttributeValue{
+				"space": &types.AttributeValueMemberS{Value: space.String()},
+				"hour":  &types.AttributeValueMemberS{Value: hour.Format(hourFormat)},
+			},
+			UpdateExpression: aws.String("ADD egress :egress SET expiresAt = :expiresAt"),
+			ExpressionAttributeValues: map[string]types.AttributeValue{
+				":egress":    &types.AttributeValueMemberN{Value: fmt.Sprintf("%d", egress)},
+				":expiresAt": &types.AttributeValueMemberN{Value: strconv.FormatInt(expiresAt, 10)},
+			},
+		},
+	}
+
+	if err := markers.Record(ctx, d.client, marker, bucket); err != nil {
+		if errors.Is(err, ErrAlreadyRecorded) {
+			return err
+		}
+		return fmt.Errorf("recording hourly space stats: %w", err)
+	}
+
+	return nil
+}
+
+func (d *DynamoHourlyStatsTable) GetHourlyStats(ctx context.Context, space did.DID, from time.Time, to time.Time) ([]HourlyStats, error) {
+	stats := make([]HourlyStats, 0)
+	var exclusiveStartKey map[string]types.AttributeValue
+
+	for {
+		input := &dynamodb.QueryInput{
+			TableName:              aws.String(d.tableName),
+			KeyConditionExpression: aws.String("#space = :space AND #hour BETWEEN :from AND :to"),
+			FilterExpression:       aws.String("expiresAt > :now"),
+			ExpressionAttributeNames: map[string]string{
+				"#space": "space",
+				"#hour":  "hour",
+			},
+			ExpressionAttributeValues: map[string]types.AttributeValue{
+				":space": &types.AttributeValueMemberS{Value: space.String()},
+				":from":  &types.AttributeValueMemberS{Value: from.UTC().Format(hourFormat)},
+				":to":    &types.AttributeValueMemberS{Value: to.UTC().Format(hourFormat)},
+				":now":   &types.AttributeValueMemberN{Value: strconv.FormatInt(time.Now().Unix(), 10)},
+			},
+			ProjectionExpression: aws.String("#hour, egress"),
+		}
+
+		if exclusiveStartKey != nil {
+			input.ExclusiveStartKey = exclusiveStartKey
+		}
+
+		result, err := d.client.Query(ctx, input)
+		if err != nil {
+			return nil, fmt.Errorf("querying hourly stats for space: %w", err)
+		}
+
+		for _, item := range result.Items {
+			stat, err := d.unmarshalHourlyStats(item)
+			if err != nil {
+				return nil, err
+			}
+			stats = append(stats, stat)
+		}
+
+		if result.LastEvaluatedKey == nil {
+			break
+		}
+		exclusiveStartKey = result.LastEvaluatedKey
+	}
+
+	return stats, nil
+}
+
+// hourlyStatsRecord is the internal struct for unmarshaling from DynamoDB
+type hourlyStatsRecord struct {
+	Hour   string `dynamodbav:"hour"`
+	Egress uint64 `dynamodbav:"egress"`
+}
+
+func (d *DynamoHourlyStatsTable) unmarshalHourlyStats(item map[string]types.AttributeValue) (HourlyStats, error) {
+	var record hourlyStatsRecord
+	if err := attributevalue.UnmarshalMap(item, &record); err != nil {
+		return HourlyStats{}, fmt.Errorf("unmarshaling hourly stats record: %w", err)
+	}
+
+	hour, err := time.Parse(hourFormat, record.Hour)
+	if err != nil {
+		return HourlyStats{}, fmt.Errorf("parsing hour: %w", err)
+	}
+
+	return HourlyStats{
+		Hour:   hour,
+		Egress: record.Egress,
+	}, nil
+}
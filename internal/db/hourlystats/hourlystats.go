@@ -0,0 +1,27 @@
+package hourlystats
+
+import (
+	"context"
+	"time"
+
+	"github.com/storacha/go-ucanto/did"
+
+	"github.com/storacha/etracker/internal/db/markers"
+)
+
+// ErrAlreadyRecorded is returned when egress was recorded for the space under the same id before
+var ErrAlreadyRecorded = markers.ErrAlreadyRecorded
+
+type HourlyStats struct {
+	Hour   time.Time
+	Egress uint64
+}
+
+// HourlyStatsTable holds the egress of spaces bucketed by hour. Buckets expire after a retention period.
+type HourlyStatsTable interface {
+	// Record adds egress to the hourly stats of the space for the hour of the given time. Egress is recorded at
+	// most once per space and id, later calls return ErrAlreadyRecorded.
+	Record(ctx context.Context, space did.DID, egress uint64, at time.Time, id string) error
+	// GetHourlyStats returns the unexpired hourly stats of the space between the hours of from and to, inclusive
+	GetHourlyStats(ctx context.Context, space did.DID, from time.Time, to time.Time) ([]HourlyStats, error)
+}
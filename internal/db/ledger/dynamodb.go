@@ -0,0 +1,322 @@
+package ledger
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"iter"
+	"slices"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/ipfs/go-cid"
+	cidlink "github.com/ipld/go-ipld-prime/linking/cid"
+	"github.com/storacha/go-ucanto/did"
+	"github.com/storacha/go-ucanto/ucan"
+
+	"github.com/storacha/etracker/internal/db/paging"
+)
+
+var _ LedgerTable = (*DynamoLedgerTable)(nil)
+
+const (
+	// transactionPrefix prefixes the partition key of the items holding the transactions themselves
+	transactionPrefix = "transaction:"
+	// transactionItem is the sort key of the items holding the transactions themselves
+	transactionItem = "transaction"
+	// entryTimeFormat is fixed width so that entries sort by the time they take effect
+	entryTimeFormat = "2006-01-02T15:04:05.000000000Z"
+	// writesPerTransaction is the maximum number of items written by a DynamoDB transaction
+	writesPerTransaction = 100
+)
+
+// DynamoLedgerTable stores each transaction as an item of its own plus one item per entry, partitioned by the
+// book the entry is posted to and sorted by the time it takes effect, so balances are derived by querying a book.
+// Items are only ever put, never updated or deleted.
+type DynamoLedgerTable struct {
+	client    *dynamodb.Client
+	tableName string
+}
+
+func NewDynamoLedgerTable(client *dynamodb.Client, tableName string) *DynamoLedgerTable {
+	return &DynamoLedgerTable{client, tableName}
+}
+
+// Post writes the entries of the transaction before the transaction itself, which is conditional on no
+// transaction with the same ID existing. Transactions with too many entries to be written atomically are
+// completed by posting them again if writing them is interrupted, entries are written with the same keys.
+func (d *DynamoLedgerTable) Post(ctx context.Context, tx Transaction) error {
+	if !tx.Balanced() {
+		return ErrUnbalanced
+	}
+
+	existing, err := d.client.GetItem(ctx, &dynamodb.GetItemInput{
+		TableName:            aws.String(d.tableName),
+		Key:                  d.key(Book(transactionPrefix+tx.ID), transactionItem),
+		ProjectionExpression: aws.String("book"),
+	})
+	if err != nil {
+		return fmt.Errorf("getting transaction: %w", err)
+	}
+	if existing.Item != nil {
+		return ErrAlreadyPosted
+	}
+
+	cause := ""
+	if tx.Cause != nil {
+		cause = tx.Cause.String()
+	}
+
+	var writes []types.TransactWriteItem
+	legs := make([]legRecord, 0, len(tx.Entries))
+	for i, e := range tx.Entries {
+		leg := legRecord{Book: string(e.Book), Egress: e.Egress}
+		if e.Space != did.Undef {
+			leg.Space = e.Space.String()
+		}
+		legs = append(legs, leg)
+
+		item, err := attributevalue.MarshalMap(entryRecord{
+			legRecord:   leg,
+			Entry:       entryKey(tx.EffectiveAt, tx.ID, i),
+			Transaction: tx.ID,
+			Kind:        string(tx.Kind),
+			Actor:       tx.Actor,
+			Reason:      tx.Reason,
+			Cause:       cause,
+			EffectiveAt: tx.EffectiveAt.UTC(),
+			PostedAt:    tx.PostedAt.UTC(),
+		})
+		if err != nil {
+			return fmt.Errorf("serializing ledger entry: %w", err)
+		}
+		writes = append(writes, types.TransactWriteItem{
+			Put: &types.Put{
+				TableName: aws.String(d.tableName),
+				Item:      item,
+			},
+		})
+	}
+
+	item, err := attributevalue.MarshalMap(transactionRecord{
+		Book:        transactionPrefix + tx.ID,
+		Entry:       transactionItem,
+		ID:          tx.ID,
+		Kind:        string(tx.Kind),
+		Actor:       tx.Actor,
+		Reason:      tx.Reason,
+		Cause:       cause,
+		Reverses:    tx.Reverses,
+		EffectiveAt: tx.EffectiveAt.UTC(),
+		PostedAt:    tx.PostedAt.UTC(),
+		Entries:     legs,
+	})
+	if err != nil {
+		return fmt.Errorf("serializing transaction: %w", err)
+	}
+	header := types.TransactWriteItem{
+		Put: &types.Put{
+			TableName:           aws.String(d.tableName),
+			Item:                item,
+			ConditionExpression: aws.String("attribute_not_exists(book)"),
+		},
+	}
+
+	// small transactions are written atomically along with their entries
+	if len(writes) < writesPerTransaction {
+		return d.write(ctx, append(writes, header))
+	}
+
+	for chunk := range slices.Chunk(writes, writesPerTransaction) {
+		if err := d.write(ctx, chunk); err != nil {
+			return err
+		}
+	}
+	return d.write(ctx, []types.TransactWriteItem{header})
+}
+
+func (d *DynamoLedgerTable) write(ctx context.Context, items []types.TransactWriteItem) error {
+	_, err := d.client.TransactWriteItems(ctx, &dynamodb.TransactWriteItemsInput{TransactItems: items})
+	if err != nil {
+		var txErr *types.TransactionCanceledException
+		if errors.As(err, &txErr) {
+			for _, reason := range txErr.CancellationReasons {
+				if aws.ToString(reason.Code) == "ConditionalCheckFailed" {
+					return ErrAlreadyPosted
+				}
+			}
+		}
+		return fmt.Errorf("writing ledger items: %w", err)
+	}
+	return nil
+}
+
+func (d *DynamoLedgerTable) Get(ctx context.Context, id string) (*Transaction, error) {
+	result, err := d.client.GetItem(ctx, &dynamodb.GetItemInput{
+		TableName: aws.String(d.tableName),
+		Key:       d.key(Book(transactionPrefix+id), transactionItem),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("getting transaction: %w", err)
+	}
+
+	if result.Item == nil {
+		return nil, ErrNotFound
+	}
+
+	var record transactionRecord
+	if err := attributevalue.UnmarshalMap(result.Item, &record); err != nil {
+		return nil, fmt.Errorf("unmarshaling transaction: %w", err)
+	}
+
+	cause, err := parseCause(record.Cause)
+	if err != nil {
+		return nil, err
+	}
+
+	tx := &Transaction{
+		ID:          record.ID,
+		Kind:        Kind(record.Kind),
+		Actor:       record.Actor,
+		Reason:      record.Reason,
+		Cause:       cause,
+		Reverses:    record.Reverses,
+		EffectiveAt: record.EffectiveAt,
+		PostedAt:    record.PostedAt,
+		Entries:     make([]Entry, 0, len(record.Entries)),
+	}
+	for _, e := range record.Entries {
+		entry, err := e.entry()
+		if err != nil {
+			return nil, err
+		}
+		tx.Entries = append(tx.Entries, entry)
+	}
+
+	return tx, nil
+}
+
+func (d *DynamoLedgerTable) GetEntries(ctx context.Context, book Book, from, to time.Time) iter.Seq2[BookEntry, error] {
+	return func(yield func(BookEntry, error) bool) {
+		// entries taking effect at the end of the period sort after its formatted time
+		for item, err := range paging.Query(ctx, d.client, &dynamodb.QueryInput{
+			TableName:              aws.String(d.tableName),
+			KeyConditionExpression: aws.String("book = :book AND entry BETWEEN :from AND :to"),
+			ExpressionAttributeValues: map[string]types.AttributeValue{
+				":book": &types.AttributeValueMemberS{Value: string(book)},
+				":from": &types.AttributeValueMemberS{Value: from.UTC().Format(entryTimeFormat)},
+				":to":   &types.AttributeValueMemberS{Value: to.UTC().Format(entryTimeFormat)},
+			},
+		}) {
+			if err != nil {
+				yield(BookEntry{}, fmt.Errorf("querying ledger entries: %w", err))
+				return
+			}
+
+			var record entryRecord
+			if err := attributevalue.UnmarshalMap(item, &record); err != nil {
+				yield(BookEntry{}, fmt.Errorf("unmarshaling ledger entry: %w", err))
+				return
+			}
+
+			entry, err := record.bookEntry()
+			if !yield(entry, err) || err != nil {
+				return
+			}
+		}
+	}
+}
+
+func (d *DynamoLedgerTable) key(book Book, entry string) map[string]types.AttributeValue {
+	return map[string]types.AttributeValue{
+		"book":  &types.AttributeValueMemberS{Value: string(book)},
+		"entry": &types.AttributeValueMemberS{Value: entry},
+	}
+}
+
+func entryKey(effectiveAt time.Time, id string, i int) string {
+	return fmt.Sprintf("%s#%s#%04d", effectiveAt.UTC().Format(entryTimeFormat), id, i)
+}
+
+type transactionRecord struct {
+	Book        string      `dynamodbav:"book"`
+	Entry       string      `dynamodbav:"entry"`
+	ID          string      `dynamodbav:"id"`
+	Kind        string      `dynamodbav:"kind"`
+	Actor       string      `dynamodbav:"actor"`
+	Reason      string      `dynamodbav:"reason,omitempty"`
+	Cause       string      `dynamodbav:"cause,omitempty"`
+	Reverses    string      `dynamodbav:"reverses,omitempty"`
+	EffectiveAt time.Time   `dynamodbav:"effectiveAt"`
+	PostedAt    time.Time   `dynamodbav:"postedAt"`
+	Entries     []legRecord `dynamodbav:"entries"`
+}
+
+// legRecord is an entry as listed in the item of its transaction
+type legRecord struct {
+	Book   string `dynamodbav:"book"`
+	Space  string `dynamodbav:"space,omitempty"`
+	Egress int64  `dynamodbav:"egress"`
+}
+
+// entryRecord is the item of an entry in its book, along with the details of its transaction
+type entryRecord struct {
+	legRecord
+	Entry       string    `dynamodbav:"entry"`
+	Transaction string    `dynamodbav:"transaction"`
+	Kind        string    `dynamodbav:"kind"`
+	Actor       string    `dynamodbav:"actor"`
+	Reason      string    `dynamodbav:"reason,omitempty"`
+	Cause       string    `dynamodbav:"cause,omitempty"`
+	EffectiveAt time.Time `dynamodbav:"effectiveAt"`
+	PostedAt    time.Time `dynamodbav:"postedAt"`
+}
+
+func (r legRecord) entry() (Entry, error) {
+	entry := Entry{Book: Book(r.Book), Egress: r.Egress}
+	if r.Space != "" {
+		space, err := did.Parse(r.Space)
+		if err != nil {
+			return Entry{}, fmt.Errorf("parsing space DID: %w", err)
+		}
+		entry.Space = space
+	}
+	return entry, nil
+}
+
+func (r entryRecord) bookEntry() (BookEntry, error) {
+	entry, err := r.entry()
+	if err != nil {
+		return BookEntry{}, err
+	}
+
+	cause, err := parseCause(r.Cause)
+	if err != nil {
+		return BookEntry{}, err
+	}
+
+	return BookEntry{
+		Entry:       entry,
+		Transaction: r.Transaction,
+		Kind:        Kind(r.Kind),
+		Actor:       r.Actor,
+		Reason:      r.Reason,
+		Cause:       cause,
+		EffectiveAt: r.EffectiveAt,
+		PostedAt:    r.PostedAt,
+	}, nil
+}
+
+func parseCause(s string) (ucan.Link, error) {
+	if s == "" {
+		return nil, nil
+	}
+	c, err := cid.Decode(s)
+	if err != nil {
+		return nil, fmt.Errorf("parsing cause CID: %w", err)
+	}
+	return cidlink.Link{Cid: c}, nil
+}
@@ -0,0 +1,207 @@
+package ledger
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"iter"
+	"strings"
+	"time"
+
+	"github.com/storacha/go-ucanto/did"
+	"github.com/storacha/go-ucanto/ucan"
+)
+
+// Kind is the reason a transaction was posted
+type Kind string
+
+const (
+	// KindCredit transactions credit a node with the egress of a consolidated batch, debited from the accounts
+	// owning the spaces it was served from
+	KindCredit Kind = "credit"
+	// KindReversal transactions cancel the credit of a batch, when a held batch is rejected or the egress of a
+	// counted batch is clawed back
+	KindReversal Kind = "reversal"
+	// KindAdjustment transactions are correcting entries posted by an admin
+	KindAdjustment Kind = "adjustment"
+)
+
+// Book is a ledger account egress is posted to. Node books are credited with the egress the node served and
+// account books are debited with the egress the spaces of the account consumed, the other books balance them.
+type Book string
+
+const (
+	// UnassignedBook is debited with the egress of spaces that are not owned by any account
+	UnassignedBook Book = "unassigned"
+	// SuspenseBook balances the egress of a batch that is not attributed to any of its spaces
+	SuspenseBook Book = "suspense"
+	// AdjustmentsBook balances the correcting entries posted by admins
+	AdjustmentsBook Book = "adjustments"
+
+	nodePrefix    = "node:"
+	accountPrefix = "account:"
+)
+
+// NodeBook is the book of the egress served by the node
+func NodeBook(node did.DID) Book {
+	return Book(nodePrefix + node.String())
+}
+
+// AccountBook is the book of the egress consumed by the spaces of the account
+func AccountBook(account did.DID) Book {
+	return Book(accountPrefix + account.String())
+}
+
+// ParseBook parses the book of a node or an account, as formatted by NodeBook and AccountBook
+func ParseBook(s string) (Book, error) {
+	for _, prefix := range []string{nodePrefix, accountPrefix} {
+		if d, ok := strings.CutPrefix(s, prefix); ok {
+			if _, err := did.Parse(d); err != nil {
+				return "", fmt.Errorf("parsing DID of book %s: %w", s, err)
+			}
+			return Book(s), nil
+		}
+	}
+	return "", ErrUnknownBook
+}
+
+// IsNode reports whether the book is the book of a node
+func (b Book) IsNode() bool {
+	return strings.HasPrefix(string(b), nodePrefix)
+}
+
+// Sign is the sign of the entries increasing the egress of the book: +1 for node books, which are credited,
+// and -1 for every other book, which are debited.
+func (b Book) Sign() int64 {
+	if b.IsNode() {
+		return 1
+	}
+	return -1
+}
+
+// Entry is one leg of a transaction. Positive egress credits the book, negative egress debits it.
+type Entry struct {
+	Book Book
+	// Space is the space the egress was served from, if the entry is for a single space
+	Space  did.DID
+	Egress int64
+}
+
+// Transaction is a set of entries posted together, whose egress sums to zero
+type Transaction struct {
+	ID    string
+	Kind  Kind
+	Actor string
+	// Reason is the explanation given by the actor of a reversal or adjustment
+	Reason string
+	// Cause is the consolidate invocation of the batch the transaction is about, if any
+	Cause ucan.Link
+	// Reverses is the ID of the transaction cancelled by a reversal
+	Reverses string
+	// EffectiveAt is when the egress is accounted for. Reversals take effect when the credit they cancel did,
+	// restating the balances of the period it was posted in.
+	EffectiveAt time.Time
+	// PostedAt is when the transaction was appended to the ledger
+	PostedAt time.Time
+	Entries  []Entry
+}
+
+// Balanced reports whether the entries of the transaction sum to zero
+func (t Transaction) Balanced() bool {
+	var sum int64
+	for _, e := range t.Entries {
+		sum += e.Egress
+	}
+	return sum == 0
+}
+
+// BookEntry is an entry posted to a book along with the transaction it is part of
+type BookEntry struct {
+	Entry
+	Transaction string
+	Kind        Kind
+	Actor       string
+	Reason      string
+	Cause       ucan.Link
+	EffectiveAt time.Time
+	PostedAt    time.Time
+}
+
+// CreditID is the ID of the transaction crediting the egress of the batch consolidated by the given invocation
+func CreditID(cause ucan.Link) string {
+	return fmt.Sprintf("%s:%s", KindCredit, cause)
+}
+
+// ReversalID is the ID of the transaction reversing the credit of the batch consolidated by the given invocation
+func ReversalID(cause ucan.Link) string {
+	return fmt.Sprintf("%s:%s", KindReversal, cause)
+}
+
+// AdjustmentID is the ID of a correcting entry posted to the book at the given time
+func AdjustmentID(book Book, postedAt time.Time) string {
+	return fmt.Sprintf("%s:%s:%s", KindAdjustment, book, postedAt.UTC().Format(time.RFC3339Nano))
+}
+
+// NewCredit builds the transaction crediting the node with the egress of a consolidated batch. The egress of each
+// space is debited from the account owning it, or from UnassignedBook for spaces missing from accounts.
+func NewCredit(actor string, cause ucan.Link, node did.DID, totalEgress uint64, spaceEgress map[did.DID]uint64, accounts map[did.DID]did.DID, at time.Time) Transaction {
+	tx := Transaction{
+		ID:          CreditID(cause),
+		Kind:        KindCredit,
+		Actor:       actor,
+		Cause:       cause,
+		EffectiveAt: at,
+		Entries:     []Entry{{Book: NodeBook(node), Egress: int64(totalEgress)}},
+	}
+
+	remaining := int64(totalEgress)
+	for space, egress := range spaceEgress {
+		book := UnassignedBook
+		if account, ok := accounts[space]; ok {
+			book = AccountBook(account)
+		}
+		tx.Entries = append(tx.Entries, Entry{Book: book, Space: space, Egress: -int64(egress)})
+		remaining -= int64(egress)
+	}
+
+	if remaining != 0 {
+		tx.Entries = append(tx.Entries, Entry{Book: SuspenseBook, Egress: -remaining})
+	}
+
+	return tx
+}
+
+// NewReversal builds the transaction cancelling every entry of the given credit
+func NewReversal(actor, reason string, credit Transaction) Transaction {
+	tx := Transaction{
+		ID:          ReversalID(credit.Cause),
+		Kind:        KindReversal,
+		Actor:       actor,
+		Reason:      reason,
+		Cause:       credit.Cause,
+		Reverses:    credit.ID,
+		EffectiveAt: credit.EffectiveAt,
+		Entries:     make([]Entry, 0, len(credit.Entries)),
+	}
+	for _, e := range credit.Entries {
+		tx.Entries = append(tx.Entries, Entry{Book: e.Book, Space: e.Space, Egress: -e.Egress})
+	}
+	return tx
+}
+
+var (
+	ErrNotFound      = errors.New("transaction not found")
+	ErrAlreadyPosted = errors.New("transaction already posted")
+	ErrUnbalanced    = errors.New("transaction entries do not sum to zero")
+	ErrUnknownBook   = errors.New("book is neither a node nor an account book")
+)
+
+type LedgerTable interface {
+	// Post appends the transaction to the ledger. Transactions are immutable, it returns ErrAlreadyPosted if a
+	// transaction with the same ID was posted already, and ErrUnbalanced if its entries do not sum to zero.
+	Post(ctx context.Context, tx Transaction) error
+	// Get returns the transaction with the given ID, or ErrNotFound if there is none
+	Get(ctx context.Context, id string) (*Transaction, error)
+	// GetEntries yields the entries posted to the book taking effect in [from, to), oldest first
+	GetEntries(ctx context.Context, book Book, from, to time.Time) iter.Seq2[BookEntry, error]
+}
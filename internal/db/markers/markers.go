@@ -0,0 +1,73 @@
+// Package markers makes recording egress in the stats tables idempotent. A marker item is written in the same
+// transaction as the egress it records and can only be created once per id, so that recording can be retried.
+package markers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+// ErrAlreadyRecorded is returned when egress was recorded under the same id before
+var ErrAlreadyRecorded = errors.New("egress already recorded")
+
+// Retention is how long markers are kept before they are deleted by DynamoDB's time to live, which must be
+// enabled on expiresAt for the tables holding them. Recording is retried well within it: failed batches are
+// recovered on the next consolidation cycles and held batches are reviewed within days.
+const Retention = 90 * 24 * time.Hour
+
+// Key is the sort key of the marker of the given id. Markers sort after dates, hours and monthly rollups so
+// they are never returned by range queries over them.
+func Key(id string) string {
+	return "recorded#" + id
+}
+
+// Put returns the transaction item creating the marker of the given key, failing if it exists already.
+// The marker expires Retention after now.
+func Put(tableName string, hashKey string, key map[string]types.AttributeValue, now time.Time) types.TransactWriteItem {
+	item := make(map[string]types.AttributeValue, len(key)+1)
+	for name, value := range key {
+		item[name] = value
+	}
+	item["expiresAt"] = &types.AttributeValueMemberN{Value: strconv.FormatInt(now.Add(Retention).Unix(), 10)}
+
+	return types.TransactWriteItem{
+		Put: &types.Put{
+			TableName:           aws.String(tableName),
+			Item:                item,
+			ConditionExpression: aws.String("attribute_not_exists(#key)"),
+			ExpressionAttributeNames: map[string]string{
+				"#key": hashKey,
+			},
+		},
+	}
+}
+
+// Record writes the marker along with the items recording egress, it returns ErrAlreadyRecorded if the marker
+// exists already.
+func Record(ctx context.Context, client *dynamodb.Client, marker types.TransactWriteItem, items ...types.TransactWriteItem) error {
+	_, err := client.TransactWriteItems(ctx, &dynamodb.TransactWriteItemsInput{
+		TransactItems: append([]types.TransactWriteItem{marker}, items...),
+	})
+	if err == nil {
+		return nil
+	}
+
+	var txErr *types.TransactionCanceledException
+	if errors.As(err, &txErr) && len(txErr.CancellationReasons) > 0 && ConditionFailed(txErr.CancellationReasons[0]) {
+		return ErrAlreadyRecorded
+	}
+
+	return fmt.Errorf("writing transaction: %w", err)
+}
+
+// ConditionFailed reports whether a transaction item was canceled because its condition failed
+func ConditionFailed(reason types.CancellationReason) bool {
+	return aws.ToString(reason.Code) == "ConditionalCheckFailed"
+}
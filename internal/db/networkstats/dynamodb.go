@@ -0,0 +1,99 @@
+package networkstats
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/storacha/go-ucanto/did"
+
+	"github.com/storacha/etracker/internal/db/markers"
+	"github.com/storacha/etracker/internal/db/paging"
+)
+
+var _ NetworkStatsTable = (*DynamoNetworkStatsTable)(nil)
+
+const dateFormat = "2006-01-02"
+
+type DynamoNetworkStatsTable struct {
+	client    *dynamodb.Client
+	tableName string
+}
+
+func NewDynamoNetworkStatsTable(client *dynamodb.Client, tableName string) *DynamoNetworkStatsTable {
+	return &DynamoNetworkStatsTable{client, tableName}
+}
+
+func (d *DynamoNetworkStatsTable) Record(ctx context.Context, network did.DID, egress uint64, at time.Time, id string) error {
+	marker := markers.Put(d.tableName, "network", map[string]types.AttributeValue{
+		"network": &types.AttributeValueMemberS{Value: network.String()},
+		"date":    &types.AttributeValueMemberS{Value: markers.Key(id)},
+	}, time.Now())
+	// ADD atomically increments egress, creating the item if it doesn't exist
+	daily := types.TransactWriteItem{
+		Update: &types.Update{
+			TableName: aws.String(d.tableName),
+			Key: map[string]types.AttributeValue{
+				"network": &types.AttributeValueMemberS{Value: network.String()},
+				"date":    &types.AttributeValueMemberS{Value: at.UTC().Format(dateFormat)},
+			},
+			UpdateExpression: aws.String("ADD egress :egress"),
+			ExpressionAttributeValues: map[string]types.AttributeValue{
+				":egress": &types.AttributeValueMemberN{Value: fmt.Sprintf("%d", egress)},
+			},
+		},
+	}
+
+	if err := markers.Record(ctx, d.client, marker, daily); err != nil {
+		if errors.Is(err, ErrAlreadyRecorded) {
+			return err
+		}
+		return fmt.Errorf("recording network stats: %w", err)
+	}
+
+	return nil
+}
+
+func (d *DynamoNetworkStatsTable) GetDailyStats(ctx context.Context, network did.DID, from time.Time, to time.Time) ([]DailyStats, error) {
+	stats := make([]DailyStats, 0)
+	for item, err := range paging.Query(ctx, d.client, &dynamodb.QueryInput{
+		TableName:              aws.String(d.tableName),
+		KeyConditionExpression: aws.String("network = :network AND #date BETWEEN :from AND :to"),
+		ExpressionAttributeNames: map[string]string{
+			"#date": "date",
+		},
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":network": &types.AttributeValueMemberS{Value: network.String()},
+			":from":    &types.AttributeValueMemberS{Value: from.UTC().Format(dateFormat)},
+			":to":      &types.AttributeValueMemberS{Value: to.UTC().Format(dateFormat)},
+		},
+	}) {
+		if err != nil {
+			return nil, fmt.Errorf("querying network stats: %w", err)
+		}
+
+		var record networkStatsRecord
+		if err := attributevalue.UnmarshalMap(item, &record); err != nil {
+			return nil, fmt.Errorf("unmarshaling network stats record: %w", err)
+		}
+
+		date, err := time.Parse(dateFormat, record.Date)
+		if err != nil {
+			return nil, fmt.Errorf("parsing date: %w", err)
+		}
+
+		stats = append(stats, DailyStats{Date: date, Egress: record.Egress})
+	}
+
+	return stats, nil
+}
+
+type networkStatsRecord struct {
+	Date   string `dynamodbav:"date"`
+	Egress uint64 `dynamodbav:"egress"`
+}
@@ -0,0 +1,28 @@
+package networkstats
+
+import (
+	"context"
+	"time"
+
+	"github.com/storacha/go-ucanto/did"
+
+	"github.com/storacha/etracker/internal/db/markers"
+)
+
+// ErrAlreadyRecorded is returned when egress was recorded for the network under the same id before
+var ErrAlreadyRecorded = markers.ErrAlreadyRecorded
+
+type DailyStats struct {
+	Date   time.Time
+	Egress uint64
+}
+
+// NetworkStatsTable holds the daily egress of the spaces provisioned through each upload service (network), as
+// identified by the provider of their consumer record
+type NetworkStatsTable interface {
+	// Record adds egress to the daily stats of the network for the day of the given time. Egress is recorded at
+	// most once per network and id, later calls return ErrAlreadyRecorded.
+	Record(ctx context.Context, network did.DID, egress uint64, at time.Time, id string) error
+	// GetDailyStats returns the daily stats of the network for the days between from and to, inclusive
+	GetDailyStats(ctx context.Context, network did.DID, from time.Time, to time.Time) ([]DailyStats, error)
+}
@@ -0,0 +1,69 @@
+// Package paging streams the items of DynamoDB queries and scans across all their result pages.
+package paging
+
+import (
+	"context"
+	"iter"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+// Item is a raw DynamoDB item
+type Item = map[string]types.AttributeValue
+
+// Query yields every item matched by the query, fetching pages as they are consumed.
+// A non-nil Limit in the input bounds the size of each page, not the number of items yielded.
+// Iteration stops after the first error.
+func Query(ctx context.Context, client dynamodb.QueryAPIClient, input *dynamodb.QueryInput) iter.Seq2[Item, error] {
+	return func(yield func(Item, error) bool) {
+		paginator := dynamodb.NewQueryPaginator(client, input)
+		for paginator.HasMorePages() {
+			page, err := paginator.NextPage(ctx)
+			if err != nil {
+				yield(nil, err)
+				return
+			}
+
+			for _, item := range page.Items {
+				if !yield(item, nil) {
+					return
+				}
+			}
+		}
+	}
+}
+
+// First returns the first item matched by the query, reading on through pages that come back empty. It returns
+// false if no item matched.
+func First(ctx context.Context, client dynamodb.QueryAPIClient, input *dynamodb.QueryInput) (Item, bool, error) {
+	for item, err := range Query(ctx, client, input) {
+		if err != nil {
+			return nil, false, err
+		}
+		return item, true, nil
+	}
+	return nil, false, nil
+}
+
+// Scan yields every item matched by the scan, fetching pages as they are consumed.
+// A non-nil Limit in the input bounds the size of each page, not the number of items yielded.
+// Iteration stops after the first error.
+func Scan(ctx context.Context, client dynamodb.ScanAPIClient, input *dynamodb.ScanInput) iter.Seq2[Item, error] {
+	return func(yield func(Item, error) bool) {
+		paginator := dynamodb.NewScanPaginator(client, input)
+		for paginator.HasMorePages() {
+			page, err := paginator.NextPage(ctx)
+			if err != nil {
+				yield(nil, err)
+				return
+			}
+
+			for _, item := range page.Items {
+				if !yield(item, nil) {
+					return
+				}
+			}
+		}
+	}
+}
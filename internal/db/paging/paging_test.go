@@ -0,0 +1,163 @@
+package paging
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/stretchr/testify/require"
+)
+
+// pagedClient serves its items in pages of pageSize, like DynamoDB does when results exceed 1MB, after
+// serving empty pages first, like DynamoDB does when it evaluated items that didn't match
+type pagedClient struct {
+	items    []Item
+	pageSize int
+	empty    int
+	failAt   int
+	requests int
+}
+
+func newPagedClient(n, pageSize int) *pagedClient {
+	items := make([]Item, 0, n)
+	for i := range n {
+		items = append(items, Item{"id": &types.AttributeValueMemberN{Value: strconv.Itoa(i)}})
+	}
+	return &pagedClient{items: items, pageSize: pageSize, failAt: -1}
+}
+
+func (c *pagedClient) page(startKey Item) ([]Item, Item, error) {
+	if c.requests == c.failAt {
+		return nil, nil, errors.New("throttled")
+	}
+	c.requests++
+	if c.requests <= c.empty {
+		return nil, Item{"id": &types.AttributeValueMemberN{Value: "-1"}}, nil
+	}
+
+	start := 0
+	if startKey != nil {
+		id, err := strconv.Atoi(startKey["id"].(*types.AttributeValueMemberN).Value)
+		if err != nil {
+			return nil, nil, err
+		}
+		start = id + 1
+	}
+
+	end := min(start+c.pageSize, len(c.items))
+	var lastKey Item
+	if end < len(c.items) {
+		lastKey = c.items[end-1]
+	}
+	return c.items[start:end], lastKey, nil
+}
+
+func (c *pagedClient) Query(ctx context.Context, params *dynamodb.QueryInput, optFns ...func(*dynamodb.Options)) (*dynamodb.QueryOutput, error) {
+	items, lastKey, err := c.page(params.ExclusiveStartKey)
+	if err != nil {
+		return nil, err
+	}
+	return &dynamodb.QueryOutput{Items: items, LastEvaluatedKey: lastKey}, nil
+}
+
+func (c *pagedClient) Scan(ctx context.Context, params *dynamodb.ScanInput, optFns ...func(*dynamodb.Options)) (*dynamodb.ScanOutput, error) {
+	items, lastKey, err := c.page(params.ExclusiveStartKey)
+	if err != nil {
+		return nil, err
+	}
+	return &dynamodb.ScanOutput{Items: items, LastEvaluatedKey: lastKey}, nil
+}
+
+func collect(t *testing.T, seq func(func(Item, error) bool)) ([]string, error) {
+	t.Helper()
+	var ids []string
+	for item, err := range seq {
+		if err != nil {
+			return ids, err
+		}
+		ids = append(ids, item["id"].(*types.AttributeValueMemberN).Value)
+	}
+	return ids, nil
+}
+
+func TestQuery(t *testing.T) {
+	t.Run("yields the items of every page", func(t *testing.T) {
+		client := newPagedClient(25, 10)
+
+		ids, err := collect(t, Query(context.Background(), client, &dynamodb.QueryInput{}))
+		require.NoError(t, err)
+		require.Len(t, ids, 25)
+		require.Equal(t, "24", ids[24])
+		require.Equal(t, 3, client.requests)
+	})
+
+	t.Run("stops fetching pages when the caller stops", func(t *testing.T) {
+		client := newPagedClient(25, 10)
+
+		var n int
+		for _, err := range Query(context.Background(), client, &dynamodb.QueryInput{}) {
+			require.NoError(t, err)
+			n++
+			if n == 12 {
+				break
+			}
+		}
+		require.Equal(t, 2, client.requests)
+	})
+
+	t.Run("yields the error of a failed page", func(t *testing.T) {
+		client := newPagedClient(25, 10)
+		client.failAt = 1
+
+		ids, err := collect(t, Query(context.Background(), client, &dynamodb.QueryInput{}))
+		require.ErrorContains(t, err, "throttled")
+		require.Len(t, ids, 10)
+	})
+}
+
+func TestFirst(t *testing.T) {
+	t.Run("reads on through empty pages", func(t *testing.T) {
+		client := newPagedClient(25, 10)
+		client.empty = 2
+
+		item, ok, err := First(context.Background(), client, &dynamodb.QueryInput{})
+		require.NoError(t, err)
+		require.True(t, ok)
+		require.Equal(t, "0", item["id"].(*types.AttributeValueMemberN).Value)
+		require.Equal(t, 3, client.requests)
+	})
+
+	t.Run("reports that no item matched", func(t *testing.T) {
+		client := newPagedClient(0, 10)
+		client.empty = 2
+
+		_, ok, err := First(context.Background(), client, &dynamodb.QueryInput{})
+		require.NoError(t, err)
+		require.False(t, ok)
+	})
+
+	t.Run("returns the error of a failed page", func(t *testing.T) {
+		client := newPagedClient(25, 10)
+		client.empty = 2
+		client.failAt = 1
+
+		_, _, err := First(context.Background(), client, &dynamodb.QueryInput{})
+		require.ErrorContains(t, err, "throttled")
+	})
+}
+
+func TestScan(t *testing.T) {
+	for _, n := range []int{0, 10, 31} {
+		t.Run(fmt.Sprintf("yields all %d items", n), func(t *testing.T) {
+			client := newPagedClient(n, 10)
+
+			ids, err := collect(t, Scan(context.Background(), client, &dynamodb.ScanInput{}))
+			require.NoError(t, err)
+			require.Len(t, ids, n)
+		})
+	}
+}
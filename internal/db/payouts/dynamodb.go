@@ -0,0 +1,449 @@
+package payouts
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/ipfs/go-cid"
+	cidlink "github.com/ipld/go-ipld-prime/linking/cid"
+	"github.com/storacha/go-ucanto/did"
+	"github.com/storacha/go-ucanto/ucan"
+
+	"github.com/storacha/etracker/internal/db/paging"
+)
+
+var _ PayoutTable = (*DynamoPayoutTable)(nil)
+
+const (
+	monthFormat = "2006-01"
+	bytesPerTiB = 1024 * 1024 * 1024 * 1024
+	// runItem is the item of a month holding the run itself, lines and causes are stored in items of their own
+	runItem = "run"
+	// causesPerItem bounds the size of the items holding the causes of a line
+	causesPerItem = 2000
+	// writesPerTransaction leaves room for the condition check on the run in every transaction
+	writesPerTransaction = 24
+)
+
+// DynamoPayoutTable stores each run as a run item, one item per line and the causes of every line split over
+// as many items as needed to stay within the DynamoDB item size limit. All of them share the month as
+// partition key, and are only written while the run is not finalized.
+type DynamoPayoutTable struct {
+	client    *dynamodb.Client
+	tableName string
+}
+
+func NewDynamoPayoutTable(client *dynamodb.Client, tableName string) *DynamoPayoutTable {
+	return &DynamoPayoutTable{client, tableName}
+}
+
+func (d *DynamoPayoutTable) PutDraft(ctx context.Context, run Run) error {
+	month := run.Month.UTC().Format(monthFormat)
+
+	// flag the run as generating so it cannot be finalized while its lines are replaced
+	_, err := d.client.UpdateItem(ctx, &dynamodb.UpdateItemInput{
+		TableName:           aws.String(d.tableName),
+		Key:                 d.key(month, runItem),
+		UpdateExpression:    aws.String("SET #status = :generating, generatedAt = :generatedAt, providers = :providers, totalEgress = :totalEgress, totalAmount = :totalAmount REMOVE usdPerTiB"),
+		ConditionExpression: aws.String("attribute_not_exists(#status) OR #status <> :finalized"),
+		ExpressionAttributeNames: map[string]string{
+			"#status": "status",
+		},
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":generating":  &types.AttributeValueMemberS{Value: string(StatusGenerating)},
+			":finalized":   &types.AttributeValueMemberS{Value: string(StatusFinalized)},
+			":generatedAt": &types.AttributeValueMemberS{Value: run.GeneratedAt.UTC().Format(time.RFC3339Nano)},
+			":providers":   &types.AttributeValueMemberN{Value: fmt.Sprintf("%d", len(run.Lines))},
+			":totalEgress": &types.AttributeValueMemberN{Value: fmt.Sprintf("%d", run.TotalEgress)},
+			":totalAmount": &types.AttributeValueMemberN{Value: strconv.FormatFloat(run.TotalAmount, 'g', -1, 64)},
+		},
+	})
+	if err != nil {
+		var condErr *types.ConditionalCheckFailedException
+		if errors.As(err, &condErr) {
+			return ErrFinalized
+		}
+
+		return fmt.Errorf("updating payout run: %w", err)
+	}
+
+	writes, err := d.lineWrites(month, run.Lines)
+	if err != nil {
+		return err
+	}
+
+	// remove the items of the previous draft that are not overwritten
+	stale := make(map[string]struct{})
+	for item, err := range paging.Query(ctx, d.client, &dynamodb.QueryInput{
+		TableName:              aws.String(d.tableName),
+		KeyConditionExpression: aws.String("#month = :month"),
+		ProjectionExpression:   aws.String("#item"),
+		ExpressionAttributeNames: map[string]string{
+			"#month": "month",
+			"#item":  "item",
+		},
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":month": &types.AttributeValueMemberS{Value: month},
+		},
+	}) {
+		if err != nil {
+			return fmt.Errorf("querying payout run items: %w", err)
+		}
+
+		if key, ok := item["item"].(*types.AttributeValueMemberS); ok && key.Value != runItem {
+			stale[key.Value] = struct{}{}
+		}
+	}
+	for _, w := range writes {
+		delete(stale, w.Put.Item["item"].(*types.AttributeValueMemberS).Value)
+	}
+	for key := range stale {
+		writes = append(writes, types.TransactWriteItem{
+			Delete: &types.Delete{
+				TableName: aws.String(d.tableName),
+				Key:       d.key(month, key),
+			},
+		})
+	}
+
+	for chunk := range slices.Chunk(writes, writesPerTransaction) {
+		items := append([]types.TransactWriteItem{d.notFinalizedCheck(month)}, chunk...)
+		_, err := d.client.TransactWriteItems(ctx, &dynamodb.TransactWriteItemsInput{TransactItems: items})
+		if err != nil {
+			var txErr *types.TransactionCanceledException
+			if errors.As(err, &txErr) && len(txErr.CancellationReasons) > 0 && aws.ToString(txErr.CancellationReasons[0].Code) == "ConditionalCheckFailed" {
+				return ErrFinalized
+			}
+			return fmt.Errorf("writing payout lines: %w", err)
+		}
+	}
+
+	_, err = d.client.UpdateItem(ctx, &dynamodb.UpdateItemInput{
+		TableName:           aws.String(d.tableName),
+		Key:                 d.key(month, runItem),
+		UpdateExpression:    aws.String("SET #status = :draft"),
+		ConditionExpression: aws.String("#status <> :finalized"),
+		ExpressionAttributeNames: map[string]string{
+			"#status": "status",
+		},
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":draft":     &types.AttributeValueMemberS{Value: string(StatusDraft)},
+			":finalized": &types.AttributeValueMemberS{Value: string(StatusFinalized)},
+		},
+	})
+	if err != nil {
+		var condErr *types.ConditionalCheckFailedException
+		if errors.As(err, &condErr) {
+			return ErrFinalized
+		}
+
+		return fmt.Errorf("updating payout run: %w", err)
+	}
+
+	return nil
+}
+
+// lineWrites returns the puts of the line items and cause items of the lines
+func (d *DynamoPayoutTable) lineWrites(month string, lines []Line) ([]types.TransactWriteItem, error) {
+	var writes []types.TransactWriteItem
+	put := func(record any) error {
+		item, err := attributevalue.MarshalMap(record)
+		if err != nil {
+			return fmt.Errorf("serializing payout line: %w", err)
+		}
+		writes = append(writes, types.TransactWriteItem{
+			Put: &types.Put{
+				TableName: aws.String(d.tableName),
+				Item:      item,
+			},
+		})
+		return nil
+	}
+
+	for _, line := range lines {
+		provider := line.Provider.String()
+		causes := make([]string, 0, len(line.Causes))
+		for _, c := range line.Causes {
+			causes = append(causes, c.String())
+		}
+
+		chunks := slices.Collect(slices.Chunk(causes, causesPerItem))
+		if err := put(lineRecord{
+			Month:         month,
+			Item:          lineKey(provider),
+			Provider:      provider,
+			WalletAddress: line.WalletAddress,
+			OperatorEmail: line.OperatorEmail,
+			Egress:        line.Egress,
+			RateCard:      line.RateCard,
+			Amount:        &line.Amount,
+		}); err != nil {
+			return nil, err
+		}
+
+		for i, chunk := range chunks {
+			if err := put(causesRecord{
+				Month:    month,
+				Item:     causesKey(provider, i),
+				Provider: provider,
+				Causes:   chunk,
+			}); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	return writes, nil
+}
+
+func (d *DynamoPayoutTable) notFinalizedCheck(month string) types.TransactWriteItem {
+	return types.TransactWriteItem{
+		ConditionCheck: &types.ConditionCheck{
+			TableName:           aws.String(d.tableName),
+			Key:                 d.key(month, runItem),
+			ConditionExpression: aws.String("#status <> :finalized"),
+			ExpressionAttributeNames: map[string]string{
+				"#status": "status",
+			},
+			ExpressionAttributeValues: map[string]types.AttributeValue{
+				":finalized": &types.AttributeValueMemberS{Value: string(StatusFinalized)},
+			},
+		},
+	}
+}
+
+func (d *DynamoPayoutTable) Finalize(ctx context.Context, month time.Time) error {
+	_, err := d.client.UpdateItem(ctx, &dynamodb.UpdateItemInput{
+		TableName:           aws.String(d.tableName),
+		Key:                 d.key(month.UTC().Format(monthFormat), runItem),
+		UpdateExpression:    aws.String("SET #status = :finalized, finalizedAt = :now"),
+		ConditionExpression: aws.String("#status = :draft"),
+		ExpressionAttributeNames: map[string]string{
+			"#status": "status",
+		},
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":finalized": &types.AttributeValueMemberS{Value: string(StatusFinalized)},
+			":draft":     &types.AttributeValueMemberS{Value: string(StatusDraft)},
+			":now":       &types.AttributeValueMemberS{Value: time.Now().UTC().Format(time.RFC3339Nano)},
+		},
+	})
+	if err != nil {
+		var condErr *types.ConditionalCheckFailedException
+		if errors.As(err, &condErr) {
+			return ErrNotDraft
+		}
+
+		return fmt.Errorf("finalizing payout run: %w", err)
+	}
+
+	return nil
+}
+
+func (d *DynamoPayoutTable) Get(ctx context.Context, month time.Time) (*Run, error) {
+	var run *Run
+	lines := make(map[string]*Line)
+	var order []string
+	// lines of runs generated before rate cards have no amount of their own
+	var unpriced []*Line
+
+	for item, err := range paging.Query(ctx, d.client, &dynamodb.QueryInput{
+		TableName:              aws.String(d.tableName),
+		KeyConditionExpression: aws.String("#month = :month"),
+		ExpressionAttributeNames: map[string]string{
+			"#month": "month",
+		},
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":month": &types.AttributeValueMemberS{Value: month.UTC().Format(monthFormat)},
+		},
+	}) {
+		if err != nil {
+			return nil, fmt.Errorf("querying payout run: %w", err)
+		}
+
+		key, _ := item["item"].(*types.AttributeValueMemberS)
+		switch {
+		case key == nil:
+			return nil, fmt.Errorf("payout run item without key")
+		case key.Value == runItem:
+			run, err = unmarshalRun(item)
+			if err != nil {
+				return nil, err
+			}
+		case strings.HasPrefix(key.Value, "line#"):
+			var record lineRecord
+			if err := attributevalue.UnmarshalMap(item, &record); err != nil {
+				return nil, fmt.Errorf("unmarshaling payout line: %w", err)
+			}
+			provider, err := did.Parse(record.Provider)
+			if err != nil {
+				return nil, fmt.Errorf("parsing provider DID: %w", err)
+			}
+			line := lineFor(lines, &order, record.Provider)
+			line.Provider = provider
+			line.WalletAddress = record.WalletAddress
+			line.OperatorEmail = record.OperatorEmail
+			line.Egress = record.Egress
+			line.RateCard = record.RateCard
+			if record.Amount != nil {
+				line.Amount = *record.Amount
+			} else {
+				unpriced = append(unpriced, line)
+			}
+		case strings.HasPrefix(key.Value, "causes#"):
+			var record causesRecord
+			if err := attributevalue.UnmarshalMap(item, &record); err != nil {
+				return nil, fmt.Errorf("unmarshaling payout causes: %w", err)
+			}
+			line := lineFor(lines, &order, record.Provider)
+			for _, c := range record.Causes {
+				cause, err := cid.Parse(c)
+				if err != nil {
+					return nil, fmt.Errorf("parsing cause CID: %w", err)
+				}
+				line.Causes = append(line.Causes, cidlink.Link{Cid: cause})
+			}
+		}
+	}
+
+	if run == nil {
+		return nil, ErrNotFound
+	}
+
+	// those runs paid every byte at the same rate
+	for _, line := range unpriced {
+		if run.TotalEgress > 0 {
+			line.Amount = run.TotalAmount * float64(line.Egress) / float64(run.TotalEgress)
+		}
+	}
+
+	run.Lines = make([]Line, 0, len(order))
+	for _, provider := range order {
+		run.Lines = append(run.Lines, *lines[provider])
+	}
+
+	return run, nil
+}
+
+// lineFor returns the line of the provider, adding it if it was not seen yet. Cause items sort before line
+// items, so either can come first.
+func lineFor(lines map[string]*Line, order *[]string, provider string) *Line {
+	line, ok := lines[provider]
+	if !ok {
+		line = &Line{Causes: []ucan.Link{}}
+		lines[provider] = line
+		*order = append(*order, provider)
+	}
+	return line
+}
+
+func (d *DynamoPayoutTable) List(ctx context.Context) ([]Run, error) {
+	runs := make([]Run, 0)
+	for item, err := range paging.Scan(ctx, d.client, &dynamodb.ScanInput{
+		TableName:        aws.String(d.tableName),
+		FilterExpression: aws.String("#item = :run"),
+		ExpressionAttributeNames: map[string]string{
+			"#item": "item",
+		},
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":run": &types.AttributeValueMemberS{Value: runItem},
+		},
+	}) {
+		if err != nil {
+			return nil, fmt.Errorf("scanning payout runs: %w", err)
+		}
+
+		run, err := unmarshalRun(item)
+		if err != nil {
+			return nil, err
+		}
+		runs = append(runs, *run)
+	}
+
+	slices.SortFunc(runs, func(a, b Run) int {
+		return b.Month.Compare(a.Month)
+	})
+
+	return runs, nil
+}
+
+func (d *DynamoPayoutTable) key(month string, item string) map[string]types.AttributeValue {
+	return map[string]types.AttributeValue{
+		"month": &types.AttributeValueMemberS{Value: month},
+		"item":  &types.AttributeValueMemberS{Value: item},
+	}
+}
+
+func lineKey(provider string) string {
+	return "line#" + provider
+}
+
+func causesKey(provider string, i int) string {
+	return fmt.Sprintf("causes#%s#%04d", provider, i)
+}
+
+// runRecord is the internal struct for unmarshaling the run item from DynamoDB
+type runRecord struct {
+	Month       string    `dynamodbav:"month"`
+	Status      string    `dynamodbav:"status"`
+	GeneratedAt time.Time `dynamodbav:"generatedAt"`
+	FinalizedAt time.Time `dynamodbav:"finalizedAt"`
+	Providers   int       `dynamodbav:"providers"`
+	TotalEgress uint64    `dynamodbav:"totalEgress"`
+	TotalAmount *float64  `dynamodbav:"totalAmount"`
+	// USDPerTiB is the flat rate of runs generated before rate cards
+	USDPerTiB float64 `dynamodbav:"usdPerTiB,omitempty"`
+}
+
+type lineRecord struct {
+	Month         string   `dynamodbav:"month"`
+	Item          string   `dynamodbav:"item"`
+	Provider      string   `dynamodbav:"provider"`
+	WalletAddress string   `dynamodbav:"walletAddress"`
+	OperatorEmail string   `dynamodbav:"operatorEmail"`
+	Egress        uint64   `dynamodbav:"egress"`
+	RateCard      string   `dynamodbav:"rateCard,omitempty"`
+	Amount        *float64 `dynamodbav:"amount"`
+}
+
+type causesRecord struct {
+	Month    string   `dynamodbav:"month"`
+	Item     string   `dynamodbav:"item"`
+	Provider string   `dynamodbav:"provider"`
+	Causes   []string `dynamodbav:"causes"`
+}
+
+func unmarshalRun(item map[string]types.AttributeValue) (*Run, error) {
+	var record runRecord
+	if err := attributevalue.UnmarshalMap(item, &record); err != nil {
+		return nil, fmt.Errorf("unmarshaling payout run: %w", err)
+	}
+
+	month, err := time.Parse(monthFormat, record.Month)
+	if err != nil {
+		return nil, fmt.Errorf("parsing month: %w", err)
+	}
+
+	totalAmount := float64(record.TotalEgress) / bytesPerTiB * record.USDPerTiB
+	if record.TotalAmount != nil {
+		totalAmount = *record.TotalAmount
+	}
+
+	return &Run{
+		Month:       month,
+		Status:      Status(record.Status),
+		GeneratedAt: record.GeneratedAt,
+		FinalizedAt: record.FinalizedAt,
+		Providers:   record.Providers,
+		TotalEgress: record.TotalEgress,
+		TotalAmount: totalAmount,
+	}, nil
+}
@@ -0,0 +1,73 @@
+package payouts
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/storacha/go-ucanto/did"
+	"github.com/storacha/go-ucanto/ucan"
+)
+
+// Status is the state of a payout run
+type Status string
+
+const (
+	// StatusGenerating runs are having their lines replaced, they cannot be finalized until generation completes
+	StatusGenerating Status = "generating"
+	// StatusDraft runs can be regenerated or finalized
+	StatusDraft Status = "draft"
+	// StatusFinalized runs are locked, their lines never change again
+	StatusFinalized Status = "finalized"
+)
+
+// Line is the payout of a storage provider for the egress it served in the month of the run
+type Line struct {
+	Provider      did.DID
+	WalletAddress string
+	OperatorEmail string
+	Egress        uint64
+	// RateCard is the name of the rate card the egress was paid with, empty for runs generated before rate cards
+	RateCard string
+	// Amount is the payout in USD, the egress of every day being paid at the rate in effect that day
+	Amount float64
+	// Causes are the consolidated records the egress was counted from
+	Causes []ucan.Link
+	// Attestation is the attest invocation the signed attestation of the line was issued for. It is not
+	// stored with the run, it is only set on lines of finalized runs that were attested.
+	Attestation ucan.Link
+}
+
+// Run is the payout of every storage provider for a calendar month
+type Run struct {
+	// Month is the first day of the paid month
+	Month       time.Time
+	Status      Status
+	GeneratedAt time.Time
+	FinalizedAt time.Time
+	// Providers is the number of lines of the run
+	Providers   int
+	TotalEgress uint64
+	// TotalAmount is the total paid by the run in USD
+	TotalAmount float64
+	Lines       []Line
+}
+
+var (
+	ErrNotFound  = errors.New("payout run not found")
+	ErrFinalized = errors.New("payout run is finalized")
+	ErrNotDraft  = errors.New("payout run is not a draft")
+)
+
+// PayoutTable holds the monthly payout runs of storage providers
+type PayoutTable interface {
+	// PutDraft stores the run as the draft of its month, replacing the lines of any previous draft.
+	// It returns ErrFinalized if the run of the month was finalized.
+	PutDraft(ctx context.Context, run Run) error
+	// Finalize locks the draft run of the month of the given time. It returns ErrNotDraft if the run is not a draft.
+	Finalize(ctx context.Context, month time.Time) error
+	// Get returns the run of the month of the given time along with its lines, or ErrNotFound if there is none
+	Get(ctx context.Context, month time.Time) (*Run, error)
+	// List returns every run without its lines, most recent month first
+	List(ctx context.Context) ([]Run, error)
+}
@@ -2,6 +2,7 @@ package spacestats
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"time"
 
@@ -10,6 +11,9 @@ import (
 	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
 	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
 	"github.com/storacha/go-ucanto/did"
+
+	"github.com/storacha/etracker/internal/db/markers"
+	"github.com/storacha/etracker/internal/db/paging"
 )
 
 var _ SpaceStatsTable = (*DynamoSpaceStatsTable)(nil)
@@ -23,28 +27,90 @@ func NewDynamoSpaceStatsTable(client *dynamodb.Client, tableName string) *Dynamo
 	return &DynamoSpaceStatsTable{client, tableName}
 }
 
-func (d *DynamoSpaceStatsTable) Record(ctx context.Context, space did.DID, egress uint64) error {
+// maxRecordAttempts bounds the attempts at recording egress while the monthly rollup it goes to is being
+// materialized concurrently
+const maxRecordAttempts = 3
+
+func (d *DynamoSpaceStatsTable) Record(ctx context.Context, space did.DID, egress uint64, at time.Time, id string) error {
 	// Format date as YYYY-MM-DD
-	date := time.Now().UTC().Format("2006-01-02")
+	date := at.UTC().Format("2006-01-02")
 
-	// Use UpdateItem with ADD to atomically increment egress
-	// If the item doesn't exist, it will be created with the initial value
-	_, err := d.client.UpdateItem(ctx, &dynamodb.UpdateItemInput{
-		TableName: aws.String(d.tableName),
-		Key: map[string]types.AttributeValue{
-			"space": &types.AttributeValueMemberS{Value: space.String()},
-			"date":  &types.AttributeValueMemberS{Value: date},
+	// The marker item, the daily increment and the monthly rollup are written together, the marker can only be
+	// created once so the egress of an id is never added twice. ADD atomically increments egress, creating the
+	// daily item if it doesn't exist.
+	marker := markers.Put(d.tableName, "space", map[string]types.AttributeValue{
+		"space": &types.AttributeValueMemberS{Value: space.String()},
+		"date":  &types.AttributeValueMemberS{Value: markers.Key(id)},
+	}, time.Now())
+	daily := types.TransactWriteItem{
+		Update: &types.Update{
+			TableName: aws.String(d.tableName),
+			Key: map[string]types.AttributeValue{
+				"space": &types.AttributeValueMemberS{Value: space.String()},
+				"date":  &types.AttributeValueMemberS{Value: date},
+			},
+			UpdateExpression: aws.String("ADD egress :egress"),
+			ExpressionAttributeValues: map[string]types.AttributeValue{
+				":egress": &types.AttributeValueMemberN{Value: fmt.Sprintf("%d", egress)},
+			},
 		},
-		UpdateExpression: aws.String("ADD egress :egress"),
-		ExpressionAttributeValues: map[string]types.AttributeValue{
-			":egress": &types.AttributeValueMemberN{Value: fmt.Sprintf("%d", egress)},
+	}
+	monthlyKey := map[string]types.AttributeValue{
+		"space": &types.AttributeValueMemberS{Value: space.String()},
+		"date":  &types.AttributeValueMemberS{Value: monthKey(at)},
+	}
+	// Months that were not rolled up yet are left alone, they are computed from the daily stats when they are
+	// first read. The transaction checks the rollup is still missing so that it can't be materialized in between.
+	notRolledUp := types.TransactWriteItem{
+		ConditionCheck: &types.ConditionCheck{
+			TableName:           aws.String(d.tableName),
+			Key:                 monthlyKey,
+			ConditionExpression: aws.String("attribute_not_exists(egress)"),
 		},
-	})
-	if err != nil {
-		return fmt.Errorf("recording space stats: %w", err)
+	}
+	// Rollups that were materialized are kept in sync
+	rolledUp := types.TransactWriteItem{
+		Update: &types.Update{
+			TableName:           aws.String(d.tableName),
+			Key:                 monthlyKey,
+			UpdateExpression:    aws.String("ADD egress :egress"),
+			ConditionExpression: aws.String("attribute_exists(egress)"),
+			ExpressionAttributeValues: map[string]types.AttributeValue{
+				":egress": &types.AttributeValueMemberN{Value: fmt.Sprintf("%d", egress)},
+			},
+		},
+	}
+
+	// Most egress is recorded for the current month, which is never rolled up
+	monthly := notRolledUp
+	for range maxRecordAttempts {
+		_, err := d.client.TransactWriteItems(ctx, &dynamodb.TransactWriteItemsInput{
+			TransactItems: []types.TransactWriteItem{marker, daily, monthly},
+		})
+		if err == nil {
+			return nil
+		}
+
+		var txErr *types.TransactionCanceledException
+		if !errors.As(err, &txErr) || len(txErr.CancellationReasons) != 3 {
+			return fmt.Errorf("recording space stats: %w", err)
+		}
+		if markers.ConditionFailed(txErr.CancellationReasons[0]) {
+			return ErrAlreadyRecorded
+		}
+		if !markers.ConditionFailed(txErr.CancellationReasons[2]) {
+			return fmt.Errorf("recording space stats: %w", err)
+		}
+
+		// The rollup was materialized, or is missing, after all
+		if monthly.ConditionCheck != nil {
+			monthly = rolledUp
+		} else {
+			monthly = notRolledUp
+		}
 	}
 
-	return nil
+	return fmt.Errorf("recording space stats: monthly rollup of %s kept changing", monthKey(at))
 }
 
 func (d *DynamoSpaceStatsTable) GetDailyStats(ctx context.Context, space did.DID, from time.Time, to time.Time) ([]DailyStats, error) {
@@ -101,6 +167,111 @@ func (d *DynamoSpaceStatsTable) GetDailyStats(ctx context.Context, space did.DID
 	return stats, nil
 }
 
+func (d *DynamoSpaceStatsTable) GetMonthlyStats(ctx context.Context, space did.DID, from time.Time, to time.Time) ([]MonthlyStats, error) {
+	// Monthly rollups share the table with daily stats. Their sort key is prefixed so that they never fall
+	// within the range of a daily stats query.
+	rollups := make(map[time.Time]uint64)
+	for item, err := range paging.Query(ctx, d.client, &dynamodb.QueryInput{
+		TableName:              aws.String(d.tableName),
+		KeyConditionExpression: aws.String("#space = :space AND #date BETWEEN :from AND :to"),
+		ExpressionAttributeNames: map[string]string{
+			"#space": "space",
+			"#date":  "date",
+		},
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":space": &types.AttributeValueMemberS{Value: space.String()},
+			":from":  &types.AttributeValueMemberS{Value: monthKey(from)},
+			":to":    &types.AttributeValueMemberS{Value: monthKey(to)},
+		},
+		ProjectionExpression: aws.String("#date, egress"),
+	}) {
+		if err != nil {
+			return nil, fmt.Errorf("querying monthly stats for space: %w", err)
+		}
+
+		var record dailyStatsRecord
+		if err := attributevalue.UnmarshalMap(item, &record); err != nil {
+			return nil, fmt.Errorf("unmarshaling monthly stats record: %w", err)
+		}
+
+		month, err := time.Parse(monthKeyFormat, record.Date)
+		if err != nil {
+			return nil, fmt.Errorf("parsing month: %w", err)
+		}
+		rollups[month] = record.Egress
+	}
+
+	now := time.Now().UTC()
+	stats := make([]MonthlyStats, 0)
+	for month := startOfMonth(from); !month.After(to); month = month.AddDate(0, 1, 0) {
+		egress, ok := rollups[month]
+		if !ok {
+			var err error
+			egress, err = d.rollupMonth(ctx, space, month, now)
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		if egress > 0 {
+			stats = append(stats, MonthlyStats{Month: month, Egress: egress})
+		}
+	}
+
+	return stats, nil
+}
+
+// rollupMonth sums the daily stats of a month. The rollup is stored for complete months only, the
+// current month is still changing and is computed from the daily stats every time.
+func (d *DynamoSpaceStatsTable) rollupMonth(ctx context.Context, space did.DID, month time.Time, now time.Time) (uint64, error) {
+	monthEnd := month.AddDate(0, 1, 0)
+	dailyStats, err := d.GetDailyStats(ctx, space, month, monthEnd.AddDate(0, 0, -1))
+	if err != nil {
+		return 0, err
+	}
+
+	var egress uint64
+	for _, stat := range dailyStats {
+		egress += stat.Egress
+	}
+
+	if now.Before(monthEnd) {
+		return egress, nil
+	}
+
+	_, err = d.client.PutItem(ctx, &dynamodb.PutItemInput{
+		TableName: aws.String(d.tableName),
+		Item: map[string]types.AttributeValue{
+			"space":  &types.AttributeValueMemberS{Value: space.String()},
+			"date":   &types.AttributeValueMemberS{Value: monthKey(month)},
+			"egress": &types.AttributeValueMemberN{Value: fmt.Sprintf("%d", egress)},
+		},
+		ConditionExpression: aws.String("attribute_not_exists(egress)"),
+	})
+	if err != nil {
+		// Someone else materialized it first, both rollups were computed from the same daily stats
+		var condErr *types.ConditionalCheckFailedException
+		if errors.As(err, &condErr) {
+			return egress, nil
+		}
+		return 0, fmt.Errorf("storing monthly stats: %w", err)
+	}
+
+	return egress, nil
+}
+
+const monthKeyFormat = "month#2006-01"
+
+// monthKey is the sort key of the monthly rollup for the month of t
+func monthKey(t time.Time) string {
+	return t.UTC().Format(monthKeyFormat)
+}
+
+func startOfMonth(t time.Time) time.Time {
+	t = t.UTC()
+	return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.UTC)
+}
+
 // dailyStatsRecord is the internal struct for unmarshaling from DynamoDB
 type dailyStatsRecord struct {
 	Date   string `dynamodbav:"date"`
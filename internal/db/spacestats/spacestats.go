@@ -5,6 +5,8 @@ import (
 	"time"
 
 	"github.com/storacha/go-ucanto/did"
+
+	"github.com/storacha/etracker/internal/db/markers"
 )
 
 type DailyStats struct {
@@ -12,7 +14,22 @@ type DailyStats struct {
 	Egress uint64
 }
 
+// MonthlyStats is the egress of a space over a calendar month, Month being its first day
+type MonthlyStats struct {
+	Month  time.Time
+	Egress uint64
+}
+
+// ErrAlreadyRecorded is returned when egress was recorded for the space under the same id before
+var ErrAlreadyRecorded = markers.ErrAlreadyRecorded
+
 type SpaceStatsTable interface {
-	Record(ctx context.Context, space did.DID, egress uint64) error
+	// Record adds egress to the daily stats of the space for the day of the given time, and to the
+	// monthly rollup of that month if it was already materialized. Egress is recorded at most once per
+	// space and id, later calls return ErrAlreadyRecorded, so that recording can be safely retried.
+	Record(ctx context.Context, space did.DID, egress uint64, at time.Time, id string) error
 	GetDailyStats(ctx context.Context, space did.DID, from time.Time, to time.Time) ([]DailyStats, error)
+	// GetMonthlyStats returns the egress of the space for every month between the months of from and to, inclusive.
+	// Rollups of complete months are materialized from the daily stats the first time they are read.
+	GetMonthlyStats(ctx context.Context, space did.DID, from time.Time, to time.Time) ([]MonthlyStats, error)
 }
@@ -0,0 +1,372 @@
+package statements
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/storacha/go-ucanto/did"
+
+	"github.com/storacha/etracker/internal/pricing"
+)
+
+var _ StatementTable = (*DynamoStatementTable)(nil)
+
+const monthFormat = "2006-01"
+
+type DynamoStatementTable struct {
+	client    *dynamodb.Client
+	tableName string
+}
+
+func NewDynamoStatementTable(client *dynamodb.Client, tableName string) *DynamoStatementTable {
+	return &DynamoStatementTable{client, tableName}
+}
+
+func (d *DynamoStatementTable) Put(ctx context.Context, statement Statement) error {
+	record := statementRecord{
+		Account:     statement.Account.String(),
+		Month:       statement.Month.UTC().Format(monthFormat),
+		ClosedAt:    statement.ClosedAt.UTC(),
+		Plans:       make([]planRecord, 0, len(statement.Plans)),
+		Lines:       make([]lineRecord, 0, len(statement.Lines)),
+		Adjustments: marshalAdjustments(statement.Adjustments),
+		Spaces:      make([]string, 0, len(statement.Spaces)),
+	}
+	for _, p := range statement.Plans {
+		plan := planRecord{Name: p.Name, IncludedEgress: p.IncludedEgress, Tiers: make([]tierRecord, 0, len(p.Tiers))}
+		for _, t := range p.Tiers {
+			plan.Tiers = append(plan.Tiers, tierRecord{From: t.From, USDPerTiB: t.USDPerTiB})
+		}
+		record.Plans = append(record.Plans, plan)
+	}
+	for _, l := range statement.Lines {
+		record.Lines = append(record.Lines, lineRecord{Space: l.Space.String(), Plan: l.Plan, Egress: l.Egress})
+	}
+	for _, space := range statement.Spaces {
+		record.Spaces = append(record.Spaces, space.String())
+	}
+
+	item, err := attributevalue.MarshalMap(record)
+	if err != nil {
+		return fmt.Errorf("serializing statement: %w", err)
+	}
+
+	// statements are immutable once stored
+	_, err = d.client.PutItem(ctx, &dynamodb.PutItemInput{
+		TableName:           aws.String(d.tableName),
+		Item:                item,
+		ConditionExpression: aws.String("attribute_not_exists(account)"),
+	})
+	if err != nil {
+		var condErr *types.ConditionalCheckFailedException
+		if errors.As(err, &condErr) {
+			return ErrAlreadyClosed
+		}
+
+		return fmt.Errorf("storing statement: %w", err)
+	}
+
+	return nil
+}
+
+func (d *DynamoStatementTable) Get(ctx context.Context, account did.DID, month time.Time) (*Statement, error) {
+	result, err := d.client.GetItem(ctx, &dynamodb.GetItemInput{
+		TableName: aws.String(d.tableName),
+		Key:       d.key(account, month),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("getting statement: %w", err)
+	}
+
+	if result.Item == nil {
+		return nil, ErrNotFound
+	}
+
+	return unmarshalStatement(result.Item)
+}
+
+func (d *DynamoStatementTable) AddAdjustments(ctx context.Context, account did.DID, month time.Time, adjustments []Adjustment) error {
+	if len(adjustments) == 0 {
+		return nil
+	}
+
+	value, err := attributevalue.Marshal(marshalAdjustments(adjustments))
+	if err != nil {
+		return fmt.Errorf("serializing adjustments: %w", err)
+	}
+
+	_, err = d.client.UpdateItem(ctx, &dynamodb.UpdateItemInput{
+		TableName:           aws.String(d.tableName),
+		Key:                 d.key(account, month),
+		UpdateExpression:    aws.String("SET adjustments = list_append(if_not_exists(adjustments, :empty), :adjustments)"),
+		ConditionExpression: aws.String("attribute_exists(account)"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":adjustments": value,
+			":empty":       &types.AttributeValueMemberL{Value: []types.AttributeValue{}},
+		},
+	})
+	if err != nil {
+		var condErr *types.ConditionalCheckFailedException
+		if errors.As(err, &condErr) {
+			return ErrNotFound
+		}
+
+		return fmt.Errorf("adding statement adjustments: %w", err)
+	}
+
+	return nil
+}
+
+func (d *DynamoStatementTable) ListByAccount(ctx context.Context, account did.DID) ([]Statement, error) {
+	statements := make([]Statement, 0)
+	var exclusiveStartKey map[string]types.AttributeValue
+
+	for {
+		input := &dynamodb.QueryInput{
+			TableName:              aws.String(d.tableName),
+			KeyConditionExpression: aws.String("account = :account"),
+			ExpressionAttributeValues: map[string]types.AttributeValue{
+				":account": &types.AttributeValueMemberS{Value: account.String()},
+			},
+		}
+
+		if exclusiveStartKey != nil {
+			input.ExclusiveStartKey = exclusiveStartKey
+		}
+
+		result, err := d.client.Query(ctx, input)
+		if err != nil {
+			return nil, fmt.Errorf("querying statements: %w", err)
+		}
+
+		for _, item := range result.Items {
+			statement, err := unmarshalStatement(item)
+			if err != nil {
+				return nil, err
+			}
+			statements = append(statements, *statement)
+		}
+
+		if result.LastEvaluatedKey == nil {
+			break
+		}
+		exclusiveStartKey = result.LastEvaluatedKey
+	}
+
+	return statements, nil
+}
+
+func (d *DynamoStatementTable) List(ctx context.Context, limit int, cursor *string) (*ListResult, error) {
+	input := &dynamodb.ScanInput{
+		TableName: aws.String(d.tableName),
+		Limit:     aws.Int32(int32(limit)),
+	}
+
+	// Decode cursor if provided
+	if cursor != nil && *cursor != "" {
+		exclusiveStartKey, err := decodeToken(*cursor)
+		if err != nil {
+			return nil, fmt.Errorf("decoding cursor: %w", err)
+		}
+		input.ExclusiveStartKey = exclusiveStartKey
+	}
+
+	result, err := d.client.Scan(ctx, input)
+	if err != nil {
+		return nil, fmt.Errorf("scanning statements: %w", err)
+	}
+
+	statements := make([]Statement, 0, len(result.Items))
+	for _, item := range result.Items {
+		statement, err := unmarshalStatement(item)
+		if err != nil {
+			return nil, err
+		}
+		statements = append(statements, *statement)
+	}
+
+	// Encode nextCursor if there are more results
+	var nextCursor *string
+	if result.LastEvaluatedKey != nil {
+		token, err := encodeToken(result.LastEvaluatedKey)
+		if err != nil {
+			return nil, fmt.Errorf("encoding cursor: %w", err)
+		}
+		nextCursor = aws.String(token)
+	}
+
+	return &ListResult{
+		Statements: statements,
+		Cursor:     nextCursor,
+	}, nil
+}
+
+func (d *DynamoStatementTable) key(account did.DID, month time.Time) map[string]types.AttributeValue {
+	return map[string]types.AttributeValue{
+		"account": &types.AttributeValueMemberS{Value: account.String()},
+		"month":   &types.AttributeValueMemberS{Value: month.UTC().Format(monthFormat)},
+	}
+}
+
+// statementRecord is the internal struct for marshaling to and unmarshaling from DynamoDB
+type statementRecord struct {
+	Account  string    `dynamodbav:"account"`
+	Month    string    `dynamodbav:"month"`
+	ClosedAt time.Time `dynamodbav:"closedAt"`
+	// USDPerTiB is the flat rate of statements closed before plans were introduced
+	USDPerTiB   float64            `dynamodbav:"usdPerTiB,omitempty"`
+	Plans       []planRecord       `dynamodbav:"plans,omitempty"`
+	Lines       []lineRecord       `dynamodbav:"lines"`
+	Adjustments []adjustmentRecord `dynamodbav:"adjustments,omitempty"`
+	Spaces      []string           `dynamodbav:"spaces,omitempty"`
+}
+
+type planRecord struct {
+	Name           string       `dynamodbav:"name"`
+	IncludedEgress uint64       `dynamodbav:"includedEgress"`
+	Tiers          []tierRecord `dynamodbav:"tiers"`
+}
+
+type tierRecord struct {
+	From      uint64  `dynamodbav:"from"`
+	USDPerTiB float64 `dynamodbav:"usdPerTiB"`
+}
+
+type lineRecord struct {
+	Space  string `dynamodbav:"space"`
+	Plan   string `dynamodbav:"plan,omitempty"`
+	Egress uint64 `dynamodbav:"egress"`
+}
+
+type adjustmentRecord struct {
+	Space      string    `dynamodbav:"space"`
+	Plan       string    `dynamodbav:"plan,omitempty"`
+	Egress     int64     `dynamodbav:"egress"`
+	RecordedAt time.Time `dynamodbav:"recordedAt"`
+}
+
+func marshalAdjustments(adjustments []Adjustment) []adjustmentRecord {
+	records := make([]adjustmentRecord, 0, len(adjustments))
+	for _, a := range adjustments {
+		records = append(records, adjustmentRecord{Space: a.Space.String(), Plan: a.Plan, Egress: a.Egress, RecordedAt: a.RecordedAt.UTC()})
+	}
+	return records
+}
+
+func unmarshalStatement(item map[string]types.AttributeValue) (*Statement, error) {
+	var record statementRecord
+	if err := attributevalue.UnmarshalMap(item, &record); err != nil {
+		return nil, fmt.Errorf("unmarshaling statement: %w", err)
+	}
+
+	account, err := did.Parse(record.Account)
+	if err != nil {
+		return nil, fmt.Errorf("parsing account DID: %w", err)
+	}
+
+	month, err := time.Parse(monthFormat, record.Month)
+	if err != nil {
+		return nil, fmt.Errorf("parsing month: %w", err)
+	}
+
+	plans := make([]pricing.Plan, 0, len(record.Plans))
+	for _, p := range record.Plans {
+		plan := pricing.Plan{Name: p.Name, IncludedEgress: p.IncludedEgress, Tiers: make([]pricing.Tier, 0, len(p.Tiers))}
+		for _, t := range p.Tiers {
+			plan.Tiers = append(plan.Tiers, pricing.Tier{From: t.From, USDPerTiB: t.USDPerTiB})
+		}
+		plans = append(plans, plan)
+	}
+
+	// statements closed before plans were introduced bill every line at a flat rate
+	if len(record.Plans) == 0 {
+		plans = append(plans, pricing.FlatPlan(pricing.DefaultPlanName, record.USDPerTiB))
+	}
+	planOf := func(name string) string {
+		if name == "" {
+			return pricing.DefaultPlanName
+		}
+		return name
+	}
+
+	lines := make([]Line, 0, len(record.Lines))
+	for _, l := range record.Lines {
+		space, err := did.Parse(l.Space)
+		if err != nil {
+			return nil, fmt.Errorf("parsing space DID: %w", err)
+		}
+		lines = append(lines, Line{Space: space, Plan: planOf(l.Plan), Egress: l.Egress})
+	}
+
+	adjustments := make([]Adjustment, 0, len(record.Adjustments))
+	for _, a := range record.Adjustments {
+		space, err := did.Parse(a.Space)
+		if err != nil {
+			return nil, fmt.Errorf("parsing space DID: %w", err)
+		}
+		adjustments = append(adjustments, Adjustment{Space: space, Plan: planOf(a.Plan), Egress: a.Egress, RecordedAt: a.RecordedAt})
+	}
+
+	spaces := make([]did.DID, 0, len(record.Spaces))
+	for _, s := range record.Spaces {
+		space, err := did.Parse(s)
+		if err != nil {
+			return nil, fmt.Errorf("parsing space DID: %w", err)
+		}
+		spaces = append(spaces, space)
+	}
+
+	return &Statement{
+		Account:     account,
+		Month:       month,
+		ClosedAt:    record.ClosedAt,
+		Plans:       plans,
+		Lines:       lines,
+		Adjustments: adjustments,
+		Spaces:      spaces,
+	}, nil
+}
+
+// statementKey is the key of an item in the table
+type statementKey struct {
+	Account string `dynamodbav:"account" json:"account"`
+	Month   string `dynamodbav:"month" json:"month"`
+}
+
+// encodeToken encodes a DynamoDB LastEvaluatedKey from a table scan into a base64 string token
+func encodeToken(key map[string]types.AttributeValue) (string, error) {
+	var sk statementKey
+	if err := attributevalue.UnmarshalMap(key, &sk); err != nil {
+		return "", fmt.Errorf("unmarshaling last evaluated key: %w", err)
+	}
+
+	data, err := json.Marshal(sk)
+	if err != nil {
+		return "", err
+	}
+
+	return base64.URLEncoding.EncodeToString(data), nil
+}
+
+// decodeToken decodes a base64 string token into a DynamoDB ExclusiveStartKey
+func decodeToken(token string) (map[string]types.AttributeValue, error) {
+	data, err := base64.URLEncoding.DecodeString(token)
+	if err != nil {
+		return nil, fmt.Errorf("decoding base64 token: %w", err)
+	}
+
+	var sk statementKey
+	if err := json.Unmarshal(data, &sk); err != nil {
+		return nil, fmt.Errorf("unmarshaling token: %w", err)
+	}
+
+	return attributevalue.MarshalMap(sk)
+}
@@ -0,0 +1,186 @@
+package statements
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/storacha/go-ucanto/did"
+
+	"github.com/storacha/etracker/internal/pricing"
+)
+
+// Line is the egress of a space billed in a statement, priced with the plan of the space when the month was closed
+type Line struct {
+	Space  did.DID
+	Plan   string
+	Egress uint64
+}
+
+// Adjustment corrects the egress billed for a space after its statement was closed. Egress is negative when
+// less egress than billed was eventually recorded. It is priced with the plan the space was billed with.
+type Adjustment struct {
+	Space      did.DID
+	Plan       string
+	Egress     int64
+	RecordedAt time.Time
+}
+
+// Statement is the bill of an account for a calendar month. Lines and plans are fixed when the month is
+// closed, late egress is only ever added as adjustments.
+type Statement struct {
+	Account did.DID
+	// Month is the first day of the billed month
+	Month    time.Time
+	ClosedAt time.Time
+	// Plans are the plans the lines and adjustments refer to, as configured when the month was closed
+	Plans       []pricing.Plan
+	Lines       []Line
+	Adjustments []Adjustment
+	// Spaces are the spaces the account owned when the month was closed, with or without egress. It is empty for
+	// statements closed before ownership was recorded.
+	Spaces []did.DID
+}
+
+// Total is the egress billed when the month was closed
+func (s Statement) Total() uint64 {
+	var total uint64
+	for _, l := range s.Lines {
+		total += l.Egress
+	}
+	return total
+}
+
+// AdjustmentTotal is the egress added by the adjustments of the statement
+func (s Statement) AdjustmentTotal() int64 {
+	var total int64
+	for _, a := range s.Adjustments {
+		total += a.Egress
+	}
+	return total
+}
+
+// AdjustedTotal is the egress billed by the statement, adjustments included
+func (s Statement) AdjustedTotal() int64 {
+	return int64(s.Total()) + s.AdjustmentTotal()
+}
+
+// Billed returns the egress billed for every space, adjustments included
+func (s Statement) Billed() map[did.DID]int64 {
+	billed := make(map[did.DID]int64, len(s.Lines))
+	for _, l := range s.Lines {
+		billed[l.Space] += int64(l.Egress)
+	}
+	for _, a := range s.Adjustments {
+		billed[a.Space] += a.Egress
+	}
+	return billed
+}
+
+// BilledPlans returns the plan every space billed by the statement was priced with
+func (s Statement) BilledPlans() map[did.DID]string {
+	plans := make(map[did.DID]string, len(s.Lines))
+	for _, a := range s.Adjustments {
+		plans[a.Space] = a.Plan
+	}
+	for _, l := range s.Lines {
+		plans[l.Space] = l.Plan
+	}
+	return plans
+}
+
+// Plan returns the plan of the statement with the given name. Statements refer to plans they hold only,
+// an unknown plan charges nothing.
+func (s Statement) Plan(name string) pricing.Plan {
+	for _, p := range s.Plans {
+		if p.Name == name {
+			return p
+		}
+	}
+	return pricing.Plan{Name: name}
+}
+
+// Amount is the price of the egress billed when the month was closed. The allowance and tiers of a plan
+// apply to the egress of all the spaces of the account priced with it.
+func (s Statement) Amount() float64 {
+	var usd float64
+	for plan, egress := range s.planTotals(false) {
+		usd += s.Plan(plan).PriceSigned(egress)
+	}
+	return usd
+}
+
+// AdjustedAmount is the price of the egress billed by the statement, adjustments included
+func (s Statement) AdjustedAmount() float64 {
+	var usd float64
+	for plan, egress := range s.planTotals(true) {
+		usd += s.Plan(plan).PriceSigned(egress)
+	}
+	return usd
+}
+
+// LineAmounts returns the share of Amount of every line, in proportion to its egress in the total of its plan
+func (s Statement) LineAmounts() []float64 {
+	totals := s.planTotals(false)
+	amounts := make([]float64, 0, len(s.Lines))
+	for _, l := range s.Lines {
+		total := totals[l.Plan]
+		if total <= 0 {
+			amounts = append(amounts, 0)
+			continue
+		}
+		amounts = append(amounts, s.Plan(l.Plan).PriceSigned(total)*float64(l.Egress)/float64(total))
+	}
+	return amounts
+}
+
+// AdjustmentAmounts returns the change of the amount of the statement caused by every adjustment, in the
+// order they were recorded. They add up to the difference between AdjustedAmount and Amount.
+func (s Statement) AdjustmentAmounts() []float64 {
+	totals := s.planTotals(false)
+	amounts := make([]float64, 0, len(s.Adjustments))
+	for _, a := range s.Adjustments {
+		plan := s.Plan(a.Plan)
+		before := totals[a.Plan]
+		totals[a.Plan] += a.Egress
+		amounts = append(amounts, plan.PriceSigned(totals[a.Plan])-plan.PriceSigned(before))
+	}
+	return amounts
+}
+
+// planTotals returns the egress billed with every plan, optionally including adjustments
+func (s Statement) planTotals(adjusted bool) map[string]int64 {
+	totals := make(map[string]int64)
+	for _, l := range s.Lines {
+		totals[l.Plan] += int64(l.Egress)
+	}
+	if adjusted {
+		for _, a := range s.Adjustments {
+			totals[a.Plan] += a.Egress
+		}
+	}
+	return totals
+}
+
+type ListResult struct {
+	Statements []Statement
+	Cursor     *string
+}
+
+var (
+	ErrNotFound      = errors.New("statement not found")
+	ErrAlreadyClosed = errors.New("statement already closed")
+)
+
+// StatementTable holds the monthly statements of accounts
+type StatementTable interface {
+	// Put stores the statement of a closed month, or returns ErrAlreadyClosed if there is one already
+	Put(ctx context.Context, statement Statement) error
+	// Get returns the statement of the account for the month of the given time, or ErrNotFound if it is not closed
+	Get(ctx context.Context, account did.DID, month time.Time) (*Statement, error)
+	// AddAdjustments appends adjustments to the statement of the account for the month of the given time
+	AddAdjustments(ctx context.Context, account did.DID, month time.Time, adjustments []Adjustment) error
+	// ListByAccount returns the statements of the account, oldest first
+	ListByAccount(ctx context.Context, account did.DID) ([]Statement, error)
+	List(ctx context.Context, limit int, cursor *string) (*ListResult, error)
+}
@@ -0,0 +1,54 @@
+package exclusion
+
+import (
+	"fmt"
+	"slices"
+
+	"github.com/storacha/go-ucanto/did"
+
+	"github.com/storacha/etracker/internal/db/spacestats"
+)
+
+// List is the set of spaces whose egress counts neither toward the billing of their account nor toward the
+// payouts of the nodes serving it, such as the spaces of synthetic monitoring. Their egress is recorded in a
+// stats table of its own instead of the space stats.
+type List struct {
+	spaces     []did.DID
+	statsTable spacestats.SpaceStatsTable
+}
+
+// New creates an exclusion list of the given spaces, whose egress is recorded in statsTable
+func New(spaces []did.DID, statsTable spacestats.SpaceStatsTable) *List {
+	return &List{spaces: slices.Clone(spaces), statsTable: statsTable}
+}
+
+// Parse creates an exclusion list of the spaces with the given DIDs
+func Parse(spaces []string, statsTable spacestats.SpaceStatsTable) (*List, error) {
+	dids := make([]did.DID, 0, len(spaces))
+	for _, s := range spaces {
+		space, err := did.Parse(s)
+		if err != nil {
+			return nil, fmt.Errorf("parsing excluded space %s: %w", s, err)
+		}
+		dids = append(dids, space)
+	}
+	return New(dids, statsTable), nil
+}
+
+// Contains reports whether the egress of the space is excluded. A nil list excludes no space.
+func (l *List) Contains(space did.DID) bool {
+	return l != nil && slices.Contains(l.spaces, space)
+}
+
+// Spaces returns the excluded spaces
+func (l *List) Spaces() []did.DID {
+	if l == nil {
+		return nil
+	}
+	return l.spaces
+}
+
+// StatsTable is the table the egress of excluded spaces is recorded in
+func (l *List) StatsTable() spacestats.SpaceStatsTable {
+	return l.statsTable
+}
@@ -26,6 +26,15 @@ var (
 
 	// ConsolidationRunDuration tracks the time (in milliseconds) each consolidation run takes to process all batches
 	ConsolidationRunDuration metric.Int64Histogram
+
+	// AnomaliesDetected counts the egress anomaly flags raised, by kind and severity
+	AnomaliesDetected metric.Int64Counter
+
+	// HeldBatchesPerNode counts the number of consolidated batches held for review per node
+	HeldBatchesPerNode metric.Int64Counter
+
+	// OldestUnprocessedBatchAge is the time (in seconds) the oldest unprocessed batch has been waiting for consolidation
+	OldestUnprocessedBatchAge metric.Int64Gauge
 )
 
 // Init initializes the OpenTelemetry metrics with Prometheus exporter
@@ -88,6 +97,30 @@ func Init(environment string) error {
 		return fmt.Errorf("failed to create ConsolidationRunDuration histogram: %w", err)
 	}
 
+	AnomaliesDetected, err = meter.Int64Counter(
+		"etracker_anomalies_detected_total",
+		metric.WithDescription("Total number of egress anomaly flags raised"),
+	)
+	if err != nil {
+		return fmt.Errorf("failed to create AnomaliesDetected counter: %w", err)
+	}
+
+	HeldBatchesPerNode, err = meter.Int64Counter(
+		"etracker_held_batches_total",
+		metric.WithDescription("Total number of consolidated batches held for review per node"),
+	)
+	if err != nil {
+		return fmt.Errorf("failed to create HeldBatchesPerNode counter: %w", err)
+	}
+
+	OldestUnprocessedBatchAge, err = meter.Int64Gauge(
+		"etracker_oldest_unprocessed_batch_age_seconds",
+		metric.WithDescription("Time in seconds the oldest unprocessed batch has been waiting for consolidation"),
+	)
+	if err != nil {
+		return fmt.Errorf("failed to create OldestUnprocessedBatchAge gauge: %w", err)
+	}
+
 	log.Info("OpenTelemetry metrics initialized with Prometheus exporter")
 	return nil
 }
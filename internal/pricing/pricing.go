@@ -0,0 +1,254 @@
+package pricing
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"reflect"
+	"slices"
+	"strings"
+
+	"github.com/storacha/go-ucanto/did"
+
+	"github.com/storacha/etracker/internal/db/consumer"
+)
+
+const (
+	bytesPerTiB = 1024 * 1024 * 1024 * 1024
+
+	// DefaultPlanName is the name of the plan applying to spaces no other plan is configured for
+	DefaultPlanName = "default"
+)
+
+// Tier is the rate of the monthly egress from From bytes up to the From of the next tier
+type Tier struct {
+	From      uint64  `json:"from"`
+	USDPerTiB float64 `json:"usdPerTiB"`
+}
+
+// Plan prices the egress of a calendar month. Egress up to IncludedEgress is free, egress above it is charged
+// at the rate of the tier it falls in. Tiers are sorted by From, egress below the From of the first tier is free.
+type Plan struct {
+	Name           string `json:"name"`
+	IncludedEgress uint64 `json:"includedEgress"`
+	Tiers          []Tier `json:"tiers"`
+}
+
+// FlatPlan charges every byte of egress at the same rate
+func FlatPlan(name string, usdPerTiB float64) Plan {
+	return Plan{Name: name, Tiers: []Tier{{From: 0, USDPerTiB: usdPerTiB}}}
+}
+
+// Price returns the price in USD of the total egress of a month
+func (p Plan) Price(egress uint64) float64 {
+	return p.PriceIncrement(0, egress)
+}
+
+// PriceIncrement returns the price in USD of egress added to a month in which used bytes were served already,
+// so that egress crossing the allowance or a tier boundary is split between the rates on either side.
+func (p Plan) PriceIncrement(used, egress uint64) float64 {
+	from := max(used, p.IncludedEgress)
+	to := used + egress
+	if to <= from {
+		return 0
+	}
+
+	var usd float64
+	for i, tier := range p.Tiers {
+		end := uint64(math.MaxUint64)
+		if i+1 < len(p.Tiers) {
+			end = p.Tiers[i+1].From
+		}
+
+		lo, hi := max(from, tier.From), min(to, end)
+		if hi > lo {
+			usd += float64(hi-lo) / bytesPerTiB * tier.USDPerTiB
+		}
+	}
+
+	return usd
+}
+
+// PriceSigned is Price for egress that may be negative, which is priced as no egress
+func (p Plan) PriceSigned(egress int64) float64 {
+	if egress <= 0 {
+		return 0
+	}
+	return p.Price(uint64(egress))
+}
+
+func (p Plan) validate() error {
+	for i, tier := range p.Tiers {
+		if tier.USDPerTiB < 0 {
+			return fmt.Errorf("plan %s: tier %d has a negative rate", p.Name, i)
+		}
+		if i > 0 && tier.From <= p.Tiers[i-1].From {
+			return fmt.Errorf("plan %s: tier %d does not start after the previous tier", p.Name, i)
+		}
+	}
+	return nil
+}
+
+// Config selects the plan of a space by the subscription of its consumer record first, then by the upload
+// service provider (network) it was provisioned through, falling back to the default plan.
+type Config struct {
+	Default Plan `json:"default"`
+	// Plans are keyed by subscription
+	Plans map[string]Plan `json:"plans"`
+	// Providers are keyed by provider DID
+	Providers map[string]Plan `json:"providers"`
+}
+
+// ParseConfig decodes a JSON pricing configuration
+func ParseConfig(data []byte) (Config, error) {
+	var cfg Config
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		return Config{}, fmt.Errorf("decoding pricing config: %w", err)
+	}
+	return cfg, nil
+}
+
+// Engine resolves the plan egress is priced with
+type Engine struct {
+	defaultPlan    Plan
+	bySubscription map[string]Plan
+	byProvider     map[did.DID]Plan
+}
+
+// New creates a pricing engine from the given configuration. Plans without a name are named after the
+// subscription or provider they are configured for, plans sharing a name must have the same rates.
+func New(cfg Config) (*Engine, error) {
+	e := &Engine{
+		defaultPlan:    cfg.Default,
+		bySubscription: make(map[string]Plan, len(cfg.Plans)),
+		byProvider:     make(map[did.DID]Plan, len(cfg.Providers)),
+	}
+	if e.defaultPlan.Name == "" {
+		e.defaultPlan.Name = DefaultPlanName
+	}
+	if err := e.defaultPlan.validate(); err != nil {
+		return nil, err
+	}
+
+	// statements refer to plans by name, so a name must always designate the same plan
+	named := map[string]Plan{e.defaultPlan.Name: e.defaultPlan}
+	register := func(plan Plan) error {
+		if err := plan.validate(); err != nil {
+			return err
+		}
+		if other, ok := named[plan.Name]; ok && !reflect.DeepEqual(other, plan) {
+			return fmt.Errorf("plan %s is configured with different rates", plan.Name)
+		}
+		named[plan.Name] = plan
+		return nil
+	}
+
+	for subscription, plan := range cfg.Plans {
+		if plan.Name == "" {
+			plan.Name = subscription
+		}
+		if err := register(plan); err != nil {
+			return nil, err
+		}
+		e.bySubscription[subscription] = plan
+	}
+
+	for provider, plan := range cfg.Providers {
+		p, err := did.Parse(provider)
+		if err != nil {
+			return nil, fmt.Errorf("parsing provider %s: %w", provider, err)
+		}
+		if plan.Name == "" {
+			plan.Name = provider
+		}
+		if err := register(plan); err != nil {
+			return nil, err
+		}
+		e.byProvider[p] = plan
+	}
+
+	return e, nil
+}
+
+// NewFlat creates a pricing engine charging every space the same flat rate
+func NewFlat(usdPerTiB float64) *Engine {
+	return &Engine{
+		defaultPlan:    FlatPlan(DefaultPlanName, usdPerTiB),
+		bySubscription: map[string]Plan{},
+		byProvider:     map[did.DID]Plan{},
+	}
+}
+
+// Uniform reports whether every space is priced with the default plan, in which case consumer records do not
+// need to be looked up
+func (e *Engine) Uniform() bool {
+	return len(e.bySubscription) == 0 && len(e.byProvider) == 0
+}
+
+// Default returns the plan of spaces no other plan is configured for
+func (e *Engine) Default() Plan {
+	return e.defaultPlan
+}
+
+// Plans returns every configured plan, the default plan first and the others sorted by name
+func (e *Engine) Plans() []Plan {
+	plans := []Plan{e.defaultPlan}
+	seen := map[string]bool{e.defaultPlan.Name: true}
+	add := func(plan Plan) {
+		if !seen[plan.Name] {
+			seen[plan.Name] = true
+			plans = append(plans, plan)
+		}
+	}
+	for _, plan := range e.bySubscription {
+		add(plan)
+	}
+	for _, plan := range e.byProvider {
+		add(plan)
+	}
+	slices.SortFunc(plans[1:], func(a, b Plan) int {
+		return strings.Compare(a.Name, b.Name)
+	})
+	return plans
+}
+
+// Plan returns the configured plan with the given name
+func (e *Engine) Plan(name string) (Plan, bool) {
+	for _, plan := range e.Plans() {
+		if plan.Name == name {
+			return plan, true
+		}
+	}
+	return Plan{}, false
+}
+
+// PlanFor returns the plan the egress of the consumer's space is priced with
+func (e *Engine) PlanFor(c consumer.Consumer) Plan {
+	if plan, ok := e.bySubscription[c.Subscription]; ok && c.Subscription != "" {
+		return plan
+	}
+	if plan, ok := e.byProvider[c.Provider]; ok {
+		return plan
+	}
+	return e.defaultPlan
+}
+
+// PlanForSpace looks up the consumer record of the space to return the plan its egress is priced with.
+// Spaces without a consumer record are priced with the default plan.
+func (e *Engine) PlanForSpace(ctx context.Context, consumerTable consumer.ConsumerTable, space did.DID) (Plan, error) {
+	if e.Uniform() {
+		return e.defaultPlan, nil
+	}
+
+	c, err := consumerTable.Get(ctx, space.String())
+	if err != nil {
+		if errors.Is(err, consumer.ErrNotFound) {
+			return e.defaultPlan, nil
+		}
+		return Plan{}, fmt.Errorf("getting consumer %s: %w", space, err)
+	}
+
+	return e.PlanFor(c), nil
+}
@@ -0,0 +1,100 @@
+package pricing
+
+import (
+	"testing"
+	"time"
+
+	"github.com/storacha/go-libstoracha/testutil"
+	"github.com/stretchr/testify/require"
+
+	"github.com/storacha/etracker/internal/db/consumer"
+)
+
+const tib = 1 << 40
+
+func TestPlanPrice(t *testing.T) {
+	plan := Plan{
+		Name:           "pro",
+		IncludedEgress: tib,
+		Tiers:          []Tier{{From: 0, USDPerTiB: 10}, {From: 3 * tib, USDPerTiB: 5}},
+	}
+
+	require.Equal(t, 0.0, plan.Price(tib))
+	require.Equal(t, 20.0, plan.Price(3*tib))
+	require.Equal(t, 25.0, plan.Price(4*tib))
+
+	// increments are priced against the egress served before them
+	require.Equal(t, 5.0, plan.PriceIncrement(tib/2, tib))
+	require.Equal(t, 15.0, plan.PriceIncrement(2*tib, 2*tib))
+	require.Equal(t, plan.Price(4*tib), plan.PriceIncrement(0, tib)+plan.PriceIncrement(tib, 2*tib)+plan.PriceIncrement(3*tib, tib))
+
+	require.Equal(t, 0.0, plan.PriceSigned(-tib))
+}
+
+func TestEngine(t *testing.T) {
+	network := testutil.RandomDID(t)
+
+	cfg, err := ParseConfig([]byte(`{
+		"default": {"tiers": [{"from": 0, "usdPerTiB": 20}]},
+		"plans": {"pro": {"includedEgress": 1099511627776, "tiers": [{"from": 0, "usdPerTiB": 10}]}},
+		"providers": {"` + network.String() + `": {"name": "network", "tiers": [{"from": 0, "usdPerTiB": 15}]}}
+	}`))
+	require.NoError(t, err)
+
+	e, err := New(cfg)
+	require.NoError(t, err)
+	require.False(t, e.Uniform())
+
+	// the subscription takes precedence over the provider
+	require.Equal(t, "pro", e.PlanFor(consumer.Consumer{Subscription: "pro", Provider: network}).Name)
+	require.Equal(t, "network", e.PlanFor(consumer.Consumer{Subscription: "free", Provider: network}).Name)
+	require.Equal(t, DefaultPlanName, e.PlanFor(consumer.Consumer{}).Name)
+
+	require.Equal(t, []string{DefaultPlanName, "network", "pro"}, []string{e.Plans()[0].Name, e.Plans()[1].Name, e.Plans()[2].Name})
+
+	t.Run("plans sharing a name must have the same rates", func(t *testing.T) {
+		cfg.Plans["enterprise"] = Plan{Name: "pro", Tiers: []Tier{{From: 0, USDPerTiB: 8}}}
+		_, err := New(cfg)
+		require.Error(t, err)
+	})
+
+	t.Run("tiers must be sorted", func(t *testing.T) {
+		_, err := New(Config{Default: Plan{Tiers: []Tier{{From: tib, USDPerTiB: 10}, {From: 0, USDPerTiB: 5}}}})
+		require.Error(t, err)
+	})
+}
+
+func TestRateCards(t *testing.T) {
+	node1 := testutil.RandomDID(t)
+	node2 := testutil.RandomDID(t)
+	node3 := testutil.RandomDID(t)
+
+	cfg, err := ParseRateCardConfig([]byte(`{
+		"default": [{"usdPerTiB": 2}],
+		"tiers": {"gold": [{"from": "2025-03-15", "usdPerTiB": 4}, {"usdPerTiB": 3}]},
+		"nodes": {
+			"` + node1.String() + `": {"tier": "gold"},
+			"` + node2.String() + `": {"rates": [{"usdPerTiB": 1}]}
+		}
+	}`))
+	require.NoError(t, err)
+
+	cards, err := NewRateCards(cfg)
+	require.NoError(t, err)
+
+	// rates take effect at the start of their day
+	require.Equal(t, 3.0, cards.CardFor(node1).RateOn(time.Date(2025, 3, 14, 23, 59, 0, 0, time.UTC)))
+	require.Equal(t, 4.0, cards.CardFor(node1).RateOn(time.Date(2025, 3, 15, 0, 0, 0, 0, time.UTC)))
+	require.Equal(t, 8.0, cards.Payout(node1, 2*tib, time.Date(2025, 4, 1, 0, 0, 0, 0, time.UTC)))
+
+	require.Equal(t, node2.String(), cards.CardFor(node2).Name)
+	require.Equal(t, 1.0, cards.Payout(node2, tib, time.Now()))
+	require.Equal(t, DefaultRateCardName, cards.CardFor(node3).Name)
+	require.Equal(t, 2.0, cards.Payout(node3, tib, time.Now()))
+
+	t.Run("nodes cannot be assigned to unknown tiers", func(t *testing.T) {
+		cfg.Nodes[node3.String()] = NodeRateConfig{Tier: "platinum"}
+		_, err := NewRateCards(cfg)
+		require.Error(t, err)
+	})
+}
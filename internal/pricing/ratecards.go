@@ -0,0 +1,180 @@
+package pricing
+
+import (
+	"encoding/json"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/storacha/go-ucanto/did"
+)
+
+const (
+	// DefaultRateCardName is the name of the rate card of nodes no other rate card is configured for
+	DefaultRateCardName = "default"
+
+	dayFormat = "2006-01-02"
+)
+
+// Rate is the payout rate of node egress from the day From onwards
+type Rate struct {
+	From      time.Time
+	USDPerTiB float64
+}
+
+// RateCard is the schedule of payout rates of nodes. Rates are sorted by the day they take effect, egress
+// before the first of them is paid at its rate.
+type RateCard struct {
+	Name  string
+	Rates []Rate
+}
+
+// RateOn returns the rate in effect on the day of the given time
+func (c RateCard) RateOn(when time.Time) float64 {
+	if len(c.Rates) == 0 {
+		return 0
+	}
+
+	rate := c.Rates[0].USDPerTiB
+	for _, r := range c.Rates[1:] {
+		if when.Before(r.From) {
+			break
+		}
+		rate = r.USDPerTiB
+	}
+	return rate
+}
+
+// Payout returns the payout in USD of egress served on the day of the given time
+func (c RateCard) Payout(egress uint64, when time.Time) float64 {
+	return float64(egress) / bytesPerTiB * c.RateOn(when)
+}
+
+// RateConfig is a rate taking effect on the day From, formatted as YYYY-MM-DD
+type RateConfig struct {
+	From      string  `json:"from"`
+	USDPerTiB float64 `json:"usdPerTiB"`
+}
+
+// NodeRateConfig assigns a node either to a tier or to rates of its own
+type NodeRateConfig struct {
+	Tier  string       `json:"tier,omitempty"`
+	Rates []RateConfig `json:"rates,omitempty"`
+}
+
+// RateCardConfig configures the payout rates of nodes by node first, then by the tier the node is assigned to,
+// falling back to the default rates
+type RateCardConfig struct {
+	Default []RateConfig `json:"default"`
+	// Tiers are keyed by tier name
+	Tiers map[string][]RateConfig `json:"tiers"`
+	// Nodes are keyed by node DID
+	Nodes map[string]NodeRateConfig `json:"nodes"`
+}
+
+// ParseRateCardConfig decodes a JSON rate card configuration
+func ParseRateCardConfig(data []byte) (RateCardConfig, error) {
+	var cfg RateCardConfig
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		return RateCardConfig{}, fmt.Errorf("decoding rate card config: %w", err)
+	}
+	return cfg, nil
+}
+
+// RateCards resolves the rate card node egress is paid with
+type RateCards struct {
+	defaultCard RateCard
+	byNode      map[did.DID]RateCard
+}
+
+// NewRateCards creates the rate cards of the given configuration. Nodes with rates of their own get a rate card
+// named after their DID, nodes assigned to a tier get the rate card of the tier.
+func NewRateCards(cfg RateCardConfig) (*RateCards, error) {
+	defaultCard, err := newRateCard(DefaultRateCardName, cfg.Default)
+	if err != nil {
+		return nil, err
+	}
+
+	tiers := make(map[string]RateCard, len(cfg.Tiers))
+	for name, rates := range cfg.Tiers {
+		if tiers[name], err = newRateCard(name, rates); err != nil {
+			return nil, err
+		}
+	}
+
+	r := &RateCards{defaultCard: defaultCard, byNode: make(map[did.DID]RateCard, len(cfg.Nodes))}
+	for node, nodeCfg := range cfg.Nodes {
+		n, err := did.Parse(node)
+		if err != nil {
+			return nil, fmt.Errorf("parsing node %s: %w", node, err)
+		}
+
+		if nodeCfg.Tier != "" {
+			card, ok := tiers[nodeCfg.Tier]
+			if !ok {
+				return nil, fmt.Errorf("node %s is assigned to unknown tier %s", node, nodeCfg.Tier)
+			}
+			r.byNode[n] = card
+			continue
+		}
+
+		if r.byNode[n], err = newRateCard(node, nodeCfg.Rates); err != nil {
+			return nil, err
+		}
+	}
+
+	return r, nil
+}
+
+// FlatRateCards creates rate cards paying every node the same rate at all times
+func FlatRateCards(usdPerTiB float64) *RateCards {
+	return &RateCards{
+		defaultCard: RateCard{Name: DefaultRateCardName, Rates: []Rate{{USDPerTiB: usdPerTiB}}},
+		byNode:      map[did.DID]RateCard{},
+	}
+}
+
+// CardFor returns the rate card the egress of the node is paid with
+func (r *RateCards) CardFor(node did.DID) RateCard {
+	if card, ok := r.byNode[node]; ok {
+		return card
+	}
+	return r.defaultCard
+}
+
+// Payout returns the payout in USD of egress served by the node on the day of the given time
+func (r *RateCards) Payout(node did.DID, egress uint64, when time.Time) float64 {
+	return r.CardFor(node).Payout(egress, when)
+}
+
+func newRateCard(name string, rates []RateConfig) (RateCard, error) {
+	if len(rates) == 0 {
+		return RateCard{}, fmt.Errorf("rate card %s has no rates", name)
+	}
+
+	card := RateCard{Name: name, Rates: make([]Rate, 0, len(rates))}
+	for _, rc := range rates {
+		var from time.Time
+		if rc.From != "" {
+			var err error
+			if from, err = time.Parse(dayFormat, rc.From); err != nil {
+				return RateCard{}, fmt.Errorf("rate card %s: parsing effective date: %w", name, err)
+			}
+		}
+		if rc.USDPerTiB < 0 {
+			return RateCard{}, fmt.Errorf("rate card %s: negative rate from %s", name, rc.From)
+		}
+		card.Rates = append(card.Rates, Rate{From: from, USDPerTiB: rc.USDPerTiB})
+	}
+
+	slices.SortFunc(card.Rates, func(a, b Rate) int {
+		return a.From.Compare(b.From)
+	})
+	for i := 1; i < len(card.Rates); i++ {
+		if card.Rates[i].From.Equal(card.Rates[i-1].From) {
+			return RateCard{}, fmt.Errorf("rate card %s has several rates from %s", name, card.Rates[i].From.Format(dayFormat))
+		}
+	}
+
+	return card, nil
+}
@@ -0,0 +1,175 @@
+package quota
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	logging "github.com/ipfs/go-log/v2"
+	"github.com/storacha/go-ucanto/did"
+)
+
+var log = logging.Logger("quota")
+
+// Event is the notification sent when the egress of an account crosses a threshold of its monthly limit
+type Event struct {
+	// ID is the same for every notification of the same crossing, so sinks can deduplicate them
+	ID        string    `json:"id"`
+	Account   string    `json:"account"`
+	Month     string    `json:"month"`
+	Threshold float64   `json:"threshold"`
+	Limit     uint64    `json:"limit"`
+	Used      uint64    `json:"used"`
+	At        time.Time `json:"at"`
+}
+
+// Sink receives the events of accounts crossing a threshold of their quota
+type Sink interface {
+	Send(ctx context.Context, event Event) error
+}
+
+// LogSink logs events as warnings
+type LogSink struct{}
+
+func (LogSink) Send(ctx context.Context, event Event) error {
+	log.Warnw("Account crossed egress quota threshold", "account", event.Account, "month", event.Month,
+		"threshold", event.Threshold, "limit", event.Limit, "used", event.Used)
+	return nil
+}
+
+// WebhookSink posts events as JSON to a URL, any response status other than 2xx is an error
+type WebhookSink struct {
+	endpoint   *url.URL
+	httpClient *http.Client
+}
+
+func NewWebhookSink(endpoint *url.URL) *WebhookSink {
+	return &WebhookSink{endpoint: endpoint, httpClient: &http.Client{Timeout: 30 * time.Second}}
+}
+
+func (w *WebhookSink) Send(ctx context.Context, event Event) error {
+	body, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("serializing quota event: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, w.endpoint.String(), bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("creating request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	res, err := w.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("posting quota event: %w", err)
+	}
+	defer res.Body.Close()
+	_, _ = io.Copy(io.Discard, res.Body)
+
+	if res.StatusCode < 200 || res.StatusCode >= 300 {
+		return fmt.Errorf("posting quota event: unexpected status %d", res.StatusCode)
+	}
+	return nil
+}
+
+type observation struct {
+	account did.DID
+	month   time.Time
+}
+
+// Monitor alerts when the egress of accounts crosses the thresholds of their quota. The egress recorded for
+// accounts is fed to the monitor with Observe during a consolidation cycle, and Run compares the usage of each
+// account before and after the cycle once it is over, so that every crossing is notified once without keeping
+// track of past notifications.
+type Monitor struct {
+	quotas *Quotas
+	sink   Sink
+
+	mu       sync.Mutex
+	observed map[observation]uint64
+}
+
+func NewMonitor(quotas *Quotas, sink Sink) *Monitor {
+	return &Monitor{
+		quotas:   quotas,
+		sink:     sink,
+		observed: make(map[observation]uint64),
+	}
+}
+
+// Observe records egress added to the daily stats of the account at the given time
+func (m *Monitor) Observe(account did.DID, egress uint64, at time.Time) {
+	at = at.UTC()
+	key := observation{account, time.Date(at.Year(), at.Month(), 1, 0, 0, 0, 0, time.UTC)}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.observed[key] += egress
+}
+
+// Run evaluates the quota of every account observed since the previous run and sends an event for each threshold
+// crossed. Accounts that could not be evaluated or notified are evaluated again on the next run.
+func (m *Monitor) Run(ctx context.Context) ([]Event, error) {
+	m.mu.Lock()
+	observed := m.observed
+	m.observed = make(map[observation]uint64)
+	m.mu.Unlock()
+
+	var (
+		events []Event
+		errs   []error
+	)
+	for key, added := range observed {
+		sent, err := m.evaluate(ctx, key, added)
+		events = append(events, sent...)
+		if err != nil {
+			errs = append(errs, err)
+			m.mu.Lock()
+			m.observed[key] += added
+			m.mu.Unlock()
+		}
+	}
+
+	return events, errors.Join(errs...)
+}
+
+func (m *Monitor) evaluate(ctx context.Context, key observation, added uint64) ([]Event, error) {
+	usage, err := m.quotas.Usage(ctx, key.account, key.month)
+	if err != nil || usage == nil {
+		return nil, err
+	}
+
+	before := usage.Used - min(added, usage.Used)
+
+	var events []Event
+	for _, threshold := range m.quotas.Thresholds() {
+		level := uint64(float64(usage.Limit) * threshold / 100)
+		if before >= level || usage.Used < level {
+			continue
+		}
+
+		month := usage.Month.Format("2006-01")
+		event := Event{
+			ID:        fmt.Sprintf("%s:%s:%v", usage.Account, month, threshold),
+			Account:   usage.Account.String(),
+			Month:     month,
+			Threshold: threshold,
+			Limit:     usage.Limit,
+			Used:      usage.Used,
+			At:        time.Now().UTC(),
+		}
+		if err := m.sink.Send(ctx, event); err != nil {
+			return events, fmt.Errorf("sending quota event %s: %w", event.ID, err)
+		}
+		events = append(events, event)
+	}
+
+	return events, nil
+}
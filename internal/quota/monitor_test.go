@@ -0,0 +1,203 @@
+package quota
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/storacha/go-libstoracha/testutil"
+	"github.com/storacha/go-ucanto/did"
+	"github.com/stretchr/testify/require"
+
+	"github.com/storacha/etracker/internal/db/accountstats"
+	"github.com/storacha/etracker/internal/db/consumer"
+	"github.com/storacha/etracker/internal/pricing"
+)
+
+type mockConsumerTable struct {
+	consumers map[did.DID]consumer.Consumer
+}
+
+func (m *mockConsumerTable) Get(ctx context.Context, consumerID string) (consumer.Consumer, error) {
+	for space, c := range m.consumers {
+		if space.String() == consumerID {
+			return c, nil
+		}
+	}
+	return consumer.Consumer{}, consumer.ErrNotFound
+}
+
+func (m *mockConsumerTable) ListByCustomer(ctx context.Context, customerID did.DID) ([]did.DID, error) {
+	var spaces []did.DID
+	for space, c := range m.consumers {
+		if c.Customer == customerID {
+			spaces = append(spaces, space)
+		}
+	}
+	return spaces, nil
+}
+
+type mockAccountStatsTable struct {
+	stats map[did.DID]uint64
+}
+
+func (m *mockAccountStatsTable) Record(ctx context.Context, account did.DID, plan string, egress uint64, at time.Time, id string) error {
+	m.stats[account] += egress
+	return nil
+}
+
+func (m *mockAccountStatsTable) Put(ctx context.Context, account did.DID, plans map[string]uint64, at time.Time) error {
+	return fmt.Errorf("not implemented")
+}
+
+func (m *mockAccountStatsTable) GetDailyStats(ctx context.Context, account did.DID, from time.Time, to time.Time) ([]accountstats.DailyStats, error) {
+	return []accountstats.DailyStats{{Date: from, Egress: m.stats[account]}}, nil
+}
+
+type mockSink struct {
+	events []Event
+	err    error
+}
+
+func (m *mockSink) Send(ctx context.Context, event Event) error {
+	if m.err != nil {
+		return m.err
+	}
+	m.events = append(m.events, event)
+	return nil
+}
+
+func TestQuotas(t *testing.T) {
+	at := time.Date(2025, 3, 14, 10, 0, 0, 0, time.UTC)
+
+	pro := testutil.RandomDID(t)
+	mixed := testutil.RandomDID(t)
+	free := testutil.RandomDID(t)
+	capped := testutil.RandomDID(t)
+
+	consumerTable := &mockConsumerTable{consumers: map[did.DID]consumer.Consumer{
+		testutil.RandomDID(t): {Customer: pro, Subscription: "pro"},
+		testutil.RandomDID(t): {Customer: mixed, Subscription: "pro"},
+		testutil.RandomDID(t): {Customer: mixed, Subscription: "enterprise"},
+		testutil.RandomDID(t): {Customer: free},
+		testutil.RandomDID(t): {Customer: capped, Subscription: "enterprise"},
+	}}
+	accountStatsTable := &mockAccountStatsTable{stats: map[did.DID]uint64{pro: 500}}
+
+	pricer, err := pricing.New(pricing.Config{Plans: map[string]pricing.Plan{
+		"pro":        pricing.FlatPlan("pro", 10),
+		"enterprise": pricing.FlatPlan("enterprise", 5),
+	}})
+	require.NoError(t, err)
+
+	cfg, err := ParseConfig([]byte(`{
+		"plans": {"pro": 1000, "enterprise": 5000},
+		"accounts": {"` + capped.String() + `": 200}
+	}`))
+	require.NoError(t, err)
+
+	quotas, err := New(cfg, pricer, consumerTable, accountStatsTable)
+	require.NoError(t, err)
+	require.Equal(t, DefaultThresholds, quotas.Thresholds())
+
+	limit := func(account did.DID) uint64 {
+		l, _, err := quotas.Limit(context.Background(), account)
+		require.NoError(t, err)
+		return l
+	}
+	require.Equal(t, uint64(1000), limit(pro))
+	// the largest limit of the plans of the account applies
+	require.Equal(t, uint64(5000), limit(mixed))
+	// the limit of the account takes precedence over the limits of its plans
+	require.Equal(t, uint64(200), limit(capped))
+
+	usage, err := quotas.Usage(context.Background(), free, at)
+	require.NoError(t, err)
+	require.Nil(t, usage)
+
+	usage, err = quotas.Usage(context.Background(), pro, at)
+	require.NoError(t, err)
+	require.Equal(t, time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC), usage.Month)
+	require.Equal(t, 50.0, usage.Percent())
+
+	t.Run("thresholds are positive percentages", func(t *testing.T) {
+		_, err := New(Config{Thresholds: []float64{0, 100}}, nil, consumerTable, accountStatsTable)
+		require.Error(t, err)
+	})
+}
+
+func TestMonitor(t *testing.T) {
+	at := time.Date(2025, 3, 14, 10, 0, 0, 0, time.UTC)
+	account := testutil.RandomDID(t)
+	other := testutil.RandomDID(t)
+
+	setup := func(t *testing.T) (*Monitor, *mockAccountStatsTable, *mockSink) {
+		accountStatsTable := &mockAccountStatsTable{stats: map[did.DID]uint64{}}
+		quotas, err := New(Config{Accounts: map[string]uint64{account.String(): 1000}}, nil, &mockConsumerTable{}, accountStatsTable)
+		require.NoError(t, err)
+		sink := &mockSink{}
+		return NewMonitor(quotas, sink), accountStatsTable, sink
+	}
+
+	record := func(m *Monitor, accountStatsTable *mockAccountStatsTable, account did.DID, egress uint64) {
+		require.NoError(t, accountStatsTable.Record(context.Background(), account, pricing.DefaultPlanName, egress, at, "batch"))
+		m.Observe(account, egress, at)
+	}
+
+	t.Run("alerts once per threshold crossed", func(t *testing.T) {
+		m, accountStatsTable, sink := setup(t)
+
+		record(m, accountStatsTable, account, 500)
+		record(m, accountStatsTable, other, 5000)
+		events, err := m.Run(context.Background())
+		require.NoError(t, err)
+		require.Empty(t, events)
+
+		record(m, accountStatsTable, account, 350)
+		events, err = m.Run(context.Background())
+		require.NoError(t, err)
+		require.Len(t, events, 1)
+		require.Equal(t, 80.0, events[0].Threshold)
+		require.Equal(t, uint64(850), events[0].Used)
+		require.Equal(t, "2025-03", events[0].Month)
+
+		// nothing observed, nothing crossed
+		events, err = m.Run(context.Background())
+		require.NoError(t, err)
+		require.Empty(t, events)
+
+		record(m, accountStatsTable, account, 100)
+		record(m, accountStatsTable, account, 100)
+		events, err = m.Run(context.Background())
+		require.NoError(t, err)
+		require.Len(t, events, 1)
+		require.Equal(t, 100.0, events[0].Threshold)
+
+		require.Len(t, sink.events, 2)
+	})
+
+	t.Run("a single cycle can cross several thresholds", func(t *testing.T) {
+		m, accountStatsTable, _ := setup(t)
+
+		record(m, accountStatsTable, account, 1500)
+		events, err := m.Run(context.Background())
+		require.NoError(t, err)
+		require.Len(t, events, 2)
+	})
+
+	t.Run("failed alerts are retried on the next run", func(t *testing.T) {
+		m, accountStatsTable, sink := setup(t)
+		sink.err = fmt.Errorf("webhook unavailable")
+
+		record(m, accountStatsTable, account, 900)
+		_, err := m.Run(context.Background())
+		require.Error(t, err)
+
+		sink.err = nil
+		events, err := m.Run(context.Background())
+		require.NoError(t, err)
+		require.Len(t, events, 1)
+		require.Equal(t, fmt.Sprintf("%s:2025-03:80", account), events[0].ID)
+	})
+}
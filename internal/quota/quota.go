@@ -0,0 +1,154 @@
+package quota
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/storacha/go-ucanto/did"
+
+	"github.com/storacha/etracker/internal/db/accountstats"
+	"github.com/storacha/etracker/internal/db/consumer"
+	"github.com/storacha/etracker/internal/pricing"
+)
+
+// DefaultThresholds are the percentages of the limit alerted on when none are configured
+var DefaultThresholds = []float64{80, 100}
+
+// Config sets the monthly egress limit of accounts, in bytes. The limit configured for an account takes
+// precedence over the limits of the pricing plans of its spaces, an account with spaces on several limited plans
+// gets the largest of their limits. Accounts without any limit fall back to Default, 0 meaning unlimited.
+type Config struct {
+	Default uint64 `json:"default"`
+	// Plans are keyed by the name of the pricing plan
+	Plans map[string]uint64 `json:"plans"`
+	// Accounts are keyed by account DID
+	Accounts map[string]uint64 `json:"accounts"`
+	// Thresholds are the percentages of the limit that raise an alert when the egress of the month crosses them
+	Thresholds []float64 `json:"thresholds"`
+}
+
+// ParseConfig decodes a JSON quota configuration
+func ParseConfig(data []byte) (Config, error) {
+	var cfg Config
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		return Config{}, fmt.Errorf("decoding quota config: %w", err)
+	}
+	return cfg, nil
+}
+
+// Usage is the egress of an account in a calendar month against its limit
+type Usage struct {
+	Account did.DID
+	// Month is the first day of the month
+	Month time.Time
+	Limit uint64
+	Used  uint64
+}
+
+// Percent is the share of the limit used, which exceeds 100 once the account is over quota
+func (u Usage) Percent() float64 {
+	return float64(u.Used) / float64(u.Limit) * 100
+}
+
+// Quotas resolves the limits of accounts and measures their usage from the daily account stats
+type Quotas struct {
+	defaultLimit      uint64
+	byPlan            map[string]uint64
+	byAccount         map[did.DID]uint64
+	thresholds        []float64
+	pricer            *pricing.Engine
+	consumerTable     consumer.ConsumerTable
+	accountStatsTable accountstats.AccountStatsTable
+}
+
+// New creates quotas from the given configuration. Plan limits are only enforced when a pricer is given, the plan
+// of each space being the plan its egress is priced with.
+func New(cfg Config, pricer *pricing.Engine, consumerTable consumer.ConsumerTable, accountStatsTable accountstats.AccountStatsTable) (*Quotas, error) {
+	q := &Quotas{
+		defaultLimit:      cfg.Default,
+		byPlan:            cfg.Plans,
+		byAccount:         make(map[did.DID]uint64, len(cfg.Accounts)),
+		thresholds:        slices.Clone(cfg.Thresholds),
+		pricer:            pricer,
+		consumerTable:     consumerTable,
+		accountStatsTable: accountStatsTable,
+	}
+
+	for account, limit := range cfg.Accounts {
+		a, err := did.Parse(account)
+		if err != nil {
+			return nil, fmt.Errorf("parsing account %s: %w", account, err)
+		}
+		q.byAccount[a] = limit
+	}
+
+	if len(q.thresholds) == 0 {
+		q.thresholds = slices.Clone(DefaultThresholds)
+	}
+	for _, t := range q.thresholds {
+		if t <= 0 {
+			return nil, fmt.Errorf("threshold %v is not a positive percentage", t)
+		}
+	}
+	slices.Sort(q.thresholds)
+
+	return q, nil
+}
+
+// Thresholds returns the percentages of the limit alerted on, in increasing order
+func (q *Quotas) Thresholds() []float64 {
+	return q.thresholds
+}
+
+// Limit returns the monthly egress limit of the account, or false if the account is unlimited
+func (q *Quotas) Limit(ctx context.Context, account did.DID) (uint64, bool, error) {
+	if limit, ok := q.byAccount[account]; ok {
+		return limit, limit > 0, nil
+	}
+
+	if len(q.byPlan) > 0 && q.pricer != nil {
+		spaces, err := q.consumerTable.ListByCustomer(ctx, account)
+		if err != nil {
+			return 0, false, fmt.Errorf("listing spaces of account %s: %w", account, err)
+		}
+
+		var limit uint64
+		for _, space := range spaces {
+			plan, err := q.pricer.PlanForSpace(ctx, q.consumerTable, space)
+			if err != nil {
+				return 0, false, err
+			}
+			limit = max(limit, q.byPlan[plan.Name])
+		}
+		if limit > 0 {
+			return limit, true, nil
+		}
+	}
+
+	return q.defaultLimit, q.defaultLimit > 0, nil
+}
+
+// Usage returns the egress of the account in the month of the given time, or nil if the account is unlimited
+func (q *Quotas) Usage(ctx context.Context, account did.DID, at time.Time) (*Usage, error) {
+	limit, ok, err := q.Limit(ctx, account)
+	if err != nil || !ok {
+		return nil, err
+	}
+
+	at = at.UTC()
+	month := time.Date(at.Year(), at.Month(), 1, 0, 0, 0, 0, time.UTC)
+	dailyStats, err := q.accountStatsTable.GetDailyStats(ctx, account, month, month.AddDate(0, 1, -1))
+	if err != nil {
+		return nil, fmt.Errorf("getting daily stats of account %s: %w", account, err)
+	}
+
+	usage := &Usage{Account: account, Month: month, Limit: limit}
+	for _, stat := range dailyStats {
+		usage.Used += stat.Egress
+	}
+
+	return usage, nil
+}
@@ -0,0 +1,198 @@
+package reconciler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"time"
+
+	logging "github.com/ipfs/go-log/v2"
+	"github.com/storacha/go-ucanto/did"
+
+	"github.com/storacha/etracker/internal/db/accountstats"
+	"github.com/storacha/etracker/internal/db/consumer"
+	"github.com/storacha/etracker/internal/db/customer"
+	"github.com/storacha/etracker/internal/db/spacestats"
+	"github.com/storacha/etracker/internal/pricing"
+)
+
+var log = logging.Logger("reconciler")
+
+const customersPageSize = 100
+
+// Reconciler repairs the daily account stats maintained during consolidation. Egress is added to the account
+// owning a space at consolidation time, so the stats drift when spaces move between accounts, when stats
+// fail to be recorded, and for egress consolidated before account stats were recorded. The reconciler
+// periodically recomputes the daily stats of every account from the daily stats of its current spaces.
+type Reconciler struct {
+	customerTable     customer.CustomerTable
+	consumerTable     consumer.ConsumerTable
+	spaceStatsTable   spacestats.SpaceStatsTable
+	accountStatsTable accountstats.AccountStatsTable
+	pricer            *pricing.Engine
+	interval          time.Duration
+	window            time.Duration
+	stopCh            chan struct{}
+}
+
+// New creates a reconciler that runs every interval and repairs the account stats of the days in the trailing
+// window, today included. The egress of spaces is attributed to the default plan if pricer is nil.
+func New(
+	customerTable customer.CustomerTable,
+	consumerTable consumer.ConsumerTable,
+	spaceStatsTable spacestats.SpaceStatsTable,
+	accountStatsTable accountstats.AccountStatsTable,
+	pricer *pricing.Engine,
+	interval time.Duration,
+	window time.Duration,
+) *Reconciler {
+	return &Reconciler{
+		customerTable:     customerTable,
+		consumerTable:     consumerTable,
+		spaceStatsTable:   spaceStatsTable,
+		accountStatsTable: accountStatsTable,
+		pricer:            pricer,
+		interval:          interval,
+		window:            window,
+		stopCh:            make(chan struct{}),
+	}
+}
+
+// Start reconciles the account stats right away, so they are backfilled on first deployment, and then on
+// every interval.
+func (r *Reconciler) Start(ctx context.Context) {
+	ticker := time.NewTicker(r.interval)
+	defer ticker.Stop()
+
+	log.Infof("Reconciler started with interval: %v", r.interval)
+
+	if err := r.Reconcile(ctx); err != nil {
+		log.Errorf("Reconciliation error: %v", err)
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			log.Info("Reconciler stopping due to context cancellation")
+			return
+		case <-r.stopCh:
+			log.Info("Reconciler stopping")
+			return
+		case <-ticker.C:
+			if err := r.Reconcile(ctx); err != nil {
+				log.Errorf("Reconciliation error: %v", err)
+			}
+		}
+	}
+}
+
+func (r *Reconciler) Stop() {
+	close(r.stopCh)
+}
+
+// Reconcile repairs the account stats of every account. Accounts failing to reconcile are skipped and
+// reported in the returned error.
+func (r *Reconciler) Reconcile(ctx context.Context) error {
+	log.Info("Starting account stats reconciliation")
+
+	to := time.Now().UTC()
+	from := to.Add(-r.window)
+
+	var errs []error
+	var cursor *string
+	for {
+		result, err := r.customerTable.List(ctx, customersPageSize, cursor)
+		if err != nil {
+			return errors.Join(append(errs, fmt.Errorf("listing customers: %w", err))...)
+		}
+
+		for _, account := range result.Customers {
+			if err := r.ReconcileAccount(ctx, account, from, to); err != nil {
+				errs = append(errs, fmt.Errorf("reconciling account %s: %w", account, err))
+			}
+		}
+
+		if result.Cursor == nil {
+			break
+		}
+		cursor = result.Cursor
+	}
+
+	log.Infof("Account stats reconciliation finished with %d failed accounts", len(errs))
+
+	return errors.Join(errs...)
+}
+
+// ReconcileAccount overwrites the daily stats of the account between from and to that differ from the sum of
+// the daily stats of the spaces it currently owns, grouped by the pricing plan of each space. Egress recorded
+// concurrently for the account may be lost, it is repaired on the next run.
+func (r *Reconciler) ReconcileAccount(ctx context.Context, account did.DID, from, to time.Time) error {
+	spaces, err := r.consumerTable.ListByCustomer(ctx, account)
+	if err != nil {
+		return fmt.Errorf("listing spaces: %w", err)
+	}
+
+	expected := make(map[time.Time]map[string]uint64)
+	for _, space := range spaces {
+		plan := pricing.DefaultPlanName
+		if r.pricer != nil {
+			p, err := r.pricer.PlanForSpace(ctx, r.consumerTable, space)
+			if err != nil {
+				return err
+			}
+			plan = p.Name
+		}
+
+		dailyStats, err := r.spaceStatsTable.GetDailyStats(ctx, space, from, to)
+		if err != nil {
+			return fmt.Errorf("getting daily stats of space %s: %w", space, err)
+		}
+		for _, stat := range dailyStats {
+			if stat.Egress == 0 {
+				continue
+			}
+			if expected[stat.Date] == nil {
+				expected[stat.Date] = make(map[string]uint64)
+			}
+			expected[stat.Date][plan] += stat.Egress
+		}
+	}
+
+	recorded, err := r.accountStatsTable.GetDailyStats(ctx, account, from, to)
+	if err != nil {
+		return fmt.Errorf("getting account stats: %w", err)
+	}
+
+	actual := make(map[time.Time]accountstats.DailyStats, len(recorded))
+	for _, stat := range recorded {
+		actual[stat.Date] = stat
+		// days the account no longer has egress on are zeroed
+		if _, ok := expected[stat.Date]; !ok {
+			expected[stat.Date] = map[string]uint64{}
+		}
+	}
+
+	for date, plans := range expected {
+		var egress uint64
+		for _, planEgress := range plans {
+			egress += planEgress
+		}
+
+		current, ok := actual[date]
+		// egress recorded before it was attributed to plans is missing from the recorded plans
+		if ok && current.Egress == egress && maps.Equal(current.Plans, plans) {
+			continue
+		}
+		if !ok && egress == 0 {
+			continue
+		}
+
+		log.Infow("Repairing account stats", "account", account, "date", date.Format("2006-01-02"), "recorded", current.Egress, "expected", egress)
+		if err := r.accountStatsTable.Put(ctx, account, plans, date); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
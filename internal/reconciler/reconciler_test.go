@@ -0,0 +1,163 @@
+package reconciler
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/storacha/go-libstoracha/testutil"
+	"github.com/storacha/go-ucanto/did"
+	"github.com/stretchr/testify/require"
+
+	"github.com/storacha/etracker/internal/db/accountstats"
+	"github.com/storacha/etracker/internal/db/consumer"
+	"github.com/storacha/etracker/internal/db/customer"
+	"github.com/storacha/etracker/internal/db/spacestats"
+	"github.com/storacha/etracker/internal/pricing"
+)
+
+type mockCustomerTable struct {
+	customers []did.DID
+}
+
+func (m *mockCustomerTable) List(ctx context.Context, limit int, cursor *string) (*customer.ListResult, error) {
+	return &customer.ListResult{Customers: m.customers}, nil
+}
+
+func (m *mockCustomerTable) Has(ctx context.Context, customerDID did.DID) (bool, error) {
+	return false, fmt.Errorf("not implemented")
+}
+
+type mockConsumerTable struct {
+	spaces        map[did.DID][]did.DID
+	subscriptions map[string]string
+}
+
+func (m *mockConsumerTable) Get(ctx context.Context, consumerID string) (consumer.Consumer, error) {
+	subscription, ok := m.subscriptions[consumerID]
+	if !ok {
+		return consumer.Consumer{}, consumer.ErrNotFound
+	}
+	return consumer.Consumer{Subscription: subscription}, nil
+}
+
+func (m *mockConsumerTable) ListByCustomer(ctx context.Context, customerID did.DID) ([]did.DID, error) {
+	return m.spaces[customerID], nil
+}
+
+type mockSpaceStatsTable struct {
+	stats map[did.DID][]spacestats.DailyStats
+}
+
+func (m *mockSpaceStatsTable) Record(ctx context.Context, space did.DID, egress uint64, at time.Time, id string) error {
+	return fmt.Errorf("not implemented")
+}
+
+func (m *mockSpaceStatsTable) GetDailyStats(ctx context.Context, space did.DID, from time.Time, to time.Time) ([]spacestats.DailyStats, error) {
+	return m.stats[space], nil
+}
+
+func (m *mockSpaceStatsTable) GetMonthlyStats(ctx context.Context, space did.DID, from time.Time, to time.Time) ([]spacestats.MonthlyStats, error) {
+	return nil, fmt.Errorf("not implemented")
+}
+
+type mockAccountStatsTable struct {
+	stats map[did.DID]map[time.Time]accountstats.DailyStats
+	puts  int
+}
+
+func (m *mockAccountStatsTable) Record(ctx context.Context, account did.DID, plan string, egress uint64, at time.Time, id string) error {
+	return fmt.Errorf("not implemented")
+}
+
+func (m *mockAccountStatsTable) Put(ctx context.Context, account did.DID, plans map[string]uint64, at time.Time) error {
+	if m.stats[account] == nil {
+		m.stats[account] = make(map[time.Time]accountstats.DailyStats)
+	}
+	stat := accountstats.DailyStats{Date: at, Plans: plans}
+	for _, egress := range plans {
+		stat.Egress += egress
+	}
+	m.stats[account][at] = stat
+	m.puts++
+	return nil
+}
+
+func (m *mockAccountStatsTable) GetDailyStats(ctx context.Context, account did.DID, from time.Time, to time.Time) ([]accountstats.DailyStats, error) {
+	var stats []accountstats.DailyStats
+	for _, stat := range m.stats[account] {
+		stats = append(stats, stat)
+	}
+	return stats, nil
+}
+
+// plans returns the recorded egress of each plan of the account per day
+func (m *mockAccountStatsTable) plans(account did.DID) map[time.Time]map[string]uint64 {
+	plans := make(map[time.Time]map[string]uint64)
+	for date, stat := range m.stats[account] {
+		plans[date] = stat.Plans
+	}
+	return plans
+}
+
+func TestReconcile(t *testing.T) {
+	day1 := time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC)
+	day2 := day1.AddDate(0, 0, 1)
+
+	account1 := testutil.RandomDID(t)
+	account2 := testutil.RandomDID(t)
+	space1 := testutil.RandomDID(t)
+	space2 := testutil.RandomDID(t)
+
+	// space2 moved from account1 to account2 after its egress was recorded, before egress was attributed to plans
+	accountStatsTable := &mockAccountStatsTable{stats: map[did.DID]map[time.Time]accountstats.DailyStats{
+		account1: {
+			day1: {Date: day1, Egress: 300},
+			day2: {Date: day2, Egress: 50},
+		},
+	}}
+
+	pricer, err := pricing.New(pricing.Config{
+		Default: pricing.FlatPlan("", 20),
+		Plans:   map[string]pricing.Plan{"pro": pricing.FlatPlan("", 10)},
+	})
+	require.NoError(t, err)
+
+	r := New(
+		&mockCustomerTable{customers: []did.DID{account1, account2}},
+		&mockConsumerTable{
+			spaces: map[did.DID][]did.DID{
+				account1: {space1},
+				account2: {space2},
+			},
+			subscriptions: map[string]string{space2.String(): "pro"},
+		},
+		&mockSpaceStatsTable{stats: map[did.DID][]spacestats.DailyStats{
+			space1: {{Date: day1, Egress: 100}},
+			space2: {{Date: day1, Egress: 200}, {Date: day2, Egress: 50}},
+		}},
+		accountStatsTable,
+		pricer,
+		time.Hour,
+		24*time.Hour,
+	)
+
+	require.NoError(t, r.Reconcile(context.Background()))
+
+	require.Equal(t, map[time.Time]map[string]uint64{
+		day1: {pricing.DefaultPlanName: 100},
+		day2: {},
+	}, accountStatsTable.plans(account1))
+	require.Zero(t, accountStatsTable.stats[account1][day2].Egress)
+	require.Equal(t, map[time.Time]map[string]uint64{
+		day1: {"pro": 200},
+		day2: {"pro": 50},
+	}, accountStatsTable.plans(account2))
+
+	t.Run("leaves accurate stats untouched", func(t *testing.T) {
+		accountStatsTable.puts = 0
+		require.NoError(t, r.Reconcile(context.Background()))
+		require.Zero(t, accountStatsTable.puts)
+	})
+}
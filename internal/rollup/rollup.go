@@ -0,0 +1,111 @@
+// Package rollup records the egress of consolidated batches in the stats rolling it up by account and by network,
+// both when batches are counted on consolidation and when held batches are approved.
+package rollup
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/storacha/go-ucanto/did"
+	"github.com/storacha/go-ucanto/ucan"
+
+	"github.com/storacha/etracker/internal/db/accountstats"
+	"github.com/storacha/etracker/internal/db/consumer"
+	"github.com/storacha/etracker/internal/db/networkstats"
+	"github.com/storacha/etracker/internal/pricing"
+	"github.com/storacha/etracker/internal/quota"
+)
+
+// Recorder adds the egress of spaces to the daily stats of the accounts owning them and of the networks they were
+// provisioned through. The stats of a nil table are not recorded.
+type Recorder struct {
+	consumerTable     consumer.ConsumerTable
+	accountStatsTable accountstats.AccountStatsTable
+	networkStatsTable networkstats.NetworkStatsTable
+	pricer            *pricing.Engine
+	quotaMonitor      *quota.Monitor
+}
+
+// New creates a recorder. Without a pricer all account egress is attributed to the default plan, and without a
+// quota monitor the egress recorded for accounts is not checked against their quota.
+func New(
+	consumerTable consumer.ConsumerTable,
+	accountStatsTable accountstats.AccountStatsTable,
+	networkStatsTable networkstats.NetworkStatsTable,
+	pricer *pricing.Engine,
+	quotaMonitor *quota.Monitor,
+) *Recorder {
+	return &Recorder{
+		consumerTable:     consumerTable,
+		accountStatsTable: accountStatsTable,
+		networkStatsTable: networkStatsTable,
+		pricer:            pricer,
+		quotaMonitor:      quotaMonitor,
+	}
+}
+
+// Record adds the egress of each space to the stats of the account currently owning it and of its network, at
+// most once per batch, and feeds the egress newly recorded for accounts to the quota monitor. Spaces moving
+// between accounts make the account stats drift, which is repaired by the account stats reconciler.
+func (r *Recorder) Record(ctx context.Context, cause ucan.Link, spaceEgress map[did.DID]uint64, at time.Time) error {
+	if r.accountStatsTable == nil && r.networkStatsTable == nil {
+		return nil
+	}
+
+	var errs []error
+	accountEgress := make(map[did.DID]map[string]uint64)
+	networkEgress := make(map[did.DID]uint64)
+	for space, egress := range spaceEgress {
+		consumer, err := r.consumerTable.Get(ctx, space.String())
+		if err != nil {
+			errs = append(errs, fmt.Errorf("getting consumer %s: %w", space, err))
+			continue
+		}
+		if consumer.Customer != did.Undef {
+			plan := pricing.DefaultPlanName
+			if r.pricer != nil {
+				plan = r.pricer.PlanFor(consumer).Name
+			}
+			if accountEgress[consumer.Customer] == nil {
+				accountEgress[consumer.Customer] = make(map[string]uint64)
+			}
+			accountEgress[consumer.Customer][plan] += egress
+		}
+		if consumer.Provider != did.Undef {
+			networkEgress[consumer.Provider] += egress
+		}
+	}
+
+	if r.networkStatsTable != nil {
+		for network, egress := range networkEgress {
+			err := r.networkStatsTable.Record(ctx, network, egress, at, cause.String())
+			if err != nil && !errors.Is(err, networkstats.ErrAlreadyRecorded) {
+				errs = append(errs, err)
+			}
+		}
+	}
+
+	if r.accountStatsTable == nil {
+		return errors.Join(errs...)
+	}
+
+	for account, plans := range accountEgress {
+		for plan, egress := range plans {
+			err := r.accountStatsTable.Record(ctx, account, plan, egress, at, cause.String())
+			if errors.Is(err, accountstats.ErrAlreadyRecorded) {
+				continue
+			}
+			if err != nil {
+				errs = append(errs, err)
+				continue
+			}
+			if r.quotaMonitor != nil {
+				r.quotaMonitor.Observe(account, egress, at)
+			}
+		}
+	}
+
+	return errors.Join(errs...)
+}
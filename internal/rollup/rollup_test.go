@@ -0,0 +1,134 @@
+package rollup
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/storacha/go-libstoracha/testutil"
+	"github.com/storacha/go-ucanto/did"
+	"github.com/stretchr/testify/require"
+
+	"github.com/storacha/etracker/internal/db/accountstats"
+	"github.com/storacha/etracker/internal/db/consumer"
+	"github.com/storacha/etracker/internal/db/networkstats"
+	"github.com/storacha/etracker/internal/quota"
+)
+
+type mockConsumerTable struct {
+	consumers map[did.DID]consumer.Consumer
+}
+
+func (m *mockConsumerTable) Get(ctx context.Context, consumerID string) (consumer.Consumer, error) {
+	for space, c := range m.consumers {
+		if space.String() == consumerID {
+			return c, nil
+		}
+	}
+	return consumer.Consumer{}, consumer.ErrNotFound
+}
+
+func (m *mockConsumerTable) ListByCustomer(ctx context.Context, customerID did.DID) ([]did.DID, error) {
+	return nil, fmt.Errorf("not implemented")
+}
+
+type mockAccountStatsTable struct {
+	stats    map[did.DID]uint64
+	recorded map[string]bool
+}
+
+func (m *mockAccountStatsTable) Record(ctx context.Context, account did.DID, plan string, egress uint64, at time.Time, id string) error {
+	key := account.String() + plan + id
+	if m.recorded[key] {
+		return accountstats.ErrAlreadyRecorded
+	}
+	m.recorded[key] = true
+	m.stats[account] += egress
+	return nil
+}
+
+func (m *mockAccountStatsTable) Put(ctx context.Context, account did.DID, plans map[string]uint64, at time.Time) error {
+	return fmt.Errorf("not implemented")
+}
+
+func (m *mockAccountStatsTable) GetDailyStats(ctx context.Context, account did.DID, from time.Time, to time.Time) ([]accountstats.DailyStats, error) {
+	return []accountstats.DailyStats{{Date: from, Egress: m.stats[account]}}, nil
+}
+
+type mockNetworkStatsTable struct {
+	stats    map[did.DID]uint64
+	recorded map[string]bool
+}
+
+func (m *mockNetworkStatsTable) Record(ctx context.Context, network did.DID, egress uint64, at time.Time, id string) error {
+	key := network.String() + id
+	if m.recorded[key] {
+		return networkstats.ErrAlreadyRecorded
+	}
+	m.recorded[key] = true
+	m.stats[network] += egress
+	return nil
+}
+
+func (m *mockNetworkStatsTable) GetDailyStats(ctx context.Context, network did.DID, from time.Time, to time.Time) ([]networkstats.DailyStats, error) {
+	return nil, fmt.Errorf("not implemented")
+}
+
+type mockSink struct {
+	events []quota.Event
+}
+
+func (m *mockSink) Send(ctx context.Context, event quota.Event) error {
+	m.events = append(m.events, event)
+	return nil
+}
+
+func TestRecord(t *testing.T) {
+	at := time.Date(2025, 3, 14, 10, 0, 0, 0, time.UTC)
+	account := testutil.RandomDID(t)
+	network := testutil.RandomDID(t)
+	space := testutil.RandomDID(t)
+	orphan := testutil.RandomDID(t)
+
+	consumerTable := &mockConsumerTable{consumers: map[did.DID]consumer.Consumer{
+		space: {ID: space, Provider: network, Customer: account},
+	}}
+	accountStatsTable := &mockAccountStatsTable{stats: map[did.DID]uint64{}, recorded: map[string]bool{}}
+	networkStatsTable := &mockNetworkStatsTable{stats: map[did.DID]uint64{}, recorded: map[string]bool{}}
+
+	quotas, err := quota.New(quota.Config{Accounts: map[string]uint64{account.String(): 1000}}, nil, consumerTable, accountStatsTable)
+	require.NoError(t, err)
+	sink := &mockSink{}
+	monitor := quota.NewMonitor(quotas, sink)
+
+	recorder := New(consumerTable, accountStatsTable, networkStatsTable, nil, monitor)
+	cause := testutil.RandomCID(t)
+
+	// the space whose consumer is unknown fails the batch, the egress of the others is recorded
+	err = recorder.Record(context.Background(), cause, map[did.DID]uint64{space: 900, orphan: 50}, at)
+	require.ErrorIs(t, err, consumer.ErrNotFound)
+	require.Equal(t, uint64(900), accountStatsTable.stats[account])
+	require.Equal(t, uint64(900), networkStatsTable.stats[network])
+
+	t.Run("egress recorded for accounts is observed by the quota monitor", func(t *testing.T) {
+		events, err := monitor.Run(context.Background())
+		require.NoError(t, err)
+		require.Len(t, events, 1)
+		require.Equal(t, uint64(900), events[0].Used)
+	})
+
+	t.Run("egress is recorded and observed once per batch", func(t *testing.T) {
+		require.NoError(t, recorder.Record(context.Background(), cause, map[did.DID]uint64{space: 900}, at))
+		require.Equal(t, uint64(900), accountStatsTable.stats[account])
+		require.Equal(t, uint64(900), networkStatsTable.stats[network])
+
+		events, err := monitor.Run(context.Background())
+		require.NoError(t, err)
+		require.Empty(t, events)
+	})
+
+	t.Run("nothing is recorded without account and network stats", func(t *testing.T) {
+		require.NoError(t, New(consumerTable, nil, nil, nil, monitor).Record(context.Background(), testutil.RandomCID(t), map[did.DID]uint64{orphan: 50}, at))
+	})
+}
@@ -17,6 +17,8 @@ import (
 
 	"github.com/storacha/etracker/internal/build"
 	"github.com/storacha/etracker/internal/consolidator"
+	"github.com/storacha/etracker/internal/db/attestations"
+	"github.com/storacha/etracker/internal/service"
 )
 
 func (s *Server) getRootHandler() http.HandlerFunc {
@@ -59,19 +61,37 @@ func (s *Server) getReceiptsHandler() http.HandlerFunc {
 		cidStr := r.PathValue("cid")
 		cid, err := cid.Parse(cidStr)
 		if err != nil {
-			http.Error(w, "invalid invocation CID", http.StatusBadRequest)
+			http.Error(w, "invalid CID", http.StatusBadRequest)
 			return
 		}
 
 		cause := cidlink.Link{Cid: cid}
 
 		rcpt, err := s.cons.GetReceipt(r.Context(), cause)
+
+		// Not a consolidation, it may be the attestation of a payout
+		if errors.Is(err, consolidator.ErrNotFound) {
+			var attestation *attestations.Attestation
+			attestation, err = s.svc.GetAttestation(r.Context(), cause)
+			if err == nil {
+				rcpt = attestation.Receipt
+			} else if errors.Is(err, service.ErrAttestationNotFound) {
+				err = consolidator.ErrNotFound
+			}
+		}
+
 		if err != nil {
 			if errors.Is(err, consolidator.ErrNotFound) {
 				w.WriteHeader(http.StatusNotFound)
 				return
 			}
 
+			// The batch was consolidated but its receipt is not issued until it is reviewed
+			if errors.Is(err, consolidator.ErrPendingReview) {
+				w.WriteHeader(http.StatusAccepted)
+				return
+			}
+
 			log.Errorf("getting receipt: %v", err)
 			w.WriteHeader(http.StatusInternalServerError)
 			return
@@ -3,6 +3,7 @@ package server
 import (
 	"context"
 	"errors"
+	"fmt"
 
 	accountegress "github.com/storacha/go-libstoracha/capabilities/account/egress"
 	"github.com/storacha/go-libstoracha/capabilities/space/egress"
@@ -14,19 +15,68 @@ import (
 	userver "github.com/storacha/go-ucanto/server"
 	"github.com/storacha/go-ucanto/ucan"
 
+	accountquota "github.com/storacha/etracker/internal/capabilities/account/quota"
+	"github.com/storacha/etracker/internal/capabilities/batch"
+	spaceegress "github.com/storacha/etracker/internal/capabilities/space/egress"
+	"github.com/storacha/etracker/internal/capabilities/stats"
+	"github.com/storacha/etracker/internal/consolidator"
 	"github.com/storacha/etracker/internal/service"
 )
 
-func serviceMethods(svc service.Service) []userver.Option {
+const (
+	// UnauthorizedErrorName is the error name of `space/egress/consolidate` invocations on a resource other than the service
+	UnauthorizedErrorName = "Unauthorized"
+	// CauseNotFoundErrorName is the error name of `space/egress/consolidate` invocations for causes the service did not track
+	CauseNotFoundErrorName = "CauseNotFound"
+	// PendingReviewErrorName is the error name of `space/egress/consolidate` invocations for batches held for review
+	PendingReviewErrorName = "PendingReview"
+	// PartialResultErrorName is the error name of `account/egress/get` invocations for which the egress of some
+	// spaces could not be fetched, so that clients never receive a silently wrong total
+	PartialResultErrorName = "PartialResult"
+)
+
+const (
+	// defaultBatchListLimit is the page size of `egress/batch/list` invocations without a limit
+	defaultBatchListLimit = 100
+	// maxBatchListLimit is the largest page size accepted by `egress/batch/list`
+	maxBatchListLimit = 1000
+)
+
+// causeConsolidator consolidates the batch tracked by a given `space/egress/track` invocation
+type causeConsolidator interface {
+	ConsolidateCause(ctx context.Context, cause ucan.Link) (egress.ConsolidateReceipt, error)
+}
+
+func serviceMethods(svc service.Service, cons causeConsolidator) []userver.Option {
 	return []userver.Option{
 		userver.WithServiceMethod(
 			egress.TrackAbility,
 			userver.Provide(egress.Track, ucanTrackHandler(svc)),
 		),
+		userver.WithServiceMethod(
+			egress.ConsolidateAbility,
+			userver.Provide(egress.Consolidate, ucanConsolidateHandler(cons)),
+		),
 		userver.WithServiceMethod(
 			accountegress.GetAbility,
 			userver.Provide(accountegress.Get, ucanAccountEgressGetHandler(svc)),
 		),
+		userver.WithServiceMethod(
+			accountquota.GetAbility,
+			userver.Provide(accountquota.Get, ucanAccountQuotaGetHandler(svc)),
+		),
+		userver.WithServiceMethod(
+			spaceegress.GetAbility,
+			userver.Provide(spaceegress.Get, ucanSpaceEgressGetHandler(svc)),
+		),
+		userver.WithServiceMethod(
+			batch.ListAbility,
+			userver.Provide(batch.List, ucanBatchListHandler(svc)),
+		),
+		userver.WithServiceMethod(
+			stats.GetAbility,
+			userver.Provide(stats.Get, ucanStatsGetHandler(svc)),
+		),
 	}
 }
 
@@ -71,6 +121,51 @@ func ucanTrackHandler(svc service.Service) func(
 	}
 }
 
+// ucanConsolidateHandler consolidates batches on behalf of principals the service delegated `space/egress/consolidate` to.
+// Submitting the same cause more than once is safe: batches are consolidated once and the same result is returned every time.
+func ucanConsolidateHandler(cons causeConsolidator) func(
+	ctx context.Context,
+	cap ucan.Capability[egress.ConsolidateCaveats],
+	inv invocation.Invocation,
+	ictx userver.InvocationContext,
+) (result.Result[egress.ConsolidateOk, egress.ConsolidateError], fx.Effects, error) {
+	return func(
+		ctx context.Context,
+		cap ucan.Capability[egress.ConsolidateCaveats],
+		inv invocation.Invocation,
+		ictx userver.InvocationContext,
+	) (result.Result[egress.ConsolidateOk, egress.ConsolidateError], fx.Effects, error) {
+		// consolidation is only ever performed on the service's own resource
+		if cap.With() != ictx.ID().DID().String() {
+			return result.Error[egress.ConsolidateOk](egress.ConsolidateError{
+				ErrorName: UnauthorizedErrorName,
+				Message:   fmt.Sprintf("consolidation can only be invoked on %s", ictx.ID().DID()),
+			}), nil, nil
+		}
+
+		cause := cap.Nb().Cause
+		rcpt, err := cons.ConsolidateCause(ctx, cause)
+		if err != nil {
+			switch {
+			case errors.Is(err, consolidator.ErrNotFound):
+				return result.Error[egress.ConsolidateOk](egress.ConsolidateError{
+					ErrorName: CauseNotFoundErrorName,
+					Message:   fmt.Sprintf("no batch was tracked by %s", cause),
+				}), nil, nil
+			case errors.Is(err, consolidator.ErrPendingReview):
+				return result.Error[egress.ConsolidateOk](egress.ConsolidateError{
+					ErrorName: PendingReviewErrorName,
+					Message:   fmt.Sprintf("batch tracked by %s is held for review", cause),
+				}), nil, nil
+			default:
+				return nil, nil, err
+			}
+		}
+
+		return rcpt.Out(), nil, nil
+	}
+}
+
 func ucanAccountEgressGetHandler(svc service.Service) func(
 	ctx context.Context,
 	cap ucan.Capability[accountegress.GetCaveats],
@@ -101,7 +196,8 @@ func ucanAccountEgressGetHandler(svc service.Service) func(
 		}
 
 		// 3. Call service layer
-		egressData, err := svc.GetAccountEgress(ctx, accountDID, spacesFilter, periodFilter)
+		// account/egress/get returns daily stats and cannot tell the granularity of its buckets, so it is always daily
+		egressData, err := svc.GetAccountEgress(ctx, accountDID, spacesFilter, periodFilter, service.GranularityDay)
 		if err != nil {
 			var accErr service.ErrAccountNotFound
 			if errors.As(err, &accErr) {
@@ -128,6 +224,15 @@ func ucanAccountEgressGetHandler(svc service.Service) func(
 			return nil, nil, err
 		}
 
+		if len(egressData.FailedSpaces) > 0 {
+			return result.Error[accountegress.GetOk, accountegress.GetError](
+				accountegress.GetError{
+					ErrorName: PartialResultErrorName,
+					Message:   service.NewPartialResultError(egressData.FailedSpaces).Error(),
+				},
+			), nil, nil
+		}
+
 		// 4. Transform service result to GetOk format
 		spacesModel := accountegress.SpacesModel{
 			Keys:   make([]string, 0, len(egressData.Spaces)),
@@ -159,3 +264,245 @@ func ucanAccountEgressGetHandler(svc service.Service) func(
 		return result.Ok[accountegress.GetOk, accountegress.GetError](ok), nil, nil
 	}
 }
+
+// ucanAccountQuotaGetHandler returns the usage of the monthly egress quota of the account the capability is
+// invoked on. It is kept apart from `account/egress/get`, whose result is defined upstream.
+func ucanAccountQuotaGetHandler(svc service.Service) func(
+	ctx context.Context,
+	cap ucan.Capability[accountquota.GetCaveats],
+	inv invocation.Invocation,
+	ictx userver.InvocationContext,
+) (result.Result[accountquota.GetOk, accountquota.GetError], fx.Effects, error) {
+	return func(
+		ctx context.Context,
+		cap ucan.Capability[accountquota.GetCaveats],
+		inv invocation.Invocation,
+		ictx userver.InvocationContext,
+	) (result.Result[accountquota.GetOk, accountquota.GetError], fx.Effects, error) {
+		accountDID, err := did.Parse(cap.With())
+		if err != nil {
+			return nil, nil, err
+		}
+
+		usage, err := svc.GetAccountQuota(ctx, accountDID)
+		if err != nil {
+			var accErr service.ErrAccountNotFound
+			if errors.As(err, &accErr) {
+				return result.Error[accountquota.GetOk](accountquota.NewAccountNotFoundError(accErr.Error())), nil, nil
+			}
+
+			return nil, nil, err
+		}
+
+		if usage == nil {
+			return result.Error[accountquota.GetOk](accountquota.NewNoQuotaError(fmt.Sprintf("account %s has no monthly egress limit", accountDID))), nil, nil
+		}
+
+		return result.Ok[accountquota.GetOk, accountquota.GetError](accountquota.GetOk{
+			Month: usage.Month,
+			Limit: usage.Limit,
+			Used:  usage.Used,
+		}), nil, nil
+	}
+}
+
+// ucanSpaceEgressGetHandler returns the egress of the space the capability is invoked on.
+// Authority over the space is enough, the space is not resolved through its account.
+func ucanSpaceEgressGetHandler(svc service.Service) func(
+	ctx context.Context,
+	cap ucan.Capability[spaceegress.GetCaveats],
+	inv invocation.Invocation,
+	ictx userver.InvocationContext,
+) (result.Result[spaceegress.GetOk, spaceegress.GetError], fx.Effects, error) {
+	return func(
+		ctx context.Context,
+		cap ucan.Capability[spaceegress.GetCaveats],
+		inv invocation.Invocation,
+		ictx userver.InvocationContext,
+	) (result.Result[spaceegress.GetOk, spaceegress.GetError], fx.Effects, error) {
+		space, err := did.Parse(cap.With())
+		if err != nil {
+			return nil, nil, err
+		}
+
+		var periodFilter *service.Period
+		if cap.Nb().Period != nil {
+			periodFilter = &service.Period{
+				From: cap.Nb().Period.From,
+				To:   cap.Nb().Period.To,
+			}
+		}
+
+		granularity := service.GranularityAuto
+		if cap.Nb().Granularity != nil {
+			granularity, err = service.ParseGranularity(*cap.Nb().Granularity)
+			if err != nil {
+				return result.Error[spaceegress.GetOk](spaceegress.NewGranularityNotAcceptableError(err.Error())), nil, nil
+			}
+		}
+
+		spaceData, err := svc.GetSpaceEgress(ctx, space, periodFilter, granularity)
+		if err != nil {
+			var periodErr service.ErrPeriodNotAcceptable
+			if errors.As(err, &periodErr) {
+				return result.Error[spaceegress.GetOk](spaceegress.NewPeriodNotAcceptableError(periodErr.Error())), nil, nil
+			}
+
+			return nil, nil, err
+		}
+
+		dailyStats := make([]spaceegress.DailyStats, 0, len(spaceData.DailyStats))
+		for _, ds := range spaceData.DailyStats {
+			dailyStats = append(dailyStats, spaceegress.DailyStats{
+				Date:   ds.Date,
+				Egress: ds.Egress,
+			})
+		}
+
+		return result.Ok[spaceegress.GetOk, spaceegress.GetError](spaceegress.GetOk{
+			Total:       spaceData.Total,
+			Granularity: string(spaceData.Granularity),
+			DailyStats:  dailyStats,
+		}), nil, nil
+	}
+}
+
+// ucanBatchListHandler lists the batches tracked by the node the capability is invoked on.
+// Only the node, or principals it delegated `egress/batch/list` to, can list its batches.
+func ucanBatchListHandler(svc service.Service) func(
+	ctx context.Context,
+	cap ucan.Capability[batch.ListCaveats],
+	inv invocation.Invocation,
+	ictx userver.InvocationContext,
+) (result.Result[batch.ListOk, batch.ListError], fx.Effects, error) {
+	return func(
+		ctx context.Context,
+		cap ucan.Capability[batch.ListCaveats],
+		inv invocation.Invocation,
+		ictx userver.InvocationContext,
+	) (result.Result[batch.ListOk, batch.ListError], fx.Effects, error) {
+		node, err := did.Parse(cap.With())
+		if err != nil {
+			return nil, nil, err
+		}
+
+		caveats := cap.Nb()
+		var periodFilter *service.Period
+		if caveats.Period != nil {
+			periodFilter = &service.Period{
+				From: caveats.Period.From,
+				To:   caveats.Period.To,
+			}
+		}
+
+		limit := defaultBatchListLimit
+		if caveats.Limit != nil {
+			if *caveats.Limit <= 0 || *caveats.Limit > maxBatchListLimit {
+				return result.Error[batch.ListOk](
+					batch.NewListError(fmt.Sprintf("limit must be between 1 and %d", maxBatchListLimit)),
+				), nil, nil
+			}
+			limit = int(*caveats.Limit)
+		}
+
+		res, err := svc.ListBatches(ctx, node, periodFilter, limit, caveats.Cursor)
+		if err != nil {
+			var periodErr service.ErrPeriodNotAcceptable
+			if errors.As(err, &periodErr) {
+				return result.Error[batch.ListOk](batch.NewPeriodNotAcceptableError(periodErr.Error())), nil, nil
+			}
+
+			return nil, nil, err
+		}
+
+		batches := make([]batch.Batch, 0, len(res.Batches))
+		for _, b := range res.Batches {
+			batches = append(batches, batch.Batch{
+				Batch:       b.Batch,
+				Cause:       b.Cause,
+				Status:      string(b.Status),
+				ReceivedAt:  b.ReceivedAt,
+				ProcessedAt: b.ProcessedAt,
+				TotalEgress: b.TotalEgress,
+				Rejected:    b.Rejected,
+			})
+		}
+
+		return result.Ok[batch.ListOk, batch.ListError](batch.ListOk{
+			Batches: batches,
+			Cursor:  res.NextToken,
+		}), nil, nil
+	}
+}
+
+// ucanStatsGetHandler returns the egress stats of the node the capability is invoked on.
+// Only the node, or principals it delegated `egress/stats/get` to, can read its stats.
+func ucanStatsGetHandler(svc service.Service) func(
+	ctx context.Context,
+	cap ucan.Capability[stats.GetCaveats],
+	inv invocation.Invocation,
+	ictx userver.InvocationContext,
+) (result.Result[stats.GetOk, stats.GetError], fx.Effects, error) {
+	return func(
+		ctx context.Context,
+		cap ucan.Capability[stats.GetCaveats],
+		inv invocation.Invocation,
+		ictx userver.InvocationContext,
+	) (result.Result[stats.GetOk, stats.GetError], fx.Effects, error) {
+		node, err := did.Parse(cap.With())
+		if err != nil {
+			return nil, nil, err
+		}
+
+		var periodFilter *service.Period
+		if cap.Nb().Period != nil {
+			periodFilter = &service.Period{
+				From: cap.Nb().Period.From,
+				To:   cap.Nb().Period.To,
+			}
+		}
+
+		nodeStats, err := svc.GetNodeStats(ctx, node, periodFilter)
+		if err != nil {
+			var periodErr service.ErrPeriodNotAcceptable
+			if errors.As(err, &periodErr) {
+				return result.Error[stats.GetOk](stats.NewPeriodNotAcceptableError(periodErr.Error())), nil, nil
+			}
+
+			return nil, nil, err
+		}
+
+		dailyStats := make([]stats.DailyStats, 0, len(nodeStats.DailyStats))
+		for _, ds := range nodeStats.DailyStats {
+			dailyStats = append(dailyStats, stats.DailyStats{
+				Date:   ds.Date,
+				Egress: ds.Egress,
+			})
+		}
+
+		ok := stats.GetOk{
+			PreviousMonth: toPeriodStats(nodeStats.Stats.PreviousMonth),
+			CurrentMonth:  toPeriodStats(nodeStats.Stats.CurrentMonth),
+			CurrentWeek:   toPeriodStats(nodeStats.Stats.CurrentWeek),
+			CurrentDay:    toPeriodStats(nodeStats.Stats.CurrentDay),
+			Period: stats.Period{
+				From: nodeStats.Period.From,
+				To:   nodeStats.Period.To,
+			},
+			Total:      nodeStats.Total,
+			DailyStats: dailyStats,
+		}
+
+		return result.Ok[stats.GetOk, stats.GetError](ok), nil, nil
+	}
+}
+
+func toPeriodStats(ps service.PeriodStats) stats.PeriodStats {
+	return stats.PeriodStats{
+		Egress: ps.Egress,
+		Period: stats.Period{
+			From: ps.Period.From,
+			To:   ps.Period.To,
+		},
+	}
+}
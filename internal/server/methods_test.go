@@ -8,10 +8,13 @@ import (
 	"time"
 
 	accountegress "github.com/storacha/go-libstoracha/capabilities/account/egress"
+	"github.com/storacha/go-libstoracha/capabilities/space/egress"
 	"github.com/storacha/go-libstoracha/testutil"
 	"github.com/storacha/go-ucanto/client"
 	"github.com/storacha/go-ucanto/core/delegation"
 	"github.com/storacha/go-ucanto/core/invocation"
+	"github.com/storacha/go-ucanto/core/receipt"
+	"github.com/storacha/go-ucanto/core/receipt/ran"
 	"github.com/storacha/go-ucanto/core/result"
 	"github.com/storacha/go-ucanto/did"
 	"github.com/storacha/go-ucanto/principal"
@@ -20,25 +23,73 @@ import (
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 
+	accountquota "github.com/storacha/etracker/internal/capabilities/account/quota"
+	"github.com/storacha/etracker/internal/capabilities/batch"
+	spaceegress "github.com/storacha/etracker/internal/capabilities/space/egress"
+	"github.com/storacha/etracker/internal/capabilities/stats"
+	"github.com/storacha/etracker/internal/consolidator"
+	"github.com/storacha/etracker/internal/db/attestations"
+	"github.com/storacha/etracker/internal/db/ledger"
+	"github.com/storacha/etracker/internal/db/payouts"
+	"github.com/storacha/etracker/internal/db/statements"
+	"github.com/storacha/etracker/internal/quota"
 	"github.com/storacha/etracker/internal/service"
 )
 
 // mockService implements service.Service interface for testing
 type mockService struct {
-	getAccountEgressFunc     func(ctx context.Context, accountDID did.DID, spacesFilter []did.DID, periodFilter *service.Period) (*service.AccountEgress, error)
+	getAccountEgressFunc     func(ctx context.Context, accountDID did.DID, spacesFilter []did.DID, periodFilter *service.Period, granularity service.Granularity) (*service.AccountEgress, error)
+	getAccountQuotaFunc      func(ctx context.Context, accountDID did.DID) (*quota.Usage, error)
+	getSpaceEgressFunc       func(ctx context.Context, space did.DID, periodFilter *service.Period, granularity service.Granularity) (*service.SpaceEgress, error)
 	recordFunc               func(ctx context.Context, node did.DID, receipts ucan.Link, endpoint *url.URL, cause invocation.Invocation) error
 	getStatsFunc             func(ctx context.Context, node did.DID) (*service.Stats, error)
+	getNodeStatsFunc         func(ctx context.Context, node did.DID, periodFilter *service.Period) (*service.NodeStats, error)
 	getAllProvidersStatsFunc func(ctx context.Context, limit int, startToken *string) (*service.GetAllProvidersStatsResult, error)
 	getAllAccountsStatsFunc  func(ctx context.Context, limit int, startToken *string) (*service.GetAllAccountsStatsResult, error)
+	getAnomaliesFunc         func(ctx context.Context, limit int, startToken *string) (*service.GetAnomaliesResult, error)
+	getHeldBatchesFunc       func(ctx context.Context, limit int, startToken *string) (*service.GetHeldBatchesResult, error)
+	approveBatchFunc         func(ctx context.Context, cause ucan.Link) error
+	rejectBatchFunc          func(ctx context.Context, cause ucan.Link, reason string) error
+	getCallbacksFunc         func(ctx context.Context, limit int, startToken *string) (*service.GetCallbacksResult, error)
+	setCallbackFunc          func(ctx context.Context, node did.DID, callbackURL string) error
+	listBatchesFunc          func(ctx context.Context, node did.DID, period *service.Period, limit int, startToken *string) (*service.ListBatchesResult, error)
+	getStatementsFunc        func(ctx context.Context, limit int, startToken *string) (*service.GetStatementsResult, error)
+	getAccountStatementsFunc func(ctx context.Context, account did.DID) ([]statements.Statement, error)
+	getStatementFunc         func(ctx context.Context, account did.DID, month time.Time) (*statements.Statement, error)
+	generatePayoutRunFunc    func(ctx context.Context, month time.Time) (*payouts.Run, error)
+	finalizePayoutRunFunc    func(ctx context.Context, month time.Time) error
+	getPayoutRunsFunc        func(ctx context.Context) ([]payouts.Run, error)
+	getPayoutRunFunc         func(ctx context.Context, month time.Time) (*payouts.Run, error)
+	attestPayoutRunFunc      func(ctx context.Context, month time.Time) (int, error)
+	getAttestationFunc       func(ctx context.Context, cause ucan.Link) (*attestations.Attestation, error)
+	getLedgerBalanceFunc     func(ctx context.Context, book ledger.Book, period service.Period, asOf time.Time) (*service.LedgerBalance, error)
+	postLedgerAdjustmentFunc func(ctx context.Context, adjustment service.LedgerAdjustment) (*ledger.Transaction, error)
+	reverseBatchFunc         func(ctx context.Context, cause ucan.Link, actor, reason string) (*ledger.Transaction, error)
+	getExcludedStatsFunc     func(ctx context.Context) ([]service.ExcludedSpaceStats, error)
+	getNetworkStatsFunc      func(ctx context.Context, period service.Period) (*service.NetworkStats, error)
 }
 
-func (m *mockService) GetAccountEgress(ctx context.Context, accountDID did.DID, spacesFilter []did.DID, periodFilter *service.Period) (*service.AccountEgress, error) {
+func (m *mockService) GetAccountEgress(ctx context.Context, accountDID did.DID, spacesFilter []did.DID, periodFilter *service.Period, granularity service.Granularity) (*service.AccountEgress, error) {
 	if m.getAccountEgressFunc != nil {
-		return m.getAccountEgressFunc(ctx, accountDID, spacesFilter, periodFilter)
+		return m.getAccountEgressFunc(ctx, accountDID, spacesFilter, periodFilter, granularity)
 	}
 	return nil, fmt.Errorf("mockService.GetAccountEgress not implemented")
 }
 
+func (m *mockService) GetAccountQuota(ctx context.Context, accountDID did.DID) (*quota.Usage, error) {
+	if m.getAccountQuotaFunc != nil {
+		return m.getAccountQuotaFunc(ctx, accountDID)
+	}
+	return nil, fmt.Errorf("mockService.GetAccountQuota not implemented")
+}
+
+func (m *mockService) GetSpaceEgress(ctx context.Context, space did.DID, periodFilter *service.Period, granularity service.Granularity) (*service.SpaceEgress, error) {
+	if m.getSpaceEgressFunc != nil {
+		return m.getSpaceEgressFunc(ctx, space, periodFilter, granularity)
+	}
+	return nil, fmt.Errorf("mockService.GetSpaceEgress not implemented")
+}
+
 func (m *mockService) Record(ctx context.Context, node did.DID, receipts ucan.Link, endpoint *url.URL, cause invocation.Invocation) error {
 	if m.recordFunc != nil {
 		return m.recordFunc(ctx, node, receipts, endpoint, cause)
@@ -53,6 +104,13 @@ func (m *mockService) GetStats(ctx context.Context, node did.DID) (*service.Stat
 	return nil, fmt.Errorf("mockService.GetStats not implemented")
 }
 
+func (m *mockService) GetNodeStats(ctx context.Context, node did.DID, periodFilter *service.Period) (*service.NodeStats, error) {
+	if m.getNodeStatsFunc != nil {
+		return m.getNodeStatsFunc(ctx, node, periodFilter)
+	}
+	return nil, fmt.Errorf("mockService.GetNodeStats not implemented")
+}
+
 func (m *mockService) GetAllProvidersStats(ctx context.Context, limit int, startToken *string) (*service.GetAllProvidersStatsResult, error) {
 	if m.getAllProvidersStatsFunc != nil {
 		return m.getAllProvidersStatsFunc(ctx, limit, startToken)
@@ -67,6 +125,153 @@ func (m *mockService) GetAllAccountsStats(ctx context.Context, limit int, startT
 	return nil, fmt.Errorf("mockService.GetAllAccountsStats not implemented")
 }
 
+func (m *mockService) GetAnomalies(ctx context.Context, limit int, startToken *string) (*service.GetAnomaliesResult, error) {
+	if m.getAnomaliesFunc != nil {
+		return m.getAnomaliesFunc(ctx, limit, startToken)
+	}
+	return nil, fmt.Errorf("mockService.GetAnomalies not implemented")
+}
+
+func (m *mockService) GetHeldBatches(ctx context.Context, limit int, startToken *string) (*service.GetHeldBatchesResult, error) {
+	if m.getHeldBatchesFunc != nil {
+		return m.getHeldBatchesFunc(ctx, limit, startToken)
+	}
+	return nil, fmt.Errorf("mockService.GetHeldBatches not implemented")
+}
+
+func (m *mockService) ApproveBatch(ctx context.Context, cause ucan.Link) error {
+	if m.approveBatchFunc != nil {
+		return m.approveBatchFunc(ctx, cause)
+	}
+	return fmt.Errorf("mockService.ApproveBatch not implemented")
+}
+
+func (m *mockService) RejectBatch(ctx context.Context, cause ucan.Link, reason string) error {
+	if m.rejectBatchFunc != nil {
+		return m.rejectBatchFunc(ctx, cause, reason)
+	}
+	return fmt.Errorf("mockService.RejectBatch not implemented")
+}
+
+func (m *mockService) GetCallbacks(ctx context.Context, limit int, startToken *string) (*service.GetCallbacksResult, error) {
+	if m.getCallbacksFunc != nil {
+		return m.getCallbacksFunc(ctx, limit, startToken)
+	}
+	return nil, fmt.Errorf("mockService.GetCallbacks not implemented")
+}
+
+func (m *mockService) SetCallback(ctx context.Context, node did.DID, callbackURL string) error {
+	if m.setCallbackFunc != nil {
+		return m.setCallbackFunc(ctx, node, callbackURL)
+	}
+	return fmt.Errorf("mockService.SetCallback not implemented")
+}
+
+func (m *mockService) ListBatches(ctx context.Context, node did.DID, period *service.Period, limit int, startToken *string) (*service.ListBatchesResult, error) {
+	if m.listBatchesFunc != nil {
+		return m.listBatchesFunc(ctx, node, period, limit, startToken)
+	}
+	return nil, fmt.Errorf("mockService.ListBatches not implemented")
+}
+
+func (m *mockService) GetStatements(ctx context.Context, limit int, startToken *string) (*service.GetStatementsResult, error) {
+	if m.getStatementsFunc != nil {
+		return m.getStatementsFunc(ctx, limit, startToken)
+	}
+	return nil, fmt.Errorf("mockService.GetStatements not implemented")
+}
+
+func (m *mockService) GetAccountStatements(ctx context.Context, account did.DID) ([]statements.Statement, error) {
+	if m.getAccountStatementsFunc != nil {
+		return m.getAccountStatementsFunc(ctx, account)
+	}
+	return nil, fmt.Errorf("mockService.GetAccountStatements not implemented")
+}
+
+func (m *mockService) GetStatement(ctx context.Context, account did.DID, month time.Time) (*statements.Statement, error) {
+	if m.getStatementFunc != nil {
+		return m.getStatementFunc(ctx, account, month)
+	}
+	return nil, fmt.Errorf("mockService.GetStatement not implemented")
+}
+
+func (m *mockService) GeneratePayoutRun(ctx context.Context, month time.Time) (*payouts.Run, error) {
+	if m.generatePayoutRunFunc != nil {
+		return m.generatePayoutRunFunc(ctx, month)
+	}
+	return nil, fmt.Errorf("mockService.GeneratePayoutRun not implemented")
+}
+
+func (m *mockService) FinalizePayoutRun(ctx context.Context, month time.Time) error {
+	if m.finalizePayoutRunFunc != nil {
+		return m.finalizePayoutRunFunc(ctx, month)
+	}
+	return fmt.Errorf("mockService.FinalizePayoutRun not implemented")
+}
+
+func (m *mockService) GetPayoutRuns(ctx context.Context) ([]payouts.Run, error) {
+	if m.getPayoutRunsFunc != nil {
+		return m.getPayoutRunsFunc(ctx)
+	}
+	return nil, fmt.Errorf("mockService.GetPayoutRuns not implemented")
+}
+
+func (m *mockService) GetPayoutRun(ctx context.Context, month time.Time) (*payouts.Run, error) {
+	if m.getPayoutRunFunc != nil {
+		return m.getPayoutRunFunc(ctx, month)
+	}
+	return nil, fmt.Errorf("mockService.GetPayoutRun not implemented")
+}
+
+func (m *mockService) AttestPayoutRun(ctx context.Context, month time.Time) (int, error) {
+	if m.attestPayoutRunFunc != nil {
+		return m.attestPayoutRunFunc(ctx, month)
+	}
+	return 0, fmt.Errorf("mockService.AttestPayoutRun not implemented")
+}
+
+func (m *mockService) GetAttestation(ctx context.Context, cause ucan.Link) (*attestations.Attestation, error) {
+	if m.getAttestationFunc != nil {
+		return m.getAttestationFunc(ctx, cause)
+	}
+	return nil, fmt.Errorf("mockService.GetAttestation not implemented")
+}
+
+func (m *mockService) GetLedgerBalance(ctx context.Context, book ledger.Book, period service.Period, asOf time.Time) (*service.LedgerBalance, error) {
+	if m.getLedgerBalanceFunc != nil {
+		return m.getLedgerBalanceFunc(ctx, book, period, asOf)
+	}
+	return nil, fmt.Errorf("mockService.GetLedgerBalance not implemented")
+}
+
+func (m *mockService) PostLedgerAdjustment(ctx context.Context, adjustment service.LedgerAdjustment) (*ledger.Transaction, error) {
+	if m.postLedgerAdjustmentFunc != nil {
+		return m.postLedgerAdjustmentFunc(ctx, adjustment)
+	}
+	return nil, fmt.Errorf("mockService.PostLedgerAdjustment not implemented")
+}
+
+func (m *mockService) ReverseBatch(ctx context.Context, cause ucan.Link, actor, reason string) (*ledger.Transaction, error) {
+	if m.reverseBatchFunc != nil {
+		return m.reverseBatchFunc(ctx, cause, actor, reason)
+	}
+	return nil, fmt.Errorf("mockService.ReverseBatch not implemented")
+}
+
+func (m *mockService) GetExcludedSpacesStats(ctx context.Context) ([]service.ExcludedSpaceStats, error) {
+	if m.getExcludedStatsFunc != nil {
+		return m.getExcludedStatsFunc(ctx)
+	}
+	return nil, fmt.Errorf("mockService.GetExcludedSpacesStats not implemented")
+}
+
+func (m *mockService) GetNetworkStats(ctx context.Context, period service.Period) (*service.NetworkStats, error) {
+	if m.getNetworkStatsFunc != nil {
+		return m.getNetworkStatsFunc(ctx, period)
+	}
+	return nil, fmt.Errorf("mockService.GetNetworkStats not implemented")
+}
+
 var _ service.Service = (*mockService)(nil)
 
 func TestAccountEgressGetHandler(t *testing.T) {
@@ -99,16 +304,17 @@ func TestAccountEgressGetHandler(t *testing.T) {
 		}
 
 		mockSvc := &mockService{
-			getAccountEgressFunc: func(ctx context.Context, acctDID did.DID, spacesFilter []did.DID, periodFilter *service.Period) (*service.AccountEgress, error) {
+			getAccountEgressFunc: func(ctx context.Context, acctDID did.DID, spacesFilter []did.DID, periodFilter *service.Period, granularity service.Granularity) (*service.AccountEgress, error) {
 				assert.Equal(t, accountDID, acctDID)
 				assert.Empty(t, spacesFilter)
 				assert.Nil(t, periodFilter)
+				assert.Equal(t, service.GranularityDay, granularity)
 				return expectedData, nil
 			},
 		}
 
 		// Create server and connection
-		conn, err := newTestConnection(serviceSigner, mockSvc)
+		conn, err := newTestConnection(serviceSigner, mockSvc, nil)
 		require.NoError(t, err)
 
 		// Create invocation
@@ -167,7 +373,7 @@ func TestAccountEgressGetHandler(t *testing.T) {
 		}
 
 		mockSvc := &mockService{
-			getAccountEgressFunc: func(ctx context.Context, acctDID did.DID, spacesFilter []did.DID, periodFilter *service.Period) (*service.AccountEgress, error) {
+			getAccountEgressFunc: func(ctx context.Context, acctDID did.DID, spacesFilter []did.DID, periodFilter *service.Period, granularity service.Granularity) (*service.AccountEgress, error) {
 				assert.Equal(t, accountDID, acctDID)
 				require.Len(t, spacesFilter, 1)
 				assert.Contains(t, spacesFilter, space1)
@@ -179,7 +385,7 @@ func TestAccountEgressGetHandler(t *testing.T) {
 		}
 
 		// Create server and connection
-		conn, err := newTestConnection(serviceSigner, mockSvc)
+		conn, err := newTestConnection(serviceSigner, mockSvc, nil)
 		require.NoError(t, err)
 
 		// Create invocation
@@ -223,13 +429,13 @@ func TestAccountEgressGetHandler(t *testing.T) {
 		accountDID := issuer.DID()
 
 		mockSvc := &mockService{
-			getAccountEgressFunc: func(ctx context.Context, acctDID did.DID, spacesFilter []did.DID, periodFilter *service.Period) (*service.AccountEgress, error) {
+			getAccountEgressFunc: func(ctx context.Context, acctDID did.DID, spacesFilter []did.DID, periodFilter *service.Period, granularity service.Granularity) (*service.AccountEgress, error) {
 				return nil, service.NewAccountNotFoundError(accountDID)
 			},
 		}
 
 		// Create server and connection
-		conn, err := newTestConnection(serviceSigner, mockSvc)
+		conn, err := newTestConnection(serviceSigner, mockSvc, nil)
 		require.NoError(t, err)
 
 		// Create invocation
@@ -270,13 +476,13 @@ func TestAccountEgressGetHandler(t *testing.T) {
 		space1 := testutil.RandomDID(t)
 
 		mockSvc := &mockService{
-			getAccountEgressFunc: func(ctx context.Context, acctDID did.DID, spacesFilter []did.DID, periodFilter *service.Period) (*service.AccountEgress, error) {
+			getAccountEgressFunc: func(ctx context.Context, acctDID did.DID, spacesFilter []did.DID, periodFilter *service.Period, granularity service.Granularity) (*service.AccountEgress, error) {
 				return nil, service.NewSpaceUnauthorizedError([]did.DID{space1})
 			},
 		}
 
 		// Create server and connection
-		conn, err := newTestConnection(serviceSigner, mockSvc)
+		conn, err := newTestConnection(serviceSigner, mockSvc, nil)
 		require.NoError(t, err)
 
 		// Create invocation
@@ -318,13 +524,13 @@ func TestAccountEgressGetHandler(t *testing.T) {
 		to := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
 
 		mockSvc := &mockService{
-			getAccountEgressFunc: func(ctx context.Context, acctDID did.DID, spacesFilter []did.DID, periodFilter *service.Period) (*service.AccountEgress, error) {
+			getAccountEgressFunc: func(ctx context.Context, acctDID did.DID, spacesFilter []did.DID, periodFilter *service.Period, granularity service.Granularity) (*service.AccountEgress, error) {
 				return nil, service.NewPeriodNotAcceptableError("'from' date is after 'to' date")
 			},
 		}
 
 		// Create server and connection
-		conn, err := newTestConnection(serviceSigner, mockSvc)
+		conn, err := newTestConnection(serviceSigner, mockSvc, nil)
 		require.NoError(t, err)
 
 		// Create invocation
@@ -359,11 +565,607 @@ func TestAccountEgressGetHandler(t *testing.T) {
 		assert.Equal(t, accountegress.PeriodNotAcceptableErrorName, errVal.ErrorName)
 		assert.Contains(t, errVal.Message, "from")
 	})
+
+	t.Run("returns error when the egress of some spaces could not be fetched", func(t *testing.T) {
+		issuer := testutil.RandomSigner(t)
+		accountDID := issuer.DID()
+		space1 := testutil.RandomDID(t)
+		space2 := testutil.RandomDID(t)
+
+		mockSvc := &mockService{
+			getAccountEgressFunc: func(ctx context.Context, acctDID did.DID, spacesFilter []did.DID, periodFilter *service.Period, granularity service.Granularity) (*service.AccountEgress, error) {
+				return &service.AccountEgress{
+					Total: 500,
+					Spaces: map[did.DID]service.SpaceEgress{
+						space1: {Total: 500},
+					},
+					FailedSpaces: map[did.DID]error{
+						space2: fmt.Errorf("database error"),
+					},
+				}, nil
+			},
+		}
+
+		conn, err := newTestConnection(serviceSigner, mockSvc, nil)
+		require.NoError(t, err)
+
+		inv, err := accountegress.Get.Invoke(
+			issuer,
+			serviceSigner,
+			accountDID.String(),
+			accountegress.GetCaveats{},
+			delegation.WithNoExpiration(),
+		)
+		require.NoError(t, err)
+
+		resp, err := client.Execute(context.Background(), []invocation.Invocation{inv}, conn)
+		require.NoError(t, err)
+
+		rcptLink, ok := resp.Get(inv.Link())
+		require.True(t, ok)
+
+		reader, err := accountegress.NewGetReceiptReader()
+		require.NoError(t, err)
+
+		rcpt, err := reader.Read(rcptLink, resp.Blocks())
+		require.NoError(t, err)
+
+		_, errVal := result.Unwrap(rcpt.Out())
+
+		assert.Equal(t, PartialResultErrorName, errVal.ErrorName)
+		assert.Contains(t, errVal.Message, space2.String())
+	})
+}
+
+// mockConsolidator consolidates causes once and returns the same receipt on later calls
+type mockConsolidator struct {
+	id       principal.Signer
+	tracked  map[string]uint64
+	held     map[string]bool
+	receipts map[string]egress.ConsolidateReceipt
+}
+
+func (m *mockConsolidator) ConsolidateCause(ctx context.Context, cause ucan.Link) (egress.ConsolidateReceipt, error) {
+	if rcpt, ok := m.receipts[cause.String()]; ok {
+		return rcpt, nil
+	}
+
+	if m.held[cause.String()] {
+		return nil, consolidator.ErrPendingReview
+	}
+
+	totalEgress, ok := m.tracked[cause.String()]
+	if !ok {
+		return nil, consolidator.ErrNotFound
+	}
+
+	inv, err := egress.Consolidate.Invoke(m.id, m.id, m.id.DID().String(), egress.ConsolidateCaveats{Cause: cause}, delegation.WithNoExpiration())
+	if err != nil {
+		return nil, err
+	}
+
+	anyRcpt, err := receipt.Issue(m.id, result.Ok[egress.ConsolidateOk, egress.ConsolidateError](egress.ConsolidateOk{TotalEgress: totalEgress}), ran.FromInvocation(inv))
+	if err != nil {
+		return nil, err
+	}
+
+	reader, err := egress.NewConsolidateReceiptReader()
+	if err != nil {
+		return nil, err
+	}
+
+	rcpt, err := reader.Read(anyRcpt.Root().Link(), anyRcpt.Blocks())
+	if err != nil {
+		return nil, err
+	}
+
+	m.receipts[cause.String()] = rcpt
+	return rcpt, nil
+}
+
+func TestConsolidateHandler(t *testing.T) {
+	serviceSigner := testutil.WebService
+
+	trackedCause := testutil.RandomCID(t)
+	heldCause := testutil.RandomCID(t)
+
+	newConsolidator := func() *mockConsolidator {
+		return &mockConsolidator{
+			id:       serviceSigner,
+			tracked:  map[string]uint64{trackedCause.String(): 1000, heldCause.String(): 5000},
+			held:     map[string]bool{heldCause.String(): true},
+			receipts: make(map[string]egress.ConsolidateReceipt),
+		}
+	}
+
+	// scheduler was delegated `space/egress/consolidate` by the service
+	scheduler := testutil.RandomSigner(t)
+	dlg, err := delegation.Delegate(
+		serviceSigner,
+		scheduler,
+		[]ucan.Capability[ucan.NoCaveats]{
+			ucan.NewCapability(egress.ConsolidateAbility, serviceSigner.DID().String(), ucan.NoCaveats{}),
+		},
+		delegation.WithNoExpiration(),
+	)
+	require.NoError(t, err)
+
+	execConsolidate := func(t *testing.T, conn client.Connection, issuer principal.Signer, with string, cause ucan.Link, opts ...delegation.Option) (egress.ConsolidateOk, egress.ConsolidateError) {
+		opts = append(opts, delegation.WithNoExpiration())
+		inv, err := egress.Consolidate.Invoke(issuer, serviceSigner, with, egress.ConsolidateCaveats{Cause: cause}, opts...)
+		require.NoError(t, err)
+
+		resp, err := client.Execute(context.Background(), []invocation.Invocation{inv}, conn)
+		require.NoError(t, err)
+
+		rcptLink, ok := resp.Get(inv.Link())
+		require.True(t, ok)
+
+		reader, err := egress.NewConsolidateReceiptReader()
+		require.NoError(t, err)
+
+		rcpt, err := reader.Read(rcptLink, resp.Blocks())
+		require.NoError(t, err)
+
+		return result.Unwrap(rcpt.Out())
+	}
+
+	t.Run("consolidates the batch for a delegated principal", func(t *testing.T) {
+		cons := newConsolidator()
+		conn, err := newTestConnection(serviceSigner, &mockService{}, cons)
+		require.NoError(t, err)
+
+		ok, x := execConsolidate(t, conn, scheduler, serviceSigner.DID().String(), trackedCause, delegation.WithProof(delegation.FromDelegation(dlg)))
+		require.Empty(t, x.ErrorName)
+		assert.Equal(t, uint64(1000), ok.TotalEgress)
+		assert.Len(t, cons.receipts, 1)
+	})
+
+	t.Run("repeated submissions return the same result", func(t *testing.T) {
+		cons := newConsolidator()
+		conn, err := newTestConnection(serviceSigner, &mockService{}, cons)
+		require.NoError(t, err)
+
+		first, _ := execConsolidate(t, conn, serviceSigner, serviceSigner.DID().String(), trackedCause)
+		second, _ := execConsolidate(t, conn, scheduler, serviceSigner.DID().String(), trackedCause, delegation.WithProof(delegation.FromDelegation(dlg)))
+		assert.Equal(t, first, second)
+		assert.Len(t, cons.receipts, 1)
+	})
+
+	t.Run("rejects principals without a delegation", func(t *testing.T) {
+		cons := newConsolidator()
+		conn, err := newTestConnection(serviceSigner, &mockService{}, cons)
+		require.NoError(t, err)
+
+		inv, err := egress.Consolidate.Invoke(testutil.RandomSigner(t), serviceSigner, serviceSigner.DID().String(), egress.ConsolidateCaveats{Cause: trackedCause}, delegation.WithNoExpiration())
+		require.NoError(t, err)
+
+		resp, err := client.Execute(context.Background(), []invocation.Invocation{inv}, conn)
+		require.NoError(t, err)
+
+		rcptLink, ok := resp.Get(inv.Link())
+		require.True(t, ok)
+
+		rcpt, err := receipt.NewAnyReceiptReader().Read(rcptLink, resp.Blocks())
+		require.NoError(t, err)
+
+		_, x := result.Unwrap(rcpt.Out())
+		assert.NotNil(t, x)
+		assert.Empty(t, cons.receipts)
+	})
+
+	t.Run("rejects resources other than the service", func(t *testing.T) {
+		cons := newConsolidator()
+		conn, err := newTestConnection(serviceSigner, &mockService{}, cons)
+		require.NoError(t, err)
+
+		issuer := testutil.RandomSigner(t)
+		_, x := execConsolidate(t, conn, issuer, issuer.DID().String(), trackedCause)
+		assert.Equal(t, UnauthorizedErrorName, x.ErrorName)
+		assert.Empty(t, cons.receipts)
+	})
+
+	t.Run("returns an error for unknown causes", func(t *testing.T) {
+		conn, err := newTestConnection(serviceSigner, &mockService{}, newConsolidator())
+		require.NoError(t, err)
+
+		_, x := execConsolidate(t, conn, serviceSigner, serviceSigner.DID().String(), testutil.RandomCID(t))
+		assert.Equal(t, CauseNotFoundErrorName, x.ErrorName)
+	})
+
+	t.Run("returns an error for batches held for review", func(t *testing.T) {
+		conn, err := newTestConnection(serviceSigner, &mockService{}, newConsolidator())
+		require.NoError(t, err)
+
+		_, x := execConsolidate(t, conn, serviceSigner, serviceSigner.DID().String(), heldCause)
+		assert.Equal(t, PendingReviewErrorName, x.ErrorName)
+	})
+}
+
+func TestAccountQuotaGetHandler(t *testing.T) {
+	serviceSigner := testutil.WebService
+	limited := testutil.RandomSigner(t)
+	unlimited := testutil.RandomSigner(t)
+	month := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	mockSvc := &mockService{
+		getAccountQuotaFunc: func(ctx context.Context, accountDID did.DID) (*quota.Usage, error) {
+			switch accountDID {
+			case limited.DID():
+				return &quota.Usage{Account: accountDID, Month: month, Limit: 1000, Used: 850}, nil
+			case unlimited.DID():
+				return nil, nil
+			}
+			return nil, service.NewAccountNotFoundError(accountDID)
+		},
+	}
+
+	execGet := func(t *testing.T, issuer principal.Signer) result.Result[accountquota.GetOk, accountquota.GetError] {
+		conn, err := newTestConnection(serviceSigner, mockSvc, nil)
+		require.NoError(t, err)
+
+		inv, err := accountquota.Get.Invoke(issuer, serviceSigner, issuer.DID().String(), accountquota.GetCaveats{}, delegation.WithNoExpiration())
+		require.NoError(t, err)
+
+		resp, err := client.Execute(context.Background(), []invocation.Invocation{inv}, conn)
+		require.NoError(t, err)
+
+		rcptLink, ok := resp.Get(inv.Link())
+		require.True(t, ok)
+
+		reader, err := accountquota.NewGetReceiptReader()
+		require.NoError(t, err)
+
+		rcpt, err := reader.Read(rcptLink, resp.Blocks())
+		require.NoError(t, err)
+		return rcpt.Out()
+	}
+
+	t.Run("usage of limited accounts", func(t *testing.T) {
+		ok, x := result.Unwrap(execGet(t, limited))
+		require.Empty(t, x.ErrorName)
+		assert.Equal(t, month, ok.Month)
+		assert.Equal(t, uint64(1000), ok.Limit)
+		assert.Equal(t, uint64(850), ok.Used)
+	})
+
+	t.Run("unlimited accounts have no quota", func(t *testing.T) {
+		_, x := result.Unwrap(execGet(t, unlimited))
+		assert.Equal(t, accountquota.NoQuotaErrorName, x.ErrorName)
+	})
+
+	t.Run("unknown accounts", func(t *testing.T) {
+		_, x := result.Unwrap(execGet(t, testutil.RandomSigner(t)))
+		assert.Equal(t, accountquota.AccountNotFoundErrorName, x.ErrorName)
+	})
+}
+
+func TestSpaceEgressGetHandler(t *testing.T) {
+	serviceSigner := testutil.WebService
+	space := testutil.RandomSigner(t)
+
+	mockSvc := &mockService{
+		getSpaceEgressFunc: func(ctx context.Context, s did.DID, periodFilter *service.Period, granularity service.Granularity) (*service.SpaceEgress, error) {
+			assert.Equal(t, space.DID(), s)
+			if periodFilter != nil && !periodFilter.From.Before(periodFilter.To) {
+				return nil, service.NewPeriodNotAcceptableError("invalid period")
+			}
+			return &service.SpaceEgress{
+				Total: 500,
+				DailyStats: []service.DailyStat{
+					{Date: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC), Egress: 500},
+				},
+			}, nil
+		},
+	}
+
+	execGet := func(t *testing.T, issuer principal.Signer, caveats spaceegress.GetCaveats, opts ...delegation.Option) result.Result[spaceegress.GetOk, spaceegress.GetError] {
+		conn, err := newTestConnection(serviceSigner, mockSvc, nil)
+		require.NoError(t, err)
+
+		opts = append(opts, delegation.WithNoExpiration())
+		inv, err := spaceegress.Get.Invoke(issuer, serviceSigner, space.DID().String(), caveats, opts...)
+		require.NoError(t, err)
+
+		resp, err := client.Execute(context.Background(), []invocation.Invocation{inv}, conn)
+		require.NoError(t, err)
+
+		rcptLink, ok := resp.Get(inv.Link())
+		require.True(t, ok)
+
+		reader, err := spaceegress.NewGetReceiptReader()
+		require.NoError(t, err)
+
+		rcpt, err := reader.Read(rcptLink, resp.Blocks())
+		if err != nil {
+			// authorization failures don't match the space egress error schema
+			return nil
+		}
+		return rcpt.Out()
+	}
+
+	t.Run("space owner can get the egress of the space", func(t *testing.T) {
+		out := execGet(t, space, spaceegress.GetCaveats{})
+		require.NotNil(t, out)
+
+		ok, x := result.Unwrap(out)
+		require.Empty(t, x.ErrorName)
+		assert.Equal(t, uint64(500), ok.Total)
+		assert.Len(t, ok.DailyStats, 1)
+	})
+
+	t.Run("agents delegated by the space owner can get the egress of the space", func(t *testing.T) {
+		agent := testutil.RandomSigner(t)
+		dlg, err := delegation.Delegate(
+			space,
+			agent,
+			[]ucan.Capability[ucan.NoCaveats]{
+				ucan.NewCapability(spaceegress.GetAbility, space.DID().String(), ucan.NoCaveats{}),
+			},
+			delegation.WithNoExpiration(),
+		)
+		require.NoError(t, err)
+
+		out := execGet(t, agent, spaceegress.GetCaveats{}, delegation.WithProof(delegation.FromDelegation(dlg)))
+		require.NotNil(t, out)
+
+		ok, x := result.Unwrap(out)
+		require.Empty(t, x.ErrorName)
+		assert.Equal(t, uint64(500), ok.Total)
+	})
+
+	t.Run("rejects agents without a delegation", func(t *testing.T) {
+		out := execGet(t, testutil.RandomSigner(t), spaceegress.GetCaveats{})
+		assert.Nil(t, out)
+	})
+
+	t.Run("returns error when period not acceptable", func(t *testing.T) {
+		now := time.Now().UTC()
+		out := execGet(t, space, spaceegress.GetCaveats{Period: &spaceegress.Period{From: now, To: now.AddDate(0, 0, -1)}})
+		require.NotNil(t, out)
+
+		_, x := result.Unwrap(out)
+		assert.Equal(t, spaceegress.PeriodNotAcceptableErrorName, x.ErrorName)
+	})
+}
+
+func TestBatchListHandler(t *testing.T) {
+	serviceSigner := testutil.WebService
+	node := testutil.RandomSigner(t)
+
+	processedAt := time.Date(2025, 6, 2, 0, 0, 0, 0, time.UTC)
+	batches := []service.Batch{
+		{
+			Batch:      testutil.RandomCID(t),
+			Cause:      testutil.RandomCID(t),
+			Status:     service.BatchUnprocessed,
+			ReceivedAt: time.Date(2025, 6, 3, 0, 0, 0, 0, time.UTC),
+		},
+		{
+			Batch:       testutil.RandomCID(t),
+			Cause:       testutil.RandomCID(t),
+			Status:      service.BatchConsolidated,
+			ReceivedAt:  time.Date(2025, 6, 1, 0, 0, 0, 0, time.UTC),
+			ProcessedAt: &processedAt,
+			TotalEgress: 1000,
+			Rejected:    2,
+		},
+	}
+
+	newService := func(t *testing.T) *mockService {
+		return &mockService{
+			listBatchesFunc: func(ctx context.Context, n did.DID, period *service.Period, limit int, startToken *string) (*service.ListBatchesResult, error) {
+				assert.Equal(t, node.DID(), n)
+				if period != nil && !period.From.Before(period.To) {
+					return nil, service.NewPeriodNotAcceptableError("invalid period")
+				}
+				return &service.ListBatchesResult{Batches: batches, NextToken: startToken}, nil
+			},
+		}
+	}
+
+	execList := func(t *testing.T, conn client.Connection, issuer principal.Signer, caveats batch.ListCaveats, opts ...delegation.Option) (batch.ListOk, batch.ListError, error) {
+		opts = append(opts, delegation.WithNoExpiration())
+		inv, err := batch.List.Invoke(issuer, serviceSigner, node.DID().String(), caveats, opts...)
+		require.NoError(t, err)
+
+		resp, err := client.Execute(context.Background(), []invocation.Invocation{inv}, conn)
+		require.NoError(t, err)
+
+		rcptLink, ok := resp.Get(inv.Link())
+		require.True(t, ok)
+
+		reader, err := batch.NewListReceiptReader()
+		require.NoError(t, err)
+
+		rcpt, err := reader.Read(rcptLink, resp.Blocks())
+		if err != nil {
+			return batch.ListOk{}, batch.ListError{}, err
+		}
+
+		ok2, x := result.Unwrap(rcpt.Out())
+		return ok2, x, nil
+	}
+
+	t.Run("lists the batches of the node", func(t *testing.T) {
+		conn, err := newTestConnection(serviceSigner, newService(t), nil)
+		require.NoError(t, err)
+
+		cursor := "next"
+		ok, x, err := execList(t, conn, node, batch.ListCaveats{Cursor: &cursor})
+		require.NoError(t, err)
+		require.Empty(t, x.ErrorName)
+
+		require.Len(t, ok.Batches, 2)
+		assert.Equal(t, string(service.BatchUnprocessed), ok.Batches[0].Status)
+		assert.Nil(t, ok.Batches[0].ProcessedAt)
+		assert.Equal(t, batches[1].Batch.String(), ok.Batches[1].Batch.String())
+		assert.Equal(t, string(service.BatchConsolidated), ok.Batches[1].Status)
+		assert.Equal(t, uint64(1000), ok.Batches[1].TotalEgress)
+		assert.Equal(t, uint64(2), ok.Batches[1].Rejected)
+		require.NotNil(t, ok.Batches[1].ProcessedAt)
+		assert.True(t, processedAt.Equal(*ok.Batches[1].ProcessedAt))
+		require.NotNil(t, ok.Cursor)
+		assert.Equal(t, cursor, *ok.Cursor)
+	})
+
+	t.Run("principals delegated by the node can list its batches", func(t *testing.T) {
+		conn, err := newTestConnection(serviceSigner, newService(t), nil)
+		require.NoError(t, err)
+
+		agent := testutil.RandomSigner(t)
+		dlg, err := delegation.Delegate(
+			node,
+			agent,
+			[]ucan.Capability[ucan.NoCaveats]{
+				ucan.NewCapability(batch.ListAbility, node.DID().String(), ucan.NoCaveats{}),
+			},
+			delegation.WithNoExpiration(),
+		)
+		require.NoError(t, err)
+
+		ok, x, err := execList(t, conn, agent, batch.ListCaveats{}, delegation.WithProof(delegation.FromDelegation(dlg)))
+		require.NoError(t, err)
+		require.Empty(t, x.ErrorName)
+		assert.Len(t, ok.Batches, 2)
+	})
+
+	t.Run("rejects principals without a delegation", func(t *testing.T) {
+		conn, err := newTestConnection(serviceSigner, newService(t), nil)
+		require.NoError(t, err)
+
+		// the receipt is an authorization error, which does not match the list error schema
+		_, _, err = execList(t, conn, testutil.RandomSigner(t), batch.ListCaveats{})
+		require.Error(t, err)
+	})
+
+	t.Run("returns an error when period not acceptable", func(t *testing.T) {
+		conn, err := newTestConnection(serviceSigner, newService(t), nil)
+		require.NoError(t, err)
+
+		now := time.Now().UTC()
+		_, x, err := execList(t, conn, node, batch.ListCaveats{Period: &batch.Period{From: now, To: now.AddDate(0, 0, -1)}})
+		require.NoError(t, err)
+		assert.Equal(t, batch.PeriodNotAcceptableErrorName, x.ErrorName)
+	})
+
+	t.Run("returns an error when limit out of range", func(t *testing.T) {
+		conn, err := newTestConnection(serviceSigner, newService(t), nil)
+		require.NoError(t, err)
+
+		limit := int64(maxBatchListLimit + 1)
+		_, x, err := execList(t, conn, node, batch.ListCaveats{Limit: &limit})
+		require.NoError(t, err)
+		assert.NotEmpty(t, x.ErrorName)
+	})
+}
+
+func TestStatsGetHandler(t *testing.T) {
+	serviceSigner := testutil.WebService
+	node := testutil.RandomSigner(t)
+
+	now := time.Date(2025, 6, 20, 12, 0, 0, 0, time.UTC)
+	mockSvc := &mockService{
+		getNodeStatsFunc: func(ctx context.Context, n did.DID, periodFilter *service.Period) (*service.NodeStats, error) {
+			assert.Equal(t, node.DID(), n)
+			if periodFilter != nil && !periodFilter.From.Before(periodFilter.To) {
+				return nil, service.NewPeriodNotAcceptableError("invalid period")
+			}
+
+			s := service.NewStats(now)
+			s.AddEgress(1000, now)
+			return &service.NodeStats{
+				Stats:  s,
+				Period: service.Period{From: s.Earliest(), To: now},
+				Total:  1000,
+				DailyStats: []service.DailyStat{
+					{Date: time.Date(2025, 6, 20, 0, 0, 0, 0, time.UTC), Egress: 1000},
+				},
+			}, nil
+		},
+	}
+
+	execGet := func(t *testing.T, issuer principal.Signer, caveats stats.GetCaveats, opts ...delegation.Option) (stats.GetOk, stats.GetError) {
+		conn, err := newTestConnection(serviceSigner, mockSvc, nil)
+		require.NoError(t, err)
+
+		opts = append(opts, delegation.WithNoExpiration())
+		inv, err := stats.Get.Invoke(issuer, serviceSigner, node.DID().String(), caveats, opts...)
+		require.NoError(t, err)
+
+		resp, err := client.Execute(context.Background(), []invocation.Invocation{inv}, conn)
+		require.NoError(t, err)
+
+		rcptLink, ok := resp.Get(inv.Link())
+		require.True(t, ok)
+
+		reader, err := stats.NewGetReceiptReader()
+		require.NoError(t, err)
+
+		rcpt, err := reader.Read(rcptLink, resp.Blocks())
+		require.NoError(t, err)
+
+		return result.Unwrap(rcpt.Out())
+	}
+
+	t.Run("returns the stats of the node", func(t *testing.T) {
+		ok, x := execGet(t, node, stats.GetCaveats{})
+		require.Empty(t, x.ErrorName)
+
+		assert.Equal(t, uint64(1000), ok.CurrentDay.Egress)
+		assert.Equal(t, uint64(1000), ok.CurrentMonth.Egress)
+		assert.Equal(t, uint64(0), ok.PreviousMonth.Egress)
+		assert.Equal(t, uint64(1000), ok.Total)
+		require.Len(t, ok.DailyStats, 1)
+		assert.Equal(t, uint64(1000), ok.DailyStats[0].Egress)
+	})
+
+	t.Run("principals delegated by the node can read its stats", func(t *testing.T) {
+		agent := testutil.RandomSigner(t)
+		dlg, err := delegation.Delegate(
+			node,
+			agent,
+			[]ucan.Capability[ucan.NoCaveats]{
+				ucan.NewCapability(stats.GetAbility, node.DID().String(), ucan.NoCaveats{}),
+			},
+			delegation.WithNoExpiration(),
+		)
+		require.NoError(t, err)
+
+		ok, x := execGet(t, agent, stats.GetCaveats{}, delegation.WithProof(delegation.FromDelegation(dlg)))
+		require.Empty(t, x.ErrorName)
+		assert.Equal(t, uint64(1000), ok.Total)
+	})
+
+	t.Run("rejects principals without a delegation", func(t *testing.T) {
+		conn, err := newTestConnection(serviceSigner, mockSvc, nil)
+		require.NoError(t, err)
+
+		inv, err := stats.Get.Invoke(testutil.RandomSigner(t), serviceSigner, node.DID().String(), stats.GetCaveats{}, delegation.WithNoExpiration())
+		require.NoError(t, err)
+
+		resp, err := client.Execute(context.Background(), []invocation.Invocation{inv}, conn)
+		require.NoError(t, err)
+
+		rcptLink, ok := resp.Get(inv.Link())
+		require.True(t, ok)
+
+		rcpt, err := receipt.NewAnyReceiptReader().Read(rcptLink, resp.Blocks())
+		require.NoError(t, err)
+
+		_, x := result.Unwrap(rcpt.Out())
+		assert.NotNil(t, x)
+	})
+
+	t.Run("returns error when period not acceptable", func(t *testing.T) {
+		_, x := execGet(t, node, stats.GetCaveats{Period: &stats.Period{From: now, To: now.AddDate(0, 0, -1)}})
+		assert.Equal(t, stats.PeriodNotAcceptableErrorName, x.ErrorName)
+	})
 }
 
 // newTestConnection creates a UCAN server and connection for testing
-func newTestConnection(id principal.Signer, svc service.Service) (client.Connection, error) {
-	opts := serviceMethods(svc)
+func newTestConnection(id principal.Signer, svc service.Service, cons causeConsolidator) (client.Connection, error) {
+	opts := serviceMethods(svc, cons)
 
 	srv, err := ucanto.NewServer(id, opts...)
 	if err != nil {
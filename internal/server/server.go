@@ -10,6 +10,7 @@ import (
 	"github.com/storacha/go-ucanto/validator"
 
 	"github.com/storacha/etracker/internal/consolidator"
+	"github.com/storacha/etracker/internal/pricing"
 	"github.com/storacha/etracker/internal/service"
 	"github.com/storacha/etracker/web"
 )
@@ -17,14 +18,13 @@ import (
 var log = logging.Logger("server")
 
 type config struct {
-	metricsEndpointToken    string
-	adminUser               string
-	adminPassword           string
-	clientEgressUSDPerTiB   float64
-	providerEgressUSDPerTiB float64
-	principalResolver       validator.PrincipalResolver
-	principalParser         validator.PrincipalParserFunc
-	authProofs              []delegation.Delegation
+	metricsEndpointToken string
+	adminUser            string
+	adminPassword        string
+	payRates             *pricing.RateCards
+	principalResolver    validator.PrincipalResolver
+	principalParser      validator.PrincipalParserFunc
+	authProofs           []delegation.Delegation
 }
 
 type Option func(*config)
@@ -42,10 +42,10 @@ func WithAdminCreds(user, password string) Option {
 	}
 }
 
-func WithPricing(clientEgressUSDPerTiB, providerEgressUSDPerTiB float64) Option {
+// WithPayRates sets the rate cards node egress is paid with. Client egress is priced by the service.
+func WithPayRates(payRates *pricing.RateCards) Option {
 	return func(c *config) {
-		c.clientEgressUSDPerTiB = clientEgressUSDPerTiB
-		c.providerEgressUSDPerTiB = providerEgressUSDPerTiB
+		c.payRates = payRates
 	}
 }
 
@@ -75,12 +75,12 @@ type Server struct {
 }
 
 func New(id principal.Signer, svc service.Service, cons *consolidator.Consolidator, opts ...Option) (*Server, error) {
-	cfg := &config{}
+	cfg := &config{payRates: pricing.FlatRateCards(0)}
 	for _, opt := range opts {
 		opt(cfg)
 	}
 
-	ucantoOpts := serviceMethods(svc)
+	ucantoOpts := serviceMethods(svc, cons)
 
 	if cfg.principalResolver != nil {
 		ucantoOpts = append(ucantoOpts, ucanto.WithPrincipalResolver(cfg.principalResolver.ResolveDIDKey))
@@ -108,9 +108,11 @@ func (s *Server) ListenAndServe(addr string) error {
 	mux.HandleFunc("GET /receipts/{cid}", s.getReceiptsHandler())
 
 	// Set up admin endpoint with authentication (handles both GET and POST)
-	adminHandler := web.BasicAuthMiddleware(web.AdminHandler(s.svc, s.cfg.clientEgressUSDPerTiB, s.cfg.providerEgressUSDPerTiB), s.cfg.adminUser, s.cfg.adminPassword)
+	adminHandler := web.BasicAuthMiddleware(web.AdminHandler(s.svc, s.cfg.payRates), s.cfg.adminUser, s.cfg.adminPassword)
 	mux.HandleFunc("GET /admin", adminHandler)
 	mux.HandleFunc("POST /admin", adminHandler)
+	mux.HandleFunc("GET /admin/statements/{account}/{month}", web.BasicAuthMiddleware(web.StatementHandler(s.svc), s.cfg.adminUser, s.cfg.adminPassword))
+	mux.HandleFunc("GET /admin/payouts/{month}", web.BasicAuthMiddleware(web.PayoutHandler(s.svc), s.cfg.adminUser, s.cfg.adminPassword))
 
 	if s.cfg.metricsEndpointToken != "" {
 		mux.Handle("GET /metrics", s.getMetricsHandler())
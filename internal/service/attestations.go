@@ -0,0 +1,144 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/storacha/go-ucanto/core/delegation"
+	"github.com/storacha/go-ucanto/core/receipt"
+	"github.com/storacha/go-ucanto/core/receipt/ran"
+	"github.com/storacha/go-ucanto/core/result"
+	"github.com/storacha/go-ucanto/ucan"
+
+	"github.com/storacha/etracker/internal/capabilities/payout"
+	"github.com/storacha/etracker/internal/db/attestations"
+	"github.com/storacha/etracker/internal/db/payouts"
+)
+
+var (
+	ErrAttestationNotFound   = attestations.ErrNotFound
+	ErrPayoutRunNotFinalized = errors.New("payout run is not finalized")
+)
+
+// AttestPayoutRun issues the signed attestation of every line of the finalized payout run of the month of
+// the given time that does not have one yet, and returns the number of attestations issued. It is safe to
+// retry after a failure.
+func (s *service) AttestPayoutRun(ctx context.Context, month time.Time) (int, error) {
+	if s.payoutTable == nil || s.attestationTable == nil {
+		return 0, fmt.Errorf("payouts are %w", ErrNotConfigured)
+	}
+
+	run, err := s.payoutTable.Get(ctx, month)
+	if err != nil {
+		return 0, err
+	}
+
+	if run.Status != payouts.StatusFinalized {
+		return 0, ErrPayoutRunNotFinalized
+	}
+
+	issued := 0
+	for _, line := range run.Lines {
+		_, err := s.attestationTable.Get(ctx, line.Provider, run.Month)
+		if err == nil {
+			continue
+		}
+		if !errors.Is(err, attestations.ErrNotFound) {
+			return issued, fmt.Errorf("getting attestation of %s: %w", line.Provider, err)
+		}
+
+		attestation, err := s.issueAttestation(ctx, run.Month, line)
+		if err != nil {
+			return issued, fmt.Errorf("issuing attestation of %s: %w", line.Provider, err)
+		}
+
+		if err := s.attestationTable.Put(ctx, *attestation); err != nil {
+			if errors.Is(err, attestations.ErrAlreadyIssued) {
+				continue
+			}
+			return issued, fmt.Errorf("storing attestation of %s: %w", line.Provider, err)
+		}
+		issued++
+	}
+
+	return issued, nil
+}
+
+// issueAttestation signs a receipt for an attest invocation committing to the egress of the line and the
+// consolidate receipts it was counted from
+func (s *service) issueAttestation(ctx context.Context, month time.Time, line payouts.Line) (*attestations.Attestation, error) {
+	receipts, err := s.consolidateReceipts(ctx, line.Causes)
+	if err != nil {
+		return nil, err
+	}
+	root := payout.MerkleRoot(receipts)
+	causes := uint64(len(receipts))
+
+	inv, err := payout.Attest.Invoke(
+		s.id,
+		s.id,
+		line.Provider.String(),
+		payout.AttestCaveats{
+			Month:       month,
+			TotalEgress: line.Egress,
+			Causes:      causes,
+			Root:        root,
+		},
+		delegation.WithNoExpiration(),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("creating attest invocation: %w", err)
+	}
+
+	rcpt, err := receipt.Issue(
+		s.id,
+		result.Ok[payout.AttestOk, payout.AttestError](payout.AttestOk{
+			Node:        line.Provider,
+			Month:       month,
+			TotalEgress: line.Egress,
+			Causes:      causes,
+			Root:        root,
+		}),
+		ran.FromInvocation(inv),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("issuing receipt: %w", err)
+	}
+
+	return &attestations.Attestation{
+		Node:     line.Provider,
+		Month:    month,
+		Cause:    inv.Link(),
+		Receipt:  rcpt,
+		IssuedAt: time.Now().UTC(),
+	}, nil
+}
+
+// consolidateReceipts returns the links of the consolidate receipts of the given consolidated records. Nodes are
+// pushed the receipts of their batches, not the consolidate invocations, so the attestation commits to them.
+func (s *service) consolidateReceipts(ctx context.Context, causes []ucan.Link) ([]ucan.Link, error) {
+	receipts := make([]ucan.Link, 0, len(causes))
+	for _, cause := range causes {
+		record, err := s.consolidatedTable.Get(ctx, cause)
+		if err != nil {
+			return nil, fmt.Errorf("getting consolidated record %s: %w", cause, err)
+		}
+		if record.Receipt == nil {
+			return nil, fmt.Errorf("consolidated record %s has no receipt", cause)
+		}
+		receipts = append(receipts, record.Receipt.Root().Link())
+	}
+	return receipts, nil
+}
+
+// GetAttestation returns the attestation issued for the given attest invocation, or ErrAttestationNotFound
+func (s *service) GetAttestation(ctx context.Context, cause ucan.Link) (*attestations.Attestation, error) {
+	// no attestations are issued without the table
+	if s.attestationTable == nil {
+		return nil, ErrAttestationNotFound
+	}
+
+	return s.attestationTable.GetByCause(ctx, cause)
+}
@@ -0,0 +1,154 @@
+package service
+
+import (
+	"context"
+	"slices"
+	"testing"
+	"time"
+
+	capegress "github.com/storacha/go-libstoracha/capabilities/space/egress"
+	"github.com/storacha/go-libstoracha/capabilities/types"
+	"github.com/storacha/go-libstoracha/testutil"
+	"github.com/storacha/go-ucanto/core/receipt"
+	"github.com/storacha/go-ucanto/core/receipt/ran"
+	"github.com/storacha/go-ucanto/core/result"
+	"github.com/storacha/go-ucanto/did"
+	"github.com/storacha/go-ucanto/ucan"
+	"github.com/stretchr/testify/require"
+
+	"github.com/storacha/etracker/internal/capabilities/payout"
+	"github.com/storacha/etracker/internal/db/attestations"
+	"github.com/storacha/etracker/internal/db/consolidated"
+	"github.com/storacha/etracker/internal/db/payouts"
+)
+
+type mockAttestationTable struct {
+	attestations map[string]attestations.Attestation
+}
+
+func (m *mockAttestationTable) Put(ctx context.Context, attestation attestations.Attestation) error {
+	key := attestation.Node.String() + attestation.Month.Format("2006-01")
+	if _, ok := m.attestations[key]; ok {
+		return attestations.ErrAlreadyIssued
+	}
+	m.attestations[key] = attestation
+	return nil
+}
+
+func (m *mockAttestationTable) Get(ctx context.Context, node did.DID, month time.Time) (*attestations.Attestation, error) {
+	attestation, ok := m.attestations[node.String()+month.Format("2006-01")]
+	if !ok {
+		return nil, attestations.ErrNotFound
+	}
+	return &attestation, nil
+}
+
+func (m *mockAttestationTable) GetByCause(ctx context.Context, cause ucan.Link) (*attestations.Attestation, error) {
+	for _, attestation := range m.attestations {
+		if attestation.Cause.String() == cause.String() {
+			return &attestation, nil
+		}
+	}
+	return nil, attestations.ErrNotFound
+}
+
+// issueConsolidateReceipt issues the consolidate receipt of a counted batch for the given consolidate invocation
+func issueConsolidateReceipt(t *testing.T, cause ucan.Link, totalEgress uint64) receipt.AnyReceipt {
+	rcpt, err := receipt.Issue(
+		testutil.WebService,
+		result.Ok[capegress.ConsolidateOk, capegress.ConsolidateError](capegress.ConsolidateOk{TotalEgress: totalEgress}),
+		ran.FromLink(cause),
+	)
+	require.NoError(t, err)
+	return rcpt
+}
+
+func TestAttestPayoutRun(t *testing.T) {
+	march := time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC)
+
+	node1 := testutil.RandomDID(t)
+	node2 := testutil.RandomDID(t)
+	causes1 := []ucan.Link{testutil.RandomCID(t), testutil.RandomCID(t), testutil.RandomCID(t)}
+	causes2 := []ucan.Link{testutil.RandomCID(t)}
+
+	consolidatedTable := &mockConsolidatedTable{records: map[string]*consolidated.ConsolidatedRecord{}}
+	receipts := make(map[string]ucan.Link)
+	for _, cause := range append(slices.Clone(causes1), causes2...) {
+		rcpt := issueConsolidateReceipt(t, cause, 1<<30)
+		consolidatedTable.records[cause.String()] = &consolidated.ConsolidatedRecord{Cause: cause, Receipt: rcpt}
+		receipts[cause.String()] = rcpt.Root().Link()
+	}
+
+	payoutTable := &mockPayoutTable{runs: map[time.Time]payouts.Run{
+		march: {
+			Month:       march,
+			Status:      payouts.StatusDraft,
+			TotalAmount: 2 + 2.0/1024,
+			Providers:   2,
+			TotalEgress: 1<<40 + 1<<30,
+			Lines: []payouts.Line{
+				{Provider: node1, Egress: 1 << 40, Causes: causes1},
+				{Provider: node2, Egress: 1 << 30, Causes: causes2},
+			},
+		},
+	}}
+	attestationTable := &mockAttestationTable{attestations: make(map[string]attestations.Attestation)}
+
+	svc := &service{
+		id:                testutil.WebService,
+		consolidatedTable: consolidatedTable,
+		payoutTable:       payoutTable,
+		attestationTable:  attestationTable,
+	}
+
+	_, err := svc.AttestPayoutRun(context.Background(), march)
+	require.ErrorIs(t, err, ErrPayoutRunNotFinalized)
+
+	require.NoError(t, svc.FinalizePayoutRun(context.Background(), march))
+	require.Len(t, attestationTable.attestations, 2)
+
+	run, err := svc.GetPayoutRun(context.Background(), march)
+	require.NoError(t, err)
+	for _, line := range run.Lines {
+		require.NotNil(t, line.Attestation)
+	}
+
+	t.Run("attestations are signed and commit to the egress and consolidate receipts of the line", func(t *testing.T) {
+		attestation, err := svc.GetAttestation(context.Background(), run.Lines[0].Attestation)
+		require.NoError(t, err)
+		require.Equal(t, node1, attestation.Node)
+
+		rcpt, err := receipt.Rebind[payout.AttestOk, payout.AttestError](attestation.Receipt, payout.AttestOkType(), payout.AttestErrorType(), types.Converters...)
+		require.NoError(t, err)
+		require.Equal(t, testutil.WebService.DID(), rcpt.Issuer().DID())
+
+		valid, err := rcpt.VerifySignature(testutil.WebService.Unwrap().Verifier())
+		require.NoError(t, err)
+		require.True(t, valid)
+
+		ok, _ := result.Unwrap(rcpt.Out())
+		require.Equal(t, node1, ok.Node)
+		require.Equal(t, march, ok.Month)
+		require.Equal(t, uint64(1<<40), ok.TotalEgress)
+		require.Equal(t, uint64(3), ok.Causes)
+
+		// the root does not depend on the order of the receipts
+		reversed := []ucan.Link{receipts[causes1[2].String()], receipts[causes1[1].String()], receipts[causes1[0].String()]}
+		require.Equal(t, payout.MerkleRoot(reversed), ok.Root)
+		require.NotEqual(t, payout.MerkleRoot(reversed[:2]), ok.Root)
+		require.NotEqual(t, payout.MerkleRoot(causes1), ok.Root)
+	})
+
+	t.Run("attesting again does not reissue attestations", func(t *testing.T) {
+		cause := run.Lines[1].Attestation
+		delete(attestationTable.attestations, node1.String()+march.Format("2006-01"))
+
+		issued, err := svc.AttestPayoutRun(context.Background(), march)
+		require.NoError(t, err)
+		require.Equal(t, 1, issued)
+
+		attestation, err := svc.GetAttestation(context.Background(), cause)
+		require.NoError(t, err)
+		require.Equal(t, node2, attestation.Node)
+	})
+}
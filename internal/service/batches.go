@@ -0,0 +1,150 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	capegress "github.com/storacha/go-libstoracha/capabilities/space/egress"
+	"github.com/storacha/go-ucanto/core/result"
+	"github.com/storacha/go-ucanto/did"
+	"github.com/storacha/go-ucanto/ucan"
+
+	"github.com/storacha/etracker/internal/db/consolidated"
+	"github.com/storacha/etracker/internal/db/egress"
+)
+
+// BatchStatus is the stage of its lifecycle a tracked batch is at
+type BatchStatus string
+
+const (
+	// BatchUnprocessed batches are waiting to be consolidated
+	BatchUnprocessed BatchStatus = "unprocessed"
+	// BatchProcessing batches were consolidated but not yet marked as processed
+	BatchProcessing BatchStatus = "processing"
+	// BatchConsolidated batches were consolidated and their egress counted
+	BatchConsolidated BatchStatus = "consolidated"
+	// BatchFailed batches could not be consolidated or were rejected on review
+	BatchFailed BatchStatus = "failed"
+	// BatchHeld batches are held for review
+	BatchHeld BatchStatus = "held"
+)
+
+// Batch describes the lifecycle of a batch tracked by a node
+type Batch struct {
+	Batch ucan.Link
+	// Cause is the `space/egress/track` invocation the batch was tracked with
+	Cause       ucan.Link
+	Status      BatchStatus
+	ReceivedAt  time.Time
+	ProcessedAt *time.Time
+	TotalEgress uint64
+	// Rejected is the number of retrieval receipts in the batch that were not counted
+	Rejected uint64
+}
+
+type ListBatchesResult struct {
+	Batches   []Batch
+	NextToken *string
+}
+
+// ListBatches lists the batches tracked by node, most recently received first.
+// A nil period lists batches received at any time.
+func (s *service) ListBatches(ctx context.Context, node did.DID, period *Period, limit int, startToken *string) (*ListBatchesResult, error) {
+	var from, to time.Time
+	if period != nil {
+		if !period.From.Before(period.To) {
+			return nil, NewPeriodNotAcceptableError(fmt.Sprintf("'from' date %s is after or same as 'to' date %s", period.From, period.To))
+		}
+		from, to = period.From, period.To
+	}
+
+	result, err := s.egressTable.ListByNode(ctx, node, from, to, limit, startToken)
+	if err != nil {
+		return nil, err
+	}
+
+	batches := make([]Batch, 0, len(result.Batches))
+	for _, nb := range result.Batches {
+		batch, err := s.describeBatch(ctx, nb)
+		if err != nil {
+			return nil, fmt.Errorf("describing batch %s: %w", nb.Batch, err)
+		}
+		batches = append(batches, *batch)
+	}
+
+	return &ListBatchesResult{
+		Batches:   batches,
+		NextToken: result.Cursor,
+	}, nil
+}
+
+// describeBatch combines the egress record of a batch with its consolidated record, if any
+func (s *service) describeBatch(ctx context.Context, nb egress.NodeBatch) (*Batch, error) {
+	batch := &Batch{
+		Batch:      nb.Batch,
+		Cause:      nb.Cause,
+		ReceivedAt: nb.ReceivedAt,
+	}
+
+	record, err := s.consolidatedTable.GetByBatch(ctx, nb.Batch)
+	if err != nil {
+		if !errors.Is(err, consolidated.ErrNotFound) {
+			return nil, err
+		}
+
+		// a processed batch without a consolidated record was dropped by the consolidator
+		batch.Status = BatchUnprocessed
+		if nb.Processed {
+			batch.Status = BatchFailed
+		}
+		return batch, nil
+	}
+
+	processedAt := record.ProcessedAt
+	batch.ProcessedAt = &processedAt
+
+	switch record.Status {
+	case consolidated.StatusHeld:
+		batch.Status = BatchHeld
+		batch.TotalEgress = record.HeldEgress
+	case consolidated.StatusRejected:
+		batch.Status = BatchFailed
+	default:
+		ok, failed, err := readConsolidateResult(record)
+		if err != nil {
+			return nil, err
+		}
+
+		if failed {
+			batch.Status = BatchFailed
+			break
+		}
+
+		batch.Status = BatchConsolidated
+		if !nb.Processed {
+			batch.Status = BatchProcessing
+		}
+		batch.TotalEgress = record.TotalEgress
+		batch.Rejected = uint64(len(ok.Errors))
+	}
+
+	return batch, nil
+}
+
+// readConsolidateResult reads the outcome of the consolidate receipt of a record
+func readConsolidateResult(record *consolidated.ConsolidatedRecord) (capegress.ConsolidateOk, bool, error) {
+	reader, err := capegress.NewConsolidateReceiptReader()
+	if err != nil {
+		return capegress.ConsolidateOk{}, false, fmt.Errorf("constructing receipt reader: %w", err)
+	}
+
+	rcpt, err := reader.Read(record.Receipt.Root().Link(), record.Receipt.Blocks())
+	if err != nil {
+		return capegress.ConsolidateOk{}, false, fmt.Errorf("reading consolidate receipt: %w", err)
+	}
+
+	ok, x := result.Unwrap(rcpt.Out())
+	return ok, x != (capegress.ConsolidateError{}), nil
+}
@@ -0,0 +1,150 @@
+package service
+
+import (
+	"context"
+	"iter"
+	"net/url"
+	"testing"
+	"time"
+
+	capegress "github.com/storacha/go-libstoracha/capabilities/space/egress"
+	"github.com/storacha/go-libstoracha/testutil"
+	"github.com/storacha/go-ucanto/core/invocation"
+	"github.com/storacha/go-ucanto/core/receipt"
+	"github.com/storacha/go-ucanto/core/receipt/ran"
+	"github.com/storacha/go-ucanto/core/result"
+	"github.com/storacha/go-ucanto/did"
+	"github.com/storacha/go-ucanto/ucan"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/storacha/etracker/internal/db/consolidated"
+	"github.com/storacha/etracker/internal/db/egress"
+)
+
+type mockEgressTable struct {
+	batches []egress.NodeBatch
+}
+
+func (m *mockEgressTable) Record(ctx context.Context, batch ucan.Link, node did.DID, endpoint *url.URL, cause invocation.Invocation) error {
+	return nil
+}
+
+func (m *mockEgressTable) GetByCause(ctx context.Context, cause ucan.Link) (*egress.EgressRecord, error) {
+	return nil, egress.ErrNotFound
+}
+
+func (m *mockEgressTable) ListUnprocessed(ctx context.Context, pageSize int) iter.Seq2[egress.EgressRecord, error] {
+	return func(yield func(egress.EgressRecord, error) bool) {}
+}
+
+func (m *mockEgressTable) MarkAsProcessed(ctx context.Context, records []egress.EgressRecord) error {
+	return nil
+}
+
+func (m *mockEgressTable) CountUnprocessedBatches(ctx context.Context) (int64, error) {
+	return 0, nil
+}
+
+func (m *mockEgressTable) ListByNode(ctx context.Context, node did.DID, from, to time.Time, limit int, cursor *string) (*egress.ListByNodeResult, error) {
+	return &egress.ListByNodeResult{Batches: m.batches}, nil
+}
+
+var _ egress.EgressTable = (*mockEgressTable)(nil)
+
+func TestListBatches(t *testing.T) {
+	id := testutil.RandomSigner(t)
+	node := testutil.RandomDID(t)
+	receivedAt := time.Date(2025, 6, 1, 0, 0, 0, 0, time.UTC)
+	processedAt := receivedAt.Add(time.Hour)
+
+	issue := func(t *testing.T, out result.Result[capegress.ConsolidateOk, capegress.ConsolidateError]) receipt.AnyReceipt {
+		rcpt, err := receipt.Issue(id, out, ran.FromLink(testutil.RandomCID(t)))
+		require.NoError(t, err)
+		return rcpt
+	}
+
+	okReceipt := issue(t, result.Ok[capegress.ConsolidateOk, capegress.ConsolidateError](capegress.ConsolidateOk{
+		TotalEgress: 1000,
+		Errors: []capegress.ReceiptError{
+			{Name: "InvalidReceipt", Message: "bad signature", Receipt: testutil.RandomCID(t)},
+		},
+	}))
+	errReceipt := issue(t, result.Error[capegress.ConsolidateOk](capegress.NewConsolidateError("fetching batch failed")))
+
+	unprocessed := testutil.RandomCID(t)
+	processing := testutil.RandomCID(t)
+	counted := testutil.RandomCID(t)
+	failed := testutil.RandomCID(t)
+	dropped := testutil.RandomCID(t)
+	held := testutil.RandomCID(t)
+	rejected := testutil.RandomCID(t)
+
+	egressTable := &mockEgressTable{}
+	for _, b := range []ucan.Link{unprocessed, processing} {
+		egressTable.batches = append(egressTable.batches, egress.NodeBatch{Batch: b, ReceivedAt: receivedAt})
+	}
+	for _, b := range []ucan.Link{counted, failed, dropped, held, rejected} {
+		egressTable.batches = append(egressTable.batches, egress.NodeBatch{Batch: b, ReceivedAt: receivedAt, Processed: true})
+	}
+
+	newRecord := func(batch ucan.Link, status consolidated.Status, rcpt receipt.AnyReceipt) *consolidated.ConsolidatedRecord {
+		return &consolidated.ConsolidatedRecord{
+			Cause:       testutil.RandomCID(t),
+			Batch:       batch,
+			Node:        node,
+			Status:      status,
+			Receipt:     rcpt,
+			TotalEgress: 1000,
+			HeldEgress:  2000,
+			ProcessedAt: processedAt,
+		}
+	}
+
+	consolidatedTable := &mockConsolidatedTable{records: map[string]*consolidated.ConsolidatedRecord{}}
+	for _, r := range []*consolidated.ConsolidatedRecord{
+		newRecord(processing, consolidated.StatusCounted, okReceipt),
+		newRecord(counted, consolidated.StatusApproved, okReceipt),
+		newRecord(failed, consolidated.StatusCounted, errReceipt),
+		newRecord(held, consolidated.StatusHeld, okReceipt),
+		newRecord(rejected, consolidated.StatusRejected, errReceipt),
+	} {
+		consolidatedTable.records[r.Cause.String()] = r
+	}
+
+	svc := &service{id: id, egressTable: egressTable, consolidatedTable: consolidatedTable}
+
+	t.Run("maps batches to their lifecycle status", func(t *testing.T) {
+		res, err := svc.ListBatches(context.Background(), node, nil, 100, nil)
+		require.NoError(t, err)
+		require.Len(t, res.Batches, 7)
+
+		statuses := make(map[string]Batch, len(res.Batches))
+		for _, b := range res.Batches {
+			statuses[b.Batch.String()] = b
+		}
+
+		assert.Equal(t, BatchUnprocessed, statuses[unprocessed.String()].Status)
+		assert.Nil(t, statuses[unprocessed.String()].ProcessedAt)
+		assert.Equal(t, BatchProcessing, statuses[processing.String()].Status)
+		assert.Equal(t, BatchFailed, statuses[failed.String()].Status)
+		assert.Equal(t, BatchFailed, statuses[dropped.String()].Status)
+		assert.Equal(t, BatchFailed, statuses[rejected.String()].Status)
+
+		assert.Equal(t, BatchHeld, statuses[held.String()].Status)
+		assert.Equal(t, uint64(2000), statuses[held.String()].TotalEgress)
+
+		c := statuses[counted.String()]
+		assert.Equal(t, BatchConsolidated, c.Status)
+		assert.Equal(t, uint64(1000), c.TotalEgress)
+		assert.Equal(t, uint64(1), c.Rejected)
+		require.NotNil(t, c.ProcessedAt)
+		assert.Equal(t, processedAt, *c.ProcessedAt)
+	})
+
+	t.Run("rejects empty periods", func(t *testing.T) {
+		_, err := svc.ListBatches(context.Background(), node, &Period{From: receivedAt, To: receivedAt}, 100, nil)
+		var periodErr ErrPeriodNotAcceptable
+		require.ErrorAs(t, err, &periodErr)
+	})
+}
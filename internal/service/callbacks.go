@@ -0,0 +1,52 @@
+package service
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/storacha/go-ucanto/did"
+
+	"github.com/storacha/etracker/internal/callback"
+	"github.com/storacha/etracker/internal/db/callbacks"
+)
+
+type GetCallbacksResult struct {
+	Registrations []callbacks.Registration
+	NextToken     *string
+}
+
+// GetCallbacks lists the callback URLs registered for nodes
+func (s *service) GetCallbacks(ctx context.Context, limit int, startToken *string) (*GetCallbacksResult, error) {
+	if s.callbackTable == nil {
+		return nil, fmt.Errorf("callbacks are %w", ErrNotConfigured)
+	}
+
+	result, err := s.callbackTable.List(ctx, limit, startToken)
+	if err != nil {
+		return nil, err
+	}
+
+	return &GetCallbacksResult{
+		Registrations: result.Registrations,
+		NextToken:     result.Cursor,
+	}, nil
+}
+
+// SetCallback registers the URL the consolidation results of node are pushed to.
+// An empty URL removes the registration.
+func (s *service) SetCallback(ctx context.Context, node did.DID, callbackURL string) error {
+	if s.callbackTable == nil {
+		return fmt.Errorf("callbacks are %w", ErrNotConfigured)
+	}
+
+	if callbackURL == "" {
+		return s.callbackTable.Delete(ctx, node)
+	}
+
+	u, err := callback.ParseURL(callbackURL)
+	if err != nil {
+		return err
+	}
+
+	return s.callbackTable.Put(ctx, node, u)
+}
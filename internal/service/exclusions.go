@@ -0,0 +1,54 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/storacha/go-ucanto/did"
+
+	"github.com/storacha/etracker/internal/db/consumer"
+)
+
+// ExcludedSpaceStats is the egress of a space excluded from billing and payouts
+type ExcludedSpaceStats struct {
+	Space did.DID
+	// Account is the account owning the space, undefined if the space has no consumer record
+	Account    did.DID
+	Stats      *Stats
+	StatsError error
+}
+
+// GetExcludedSpacesStats returns the egress recorded for each excluded space in the usual periods
+func (s *service) GetExcludedSpacesStats(ctx context.Context) ([]ExcludedSpaceStats, error) {
+	spaces := s.exclusions.Spaces()
+	result := make([]ExcludedSpaceStats, 0, len(spaces))
+	for _, space := range spaces {
+		entry := ExcludedSpaceStats{Space: space}
+
+		c, err := s.consumerTable.Get(ctx, space.String())
+		switch {
+		case err == nil:
+			entry.Account = c.Customer
+		case !errors.Is(err, consumer.ErrNotFound):
+			return nil, fmt.Errorf("getting consumer %s: %w", space, err)
+		}
+
+		now := time.Now().UTC()
+		stats := NewStats(now)
+		dailyStats, err := s.exclusions.StatsTable().GetDailyStats(ctx, space, stats.Earliest(), now)
+		if err != nil {
+			entry.StatsError = err
+		} else {
+			for _, stat := range dailyStats {
+				stats.AddEgress(stat.Egress, stat.Date)
+			}
+			entry.Stats = stats
+		}
+
+		result = append(result, entry)
+	}
+
+	return result, nil
+}
@@ -0,0 +1,221 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/storacha/go-ucanto/did"
+)
+
+// Granularity is the size of the buckets egress stats are aggregated in
+type Granularity string
+
+const (
+	// GranularityAuto picks the finest granularity allowed for the requested period
+	GranularityAuto  Granularity = ""
+	GranularityHour  Granularity = "hour"
+	GranularityDay   Granularity = "day"
+	GranularityWeek  Granularity = "week"
+	GranularityMonth Granularity = "month"
+)
+
+const (
+	// maxHourlyPeriodDays is the longest period served at hourly granularity
+	maxHourlyPeriodDays = 7
+	// maxDailyPeriodDays is the longest period served at daily granularity
+	maxDailyPeriodDays = 60
+	// maxWeeklyPeriodDays is the longest period served at weekly granularity
+	maxWeeklyPeriodDays = 366
+	// autoWeeklyPeriodDays is the longest period automatically served at weekly granularity, longer ones are monthly
+	autoWeeklyPeriodDays = 180
+	// maxPeriodDays is the longest period that can be requested at all
+	maxPeriodDays = 5 * 366
+)
+
+// ParseGranularity parses a granularity name, the empty string meaning automatic
+func ParseGranularity(s string) (Granularity, error) {
+	switch g := Granularity(s); g {
+	case GranularityAuto, GranularityHour, GranularityDay, GranularityWeek, GranularityMonth:
+		return g, nil
+	default:
+		return "", fmt.Errorf("unknown granularity %q", s)
+	}
+}
+
+// resolveGranularity checks the requested granularity is allowed for the period, choosing one if none was requested.
+// Hourly granularity is never chosen automatically.
+func resolveGranularity(period Period, granularity Granularity) (Granularity, error) {
+	days := int(period.To.Sub(period.From).Hours() / 24)
+
+	switch granularity {
+	case GranularityHour:
+		if period.To.Sub(period.From) > maxHourlyPeriodDays*24*time.Hour {
+			return "", NewPeriodNotAcceptableError(fmt.Sprintf("requested period exceeds maximum of %d days at hourly granularity", maxHourlyPeriodDays))
+		}
+	case GranularityAuto:
+		switch {
+		case days <= maxDailyPeriodDays:
+			return GranularityDay, nil
+		case days <= autoWeeklyPeriodDays:
+			return GranularityWeek, nil
+		default:
+			return GranularityMonth, nil
+		}
+	case GranularityDay:
+		if days > maxDailyPeriodDays {
+			return "", NewPeriodNotAcceptableError(fmt.Sprintf("requested period exceeds maximum of %d days at daily granularity", maxDailyPeriodDays))
+		}
+	case GranularityWeek:
+		if days > maxWeeklyPeriodDays {
+			return "", NewPeriodNotAcceptableError(fmt.Sprintf("requested period exceeds maximum of %d days at weekly granularity", maxWeeklyPeriodDays))
+		}
+	case GranularityMonth:
+	default:
+		return "", NewPeriodNotAcceptableError(fmt.Sprintf("unknown granularity %q", granularity))
+	}
+
+	return granularity, nil
+}
+
+// getSpaceStats returns the egress of a space in the period, aggregated at the given granularity.
+// Each stat is dated with the start of its bucket, weeks starting on Monday.
+func (s *service) getSpaceStats(ctx context.Context, space did.DID, period Period, granularity Granularity) ([]DailyStat, error) {
+	switch granularity {
+	case GranularityHour:
+		return s.getHourlyStats(ctx, space, period.From, period.To)
+	case GranularityWeek:
+		dailyStats, err := s.getDailyStats(ctx, space, period.From, period.To)
+		if err != nil {
+			return nil, err
+		}
+		return bucket(dailyStats, startOfWeek), nil
+	case GranularityMonth:
+		return s.getMonthlyStats(ctx, space, period)
+	default:
+		return s.getDailyStats(ctx, space, period.From, period.To)
+	}
+}
+
+func (s *service) getDailyStats(ctx context.Context, space did.DID, from, to time.Time) ([]DailyStat, error) {
+	dailyStatsDB, err := s.spaceStatsTable.GetDailyStats(ctx, space, from, to)
+	if err != nil {
+		return nil, err
+	}
+
+	dailyStats := make([]DailyStat, 0, len(dailyStatsDB))
+	for _, dbStat := range dailyStatsDB {
+		dailyStats = append(dailyStats, DailyStat{
+			Date:   dbStat.Date,
+			Egress: dbStat.Egress,
+		})
+	}
+
+	return dailyStats, nil
+}
+
+func (s *service) getHourlyStats(ctx context.Context, space did.DID, from, to time.Time) ([]DailyStat, error) {
+	if s.hourlyStatsTable == nil {
+		return nil, fmt.Errorf("hourly stats are not available")
+	}
+
+	hourlyStatsDB, err := s.hourlyStatsTable.GetHourlyStats(ctx, space, from, to)
+	if err != nil {
+		return nil, err
+	}
+
+	hourlyStats := make([]DailyStat, 0, len(hourlyStatsDB))
+	for _, dbStat := range hourlyStatsDB {
+		hourlyStats = append(hourlyStats, DailyStat{
+			Date:   dbStat.Hour,
+			Egress: dbStat.Egress,
+		})
+	}
+
+	return hourlyStats, nil
+}
+
+// getMonthlyStats reads the rollups of the months fully covered by the period, and the daily stats of the
+// partially covered months at either end
+func (s *service) getMonthlyStats(ctx context.Context, space did.DID, period Period) ([]DailyStat, error) {
+	from := truncateDay(period.From)
+	to := truncateDay(period.To)
+
+	// first day of the first full month, and first day of the month after the last full month
+	firstFull := startOfMonth(from)
+	if !firstFull.Equal(from) {
+		firstFull = firstFull.AddDate(0, 1, 0)
+	}
+	afterFull := startOfMonth(to.AddDate(0, 0, 1))
+
+	if !firstFull.Before(afterFull) {
+		dailyStats, err := s.getDailyStats(ctx, space, from, to)
+		if err != nil {
+			return nil, err
+		}
+		return bucket(dailyStats, startOfMonth), nil
+	}
+
+	var stats []DailyStat
+	if from.Before(firstFull) {
+		leading, err := s.getDailyStats(ctx, space, from, firstFull.AddDate(0, 0, -1))
+		if err != nil {
+			return nil, err
+		}
+		stats = append(stats, leading...)
+	}
+
+	monthlyStats, err := s.spaceStatsTable.GetMonthlyStats(ctx, space, firstFull, afterFull.AddDate(0, 0, -1))
+	if err != nil {
+		return nil, err
+	}
+	for _, ms := range monthlyStats {
+		stats = append(stats, DailyStat{Date: ms.Month, Egress: ms.Egress})
+	}
+
+	if !to.Before(afterFull) {
+		trailing, err := s.getDailyStats(ctx, space, afterFull, to)
+		if err != nil {
+			return nil, err
+		}
+		stats = append(stats, trailing...)
+	}
+
+	return bucket(stats, startOfMonth), nil
+}
+
+// bucket sums stats into buckets starting at the dates returned by start, in chronological order
+func bucket(stats []DailyStat, start func(time.Time) time.Time) []DailyStat {
+	buckets := make(map[time.Time]uint64)
+	for _, stat := range stats {
+		buckets[start(stat.Date)] += stat.Egress
+	}
+
+	bucketed := make([]DailyStat, 0, len(buckets))
+	for date, egress := range buckets {
+		bucketed = append(bucketed, DailyStat{Date: date, Egress: egress})
+	}
+	slices.SortFunc(bucketed, func(a, b DailyStat) int {
+		return a.Date.Compare(b.Date)
+	})
+
+	return bucketed
+}
+
+func truncateDay(t time.Time) time.Time {
+	t = t.UTC()
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+}
+
+func startOfWeek(t time.Time) time.Time {
+	day := truncateDay(t)
+	// Monday is the first day of the week, as in Stats
+	offset := (int(day.Weekday()) + 6) % 7
+	return day.AddDate(0, 0, -offset)
+}
+
+func startOfMonth(t time.Time) time.Time {
+	t = t.UTC()
+	return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.UTC)
+}
@@ -0,0 +1,227 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/storacha/go-ucanto/did"
+	"github.com/storacha/go-ucanto/ucan"
+
+	"github.com/storacha/etracker/internal/db/consolidated"
+	"github.com/storacha/etracker/internal/db/consumer"
+	"github.com/storacha/etracker/internal/db/ledger"
+)
+
+// ActorAdmin is the actor of the ledger transactions posted on review of held batches
+const ActorAdmin = "admin"
+
+var ErrBatchHeld = errors.New("batch is held for review, reject it instead")
+
+// LedgerAdjustment is a correcting entry posted to the book of a node or an account by an admin
+type LedgerAdjustment struct {
+	Book ledger.Book
+	// Space is the space the adjustment is for, if any
+	Space did.DID
+	// Egress increases the egress served by a node or consumed by an account when positive, decreases it
+	// when negative
+	Egress int64
+	// EffectiveAt is when the adjustment is accounted for, now if zero
+	EffectiveAt time.Time
+	Actor       string
+	Reason      string
+	// Cause is the consolidate invocation of the batch the adjustment corrects, if any
+	Cause ucan.Link
+}
+
+// LedgerBalance is the egress posted to a book over a period
+type LedgerBalance struct {
+	Book   ledger.Book
+	Period Period
+	// AsOf is the time the balance was derived as of, transactions posted later are left out
+	AsOf time.Time
+	// Egress is the egress served by a node or consumed by an account
+	Egress int64
+	// Credited, Reversed and Adjusted break Egress down by the kind of the transactions it was posted by
+	Credited int64
+	Reversed int64
+	Adjusted int64
+	Entries  []ledger.BookEntry
+}
+
+// GetLedgerBalance derives the balance of the book from the entries taking effect in the period. A zero asOf
+// includes every entry, otherwise entries posted after it are left out, so that the balance of a period can be
+// compared before and after it was restated.
+func (s *service) GetLedgerBalance(ctx context.Context, book ledger.Book, period Period, asOf time.Time) (*LedgerBalance, error) {
+	if s.ledgerTable == nil {
+		return nil, fmt.Errorf("the ledger is %w", ErrNotConfigured)
+	}
+
+	if !period.From.Before(period.To) {
+		return nil, NewPeriodNotAcceptableError(fmt.Sprintf("'from' date %s is after or same as 'to' date %s", period.From, period.To))
+	}
+
+	balance := &LedgerBalance{
+		Book:   book,
+		Period: period,
+		AsOf:   asOf,
+	}
+	for entry, err := range s.ledgerTable.GetEntries(ctx, book, period.From, period.To) {
+		if err != nil {
+			return nil, err
+		}
+		if !asOf.IsZero() && entry.PostedAt.After(asOf) {
+			continue
+		}
+
+		egress := book.Sign() * entry.Egress
+		balance.Egress += egress
+		switch entry.Kind {
+		case ledger.KindCredit:
+			balance.Credited += egress
+		case ledger.KindReversal:
+			balance.Reversed += egress
+		case ledger.KindAdjustment:
+			balance.Adjusted += egress
+		}
+		balance.Entries = append(balance.Entries, entry)
+	}
+
+	return balance, nil
+}
+
+// PostLedgerAdjustment posts a correcting entry to the book of a node or an account, balanced by an entry
+// to the adjustments book
+func (s *service) PostLedgerAdjustment(ctx context.Context, adjustment LedgerAdjustment) (*ledger.Transaction, error) {
+	if s.ledgerTable == nil {
+		return nil, fmt.Errorf("the ledger is %w", ErrNotConfigured)
+	}
+
+	if _, err := ledger.ParseBook(string(adjustment.Book)); err != nil {
+		return nil, err
+	}
+	if adjustment.Egress == 0 {
+		return nil, errors.New("an adjustment must change the egress of the book")
+	}
+	if adjustment.Actor == "" || adjustment.Reason == "" {
+		return nil, errors.New("an actor and a reason are required to post an adjustment")
+	}
+
+	now := time.Now().UTC()
+	effectiveAt := adjustment.EffectiveAt
+	if effectiveAt.IsZero() {
+		effectiveAt = now
+	}
+
+	egress := adjustment.Book.Sign() * adjustment.Egress
+	tx := ledger.Transaction{
+		ID:          ledger.AdjustmentID(adjustment.Book, now),
+		Kind:        ledger.KindAdjustment,
+		Actor:       adjustment.Actor,
+		Reason:      adjustment.Reason,
+		Cause:       adjustment.Cause,
+		EffectiveAt: effectiveAt,
+		PostedAt:    now,
+		Entries: []ledger.Entry{
+			{Book: adjustment.Book, Space: adjustment.Space, Egress: egress},
+			{Book: ledger.AdjustmentsBook, Egress: -egress},
+		},
+	}
+	if err := s.ledgerTable.Post(ctx, tx); err != nil {
+		return nil, fmt.Errorf("posting adjustment: %w", err)
+	}
+
+	log.Infow("Posted ledger adjustment", "book", adjustment.Book, "egress", adjustment.Egress, "actor", adjustment.Actor, "reason", adjustment.Reason)
+
+	return &tx, nil
+}
+
+// ReverseBatch claws back the egress credited for a consolidated batch, for instance when it turns out to be
+// fraudulent. The reversal takes effect when the credit did, restating the balances of that period. Held batches
+// are reversed by rejecting them.
+func (s *service) ReverseBatch(ctx context.Context, cause ucan.Link, actor, reason string) (*ledger.Transaction, error) {
+	if s.ledgerTable == nil {
+		return nil, fmt.Errorf("the ledger is %w", ErrNotConfigured)
+	}
+
+	if actor == "" || reason == "" {
+		return nil, errors.New("an actor and a reason are required to reverse a batch")
+	}
+
+	record, err := s.consolidatedTable.Get(ctx, cause)
+	if err != nil {
+		return nil, err
+	}
+
+	if record.Status == consolidated.StatusHeld {
+		return nil, ErrBatchHeld
+	}
+
+	tx, err := s.reverseCredit(ctx, record, actor, reason)
+	if err != nil {
+		return nil, err
+	}
+
+	log.Infow("Reversed batch credit", "cause", cause, "node", record.Node, "actor", actor, "reason", reason)
+
+	return tx, nil
+}
+
+// reverseCredit posts the reversal of the credit of the batch, posting the credit first if it is missing
+func (s *service) reverseCredit(ctx context.Context, record *consolidated.ConsolidatedRecord, actor, reason string) (*ledger.Transaction, error) {
+	credit, err := s.batchCredit(ctx, record)
+	if err != nil {
+		return nil, err
+	}
+
+	tx := ledger.NewReversal(actor, reason, *credit)
+	tx.PostedAt = time.Now().UTC()
+	if err := s.ledgerTable.Post(ctx, tx); err != nil {
+		return nil, fmt.Errorf("posting reversal: %w", err)
+	}
+
+	return &tx, nil
+}
+
+// batchCredit returns the ledger credit of the consolidated batch. Credits are posted by the consolidator, the
+// credit is posted here if the consolidator was interrupted before it could.
+func (s *service) batchCredit(ctx context.Context, record *consolidated.ConsolidatedRecord) (*ledger.Transaction, error) {
+	credit, err := s.ledgerTable.Get(ctx, ledger.CreditID(record.Cause))
+	if err == nil {
+		return credit, nil
+	}
+	if !errors.Is(err, ledger.ErrNotFound) {
+		return nil, fmt.Errorf("getting batch credit: %w", err)
+	}
+
+	accounts := make(map[did.DID]did.DID, len(record.SpaceEgress))
+	for space := range record.SpaceEgress {
+		c, err := s.consumerTable.Get(ctx, space.String())
+		if err != nil {
+			if errors.Is(err, consumer.ErrNotFound) {
+				continue
+			}
+			return nil, fmt.Errorf("getting consumer %s: %w", space, err)
+		}
+		if c.Customer != did.Undef {
+			accounts[space] = c.Customer
+		}
+	}
+
+	totalEgress := record.TotalEgress
+	if record.Status != consolidated.StatusCounted {
+		totalEgress = record.HeldEgress
+	}
+
+	tx := ledger.NewCredit(s.id.DID().String(), record.Cause, record.Node, totalEgress, record.SpaceEgress, accounts, record.ProcessedAt)
+	tx.PostedAt = time.Now().UTC()
+	if err := s.ledgerTable.Post(ctx, tx); err != nil {
+		if errors.Is(err, ledger.ErrAlreadyPosted) {
+			return s.ledgerTable.Get(ctx, tx.ID)
+		}
+		return nil, fmt.Errorf("posting batch credit: %w", err)
+	}
+
+	return &tx, nil
+}
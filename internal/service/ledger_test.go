@@ -0,0 +1,207 @@
+package service
+
+import (
+	"context"
+	"iter"
+	"slices"
+	"testing"
+	"time"
+
+	capegress "github.com/storacha/go-libstoracha/capabilities/space/egress"
+	"github.com/storacha/go-libstoracha/testutil"
+	"github.com/storacha/go-ucanto/did"
+	"github.com/stretchr/testify/require"
+
+	"github.com/storacha/etracker/internal/db/consolidated"
+	"github.com/storacha/etracker/internal/db/consumer"
+	"github.com/storacha/etracker/internal/db/ledger"
+)
+
+type mockLedgerTable struct {
+	transactions []ledger.Transaction
+}
+
+func (m *mockLedgerTable) Post(ctx context.Context, tx ledger.Transaction) error {
+	if !tx.Balanced() {
+		return ledger.ErrUnbalanced
+	}
+	if _, err := m.Get(ctx, tx.ID); err == nil {
+		return ledger.ErrAlreadyPosted
+	}
+	m.transactions = append(m.transactions, tx)
+	return nil
+}
+
+func (m *mockLedgerTable) Get(ctx context.Context, id string) (*ledger.Transaction, error) {
+	for _, tx := range m.transactions {
+		if tx.ID == id {
+			return &tx, nil
+		}
+	}
+	return nil, ledger.ErrNotFound
+}
+
+func (m *mockLedgerTable) GetEntries(ctx context.Context, book ledger.Book, from, to time.Time) iter.Seq2[ledger.BookEntry, error] {
+	return func(yield func(ledger.BookEntry, error) bool) {
+		for _, tx := range m.transactions {
+			if tx.EffectiveAt.Before(from) || !tx.EffectiveAt.Before(to) {
+				continue
+			}
+			for _, e := range tx.Entries {
+				if e.Book != book {
+					continue
+				}
+				entry := ledger.BookEntry{Entry: e, Transaction: tx.ID, Kind: tx.Kind, Actor: tx.Actor, Reason: tx.Reason, Cause: tx.Cause, EffectiveAt: tx.EffectiveAt, PostedAt: tx.PostedAt}
+				if !yield(entry, nil) {
+					return
+				}
+			}
+		}
+	}
+}
+
+var _ ledger.LedgerTable = (*mockLedgerTable)(nil)
+
+func TestLedger(t *testing.T) {
+	march := time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC)
+	period := Period{From: march, To: march.AddDate(0, 1, 0)}
+
+	node := testutil.RandomDID(t)
+	account := testutil.RandomDID(t)
+	space1 := testutil.RandomDID(t)
+	space2 := testutil.RandomDID(t)
+	countedCause := testutil.RandomCID(t)
+	heldCause := testutil.RandomCID(t)
+
+	newService := func() (*service, *mockLedgerTable) {
+		ledgerTable := &mockLedgerTable{}
+		consolidatedTable := &mockConsolidatedTable{
+			records: map[string]*consolidated.ConsolidatedRecord{
+				countedCause.String(): {
+					Cause:       countedCause,
+					Node:        node,
+					Status:      consolidated.StatusCounted,
+					TotalEgress: 1500,
+					SpaceEgress: map[did.DID]uint64{space1: 1000, space2: 500},
+					ProcessedAt: march.AddDate(0, 0, 4),
+				},
+				heldCause.String(): {
+					Cause:       heldCause,
+					Node:        node,
+					Status:      consolidated.StatusHeld,
+					HeldEgress:  700,
+					SpaceEgress: map[did.DID]uint64{space1: 700},
+					ProcessedAt: march.AddDate(0, 0, 9),
+				},
+			},
+			rejected: make(map[string]capegress.ConsolidateReceipt),
+		}
+		// space2 is not owned by any account
+		consumerTable := &mockConsumerTable{
+			getFunc: func(ctx context.Context, consumerID string) (consumer.Consumer, error) {
+				if consumerID == space1.String() {
+					return consumer.Consumer{ID: space1, Customer: account}, nil
+				}
+				return consumer.Consumer{}, consumer.ErrNotFound
+			},
+		}
+
+		svc := &service{
+			id:                testutil.WebService,
+			consolidatedTable: consolidatedTable,
+			consumerTable:     consumerTable,
+			ledgerTable:       ledgerTable,
+		}
+
+		// the consolidator credits both batches, held or not
+		for _, record := range consolidatedTable.records {
+			_, err := svc.batchCredit(context.Background(), record)
+			require.NoError(t, err)
+		}
+
+		return svc, ledgerTable
+	}
+
+	balance := func(t *testing.T, svc *service, book ledger.Book, asOf time.Time) *LedgerBalance {
+		b, err := svc.GetLedgerBalance(context.Background(), book, period, asOf)
+		require.NoError(t, err)
+		return b
+	}
+
+	t.Run("credits balance node and account books", func(t *testing.T) {
+		svc, ledgerTable := newService()
+
+		require.Equal(t, int64(2200), balance(t, svc, ledger.NodeBook(node), time.Time{}).Egress)
+		require.Equal(t, int64(1700), balance(t, svc, ledger.AccountBook(account), time.Time{}).Egress)
+
+		unassigned := balance(t, svc, ledger.UnassignedBook, time.Time{})
+		require.Equal(t, int64(500), unassigned.Egress)
+		require.Equal(t, space2, unassigned.Entries[0].Space)
+
+		for _, tx := range ledgerTable.transactions {
+			require.True(t, tx.Balanced())
+		}
+	})
+
+	t.Run("rejecting a held batch reverses its credit", func(t *testing.T) {
+		svc, ledgerTable := newService()
+
+		require.NoError(t, svc.RejectBatch(context.Background(), heldCause, "duplicate receipts"))
+
+		reversal, err := ledgerTable.Get(context.Background(), ledger.ReversalID(heldCause))
+		require.NoError(t, err)
+		require.Equal(t, ActorAdmin, reversal.Actor)
+		require.Equal(t, "duplicate receipts", reversal.Reason)
+		require.Equal(t, ledger.CreditID(heldCause), reversal.Reverses)
+
+		b := balance(t, svc, ledger.NodeBook(node), time.Time{})
+		require.Equal(t, int64(1500), b.Egress)
+		require.Equal(t, int64(-700), b.Reversed)
+	})
+
+	t.Run("clawbacks restate the period of the credit", func(t *testing.T) {
+		svc, _ := newService()
+		before := time.Now()
+
+		_, err := svc.ReverseBatch(context.Background(), heldCause, "alice", "fraud")
+		require.ErrorIs(t, err, ErrBatchHeld)
+
+		tx, err := svc.ReverseBatch(context.Background(), countedCause, "alice", "fraud")
+		require.NoError(t, err)
+		require.Equal(t, march.AddDate(0, 0, 4), tx.EffectiveAt)
+
+		_, err = svc.ReverseBatch(context.Background(), countedCause, "alice", "fraud")
+		require.ErrorIs(t, err, ledger.ErrAlreadyPosted)
+
+		require.Equal(t, int64(700), balance(t, svc, ledger.NodeBook(node), time.Time{}).Egress)
+		require.Equal(t, int64(700), balance(t, svc, ledger.AccountBook(account), time.Time{}).Egress)
+
+		// the balance as of before the clawback is unchanged
+		require.Equal(t, int64(2200), balance(t, svc, ledger.NodeBook(node), before).Egress)
+	})
+
+	t.Run("adjustments are balanced by the adjustments book", func(t *testing.T) {
+		svc, ledgerTable := newService()
+
+		_, err := svc.PostLedgerAdjustment(context.Background(), LedgerAdjustment{Book: ledger.AccountBook(account), Egress: 100, Reason: "missing"})
+		require.Error(t, err)
+
+		tx, err := svc.PostLedgerAdjustment(context.Background(), LedgerAdjustment{
+			Book:        ledger.AccountBook(account),
+			Space:       space1,
+			Egress:      -200,
+			EffectiveAt: march.AddDate(0, 0, 20),
+			Actor:       "alice",
+			Reason:      "goodwill credit",
+		})
+		require.NoError(t, err)
+		require.True(t, slices.ContainsFunc(ledgerTable.transactions, func(posted ledger.Transaction) bool {
+			return posted.ID == tx.ID
+		}))
+
+		b := balance(t, svc, ledger.AccountBook(account), time.Time{})
+		require.Equal(t, int64(1500), b.Egress)
+		require.Equal(t, int64(-200), b.Adjusted)
+		require.Equal(t, int64(200), balance(t, svc, ledger.AdjustmentsBook, time.Time{}).Egress)
+	})
+}
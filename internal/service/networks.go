@@ -0,0 +1,79 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/storacha/go-ucanto/did"
+)
+
+// NetworkDay is the egress of each network on a day
+type NetworkDay struct {
+	Date   time.Time
+	Egress map[did.DID]uint64
+}
+
+// NetworkStats is the egress of the spaces provisioned through each upload service (network) over a period
+type NetworkStats struct {
+	Period   Period
+	Networks []did.DID
+	Totals   map[did.DID]uint64
+	Total    uint64
+	// Days holds every day of the period, including the ones without egress
+	Days []NetworkDay
+}
+
+// Share returns the percentage of the egress of the period served to the spaces of the network
+func (n *NetworkStats) Share(network did.DID) float64 {
+	if n.Total == 0 {
+		return 0
+	}
+	return float64(n.Totals[network]) / float64(n.Total) * 100
+}
+
+// GetNetworkStats returns the daily egress of each known network from the start of the period up to, but not
+// including, its end.
+func (s *service) GetNetworkStats(ctx context.Context, period Period) (*NetworkStats, error) {
+	if s.networkStatsTable == nil {
+		return nil, fmt.Errorf("network stats are %w", ErrNotConfigured)
+	}
+
+	from := period.From.UTC().Truncate(24 * time.Hour)
+	to := period.To.UTC().Truncate(24 * time.Hour)
+	if !from.Before(to) {
+		return nil, NewPeriodNotAcceptableError(fmt.Sprintf("'from' date %s is after or same as 'to' date %s", period.From, period.To))
+	}
+	if to.Sub(from) > maxPeriodDays*24*time.Hour {
+		return nil, NewPeriodNotAcceptableError(fmt.Sprintf("requested period exceeds maximum of %d days", maxPeriodDays))
+	}
+
+	stats := &NetworkStats{
+		Period:   Period{From: from, To: to},
+		Networks: s.networks,
+		Totals:   make(map[did.DID]uint64, len(s.networks)),
+	}
+	days := make(map[time.Time]map[did.DID]uint64)
+	for day := from; day.Before(to); day = day.AddDate(0, 0, 1) {
+		days[day] = make(map[did.DID]uint64, len(s.networks))
+		stats.Days = append(stats.Days, NetworkDay{Date: day, Egress: days[day]})
+	}
+
+	for _, network := range s.networks {
+		dailyStats, err := s.networkStatsTable.GetDailyStats(ctx, network, from, to.AddDate(0, 0, -1))
+		if err != nil {
+			return nil, fmt.Errorf("getting stats of network %s: %w", network, err)
+		}
+		for _, stat := range dailyStats {
+			egress, ok := days[stat.Date.UTC()]
+			if !ok {
+				continue
+			}
+			egress[network] += stat.Egress
+			stats.Totals[network] += stat.Egress
+			stats.Total += stat.Egress
+		}
+	}
+
+	return stats, nil
+}
@@ -0,0 +1,102 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/storacha/go-libstoracha/testutil"
+	"github.com/storacha/go-ucanto/did"
+	"github.com/stretchr/testify/require"
+
+	"github.com/storacha/etracker/internal/db/consumer"
+	"github.com/storacha/etracker/internal/db/networkstats"
+)
+
+type mockNetworkStatsTable struct {
+	stats map[did.DID]map[time.Time]uint64
+}
+
+func (m *mockNetworkStatsTable) Record(ctx context.Context, network did.DID, egress uint64, at time.Time, id string) error {
+	if m.stats[network] == nil {
+		m.stats[network] = make(map[time.Time]uint64)
+	}
+	m.stats[network][at.UTC().Truncate(24*time.Hour)] += egress
+	return nil
+}
+
+func (m *mockNetworkStatsTable) GetDailyStats(ctx context.Context, network did.DID, from time.Time, to time.Time) ([]networkstats.DailyStats, error) {
+	var stats []networkstats.DailyStats
+	for date, egress := range m.stats[network] {
+		if date.Before(from) || date.After(to) {
+			continue
+		}
+		stats = append(stats, networkstats.DailyStats{Date: date, Egress: egress})
+	}
+	return stats, nil
+}
+
+var _ networkstats.NetworkStatsTable = (*mockNetworkStatsTable)(nil)
+
+func TestNetworkStats(t *testing.T) {
+	march := time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC)
+	period := Period{From: march, To: march.AddDate(0, 1, 0)}
+
+	forge := testutil.RandomDID(t)
+	staging := testutil.RandomDID(t)
+	account := testutil.RandomDID(t)
+	forgeSpace := testutil.RandomDID(t)
+	stagingSpace := testutil.RandomDID(t)
+	orphanSpace := testutil.RandomDID(t)
+
+	networkStatsTable := &mockNetworkStatsTable{stats: make(map[did.DID]map[time.Time]uint64)}
+	svc := &service{
+		consumerTable: &mockConsumerTable{
+			getFunc: func(ctx context.Context, consumerID string) (consumer.Consumer, error) {
+				switch consumerID {
+				case forgeSpace.String():
+					return consumer.Consumer{ID: forgeSpace, Provider: forge, Customer: account}, nil
+				case stagingSpace.String():
+					return consumer.Consumer{ID: stagingSpace, Provider: staging, Customer: account}, nil
+				}
+				return consumer.Consumer{}, consumer.ErrNotFound
+			},
+		},
+		networkStatsTable: networkStatsTable,
+		networks:          []did.DID{forge, staging},
+	}
+
+	t.Run("egress is recorded for the network of each space", func(t *testing.T) {
+		err := svc.recordRollupStats(context.Background(), testutil.RandomCID(t), map[did.DID]uint64{forgeSpace: 300, stagingSpace: 100, orphanSpace: 50}, march.Add(10*time.Hour))
+		require.ErrorIs(t, err, consumer.ErrNotFound)
+
+		err = svc.recordRollupStats(context.Background(), testutil.RandomCID(t), map[did.DID]uint64{forgeSpace: 300}, march.AddDate(0, 0, 14))
+		require.NoError(t, err)
+
+		// out of the period
+		err = svc.recordRollupStats(context.Background(), testutil.RandomCID(t), map[did.DID]uint64{stagingSpace: 1000}, march.AddDate(0, 1, 0))
+		require.NoError(t, err)
+	})
+
+	t.Run("daily egress of each network in the period", func(t *testing.T) {
+		stats, err := svc.GetNetworkStats(context.Background(), period)
+		require.NoError(t, err)
+		require.Equal(t, period, stats.Period)
+		require.Equal(t, []did.DID{forge, staging}, stats.Networks)
+		require.Len(t, stats.Days, 31)
+
+		require.Equal(t, uint64(700), stats.Total)
+		require.Equal(t, uint64(600), stats.Totals[forge])
+		require.Equal(t, uint64(100), stats.Totals[staging])
+		require.InDelta(t, 600.0/7, stats.Share(forge), 0.001)
+
+		require.Equal(t, map[did.DID]uint64{forge: 300, staging: 100}, stats.Days[0].Egress)
+		require.Equal(t, map[did.DID]uint64{forge: 300}, stats.Days[14].Egress)
+		require.Empty(t, stats.Days[30].Egress)
+	})
+
+	t.Run("empty periods are not acceptable", func(t *testing.T) {
+		_, err := svc.GetNetworkStats(context.Background(), Period{From: march, To: march})
+		require.Error(t, err)
+	})
+}
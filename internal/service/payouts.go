@@ -0,0 +1,168 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/storacha/go-ucanto/ucan"
+
+	"github.com/storacha/etracker/internal/db/attestations"
+	"github.com/storacha/etracker/internal/db/consolidated"
+	"github.com/storacha/etracker/internal/db/payouts"
+)
+
+const providersPageSize = 100
+
+var (
+	ErrPayoutRunNotFound  = payouts.ErrNotFound
+	ErrPayoutRunFinalized = payouts.ErrFinalized
+	ErrPayoutRunNotDraft  = payouts.ErrNotDraft
+)
+
+// GeneratePayoutRun computes the payout of every storage provider for the consolidated egress counted in the
+// month of the given time, and stores it as the draft run of the month. Batches are counted when they are
+// consolidated, or when they are approved if they were held for review, so that batches approved after the run
+// of the month they were consolidated in was finalized are paid by the run of the month they were approved in.
+// The egress of every batch is paid at the rate of the provider's rate card in effect on the day it was counted.
+// Batches still held for review are not paid. It fails with ErrPayoutRunFinalized if the run of the month was
+// finalized already.
+func (s *service) GeneratePayoutRun(ctx context.Context, month time.Time) (*payouts.Run, error) {
+	if s.payoutTable == nil {
+		return nil, fmt.Errorf("payouts are %w", ErrNotConfigured)
+	}
+
+	now := time.Now().UTC()
+	from := time.Date(month.Year(), month.Month(), 1, 0, 0, 0, 0, time.UTC)
+	to := from.AddDate(0, 1, 0)
+	if to.After(now) {
+		return nil, NewPeriodNotAcceptableError(fmt.Sprintf("month %s has not ended yet", from.Format("2006-01")))
+	}
+
+	run := payouts.Run{
+		Month:       from,
+		Status:      payouts.StatusDraft,
+		GeneratedAt: now,
+	}
+
+	var token *string
+	for {
+		result, err := s.storageProviderTable.GetAll(ctx, providersPageSize, token)
+		if err != nil {
+			return nil, fmt.Errorf("listing storage providers: %w", err)
+		}
+
+		for _, provider := range result.Records {
+			card := s.payRates.CardFor(provider.Provider)
+			line := payouts.Line{
+				Provider:      provider.Provider,
+				WalletAddress: provider.WalletAddress,
+				OperatorEmail: provider.OperatorEmail,
+				RateCard:      card.Name,
+				Causes:        []ucan.Link{},
+			}
+
+			pay := func(record consolidated.ConsolidatedRecord, countedAt time.Time) {
+				line.Egress += record.TotalEgress
+				line.Amount += card.Payout(record.TotalEgress, countedAt)
+				line.Causes = append(line.Causes, record.Cause)
+			}
+
+			// approved batches are paid in the run of the month they were approved in
+			for record, err := range consolidated.GetCountedByNode(ctx, s.consolidatedTable, provider.Provider, from) {
+				if err != nil {
+					return nil, fmt.Errorf("getting consolidated records of %s: %w", provider.Provider, err)
+				}
+				if !record.CountedAt().Before(to) {
+					continue
+				}
+
+				pay(record, record.CountedAt())
+			}
+
+			if line.Egress == 0 {
+				continue
+			}
+
+			run.Lines = append(run.Lines, line)
+			run.TotalEgress += line.Egress
+			run.TotalAmount += line.Amount
+		}
+
+		if result.NextToken == nil {
+			break
+		}
+		token = result.NextToken
+	}
+
+	slices.SortFunc(run.Lines, func(a, b payouts.Line) int {
+		return strings.Compare(a.Provider.String(), b.Provider.String())
+	})
+	run.Providers = len(run.Lines)
+
+	if err := s.payoutTable.PutDraft(ctx, run); err != nil {
+		return nil, err
+	}
+
+	return &run, nil
+}
+
+// FinalizePayoutRun locks the draft payout run of the month of the given time against further changes and
+// attests the egress of every line. If attesting fails the run stays finalized, see AttestPayoutRun.
+func (s *service) FinalizePayoutRun(ctx context.Context, month time.Time) error {
+	if s.payoutTable == nil {
+		return fmt.Errorf("payouts are %w", ErrNotConfigured)
+	}
+
+	if err := s.payoutTable.Finalize(ctx, month); err != nil {
+		return err
+	}
+
+	if _, err := s.AttestPayoutRun(ctx, month); err != nil {
+		return fmt.Errorf("attesting payout run: %w", err)
+	}
+
+	return nil
+}
+
+// GetPayoutRuns lists the payout runs without their lines, most recent month first
+func (s *service) GetPayoutRuns(ctx context.Context) ([]payouts.Run, error) {
+	if s.payoutTable == nil {
+		return nil, fmt.Errorf("payouts are %w", ErrNotConfigured)
+	}
+
+	return s.payoutTable.List(ctx)
+}
+
+// GetPayoutRun returns the payout run of the month of the given time with all its lines, or
+// ErrPayoutRunNotFound if none was generated. Lines of finalized runs link to their attestation.
+func (s *service) GetPayoutRun(ctx context.Context, month time.Time) (*payouts.Run, error) {
+	if s.payoutTable == nil || s.attestationTable == nil {
+		return nil, fmt.Errorf("payouts are %w", ErrNotConfigured)
+	}
+
+	run, err := s.payoutTable.Get(ctx, month)
+	if err != nil {
+		return nil, err
+	}
+
+	if run.Status != payouts.StatusFinalized {
+		return run, nil
+	}
+
+	for i, line := range run.Lines {
+		attestation, err := s.attestationTable.Get(ctx, line.Provider, run.Month)
+		if err != nil {
+			if errors.Is(err, attestations.ErrNotFound) {
+				continue
+			}
+			return nil, fmt.Errorf("getting attestation of %s: %w", line.Provider, err)
+		}
+		run.Lines[i].Attestation = attestation.Cause
+	}
+
+	return run, nil
+}
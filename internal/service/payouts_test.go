@@ -0,0 +1,179 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/storacha/go-libstoracha/testutil"
+	"github.com/storacha/go-ucanto/did"
+	"github.com/storacha/go-ucanto/ucan"
+	"github.com/stretchr/testify/require"
+
+	"github.com/storacha/etracker/internal/db/attestations"
+	"github.com/storacha/etracker/internal/db/consolidated"
+	"github.com/storacha/etracker/internal/db/payouts"
+	"github.com/storacha/etracker/internal/db/storageproviders"
+	"github.com/storacha/etracker/internal/pricing"
+)
+
+type mockStorageProviderTable struct {
+	records []storageproviders.StorageProviderRecord
+}
+
+func (m *mockStorageProviderTable) Get(ctx context.Context, provider did.DID) (*storageproviders.StorageProviderRecord, error) {
+	return nil, storageproviders.ErrNotFound
+}
+
+func (m *mockStorageProviderTable) GetAll(ctx context.Context, limit int, startToken *string) (*storageproviders.GetAllResult, error) {
+	return &storageproviders.GetAllResult{Records: m.records}, nil
+}
+
+type mockPayoutTable struct {
+	runs map[time.Time]payouts.Run
+}
+
+func (m *mockPayoutTable) PutDraft(ctx context.Context, run payouts.Run) error {
+	if m.runs[run.Month].Status == payouts.StatusFinalized {
+		return payouts.ErrFinalized
+	}
+	m.runs[run.Month] = run
+	return nil
+}
+
+func (m *mockPayoutTable) Finalize(ctx context.Context, month time.Time) error {
+	run, ok := m.runs[month]
+	if !ok || run.Status != payouts.StatusDraft {
+		return payouts.ErrNotDraft
+	}
+	run.Status = payouts.StatusFinalized
+	m.runs[month] = run
+	return nil
+}
+
+func (m *mockPayoutTable) Get(ctx context.Context, month time.Time) (*payouts.Run, error) {
+	run, ok := m.runs[month]
+	if !ok {
+		return nil, payouts.ErrNotFound
+	}
+	return &run, nil
+}
+
+func (m *mockPayoutTable) List(ctx context.Context) ([]payouts.Run, error) {
+	var runs []payouts.Run
+	for _, run := range m.runs {
+		runs = append(runs, run)
+	}
+	return runs, nil
+}
+
+func TestGeneratePayoutRun(t *testing.T) {
+	march := time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC)
+
+	node1 := testutil.RandomDID(t)
+	node2 := testutil.RandomDID(t)
+	node3 := testutil.RandomDID(t)
+
+	counted := testutil.RandomCID(t)
+	approved := testutil.RandomCID(t)
+	held := testutil.RandomCID(t)
+	april := testutil.RandomCID(t)
+	other := testutil.RandomCID(t)
+
+	consolidatedTable := &mockConsolidatedTable{records: map[string]*consolidated.ConsolidatedRecord{
+		counted.String():  {Cause: counted, Node: node1, TotalEgress: 1 << 40, ProcessedAt: march.Add(time.Hour), Status: consolidated.StatusCounted},
+		approved.String(): {Cause: approved, Node: node1, TotalEgress: 1 << 39, ProcessedAt: march.AddDate(0, 0, 30), ReviewedAt: march.AddDate(0, 1, 0).Add(-time.Second), ApprovedAt: march.AddDate(0, 1, 0).Add(-time.Second), Status: consolidated.StatusApproved},
+		held.String():     {Cause: held, Node: node1, HeldEgress: 1 << 40, ProcessedAt: march.Add(2 * time.Hour), Status: consolidated.StatusHeld},
+		april.String():    {Cause: april, Node: node1, TotalEgress: 1 << 40, ProcessedAt: march.AddDate(0, 1, 0), Status: consolidated.StatusCounted},
+		other.String():    {Cause: other, Node: node2, TotalEgress: 1 << 30, ProcessedAt: march.AddDate(0, 0, 10), Status: consolidated.StatusCounted},
+	}}
+	for _, record := range consolidatedTable.records {
+		record.Receipt = issueConsolidateReceipt(t, record.Cause, record.TotalEgress)
+	}
+	payoutTable := &mockPayoutTable{runs: make(map[time.Time]payouts.Run)}
+
+	// node1 earns the rate of its tier, which doubles from March 20
+	payRates, err := pricing.NewRateCards(pricing.RateCardConfig{
+		Default: []pricing.RateConfig{{USDPerTiB: 2}},
+		Tiers: map[string][]pricing.RateConfig{
+			"gold": {{USDPerTiB: 2}, {From: "2025-03-20", USDPerTiB: 4}},
+		},
+		Nodes: map[string]pricing.NodeRateConfig{node1.String(): {Tier: "gold"}},
+	})
+	require.NoError(t, err)
+
+	svc := &service{
+		id:                testutil.WebService,
+		consolidatedTable: consolidatedTable,
+		storageProviderTable: &mockStorageProviderTable{records: []storageproviders.StorageProviderRecord{
+			{Provider: node1, WalletAddress: "0x1", OperatorEmail: "one@example.com"},
+			{Provider: node2, WalletAddress: "0x2", OperatorEmail: "two@example.com"},
+			{Provider: node3, WalletAddress: "0x3", OperatorEmail: "three@example.com"},
+		}},
+		payoutTable:      payoutTable,
+		attestationTable: &mockAttestationTable{attestations: make(map[string]attestations.Attestation)},
+		payRates:         payRates,
+	}
+
+	run, err := svc.GeneratePayoutRun(context.Background(), march.AddDate(0, 0, 15))
+	require.NoError(t, err)
+	require.Equal(t, march, run.Month)
+	require.Equal(t, payouts.StatusDraft, run.Status)
+	require.Equal(t, 2, run.Providers)
+	require.Equal(t, uint64(1<<40+1<<39+1<<30), run.TotalEgress)
+
+	lines := make(map[did.DID]payouts.Line)
+	for _, line := range run.Lines {
+		lines[line.Provider] = line
+	}
+	require.Equal(t, "0x1", lines[node1].WalletAddress)
+	require.Equal(t, uint64(1<<40+1<<39), lines[node1].Egress)
+	require.ElementsMatch(t, []ucan.Link{counted, approved}, lines[node1].Causes)
+	require.ElementsMatch(t, []ucan.Link{other}, lines[node2].Causes)
+	require.NotContains(t, lines, node3)
+
+	// the TiB counted on March 1 is paid $2, the half TiB counted on March 31 is paid $4 per TiB
+	require.Equal(t, "gold", lines[node1].RateCard)
+	require.Equal(t, 4.0, lines[node1].Amount)
+	require.Equal(t, pricing.DefaultRateCardName, lines[node2].RateCard)
+	require.Equal(t, 2.0/1024, lines[node2].Amount)
+	require.Equal(t, 4+2.0/1024, run.TotalAmount)
+
+	t.Run("finalized runs are locked", func(t *testing.T) {
+		require.NoError(t, svc.FinalizePayoutRun(context.Background(), march))
+
+		_, err := svc.GeneratePayoutRun(context.Background(), march)
+		require.ErrorIs(t, err, ErrPayoutRunFinalized)
+		require.ErrorIs(t, svc.FinalizePayoutRun(context.Background(), march), ErrPayoutRunNotDraft)
+
+		stored, err := svc.GetPayoutRun(context.Background(), march)
+		require.NoError(t, err)
+		require.Equal(t, run.TotalAmount, stored.TotalAmount)
+	})
+
+	t.Run("batches approved after the run was finalized are paid by the next run", func(t *testing.T) {
+		// the held batch consolidated in March is approved in April, once the run of March is locked
+		record := consolidatedTable.records[held.String()]
+		record.Status = consolidated.StatusApproved
+		record.TotalEgress = record.HeldEgress
+		record.ReviewedAt = march.AddDate(0, 1, 2)
+		record.ApprovedAt = record.ReviewedAt
+
+		run, err := svc.GeneratePayoutRun(context.Background(), march.AddDate(0, 1, 0))
+		require.NoError(t, err)
+
+		lines := make(map[did.DID]payouts.Line)
+		for _, line := range run.Lines {
+			lines[line.Provider] = line
+		}
+		require.ElementsMatch(t, []ucan.Link{april, held}, lines[node1].Causes)
+		require.Equal(t, uint64(1<<41), lines[node1].Egress)
+		// both are paid at the rate in effect in April
+		require.Equal(t, 8.0, lines[node1].Amount)
+	})
+
+	t.Run("months that have not ended cannot be paid", func(t *testing.T) {
+		_, err := svc.GeneratePayoutRun(context.Background(), time.Now())
+		require.ErrorAs(t, err, &ErrPeriodNotAcceptable{})
+	})
+}
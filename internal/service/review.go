@@ -0,0 +1,203 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
+	capegress "github.com/storacha/go-libstoracha/capabilities/space/egress"
+	"github.com/storacha/go-ucanto/core/invocation"
+	"github.com/storacha/go-ucanto/core/receipt"
+	"github.com/storacha/go-ucanto/core/receipt/ran"
+	"github.com/storacha/go-ucanto/core/result"
+	"github.com/storacha/go-ucanto/did"
+	"github.com/storacha/go-ucanto/ucan"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+
+	"github.com/storacha/etracker/internal/callback"
+	"github.com/storacha/etracker/internal/db/consolidated"
+	"github.com/storacha/etracker/internal/db/hourlystats"
+	"github.com/storacha/etracker/internal/db/spacestats"
+	"github.com/storacha/etracker/internal/metrics"
+	"github.com/storacha/etracker/internal/rollup"
+)
+
+// BatchRejectedErrorName is the error name of the receipts issued for batches rejected on review
+const BatchRejectedErrorName = "BatchRejected"
+
+var ErrBatchNotHeld = consolidated.ErrNotHeld
+
+type GetHeldBatchesResult struct {
+	Batches   []consolidated.ConsolidatedRecord
+	NextToken *string
+}
+
+// GetHeldBatches lists the consolidated batches held for review, oldest first within each page
+func (s *service) GetHeldBatches(ctx context.Context, limit int, startToken *string) (*GetHeldBatchesResult, error) {
+	result, err := s.consolidatedTable.ListHeld(ctx, limit, startToken)
+	if err != nil {
+		return nil, err
+	}
+
+	batches := result.Records
+	slices.SortFunc(batches, func(a, b consolidated.ConsolidatedRecord) int {
+		return a.ProcessedAt.Compare(b.ProcessedAt)
+	})
+
+	return &GetHeldBatchesResult{
+		Batches:   batches,
+		NextToken: result.Cursor,
+	}, nil
+}
+
+// ApproveBatch counts the egress of a held batch towards node, space and account totals.
+// Egress is counted on the day the batch is approved, stats already reported are not changed.
+func (s *service) ApproveBatch(ctx context.Context, cause ucan.Link) error {
+	record, err := s.consolidatedTable.Get(ctx, cause)
+	if err != nil {
+		return err
+	}
+
+	if record.Status != consolidated.StatusHeld {
+		return ErrBatchNotHeld
+	}
+
+	// Stats are recorded before the batch is approved, so that approving can be retried until they all are.
+	// Every stats table records the egress of a batch at most once, so retries only record what failed before.
+	approvedAt := time.Now().UTC()
+	var errs []error
+	for space, egress := range record.SpaceEgress {
+		err := s.spaceStatsTable.Record(ctx, space, egress, approvedAt, cause.String())
+		if err != nil && !errors.Is(err, spacestats.ErrAlreadyRecorded) {
+			errs = append(errs, fmt.Errorf("recording space stats of %s: %w", space, err))
+		}
+
+		if s.hourlyStatsTable != nil {
+			err := s.hourlyStatsTable.Record(ctx, space, egress, approvedAt, cause.String())
+			if err != nil && !errors.Is(err, hourlystats.ErrAlreadyRecorded) {
+				errs = append(errs, fmt.Errorf("recording hourly space stats of %s: %w", space, err))
+			}
+		}
+	}
+
+	if s.accountStatsTable != nil || s.networkStatsTable != nil {
+		if err := s.recordRollupStats(ctx, cause, record.SpaceEgress, approvedAt); err != nil {
+			errs = append(errs, fmt.Errorf("recording account and network stats: %w", err))
+		}
+	}
+
+	if err := errors.Join(errs...); err != nil {
+		return fmt.Errorf("counting egress of held batch %s: %w", cause, err)
+	}
+
+	if err := s.consolidatedTable.Approve(ctx, cause, approvedAt); err != nil {
+		return err
+	}
+
+	nodeAttr := attribute.String("node", record.Node.String())
+	metrics.ConsolidatedBytesPerNode.Add(ctx, int64(record.HeldEgress), metric.WithAttributeSet(attribute.NewSet(nodeAttr)))
+
+	log.Infow("Approved held batch", "cause", cause, "node", record.Node, "egress", record.HeldEgress)
+
+	if record.Receipt != nil {
+		s.notify(ctx, *record, record.Receipt)
+	}
+
+	return nil
+}
+
+// RejectBatch discards the egress of a held batch and replaces its receipt with an error receipt
+// carrying the given reason. The credit of the batch is reversed in the ledger.
+func (s *service) RejectBatch(ctx context.Context, cause ucan.Link, reason string) error {
+	if reason == "" {
+		return errors.New("a reason is required to reject a batch")
+	}
+
+	record, err := s.consolidatedTable.Get(ctx, cause)
+	if err != nil {
+		return err
+	}
+
+	if record.Status != consolidated.StatusHeld {
+		return ErrBatchNotHeld
+	}
+
+	// The error receipt is for the same consolidate invocation the original receipt was issued for
+	ranInv := ran.FromLink(cause)
+	if record.Receipt != nil {
+		if inv, ok := record.Receipt.Ran().Invocation(); ok {
+			ranInv = ran.FromInvocation(inv)
+		}
+	}
+
+	anyRcpt, err := receipt.Issue(
+		s.id,
+		result.Error[capegress.ConsolidateOk](capegress.ConsolidateError{
+			ErrorName: BatchRejectedErrorName,
+			Message:   reason,
+		}),
+		ranInv,
+	)
+	if err != nil {
+		return fmt.Errorf("issuing error receipt: %w", err)
+	}
+
+	reader, err := capegress.NewConsolidateReceiptReader()
+	if err != nil {
+		return fmt.Errorf("constructing receipt reader: %w", err)
+	}
+
+	rcpt, err := reader.Read(anyRcpt.Root().Link(), anyRcpt.Blocks())
+	if err != nil {
+		return fmt.Errorf("reading error receipt: %w", err)
+	}
+
+	if err := s.consolidatedTable.Reject(ctx, cause, reason, rcpt); err != nil {
+		return err
+	}
+
+	// a reversal that failed to post can be posted again by reversing the rejected batch
+	if s.ledgerTable != nil {
+		if _, err := s.reverseCredit(ctx, record, ActorAdmin, reason); err != nil {
+			return fmt.Errorf("batch rejected, reversing its credit: %w", err)
+		}
+	}
+
+	log.Infow("Rejected held batch", "cause", cause, "node", record.Node, "egress", record.HeldEgress, "reason", reason)
+
+	s.notify(ctx, *record, rcpt)
+
+	return nil
+}
+
+// notify pushes the receipt of a reviewed batch to the callback of the node that tracked it. Failing to do so
+// does not fail the review.
+func (s *service) notify(ctx context.Context, record consolidated.ConsolidatedRecord, rcpt callback.Receipt) {
+	if s.notifier == nil {
+		return
+	}
+
+	// the track invocation may carry the callback of the batch, records stored before they could be looked up
+	// by track invocation fall back to the callback registered for the node
+	var trackInv invocation.Invocation
+	if record.TrackCause != nil {
+		egressRecord, err := s.egressTable.GetByCause(ctx, record.TrackCause)
+		if err != nil {
+			log.Warnw("Failed to get track invocation of reviewed batch", "cause", record.Cause, "error", err)
+		} else {
+			trackInv = egressRecord.Cause
+		}
+	}
+
+	if err := s.notifier.Notify(ctx, record.Node, trackInv, rcpt); err != nil {
+		log.Errorw("Failed to notify review result", "cause", record.Cause, "node", record.Node, "error", err)
+	}
+}
+
+// recordRollupStats adds the egress of each space to the account and network stats, at most once per batch
+func (s *service) recordRollupStats(ctx context.Context, cause ucan.Link, spaceEgress map[did.DID]uint64, at time.Time) error {
+	return rollup.New(s.consumerTable, s.accountStatsTable, s.networkStatsTable, s.pricer, s.quotaMonitor).Record(ctx, cause, spaceEgress, at)
+}
@@ -0,0 +1,371 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"iter"
+	"net/url"
+	"testing"
+	"time"
+
+	capegress "github.com/storacha/go-libstoracha/capabilities/space/egress"
+	"github.com/storacha/go-libstoracha/testutil"
+	"github.com/storacha/go-ucanto/core/result"
+	"github.com/storacha/go-ucanto/did"
+	"github.com/storacha/go-ucanto/ucan"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/storacha/etracker/internal/callback"
+	"github.com/storacha/etracker/internal/db/callbacks"
+	"github.com/storacha/etracker/internal/db/consolidated"
+	"github.com/storacha/etracker/internal/db/consumer"
+	"github.com/storacha/etracker/internal/db/deliveries"
+	"github.com/storacha/etracker/internal/db/spacestats"
+	"github.com/storacha/etracker/internal/metrics"
+)
+
+type mockConsolidatedTable struct {
+	records  map[string]*consolidated.ConsolidatedRecord
+	rejected map[string]capegress.ConsolidateReceipt
+}
+
+func (m *mockConsolidatedTable) Add(ctx context.Context, cause ucan.Link, trackCause ucan.Link, batch ucan.Link, node did.DID, totalEgress uint64, rcpt capegress.ConsolidateReceipt, spaceEgress map[did.DID]uint64) error {
+	return nil
+}
+
+func (m *mockConsolidatedTable) Get(ctx context.Context, cause ucan.Link) (*consolidated.ConsolidatedRecord, error) {
+	record, ok := m.records[cause.String()]
+	if !ok {
+		return nil, consolidated.ErrNotFound
+	}
+	return record, nil
+}
+
+func (m *mockConsolidatedTable) GetByTrackCause(ctx context.Context, trackCause ucan.Link) (*consolidated.ConsolidatedRecord, error) {
+	return nil, consolidated.ErrNotFound
+}
+
+func (m *mockConsolidatedTable) GetByBatch(ctx context.Context, batch ucan.Link) (*consolidated.ConsolidatedRecord, error) {
+	for _, record := range m.records {
+		if record.Batch != nil && record.Batch.String() == batch.String() {
+			return record, nil
+		}
+	}
+	return nil, consolidated.ErrNotFound
+}
+
+func (m *mockConsolidatedTable) GetStatsByNode(ctx context.Context, node did.DID, since time.Time) iter.Seq2[consolidated.ConsolidatedRecord, error] {
+	return func(yield func(consolidated.ConsolidatedRecord, error) bool) {
+		for _, record := range m.records {
+			if record.Node == node && !record.ProcessedAt.Before(since) {
+				if !yield(*record, nil) {
+					return
+				}
+			}
+		}
+	}
+}
+
+func (m *mockConsolidatedTable) GetReviewedByNode(ctx context.Context, node did.DID, since time.Time) iter.Seq2[consolidated.ConsolidatedRecord, error] {
+	return func(yield func(consolidated.ConsolidatedRecord, error) bool) {
+		for _, record := range m.records {
+			if record.Node == node && !record.ReviewedAt.IsZero() && !record.ReviewedAt.Before(since) {
+				if !yield(*record, nil) {
+					return
+				}
+			}
+		}
+	}
+}
+
+func (m *mockConsolidatedTable) GetFirstByNode(ctx context.Context, node did.DID) (*consolidated.ConsolidatedRecord, error) {
+	return nil, consolidated.ErrNotFound
+}
+
+func (m *mockConsolidatedTable) Hold(ctx context.Context, cause ucan.Link, trackCause ucan.Link, batch ucan.Link, node did.DID, totalEgress uint64, rcpt capegress.ConsolidateReceipt, reasons []string, spaceEgress map[did.DID]uint64) error {
+	return nil
+}
+
+func (m *mockConsolidatedTable) ListHeld(ctx context.Context, limit int, cursor *string) (*consolidated.ListHeldResult, error) {
+	return &consolidated.ListHeldResult{}, nil
+}
+
+func (m *mockConsolidatedTable) Approve(ctx context.Context, cause ucan.Link, approvedAt time.Time) error {
+	record, ok := m.records[cause.String()]
+	if !ok || record.Status != consolidated.StatusHeld {
+		return consolidated.ErrNotHeld
+	}
+
+	record.Status = consolidated.StatusApproved
+	record.TotalEgress = record.HeldEgress
+	record.ReviewedAt = approvedAt
+	record.ApprovedAt = approvedAt
+	return nil
+}
+
+func (m *mockConsolidatedTable) Reject(ctx context.Context, cause ucan.Link, reason string, rcpt capegress.ConsolidateReceipt) error {
+	record, ok := m.records[cause.String()]
+	if !ok || record.Status != consolidated.StatusHeld {
+		return consolidated.ErrNotHeld
+	}
+
+	record.Status = consolidated.StatusRejected
+	record.ReviewReason = reason
+	m.rejected[cause.String()] = rcpt
+	return nil
+}
+
+var _ consolidated.ConsolidatedTable = (*mockConsolidatedTable)(nil)
+
+type mockCallbackTable struct {
+	callbacks map[did.DID]*url.URL
+}
+
+func (m *mockCallbackTable) Get(ctx context.Context, node did.DID) (*url.URL, error) {
+	u, ok := m.callbacks[node]
+	if !ok {
+		return nil, callbacks.ErrNotFound
+	}
+	return u, nil
+}
+
+func (m *mockCallbackTable) Put(ctx context.Context, node did.DID, callback *url.URL) error {
+	m.callbacks[node] = callback
+	return nil
+}
+
+func (m *mockCallbackTable) Delete(ctx context.Context, node did.DID) error {
+	delete(m.callbacks, node)
+	return nil
+}
+
+func (m *mockCallbackTable) List(ctx context.Context, limit int, cursor *string) (*callbacks.ListResult, error) {
+	return &callbacks.ListResult{}, nil
+}
+
+var _ callbacks.CallbackTable = (*mockCallbackTable)(nil)
+
+type mockDeliveryTable struct {
+	pending map[string]deliveries.Pending
+}
+
+func (m *mockDeliveryTable) Add(ctx context.Context, delivery deliveries.Delivery) error {
+	return nil
+}
+
+func (m *mockDeliveryTable) ListByCause(ctx context.Context, cause ucan.Link) ([]deliveries.Delivery, error) {
+	return nil, nil
+}
+
+func (m *mockDeliveryTable) Schedule(ctx context.Context, pending deliveries.Pending) error {
+	m.pending[pending.Cause.String()] = pending
+	return nil
+}
+
+func (m *mockDeliveryTable) ListDue(ctx context.Context, now time.Time, limit int) ([]deliveries.Pending, error) {
+	return nil, nil
+}
+
+func (m *mockDeliveryTable) Complete(ctx context.Context, cause ucan.Link) error {
+	delete(m.pending, cause.String())
+	return nil
+}
+
+var _ deliveries.DeliveryTable = (*mockDeliveryTable)(nil)
+
+func TestRejectBatch(t *testing.T) {
+	id := testutil.RandomSigner(t)
+	heldCause := testutil.RandomCID(t)
+	countedCause := testutil.RandomCID(t)
+	node := testutil.RandomDID(t)
+
+	newService := func() (*service, *mockConsolidatedTable) {
+		consolidatedTable := &mockConsolidatedTable{
+			records: map[string]*consolidated.ConsolidatedRecord{
+				heldCause.String():    {Cause: heldCause, Status: consolidated.StatusHeld, HeldEgress: 1000},
+				countedCause.String(): {Cause: countedCause, Status: consolidated.StatusCounted, TotalEgress: 1000},
+			},
+			rejected: make(map[string]capegress.ConsolidateReceipt),
+		}
+
+		return &service{id: id, consolidatedTable: consolidatedTable}, consolidatedTable
+	}
+
+	t.Run("issues an error receipt with the reason", func(t *testing.T) {
+		svc, consolidatedTable := newService()
+
+		err := svc.RejectBatch(context.Background(), heldCause, "duplicate receipts")
+		require.NoError(t, err)
+
+		rcpt, ok := consolidatedTable.rejected[heldCause.String()]
+		require.True(t, ok)
+		assert.Equal(t, id.DID(), rcpt.Issuer().DID())
+		assert.Equal(t, heldCause.String(), rcpt.Ran().Link().String())
+
+		_, x := result.Unwrap(rcpt.Out())
+		assert.Equal(t, BatchRejectedErrorName, x.ErrorName)
+		assert.Equal(t, "duplicate receipts", x.Message)
+	})
+
+	t.Run("pushes the error receipt to the callback of the node", func(t *testing.T) {
+		svc, _ := newService()
+		svc.consolidatedTable.(*mockConsolidatedTable).records[heldCause.String()].Node = node
+		deliveryTable := &mockDeliveryTable{pending: make(map[string]deliveries.Pending)}
+		svc.notifier = callback.New(&mockCallbackTable{callbacks: map[did.DID]*url.URL{
+			node: testutil.Must(url.Parse("https://node.example.com/callback"))(t),
+		}}, deliveryTable)
+
+		err := svc.RejectBatch(context.Background(), heldCause, "duplicate receipts")
+		require.NoError(t, err)
+
+		pending, ok := deliveryTable.pending[heldCause.String()]
+		require.True(t, ok)
+		assert.Equal(t, node, pending.Node)
+		assert.Equal(t, "https://node.example.com/callback", pending.URL)
+		assert.Equal(t, 1, pending.Attempt)
+	})
+
+	t.Run("requires a reason", func(t *testing.T) {
+		svc, consolidatedTable := newService()
+
+		err := svc.RejectBatch(context.Background(), heldCause, "")
+		require.Error(t, err)
+		assert.Empty(t, consolidatedTable.rejected)
+	})
+
+	t.Run("fails for batches that are not held", func(t *testing.T) {
+		svc, _ := newService()
+
+		err := svc.RejectBatch(context.Background(), countedCause, "duplicate receipts")
+		require.ErrorIs(t, err, ErrBatchNotHeld)
+
+		err = svc.ApproveBatch(context.Background(), countedCause)
+		require.ErrorIs(t, err, ErrBatchNotHeld)
+	})
+
+	t.Run("fails for unknown batches", func(t *testing.T) {
+		svc, _ := newService()
+
+		err := svc.RejectBatch(context.Background(), testutil.RandomCID(t), "duplicate receipts")
+		require.ErrorIs(t, err, consolidated.ErrNotFound)
+	})
+}
+
+func TestApproveBatch(t *testing.T) {
+	heldCause := testutil.RandomCID(t)
+	space := testutil.RandomDID(t)
+
+	t.Run("stays held when the egress cannot be counted", func(t *testing.T) {
+		consolidatedTable := &mockConsolidatedTable{
+			records: map[string]*consolidated.ConsolidatedRecord{
+				heldCause.String(): {Cause: heldCause, Status: consolidated.StatusHeld, HeldEgress: 1000, SpaceEgress: map[did.DID]uint64{space: 1000}},
+			},
+		}
+		// the space stats table fails to record any egress
+		svc := &service{consolidatedTable: consolidatedTable, spaceStatsTable: &mockSpaceStatsTable{}}
+
+		err := svc.ApproveBatch(context.Background(), heldCause)
+		require.Error(t, err)
+		require.Equal(t, consolidated.StatusHeld, consolidatedTable.records[heldCause.String()].Status)
+	})
+
+	t.Run("counts the stats that failed before when retried", func(t *testing.T) {
+		require.NoError(t, metrics.Init("test"))
+
+		account := testutil.RandomDID(t)
+		consolidatedTable := &mockConsolidatedTable{
+			records: map[string]*consolidated.ConsolidatedRecord{
+				heldCause.String(): {Cause: heldCause, Node: testutil.RandomDID(t), Status: consolidated.StatusHeld, HeldEgress: 1000, SpaceEgress: map[did.DID]uint64{space: 1000}},
+			},
+		}
+
+		// every table records the egress of an id once, like the markers of the dynamo tables do
+		recorded := make(map[string]uint64)
+		record := func(table string, egress uint64, id string) error {
+			if _, ok := recorded[table+id]; ok {
+				return spacestats.ErrAlreadyRecorded
+			}
+			recorded[table+id] = egress
+			return nil
+		}
+		accountStatsDown := true
+
+		svc := &service{
+			consolidatedTable: consolidatedTable,
+			consumerTable: &mockConsumerTable{
+				getFunc: func(ctx context.Context, consumerID string) (consumer.Consumer, error) {
+					return consumer.Consumer{ID: space, Customer: account}, nil
+				},
+			},
+			spaceStatsTable: &mockSpaceStatsTable{
+				recordFunc: func(ctx context.Context, space did.DID, egress uint64, at time.Time, id string) error {
+					return record("space", egress, id)
+				},
+			},
+			hourlyStatsTable: &mockHourlyStatsTable{
+				recordFunc: func(ctx context.Context, space did.DID, egress uint64, at time.Time, id string) error {
+					return record("hourly", egress, id)
+				},
+			},
+			accountStatsTable: &mockAccountStatsTable{
+				recordFunc: func(ctx context.Context, account did.DID, plan string, egress uint64, at time.Time, id string) error {
+					if accountStatsDown {
+						return errors.New("account stats unavailable")
+					}
+					return record("account", egress, id)
+				},
+			},
+		}
+
+		err := svc.ApproveBatch(context.Background(), heldCause)
+		require.Error(t, err)
+		require.Equal(t, consolidated.StatusHeld, consolidatedTable.records[heldCause.String()].Status)
+		require.Len(t, recorded, 2)
+
+		accountStatsDown = false
+		err = svc.ApproveBatch(context.Background(), heldCause)
+		require.NoError(t, err)
+		require.Equal(t, consolidated.StatusApproved, consolidatedTable.records[heldCause.String()].Status)
+		require.Equal(t, map[string]uint64{
+			"space" + heldCause.String():   1000,
+			"hourly" + heldCause.String():  1000,
+			"account" + heldCause.String(): 1000,
+		}, recorded)
+	})
+
+	t.Run("counts the egress on the day the batch is approved", func(t *testing.T) {
+		require.NoError(t, metrics.Init("test"))
+
+		// the batch was consolidated before the earliest period of the stats of the node
+		node := testutil.RandomDID(t)
+		processedAt := time.Now().UTC().AddDate(0, -3, 0)
+		consolidatedTable := &mockConsolidatedTable{
+			records: map[string]*consolidated.ConsolidatedRecord{
+				heldCause.String(): {Cause: heldCause, Node: node, Status: consolidated.StatusHeld, HeldEgress: 1000, SpaceEgress: map[did.DID]uint64{space: 1000}, ProcessedAt: processedAt},
+			},
+		}
+
+		var recordedAt time.Time
+		svc := &service{
+			consolidatedTable: consolidatedTable,
+			spaceStatsTable: &mockSpaceStatsTable{
+				recordFunc: func(ctx context.Context, space did.DID, egress uint64, at time.Time, id string) error {
+					recordedAt = at
+					return nil
+				},
+			},
+		}
+
+		require.NoError(t, svc.ApproveBatch(context.Background(), heldCause))
+		approved := consolidatedTable.records[heldCause.String()]
+		require.Equal(t, approved.ApprovedAt, recordedAt)
+		require.Equal(t, approved.ApprovedAt, approved.CountedAt())
+		require.True(t, approved.ApprovedAt.After(processedAt))
+
+		stats, err := svc.GetStats(context.Background(), node)
+		require.NoError(t, err)
+		require.Equal(t, uint64(1000), stats.CurrentDay.Egress)
+		require.Zero(t, stats.PreviousMonth.Egress)
+	})
+}
@@ -2,10 +2,13 @@ package service
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"maps"
 	"net/url"
 	"slices"
 	"strings"
+	"sync"
 	"time"
 
 	logging "github.com/ipfs/go-log/v2"
@@ -16,17 +19,40 @@ import (
 	"go.opentelemetry.io/otel/attribute"
 	"go.opentelemetry.io/otel/metric"
 
+	"github.com/storacha/etracker/internal/callback"
+	"github.com/storacha/etracker/internal/db/accountstats"
+	"github.com/storacha/etracker/internal/db/anomalies"
+	"github.com/storacha/etracker/internal/db/attestations"
+	"github.com/storacha/etracker/internal/db/callbacks"
 	"github.com/storacha/etracker/internal/db/consolidated"
 	"github.com/storacha/etracker/internal/db/consumer"
 	"github.com/storacha/etracker/internal/db/customer"
 	"github.com/storacha/etracker/internal/db/egress"
+	"github.com/storacha/etracker/internal/db/hourlystats"
+	"github.com/storacha/etracker/internal/db/ledger"
+	"github.com/storacha/etracker/internal/db/networkstats"
+	"github.com/storacha/etracker/internal/db/payouts"
 	"github.com/storacha/etracker/internal/db/spacestats"
+	"github.com/storacha/etracker/internal/db/statements"
 	"github.com/storacha/etracker/internal/db/storageproviders"
+	"github.com/storacha/etracker/internal/exclusion"
 	"github.com/storacha/etracker/internal/metrics"
+	"github.com/storacha/etracker/internal/pricing"
+	"github.com/storacha/etracker/internal/quota"
 )
 
 var log = logging.Logger("service")
 
+// ErrNotConfigured is returned by the methods of optional features whose tables were not configured
+var ErrNotConfigured = errors.New("not configured")
+
+const (
+	// spaceStatsConcurrency is the number of spaces whose stats are fetched in parallel
+	spaceStatsConcurrency = 10
+	// accountEgressTimeout bounds the time spent fetching the stats of all the spaces of an account
+	accountEgressTimeout = 30 * time.Second
+)
+
 type ErrAccountNotFound struct {
 	accountDID did.DID
 }
@@ -78,13 +104,47 @@ func (e ErrSpaceUnauthorized) Error() string {
 	}
 }
 
+// ErrPartialResult reports the spaces whose stats are missing from an account egress result
+type ErrPartialResult struct {
+	failedSpaces []did.DID
+}
+
+// NewPartialResultError creates an error for the failed spaces of an account egress result, in a stable order
+func NewPartialResultError(failedSpaces map[did.DID]error) ErrPartialResult {
+	spaces := make([]did.DID, 0, len(failedSpaces))
+	for space := range failedSpaces {
+		spaces = append(spaces, space)
+	}
+	slices.SortFunc(spaces, func(a, b did.DID) int {
+		return strings.Compare(a.String(), b.String())
+	})
+	return ErrPartialResult{failedSpaces: spaces}
+}
+
+func (e ErrPartialResult) FailedSpaces() []did.DID {
+	return e.failedSpaces
+}
+
+func (e ErrPartialResult) Error() string {
+	failedSpaces := make([]string, 0, min(len(e.failedSpaces), 5)) // Limit number of spaces in error message
+	for _, s := range e.failedSpaces[:min(len(e.failedSpaces), 5)] {
+		failedSpaces = append(failedSpaces, s.String())
+	}
+	if len(e.failedSpaces) > 5 {
+		return fmt.Sprintf("failed to fetch egress of spaces %s (and %d more)", strings.Join(failedSpaces, ", "), len(e.failedSpaces)-5)
+	}
+	return fmt.Sprintf("failed to fetch egress of spaces %s", strings.Join(failedSpaces, ", "))
+}
+
 // SpaceEgress holds egress data for a single space
 type SpaceEgress struct {
-	Total      uint64
-	DailyStats []DailyStat
+	Total uint64
+	// Granularity is the size of the buckets in DailyStats
+	Granularity Granularity
+	DailyStats  []DailyStat
 }
 
-// DailyStat represents egress for a single day
+// DailyStat represents egress for a single day, or for the hour, week or month starting on Date at other granularities
 type DailyStat struct {
 	Date   time.Time
 	Egress uint64
@@ -92,17 +152,45 @@ type DailyStat struct {
 
 // AccountEgress holds complete egress data for an account
 type AccountEgress struct {
-	Total  uint64
-	Spaces map[did.DID]SpaceEgress
+	Total uint64
+	// Granularity is the size of the buckets in the daily stats of every space
+	Granularity Granularity
+	Spaces      map[did.DID]SpaceEgress
+	// FailedSpaces are the spaces whose stats could not be fetched. They are missing from Spaces and Total.
+	FailedSpaces map[did.DID]error
 }
 
 // Service defines the interface for the egress tracking service
 type Service interface {
 	Record(ctx context.Context, node did.DID, receipts ucan.Link, endpoint *url.URL, cause invocation.Invocation) error
 	GetStats(ctx context.Context, node did.DID) (*Stats, error)
+	GetNodeStats(ctx context.Context, node did.DID, periodFilter *Period) (*NodeStats, error)
 	GetAllProvidersStats(ctx context.Context, limit int, startToken *string) (*GetAllProvidersStatsResult, error)
 	GetAllAccountsStats(ctx context.Context, limit int, startToken *string) (*GetAllAccountsStatsResult, error)
-	GetAccountEgress(ctx context.Context, accountDID did.DID, spacesFilter []did.DID, periodFilter *Period) (*AccountEgress, error)
+	GetAccountEgress(ctx context.Context, accountDID did.DID, spacesFilter []did.DID, periodFilter *Period, granularity Granularity) (*AccountEgress, error)
+	GetAccountQuota(ctx context.Context, accountDID did.DID) (*quota.Usage, error)
+	GetSpaceEgress(ctx context.Context, space did.DID, periodFilter *Period, granularity Granularity) (*SpaceEgress, error)
+	GetAnomalies(ctx context.Context, limit int, startToken *string) (*GetAnomaliesResult, error)
+	GetHeldBatches(ctx context.Context, limit int, startToken *string) (*GetHeldBatchesResult, error)
+	ApproveBatch(ctx context.Context, cause ucan.Link) error
+	RejectBatch(ctx context.Context, cause ucan.Link, reason string) error
+	GetCallbacks(ctx context.Context, limit int, startToken *string) (*GetCallbacksResult, error)
+	SetCallback(ctx context.Context, node did.DID, callbackURL string) error
+	ListBatches(ctx context.Context, node did.DID, period *Period, limit int, startToken *string) (*ListBatchesResult, error)
+	GetStatements(ctx context.Context, limit int, startToken *string) (*GetStatementsResult, error)
+	GetAccountStatements(ctx context.Context, account did.DID) ([]statements.Statement, error)
+	GetStatement(ctx context.Context, account did.DID, month time.Time) (*statements.Statement, error)
+	GeneratePayoutRun(ctx context.Context, month time.Time) (*payouts.Run, error)
+	FinalizePayoutRun(ctx context.Context, month time.Time) error
+	GetPayoutRuns(ctx context.Context) ([]payouts.Run, error)
+	GetPayoutRun(ctx context.Context, month time.Time) (*payouts.Run, error)
+	AttestPayoutRun(ctx context.Context, month time.Time) (int, error)
+	GetAttestation(ctx context.Context, cause ucan.Link) (*attestations.Attestation, error)
+	GetLedgerBalance(ctx context.Context, book ledger.Book, period Period, asOf time.Time) (*LedgerBalance, error)
+	PostLedgerAdjustment(ctx context.Context, adjustment LedgerAdjustment) (*ledger.Transaction, error)
+	ReverseBatch(ctx context.Context, cause ucan.Link, actor, reason string) (*ledger.Transaction, error)
+	GetExcludedSpacesStats(ctx context.Context) ([]ExcludedSpaceStats, error)
+	GetNetworkStats(ctx context.Context, period Period) (*NetworkStats, error)
 }
 
 type service struct {
@@ -113,6 +201,119 @@ type service struct {
 	customerTable        customer.CustomerTable
 	consumerTable        consumer.ConsumerTable
 	spaceStatsTable      spacestats.SpaceStatsTable
+	hourlyStatsTable     hourlystats.HourlyStatsTable
+	accountStatsTable    accountstats.AccountStatsTable
+	networkStatsTable    networkstats.NetworkStatsTable
+	anomalyTable         anomalies.AnomalyTable
+	callbackTable        callbacks.CallbackTable
+	notifier             *callback.Notifier
+	statementTable       statements.StatementTable
+	payoutTable          payouts.PayoutTable
+	attestationTable     attestations.AttestationTable
+	ledgerTable          ledger.LedgerTable
+	pricer               *pricing.Engine
+	payRates             *pricing.RateCards
+	quotas               *quota.Quotas
+	quotaMonitor         *quota.Monitor
+	exclusions           *exclusion.List
+	networks             []did.DID
+}
+
+type Option func(*service)
+
+// WithHourlyStats serves the egress of spaces per hour from the given table.
+func WithHourlyStats(hourlyStatsTable hourlystats.HourlyStatsTable) Option {
+	return func(s *service) {
+		s.hourlyStatsTable = hourlyStatsTable
+	}
+}
+
+// WithAccountStats serves the egress of accounts from their daily rollups instead of the stats of their spaces.
+func WithAccountStats(accountStatsTable accountstats.AccountStatsTable) Option {
+	return func(s *service) {
+		s.accountStatsTable = accountStatsTable
+	}
+}
+
+// WithNetworkStats serves the egress of the given upload services (networks) per day.
+func WithNetworkStats(networkStatsTable networkstats.NetworkStatsTable, networks []did.DID) Option {
+	return func(s *service) {
+		s.networkStatsTable = networkStatsTable
+		s.networks = networks
+	}
+}
+
+// WithAnomalies serves the anomalies flagged by the anomaly detector.
+func WithAnomalies(anomalyTable anomalies.AnomalyTable) Option {
+	return func(s *service) {
+		s.anomalyTable = anomalyTable
+	}
+}
+
+// WithCallbacks registers the callbacks of nodes in the given table, and pushes the receipts of reviewed
+// batches to them with the given notifier.
+func WithCallbacks(callbackTable callbacks.CallbackTable, notifier *callback.Notifier) Option {
+	return func(s *service) {
+		s.callbackTable = callbackTable
+		s.notifier = notifier
+	}
+}
+
+// WithStatements serves the monthly statements of accounts.
+func WithStatements(statementTable statements.StatementTable) Option {
+	return func(s *service) {
+		s.statementTable = statementTable
+	}
+}
+
+// WithPayouts generates the monthly payout runs of storage providers and attests their lines.
+func WithPayouts(payoutTable payouts.PayoutTable, attestationTable attestations.AttestationTable) Option {
+	return func(s *service) {
+		s.payoutTable = payoutTable
+		s.attestationTable = attestationTable
+	}
+}
+
+// WithLedger posts reversals and adjustments to the egress ledger and serves the balances of its books.
+func WithLedger(ledgerTable ledger.LedgerTable) Option {
+	return func(s *service) {
+		s.ledgerTable = ledgerTable
+	}
+}
+
+// WithPricing prices the egress of accounts with the plans of their spaces.
+func WithPricing(pricer *pricing.Engine) Option {
+	return func(s *service) {
+		s.pricer = pricer
+	}
+}
+
+// WithPayRates prices the egress served by nodes with their rate cards.
+func WithPayRates(payRates *pricing.RateCards) Option {
+	return func(s *service) {
+		s.payRates = payRates
+	}
+}
+
+// WithQuotas reports the usage of the monthly egress quotas of accounts.
+func WithQuotas(quotas *quota.Quotas) Option {
+	return func(s *service) {
+		s.quotas = quotas
+	}
+}
+
+// WithQuotaMonitor feeds the egress of approved batches recorded for accounts to the monitor of their quota.
+func WithQuotaMonitor(quotaMonitor *quota.Monitor) Option {
+	return func(s *service) {
+		s.quotaMonitor = quotaMonitor
+	}
+}
+
+// WithExclusions serves the egress of the spaces excluded from billing and payouts.
+func WithExclusions(exclusions *exclusion.List) Option {
+	return func(s *service) {
+		s.exclusions = exclusions
+	}
 }
 
 func New(
@@ -123,8 +324,9 @@ func New(
 	customerTable customer.CustomerTable,
 	consumerTable consumer.ConsumerTable,
 	spaceStatsTable spacestats.SpaceStatsTable,
+	opts ...Option,
 ) (*service, error) {
-	return &service{
+	s := &service{
 		id:                   id,
 		egressTable:          egressTable,
 		consolidatedTable:    consolidatedTable,
@@ -132,7 +334,13 @@ func New(
 		customerTable:        customerTable,
 		consumerTable:        consumerTable,
 		spaceStatsTable:      spaceStatsTable,
-	}, nil
+	}
+
+	for _, opt := range opts {
+		opt(s)
+	}
+
+	return s, nil
 }
 
 func (s *service) Record(ctx context.Context, node did.DID, receipts ucan.Link, endpoint *url.URL, cause invocation.Invocation) error {
@@ -147,22 +355,92 @@ func (s *service) Record(ctx context.Context, node did.DID, receipts ucan.Link,
 	return nil
 }
 
+// GetStats returns the egress of the node in the usual periods, and its payout at the rates in effect on each day
 func (s *service) GetStats(ctx context.Context, node did.DID) (*Stats, error) {
 	stats := NewStats(time.Now().UTC())
 
 	// Get records from the beginning of previous month (earliest period we need)
-	records, err := s.consolidatedTable.GetStatsByNode(ctx, node, stats.Earliest())
-	if err != nil {
-		return nil, err
-	}
+	for record, err := range consolidated.GetCountedByNode(ctx, s.consolidatedTable, node, stats.Earliest()) {
+		if err != nil {
+			return nil, err
+		}
 
-	for _, record := range records {
-		stats.AddEgress(record.TotalEgress, record.ProcessedAt)
+		s.addNodeEgress(stats, node, record.TotalEgress, record.CountedAt())
 	}
 
 	return stats, nil
 }
 
+// addNodeEgress adds egress counted for the node to the stats along with its payout
+func (s *service) addNodeEgress(stats *Stats, node did.DID, egress uint64, when time.Time) {
+	stats.AddEgress(egress, when)
+	if s.payRates != nil {
+		stats.AddCost(s.payRates.Payout(node, egress, when), when)
+	}
+}
+
+// NodeStats holds the stats of a node plus the daily breakdown of its egress over a period
+type NodeStats struct {
+	Stats      *Stats
+	Period     Period
+	Total      uint64
+	DailyStats []DailyStat
+}
+
+// GetNodeStats returns the stats of a node and its consolidated egress per day in the given period.
+// A nil period covers the same range as the stats, from the first day of the previous month to now.
+func (s *service) GetNodeStats(ctx context.Context, node did.DID, periodFilter *Period) (*NodeStats, error) {
+	now := time.Now().UTC()
+	stats := NewStats(now)
+
+	period := Period{From: stats.Earliest(), To: now}
+	if periodFilter != nil {
+		if !periodFilter.From.Before(periodFilter.To) {
+			return nil, NewPeriodNotAcceptableError(fmt.Sprintf("'from' date %s is after or same as 'to' date %s", periodFilter.From, periodFilter.To))
+		}
+		period = *periodFilter
+	}
+
+	since := stats.Earliest()
+	if period.From.Before(since) {
+		since = period.From
+	}
+
+	var total uint64
+	daily := make(map[time.Time]uint64)
+	for record, err := range consolidated.GetCountedByNode(ctx, s.consolidatedTable, node, since) {
+		if err != nil {
+			return nil, err
+		}
+
+		countedAt := record.CountedAt().UTC()
+		s.addNodeEgress(stats, node, record.TotalEgress, countedAt)
+
+		if countedAt.Before(period.From) || !countedAt.Before(period.To) {
+			continue
+		}
+
+		day := time.Date(countedAt.Year(), countedAt.Month(), countedAt.Day(), 0, 0, 0, 0, time.UTC)
+		daily[day] += record.TotalEgress
+		total += record.TotalEgress
+	}
+
+	dailyStats := make([]DailyStat, 0, len(daily))
+	for day, egress := range daily {
+		dailyStats = append(dailyStats, DailyStat{Date: day, Egress: egress})
+	}
+	slices.SortFunc(dailyStats, func(a, b DailyStat) int {
+		return a.Date.Compare(b.Date)
+	})
+
+	return &NodeStats{
+		Stats:      stats,
+		Period:     period,
+		Total:      total,
+		DailyStats: dailyStats,
+	}, nil
+}
+
 type ProviderWithStats struct {
 	Provider   storageproviders.StorageProviderRecord
 	Stats      *Stats
@@ -203,6 +481,9 @@ type AccountStats struct {
 	Account    did.DID
 	Stats      *Stats
 	StatsError error // If there was an error fetching stats for this account
+	// Quota is the usage of the monthly egress limit of the account, nil if it is unlimited
+	Quota      *quota.Usage
+	QuotaError error
 }
 
 type GetAllAccountsStatsResult struct {
@@ -221,11 +502,14 @@ func (s *service) GetAllAccountsStats(ctx context.Context, limit int, startToken
 	accountsWithStats := make([]AccountStats, 0, len(result.Customers))
 	for _, customerID := range result.Customers {
 		stats, err := s.getAccountStats(ctx, customerID)
+		usage, quotaErr := s.getQuotaUsage(ctx, customerID)
 
 		accountsWithStats = append(accountsWithStats, AccountStats{
 			Account:    customerID,
 			Stats:      stats,
 			StatsError: err, // Store error so we can show partial results
+			Quota:      usage,
+			QuotaError: quotaErr,
 		})
 	}
 
@@ -235,20 +519,70 @@ func (s *service) GetAllAccountsStats(ctx context.Context, limit int, startToken
 	}, nil
 }
 
-// getAccountStats calculates aggregated stats for an account by fetching all spaces and their daily stats
+// getAccountStats calculates aggregated stats for an account from its materialized daily stats, or by fetching
+// all spaces and their daily stats when account stats are not recorded. Egress is priced with the plan of each
+// space.
 func (s *service) getAccountStats(
 	ctx context.Context,
 	customerID did.DID,
 ) (*Stats, error) {
 	stats := NewStats(time.Now().UTC())
 
+	if s.accountStatsTable != nil {
+		dailyStats, err := s.accountStatsTable.GetDailyStats(ctx, customerID, stats.Earliest(), time.Now().UTC())
+		if err != nil {
+			return nil, err
+		}
+
+		plans := make(map[string]pricing.Plan)
+		daily := make(map[string]map[time.Time]uint64)
+		addDaily := func(plan pricing.Plan, egress uint64, date time.Time) {
+			if _, ok := daily[plan.Name]; !ok {
+				plans[plan.Name] = plan
+				daily[plan.Name] = make(map[time.Time]uint64)
+			}
+			daily[plan.Name][date] += egress
+		}
+
+		for _, stat := range dailyStats {
+			stats.AddEgress(stat.Egress, stat.Date)
+			if s.pricer == nil {
+				continue
+			}
+
+			// egress recorded before it was attributed to plans, or to plans no longer configured, is priced
+			// with the default plan
+			unattributed := stat.Egress
+			for name, egress := range stat.Plans {
+				egress = min(egress, unattributed)
+				unattributed -= egress
+				plan, ok := s.pricer.Plan(name)
+				if !ok {
+					plan = s.pricer.Default()
+				}
+				addDaily(plan, egress, stat.Date)
+			}
+			if unattributed > 0 {
+				addDaily(s.pricer.Default(), unattributed, stat.Date)
+			}
+		}
+
+		for name, plan := range plans {
+			addCosts(stats, plan, daily[name])
+		}
+
+		return stats, nil
+	}
+
 	// Get all spaces (consumers) for this account
 	spaces, err := s.consumerTable.ListByCustomer(ctx, customerID)
 	if err != nil {
 		return nil, err
 	}
 
-	// Aggregate stats across all spaces
+	// Aggregate stats across all spaces, and the daily egress of the spaces of every plan
+	plans := make(map[string]pricing.Plan)
+	daily := make(map[string]map[time.Time]uint64)
 	for _, space := range spaces {
 		// Fetch daily stats for this space from the beginning of previous month to now
 		dailyStats, err := s.spaceStatsTable.GetDailyStats(ctx, space, stats.Earliest(), time.Now().UTC())
@@ -260,12 +594,41 @@ func (s *service) getAccountStats(
 		for _, stat := range dailyStats {
 			stats.AddEgress(stat.Egress, stat.Date)
 		}
+
+		if s.pricer == nil {
+			continue
+		}
+
+		plan, err := s.pricer.PlanForSpace(ctx, s.consumerTable, space)
+		if err != nil {
+			return nil, fmt.Errorf("resolving plan of space %s: %w", space, err)
+		}
+		if _, ok := daily[plan.Name]; !ok {
+			plans[plan.Name] = plan
+			daily[plan.Name] = make(map[time.Time]uint64)
+		}
+		for _, stat := range dailyStats {
+			daily[plan.Name][stat.Date] += stat.Egress
+		}
+	}
+
+	for name, plan := range plans {
+		addCosts(stats, plan, daily[name])
 	}
 
 	return stats, err
 }
 
-const maxPeriodDays = 60
+// addCosts prices the daily egress of the spaces of a plan in date order, each day against the egress of the
+// days before it in the same month, so that the allowance and tiers of the plan apply to the monthly total
+func addCosts(stats *Stats, plan pricing.Plan, daily map[time.Time]uint64) {
+	used := make(map[time.Time]uint64)
+	for _, date := range slices.SortedFunc(maps.Keys(daily), time.Time.Compare) {
+		month := time.Date(date.Year(), date.Month(), 1, 0, 0, 0, 0, time.UTC)
+		stats.AddCost(plan.PriceIncrement(used[month], daily[date]), date)
+		used[month] += daily[date]
+	}
+}
 
 // defaultPeriod returns a period from the first day of the last complete month to today
 func defaultPeriod() Period {
@@ -281,12 +644,64 @@ func defaultPeriod() Period {
 	}
 }
 
+// resolvePeriod validates the requested period and granularity. The period defaults to the first day of last
+// complete month to today and the granularity to the finest one allowed for the period.
+func resolvePeriod(periodFilter *Period, granularity Granularity) (Period, Granularity, error) {
+	if granularity == GranularityHour {
+		return resolveHourlyPeriod(periodFilter)
+	}
+
+	period := defaultPeriod()
+	if periodFilter != nil {
+		from := time.Date(periodFilter.From.Year(), periodFilter.From.Month(), periodFilter.From.Day(), 0, 0, 0, 0, period.From.Location())
+		to := time.Date(periodFilter.To.Year(), periodFilter.To.Month(), periodFilter.To.Day(), 0, 0, 0, 0, period.To.Location())
+		if from.After(to) || from.Equal(to) {
+			return Period{}, "", NewPeriodNotAcceptableError(fmt.Sprintf("'from' date %s is after or same as 'to' date %s", from, to))
+		}
+
+		daysBetween := int(to.Sub(from).Hours() / 24)
+		if daysBetween > maxPeriodDays {
+			return Period{}, "", NewPeriodNotAcceptableError(fmt.Sprintf("requested period exceeds maximum of %d days", maxPeriodDays))
+		}
+
+		period = *periodFilter
+	}
+
+	granularity, err := resolveGranularity(period, granularity)
+	if err != nil {
+		return Period{}, "", err
+	}
+
+	return period, granularity, nil
+}
+
+// resolveHourlyPeriod validates a period requested at hourly granularity, which can start and end at any
+// time of the day. It defaults to the last 24 hours.
+func resolveHourlyPeriod(periodFilter *Period) (Period, Granularity, error) {
+	now := time.Now().UTC()
+	period := Period{From: now.Add(-24 * time.Hour), To: now}
+	if periodFilter != nil {
+		if !periodFilter.From.Before(periodFilter.To) {
+			return Period{}, "", NewPeriodNotAcceptableError(fmt.Sprintf("'from' time %s is after or same as 'to' time %s", periodFilter.From, periodFilter.To))
+		}
+		period = *periodFilter
+	}
+
+	granularity, err := resolveGranularity(period, GranularityHour)
+	if err != nil {
+		return Period{}, "", err
+	}
+
+	return period, granularity, nil
+}
+
 // GetAccountEgress fetches egress data for an account with optional filters
 func (s *service) GetAccountEgress(
 	ctx context.Context,
 	accountDID did.DID,
 	spacesFilter []did.DID,
 	periodFilter *Period,
+	granularity Granularity,
 ) (*AccountEgress, error) {
 	// 1. Validate account exists
 	exists, err := s.customerTable.Has(ctx, accountDID)
@@ -321,62 +736,167 @@ func (s *service) GetAccountEgress(
 	// 3. If no spaces, return success with zeros (as per requirement)
 	if len(spacesToQuery) == 0 {
 		return &AccountEgress{
-			Total:  0,
-			Spaces: make(map[did.DID]SpaceEgress),
+			Total:       0,
+			Granularity: GranularityDay,
+			Spaces:      make(map[did.DID]SpaceEgress),
 		}, nil
 	}
 
-	// 4. Determine query time range
-	// Default: first day of last complete month to today
-	period := defaultPeriod()
-	if periodFilter != nil {
-		from := time.Date(periodFilter.From.Year(), periodFilter.From.Month(), periodFilter.From.Day(), 0, 0, 0, 0, period.From.Location())
-		to := time.Date(periodFilter.To.Year(), periodFilter.To.Month(), periodFilter.To.Day(), 0, 0, 0, 0, period.To.Location())
-		if from.After(to) || from.Equal(to) {
-			return nil, NewPeriodNotAcceptableError(fmt.Sprintf("'from' date %s is after or same as 'to' date %s", from, to))
-		}
-
-		daysBetween := int(to.Sub(from).Hours() / 24)
-		if daysBetween > maxPeriodDays {
-			return nil, NewPeriodNotAcceptableError(fmt.Sprintf("requested period exceeds maximum of %d days", maxPeriodDays))
-		}
-
-		period = *periodFilter
+	// 4. Determine query time range and granularity
+	period, granularity, err := resolvePeriod(periodFilter, granularity)
+	if err != nil {
+		return nil, err
 	}
 
 	// 5. Fetch and aggregate stats for each space
+	spacesData, failedSpaces := s.getSpacesStats(ctx, spacesToQuery, period, granularity)
+
 	var totalEgress uint64
-	spacesData := make(map[did.DID]SpaceEgress, len(spacesToQuery))
+	for _, spaceData := range spacesData {
+		totalEgress += spaceData.Total
+	}
 
-	for _, spaceDID := range spacesToQuery {
-		dailyStatsDB, err := s.spaceStatsTable.GetDailyStats(ctx, spaceDID, period.From, period.To)
-		if err != nil {
-			log.Errorf("failed to get daily stats for space %s: %v", spaceDID, err)
-			continue // Skip space but continue with others
-		}
+	return &AccountEgress{
+		Total:        totalEgress,
+		Granularity:  granularity,
+		Spaces:       spacesData,
+		FailedSpaces: failedSpaces,
+	}, nil
+}
 
-		// No need to filter - DB query already filtered by BETWEEN
-		var spaceTotal uint64
-		dailyStats := make([]DailyStat, 0, len(dailyStatsDB))
+// GetAccountQuota returns the usage of the monthly egress limit of the account in the current month, or nil if
+// quotas are not enforced or the account is unlimited
+func (s *service) GetAccountQuota(ctx context.Context, accountDID did.DID) (*quota.Usage, error) {
+	exists, err := s.customerTable.Has(ctx, accountDID)
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, NewAccountNotFoundError(accountDID)
+	}
 
-		for _, dbStat := range dailyStatsDB {
-			spaceTotal += dbStat.Egress
-			dailyStats = append(dailyStats, DailyStat{
-				Date:   dbStat.Date,
-				Egress: dbStat.Egress,
-			})
-		}
+	return s.getQuotaUsage(ctx, accountDID)
+}
 
-		// Include space even if it has no data - shows space exists but has zero egress
-		spacesData[spaceDID] = SpaceEgress{
-			Total:      spaceTotal,
-			DailyStats: dailyStats,
-		}
-		totalEgress += spaceTotal
+// getQuotaUsage returns the usage of the monthly egress limit of the account in the current month, or nil if
+// quotas are not enforced or the account is unlimited
+func (s *service) getQuotaUsage(ctx context.Context, account did.DID) (*quota.Usage, error) {
+	if s.quotas == nil {
+		return nil, nil
+	}
+	usage, err := s.quotas.Usage(ctx, account, time.Now())
+	if err != nil {
+		return nil, fmt.Errorf("getting quota usage: %w", err)
 	}
+	return usage, nil
+}
 
-	return &AccountEgress{
-		Total:  totalEgress,
-		Spaces: spacesData,
+// getSpacesStats fetches the stats of the spaces in parallel, within accountEgressTimeout. Spaces that fail are
+// returned separately along with their error.
+func (s *service) getSpacesStats(ctx context.Context, spaces []did.DID, period Period, granularity Granularity) (map[did.DID]SpaceEgress, map[did.DID]error) {
+	ctx, cancel := context.WithTimeout(ctx, accountEgressTimeout)
+	defer cancel()
+
+	var (
+		mu           sync.Mutex
+		wg           sync.WaitGroup
+		sem          = make(chan struct{}, spaceStatsConcurrency)
+		spacesData   = make(map[did.DID]SpaceEgress, len(spaces))
+		failedSpaces = make(map[did.DID]error)
+	)
+
+	for _, spaceDID := range spaces {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			select {
+			case sem <- struct{}{}:
+				defer func() { <-sem }()
+			case <-ctx.Done():
+				mu.Lock()
+				failedSpaces[spaceDID] = ctx.Err()
+				mu.Unlock()
+				return
+			}
+
+			dailyStats, err := s.getSpaceStats(ctx, spaceDID, period, granularity)
+
+			mu.Lock()
+			defer mu.Unlock()
+
+			if err != nil {
+				log.Errorf("failed to get daily stats for space %s: %v", spaceDID, err)
+				failedSpaces[spaceDID] = err
+				return
+			}
+
+			var spaceTotal uint64
+			for _, stat := range dailyStats {
+				spaceTotal += stat.Egress
+			}
+
+			// Include space even if it has no data - shows space exists but has zero egress
+			spacesData[spaceDID] = SpaceEgress{
+				Total:      spaceTotal,
+				DailyStats: dailyStats,
+			}
+		}()
+	}
+
+	wg.Wait()
+
+	return spacesData, failedSpaces
+}
+
+// GetSpaceEgress fetches the egress of a single space. Unlike GetAccountEgress it does not check
+// the space belongs to an account, callers are expected to hold authority over the space itself.
+func (s *service) GetSpaceEgress(ctx context.Context, space did.DID, periodFilter *Period, granularity Granularity) (*SpaceEgress, error) {
+	period, granularity, err := resolvePeriod(periodFilter, granularity)
+	if err != nil {
+		return nil, err
+	}
+
+	dailyStats, err := s.getSpaceStats(ctx, space, period, granularity)
+	if err != nil {
+		return nil, err
+	}
+
+	var total uint64
+	for _, stat := range dailyStats {
+		total += stat.Egress
+	}
+
+	return &SpaceEgress{
+		Total:       total,
+		Granularity: granularity,
+		DailyStats:  dailyStats,
+	}, nil
+}
+
+type GetAnomaliesResult struct {
+	Flags     []anomalies.Flag
+	NextToken *string
+}
+
+// GetAnomalies lists the egress anomaly flags raised by the detector, most recent first within each page
+func (s *service) GetAnomalies(ctx context.Context, limit int, startToken *string) (*GetAnomaliesResult, error) {
+	if s.anomalyTable == nil {
+		return nil, fmt.Errorf("anomalies are %w", ErrNotConfigured)
+	}
+
+	result, err := s.anomalyTable.List(ctx, limit, startToken)
+	if err != nil {
+		return nil, err
+	}
+
+	flags := result.Flags
+	slices.SortFunc(flags, func(a, b anomalies.Flag) int {
+		return b.DetectedAt.Compare(a.DetectedAt)
+	})
+
+	return &GetAnomaliesResult{
+		Flags:     flags,
+		NextToken: result.Cursor,
 	}, nil
 }
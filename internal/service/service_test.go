@@ -11,9 +11,13 @@ import (
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 
+	"github.com/storacha/etracker/internal/db/accountstats"
+	"github.com/storacha/etracker/internal/db/consolidated"
 	"github.com/storacha/etracker/internal/db/consumer"
 	"github.com/storacha/etracker/internal/db/customer"
+	"github.com/storacha/etracker/internal/db/hourlystats"
 	"github.com/storacha/etracker/internal/db/spacestats"
+	"github.com/storacha/etracker/internal/pricing"
 )
 
 // Mock implementations for database tables
@@ -36,10 +40,14 @@ func (m *mockCustomerTable) Has(ctx context.Context, customerDID did.DID) (bool,
 var _ customer.CustomerTable = (*mockCustomerTable)(nil)
 
 type mockConsumerTable struct {
+	getFunc            func(ctx context.Context, consumerID string) (consumer.Consumer, error)
 	listByCustomerFunc func(ctx context.Context, customerID did.DID) ([]did.DID, error)
 }
 
 func (m *mockConsumerTable) Get(ctx context.Context, consumerID string) (consumer.Consumer, error) {
+	if m.getFunc != nil {
+		return m.getFunc(ctx, consumerID)
+	}
 	return consumer.Consumer{}, fmt.Errorf("not implemented")
 }
 
@@ -53,10 +61,15 @@ func (m *mockConsumerTable) ListByCustomer(ctx context.Context, customerID did.D
 var _ consumer.ConsumerTable = (*mockConsumerTable)(nil)
 
 type mockSpaceStatsTable struct {
-	getDailyStatsFunc func(ctx context.Context, space did.DID, from time.Time, to time.Time) ([]spacestats.DailyStats, error)
+	recordFunc          func(ctx context.Context, space did.DID, egress uint64, at time.Time, id string) error
+	getDailyStatsFunc   func(ctx context.Context, space did.DID, from time.Time, to time.Time) ([]spacestats.DailyStats, error)
+	getMonthlyStatsFunc func(ctx context.Context, space did.DID, from time.Time, to time.Time) ([]spacestats.MonthlyStats, error)
 }
 
-func (m *mockSpaceStatsTable) Record(ctx context.Context, space did.DID, egress uint64) error {
+func (m *mockSpaceStatsTable) Record(ctx context.Context, space did.DID, egress uint64, at time.Time, id string) error {
+	if m.recordFunc != nil {
+		return m.recordFunc(ctx, space, egress, at, id)
+	}
 	return fmt.Errorf("not implemented")
 }
 
@@ -67,8 +80,36 @@ func (m *mockSpaceStatsTable) GetDailyStats(ctx context.Context, space did.DID,
 	return nil, fmt.Errorf("not implemented")
 }
 
+func (m *mockSpaceStatsTable) GetMonthlyStats(ctx context.Context, space did.DID, from time.Time, to time.Time) ([]spacestats.MonthlyStats, error) {
+	if m.getMonthlyStatsFunc != nil {
+		return m.getMonthlyStatsFunc(ctx, space, from, to)
+	}
+	return nil, fmt.Errorf("not implemented")
+}
+
 var _ spacestats.SpaceStatsTable = (*mockSpaceStatsTable)(nil)
 
+type mockHourlyStatsTable struct {
+	recordFunc         func(ctx context.Context, space did.DID, egress uint64, at time.Time, id string) error
+	getHourlyStatsFunc func(ctx context.Context, space did.DID, from time.Time, to time.Time) ([]hourlystats.HourlyStats, error)
+}
+
+func (m *mockHourlyStatsTable) Record(ctx context.Context, space did.DID, egress uint64, at time.Time, id string) error {
+	if m.recordFunc != nil {
+		return m.recordFunc(ctx, space, egress, at, id)
+	}
+	return fmt.Errorf("not implemented")
+}
+
+func (m *mockHourlyStatsTable) GetHourlyStats(ctx context.Context, space did.DID, from time.Time, to time.Time) ([]hourlystats.HourlyStats, error) {
+	if m.getHourlyStatsFunc != nil {
+		return m.getHourlyStatsFunc(ctx, space, from, to)
+	}
+	return nil, fmt.Errorf("not implemented")
+}
+
+var _ hourlystats.HourlyStatsTable = (*mockHourlyStatsTable)(nil)
+
 func TestGetAccountEgress(t *testing.T) {
 	t.Run("returns account not found error when account doesn't exist", func(t *testing.T) {
 		accountDID := testutil.RandomDID(t)
@@ -83,7 +124,7 @@ func TestGetAccountEgress(t *testing.T) {
 			customerTable: customerTable,
 		}
 
-		result, err := svc.GetAccountEgress(context.Background(), accountDID, nil, nil)
+		result, err := svc.GetAccountEgress(context.Background(), accountDID, nil, nil, GranularityAuto)
 
 		require.Error(t, err)
 		require.Nil(t, result)
@@ -106,7 +147,7 @@ func TestGetAccountEgress(t *testing.T) {
 			customerTable: customerTable,
 		}
 
-		result, err := svc.GetAccountEgress(context.Background(), accountDID, nil, nil)
+		result, err := svc.GetAccountEgress(context.Background(), accountDID, nil, nil, GranularityAuto)
 
 		require.Error(t, err)
 		require.Nil(t, result)
@@ -135,7 +176,7 @@ func TestGetAccountEgress(t *testing.T) {
 			consumerTable: consumerTable,
 		}
 
-		result, err := svc.GetAccountEgress(context.Background(), accountDID, []did.DID{unauthorizedSpace}, nil)
+		result, err := svc.GetAccountEgress(context.Background(), accountDID, []did.DID{unauthorizedSpace}, nil, GranularityAuto)
 
 		require.Error(t, err)
 		require.Nil(t, result)
@@ -167,7 +208,7 @@ func TestGetAccountEgress(t *testing.T) {
 			consumerTable: consumerTable,
 		}
 
-		result, err := svc.GetAccountEgress(context.Background(), accountDID, []did.DID{unauth1, unauth2}, nil)
+		result, err := svc.GetAccountEgress(context.Background(), accountDID, []did.DID{unauth1, unauth2}, nil, GranularityAuto)
 
 		require.Error(t, err)
 		require.Nil(t, result)
@@ -203,7 +244,7 @@ func TestGetAccountEgress(t *testing.T) {
 		to := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
 		period := &Period{From: from, To: to}
 
-		result, err := svc.GetAccountEgress(context.Background(), accountDID, nil, period)
+		result, err := svc.GetAccountEgress(context.Background(), accountDID, nil, period, GranularityAuto)
 
 		require.Error(t, err)
 		require.Nil(t, result)
@@ -236,7 +277,7 @@ func TestGetAccountEgress(t *testing.T) {
 		date := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
 		period := &Period{From: date, To: date}
 
-		result, err := svc.GetAccountEgress(context.Background(), accountDID, nil, period)
+		result, err := svc.GetAccountEgress(context.Background(), accountDID, nil, period, GranularityAuto)
 
 		require.Error(t, err)
 		require.Nil(t, result)
@@ -244,7 +285,7 @@ func TestGetAccountEgress(t *testing.T) {
 		require.ErrorAs(t, err, &periodErr)
 	})
 
-	t.Run("returns period not acceptable error when period exceeds 60 days", func(t *testing.T) {
+	t.Run("returns period not acceptable error when period exceeds 60 days at daily granularity", func(t *testing.T) {
 		accountDID := testutil.RandomDID(t)
 		space := testutil.RandomDID(t)
 
@@ -269,7 +310,7 @@ func TestGetAccountEgress(t *testing.T) {
 		to := time.Date(2024, 3, 2, 0, 0, 0, 0, time.UTC) // 61 days
 		period := &Period{From: from, To: to}
 
-		result, err := svc.GetAccountEgress(context.Background(), accountDID, nil, period)
+		result, err := svc.GetAccountEgress(context.Background(), accountDID, nil, period, GranularityDay)
 
 		require.Error(t, err)
 		require.Nil(t, result)
@@ -278,6 +319,53 @@ func TestGetAccountEgress(t *testing.T) {
 		assert.Contains(t, periodErr.msg, "60 days")
 	})
 
+	t.Run("serves periods longer than 60 days at a coarser granularity", func(t *testing.T) {
+		accountDID := testutil.RandomDID(t)
+		space := testutil.RandomDID(t)
+
+		customerTable := &mockCustomerTable{
+			hasFunc: func(ctx context.Context, customerDID did.DID) (bool, error) {
+				return true, nil
+			},
+		}
+
+		consumerTable := &mockConsumerTable{
+			listByCustomerFunc: func(ctx context.Context, customerID did.DID) ([]did.DID, error) {
+				return []did.DID{space}, nil
+			},
+		}
+
+		spaceStatsTable := &mockSpaceStatsTable{
+			getDailyStatsFunc: func(ctx context.Context, s did.DID, from time.Time, to time.Time) ([]spacestats.DailyStats, error) {
+				return []spacestats.DailyStats{
+					{Date: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC), Egress: 100}, // Monday
+					{Date: time.Date(2024, 1, 7, 0, 0, 0, 0, time.UTC), Egress: 200}, // Sunday
+					{Date: time.Date(2024, 1, 8, 0, 0, 0, 0, time.UTC), Egress: 400}, // Monday
+				}, nil
+			},
+		}
+
+		svc := &service{
+			customerTable:   customerTable,
+			consumerTable:   consumerTable,
+			spaceStatsTable: spaceStatsTable,
+		}
+
+		from := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+		to := time.Date(2024, 3, 2, 0, 0, 0, 0, time.UTC) // 61 days
+		period := &Period{From: from, To: to}
+
+		result, err := svc.GetAccountEgress(context.Background(), accountDID, nil, period, GranularityAuto)
+
+		require.NoError(t, err)
+		assert.Equal(t, GranularityWeek, result.Granularity)
+		assert.Equal(t, uint64(700), result.Total)
+		assert.Equal(t, []DailyStat{
+			{Date: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC), Egress: 300},
+			{Date: time.Date(2024, 1, 8, 0, 0, 0, 0, time.UTC), Egress: 400},
+		}, result.Spaces[space].DailyStats)
+	})
+
 	t.Run("successfully returns empty result for account with no spaces", func(t *testing.T) {
 		accountDID := testutil.RandomDID(t)
 
@@ -298,7 +386,7 @@ func TestGetAccountEgress(t *testing.T) {
 			consumerTable: consumerTable,
 		}
 
-		result, err := svc.GetAccountEgress(context.Background(), accountDID, nil, nil)
+		result, err := svc.GetAccountEgress(context.Background(), accountDID, nil, nil, GranularityAuto)
 
 		require.NoError(t, err)
 		require.NotNil(t, result)
@@ -354,7 +442,7 @@ func TestGetAccountEgress(t *testing.T) {
 		}
 
 		period := &Period{From: from, To: to}
-		result, err := svc.GetAccountEgress(context.Background(), accountDID, nil, period)
+		result, err := svc.GetAccountEgress(context.Background(), accountDID, nil, period, GranularityAuto)
 
 		require.NoError(t, err)
 		require.NotNil(t, result)
@@ -408,7 +496,7 @@ func TestGetAccountEgress(t *testing.T) {
 		}
 
 		period := &Period{From: from, To: to}
-		result, err := svc.GetAccountEgress(context.Background(), accountDID, []did.DID{space1}, period)
+		result, err := svc.GetAccountEgress(context.Background(), accountDID, []did.DID{space1}, period, GranularityAuto)
 
 		require.NoError(t, err)
 		require.NotNil(t, result)
@@ -417,7 +505,7 @@ func TestGetAccountEgress(t *testing.T) {
 		assert.Contains(t, result.Spaces, space1)
 	})
 
-	t.Run("continues aggregation even if one space stats fetch fails", func(t *testing.T) {
+	t.Run("continues aggregation and reports spaces whose stats fetch fails", func(t *testing.T) {
 		accountDID := testutil.RandomDID(t)
 		space1 := testutil.RandomDID(t)
 		space2 := testutil.RandomDID(t)
@@ -455,7 +543,7 @@ func TestGetAccountEgress(t *testing.T) {
 		}
 
 		period := &Period{From: from, To: to}
-		result, err := svc.GetAccountEgress(context.Background(), accountDID, nil, period)
+		result, err := svc.GetAccountEgress(context.Background(), accountDID, nil, period, GranularityAuto)
 
 		require.NoError(t, err)
 		require.NotNil(t, result)
@@ -463,6 +551,8 @@ func TestGetAccountEgress(t *testing.T) {
 		assert.Equal(t, uint64(300), result.Total)
 		assert.Len(t, result.Spaces, 1)
 		assert.Contains(t, result.Spaces, space2)
+		require.Len(t, result.FailedSpaces, 1)
+		assert.ErrorContains(t, result.FailedSpaces[space1], "database error")
 	})
 
 	t.Run("includes space with zero egress", func(t *testing.T) {
@@ -498,7 +588,7 @@ func TestGetAccountEgress(t *testing.T) {
 		}
 
 		period := &Period{From: from, To: to}
-		result, err := svc.GetAccountEgress(context.Background(), accountDID, nil, period)
+		result, err := svc.GetAccountEgress(context.Background(), accountDID, nil, period, GranularityAuto)
 
 		require.NoError(t, err)
 		require.NotNil(t, result)
@@ -509,3 +599,304 @@ func TestGetAccountEgress(t *testing.T) {
 		assert.Empty(t, result.Spaces[space].DailyStats)
 	})
 }
+
+func TestGetSpaceEgress(t *testing.T) {
+	space := testutil.RandomDID(t)
+
+	spaceStatsTable := &mockSpaceStatsTable{
+		getDailyStatsFunc: func(ctx context.Context, s did.DID, from time.Time, to time.Time) ([]spacestats.DailyStats, error) {
+			assert.Equal(t, space, s)
+			return []spacestats.DailyStats{
+				{Date: from, Egress: 100},
+				{Date: from.AddDate(0, 0, 1), Egress: 200},
+			}, nil
+		},
+	}
+
+	svc := &service{spaceStatsTable: spaceStatsTable}
+
+	t.Run("returns the daily stats of the space", func(t *testing.T) {
+		result, err := svc.GetSpaceEgress(context.Background(), space, nil, GranularityAuto)
+		require.NoError(t, err)
+		assert.Equal(t, uint64(300), result.Total)
+		assert.Len(t, result.DailyStats, 2)
+	})
+
+	t.Run("returns period not acceptable error when period exceeds the maximum", func(t *testing.T) {
+		from := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+		period := &Period{From: from, To: from.AddDate(0, 0, maxPeriodDays+1)}
+
+		result, err := svc.GetSpaceEgress(context.Background(), space, period, GranularityAuto)
+		require.Nil(t, result)
+		var periodErr ErrPeriodNotAcceptable
+		require.ErrorAs(t, err, &periodErr)
+	})
+
+	t.Run("combines monthly rollups with daily stats of partial months", func(t *testing.T) {
+		var dailyQueries [][2]time.Time
+		svc := &service{spaceStatsTable: &mockSpaceStatsTable{
+			getDailyStatsFunc: func(ctx context.Context, s did.DID, from time.Time, to time.Time) ([]spacestats.DailyStats, error) {
+				dailyQueries = append(dailyQueries, [2]time.Time{from, to})
+				return []spacestats.DailyStats{{Date: from, Egress: 1}}, nil
+			},
+			getMonthlyStatsFunc: func(ctx context.Context, s did.DID, from time.Time, to time.Time) ([]spacestats.MonthlyStats, error) {
+				assert.Equal(t, time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC), from)
+				assert.Equal(t, time.Date(2024, 11, 30, 0, 0, 0, 0, time.UTC), to)
+				return []spacestats.MonthlyStats{
+					{Month: time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC), Egress: 1000},
+					{Month: time.Date(2024, 11, 1, 0, 0, 0, 0, time.UTC), Egress: 2000},
+				}, nil
+			},
+		}}
+
+		period := &Period{
+			From: time.Date(2024, 1, 15, 0, 0, 0, 0, time.UTC),
+			To:   time.Date(2024, 12, 10, 0, 0, 0, 0, time.UTC),
+		}
+		result, err := svc.GetSpaceEgress(context.Background(), space, period, GranularityAuto)
+		require.NoError(t, err)
+
+		assert.Equal(t, GranularityMonth, result.Granularity)
+		assert.Equal(t, uint64(3002), result.Total)
+		assert.Equal(t, [][2]time.Time{
+			{time.Date(2024, 1, 15, 0, 0, 0, 0, time.UTC), time.Date(2024, 1, 31, 0, 0, 0, 0, time.UTC)},
+			{time.Date(2024, 12, 1, 0, 0, 0, 0, time.UTC), time.Date(2024, 12, 10, 0, 0, 0, 0, time.UTC)},
+		}, dailyQueries)
+		assert.Equal(t, []DailyStat{
+			{Date: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC), Egress: 1},
+			{Date: time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC), Egress: 1000},
+			{Date: time.Date(2024, 11, 1, 0, 0, 0, 0, time.UTC), Egress: 2000},
+			{Date: time.Date(2024, 12, 1, 0, 0, 0, 0, time.UTC), Egress: 1},
+		}, result.DailyStats)
+	})
+
+	t.Run("returns hourly stats for intra-day periods", func(t *testing.T) {
+		period := &Period{
+			From: time.Date(2024, 3, 1, 10, 30, 0, 0, time.UTC),
+			To:   time.Date(2024, 3, 1, 13, 0, 0, 0, time.UTC),
+		}
+		svc := &service{hourlyStatsTable: &mockHourlyStatsTable{
+			getHourlyStatsFunc: func(ctx context.Context, s did.DID, from time.Time, to time.Time) ([]hourlystats.HourlyStats, error) {
+				assert.Equal(t, space, s)
+				assert.Equal(t, period.From, from)
+				assert.Equal(t, period.To, to)
+				return []hourlystats.HourlyStats{
+					{Hour: time.Date(2024, 3, 1, 10, 0, 0, 0, time.UTC), Egress: 10},
+					{Hour: time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC), Egress: 20},
+				}, nil
+			},
+		}}
+
+		result, err := svc.GetSpaceEgress(context.Background(), space, period, GranularityHour)
+		require.NoError(t, err)
+		assert.Equal(t, GranularityHour, result.Granularity)
+		assert.Equal(t, uint64(30), result.Total)
+		assert.Len(t, result.DailyStats, 2)
+	})
+
+	t.Run("returns period not acceptable error when hourly period exceeds the maximum", func(t *testing.T) {
+		from := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+		period := &Period{From: from, To: from.AddDate(0, 0, maxHourlyPeriodDays).Add(time.Hour)}
+
+		svc := &service{hourlyStatsTable: &mockHourlyStatsTable{}}
+		result, err := svc.GetSpaceEgress(context.Background(), space, period, GranularityHour)
+		require.Nil(t, result)
+		var periodErr ErrPeriodNotAcceptable
+		require.ErrorAs(t, err, &periodErr)
+	})
+
+	t.Run("returns an error when hourly stats are not recorded", func(t *testing.T) {
+		result, err := svc.GetSpaceEgress(context.Background(), space, nil, GranularityHour)
+		require.Nil(t, result)
+		require.Error(t, err)
+	})
+}
+
+func TestGetNodeStats(t *testing.T) {
+	node := testutil.RandomDID(t)
+	otherNode := testutil.RandomDID(t)
+	now := time.Now().UTC()
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	// far enough back to be out of the default period
+	longAgo := today.AddDate(0, -6, 0)
+
+	consolidatedTable := &mockConsolidatedTable{records: map[string]*consolidated.ConsolidatedRecord{}}
+	for _, r := range []consolidated.ConsolidatedRecord{
+		{Node: node, TotalEgress: 100, ProcessedAt: today},
+		{Node: node, TotalEgress: 200, ProcessedAt: today},
+		{Node: node, TotalEgress: 400, ProcessedAt: longAgo.Add(time.Hour)},
+		{Node: node, TotalEgress: 800, ProcessedAt: longAgo.AddDate(0, 0, 1)},
+		{Node: otherNode, TotalEgress: 1600, ProcessedAt: today},
+	} {
+		r.Cause = testutil.RandomCID(t)
+		consolidatedTable.records[r.Cause.String()] = &r
+	}
+
+	svc := &service{consolidatedTable: consolidatedTable}
+
+	t.Run("default period", func(t *testing.T) {
+		result, err := svc.GetNodeStats(context.Background(), node, nil)
+		require.NoError(t, err)
+
+		assert.Equal(t, uint64(300), result.Stats.CurrentDay.Egress)
+		assert.Equal(t, uint64(300), result.Total)
+		require.Len(t, result.DailyStats, 1)
+		assert.Equal(t, today, result.DailyStats[0].Date)
+	})
+
+	t.Run("arbitrary period", func(t *testing.T) {
+		period := &Period{From: longAgo, To: longAgo.AddDate(0, 0, 7)}
+		result, err := svc.GetNodeStats(context.Background(), node, period)
+		require.NoError(t, err)
+
+		// stats cover the usual periods regardless of the requested one
+		assert.Equal(t, uint64(300), result.Stats.CurrentDay.Egress)
+		assert.Equal(t, *period, result.Period)
+		assert.Equal(t, uint64(1200), result.Total)
+		require.Len(t, result.DailyStats, 2)
+		assert.Equal(t, longAgo, result.DailyStats[0].Date)
+		assert.Equal(t, uint64(400), result.DailyStats[0].Egress)
+		assert.Equal(t, longAgo.AddDate(0, 0, 1), result.DailyStats[1].Date)
+		assert.Equal(t, uint64(800), result.DailyStats[1].Egress)
+	})
+
+	t.Run("invalid period", func(t *testing.T) {
+		_, err := svc.GetNodeStats(context.Background(), node, &Period{From: today, To: longAgo})
+		var periodErr ErrPeriodNotAcceptable
+		require.ErrorAs(t, err, &periodErr)
+	})
+}
+
+func TestGetAccountStatsCost(t *testing.T) {
+	const tib = 1 << 40
+
+	account := testutil.RandomDID(t)
+	proSpace := testutil.RandomDID(t)
+	otherSpace := testutil.RandomDID(t)
+
+	now := time.Now().UTC()
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	thisMonth := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC)
+	lastMonth := thisMonth.AddDate(0, -1, 0)
+
+	dailyStats := map[did.DID][]spacestats.DailyStats{
+		proSpace: {
+			{Date: lastMonth, Egress: tib / 2},
+			// crosses the allowance: half of it is charged at $10
+			{Date: lastMonth.AddDate(0, 0, 9), Egress: tib},
+			// crosses the tier boundary: half of it is charged at $10 and the other half at $5
+			{Date: lastMonth.AddDate(0, 0, 19), Egress: tib},
+			// the allowance is renewed every month
+			{Date: thisMonth, Egress: tib},
+		},
+		otherSpace: {
+			{Date: lastMonth.AddDate(0, 0, 14), Egress: tib / 4},
+			{Date: today, Egress: tib / 2},
+		},
+	}
+
+	pricer, err := pricing.New(pricing.Config{
+		Default: pricing.FlatPlan("", 20),
+		Plans: map[string]pricing.Plan{
+			"pro": {IncludedEgress: tib, Tiers: []pricing.Tier{{From: 0, USDPerTiB: 10}, {From: 2 * tib, USDPerTiB: 5}}},
+		},
+	})
+	require.NoError(t, err)
+
+	svc := &service{
+		consumerTable: &mockConsumerTable{
+			getFunc: func(ctx context.Context, consumerID string) (consumer.Consumer, error) {
+				if consumerID == proSpace.String() {
+					return consumer.Consumer{ID: proSpace, Subscription: "pro"}, nil
+				}
+				return consumer.Consumer{}, consumer.ErrNotFound
+			},
+			listByCustomerFunc: func(ctx context.Context, customerID did.DID) ([]did.DID, error) {
+				return []did.DID{proSpace, otherSpace}, nil
+			},
+		},
+		spaceStatsTable: &mockSpaceStatsTable{
+			getDailyStatsFunc: func(ctx context.Context, space did.DID, from time.Time, to time.Time) ([]spacestats.DailyStats, error) {
+				return dailyStats[space], nil
+			},
+		},
+		pricer: pricer,
+	}
+
+	stats, err := svc.getAccountStats(context.Background(), account)
+	require.NoError(t, err)
+
+	assert.Equal(t, uint64(2*tib+tib/2+tib/4), stats.PreviousMonth.Egress)
+	assert.InDelta(t, 5+7.5+5, stats.PreviousMonth.Cost, 1e-9)
+	assert.InDelta(t, 10, stats.CurrentMonth.Cost, 1e-9)
+	assert.InDelta(t, 10, stats.CurrentDay.Cost, 1e-9)
+}
+
+type mockAccountStatsTable struct {
+	recordFunc        func(ctx context.Context, account did.DID, plan string, egress uint64, at time.Time, id string) error
+	getDailyStatsFunc func(ctx context.Context, account did.DID, from time.Time, to time.Time) ([]accountstats.DailyStats, error)
+}
+
+func (m *mockAccountStatsTable) Record(ctx context.Context, account did.DID, plan string, egress uint64, at time.Time, id string) error {
+	if m.recordFunc != nil {
+		return m.recordFunc(ctx, account, plan, egress, at, id)
+	}
+	return fmt.Errorf("not implemented")
+}
+
+func (m *mockAccountStatsTable) Put(ctx context.Context, account did.DID, plans map[string]uint64, at time.Time) error {
+	return fmt.Errorf("not implemented")
+}
+
+func (m *mockAccountStatsTable) GetDailyStats(ctx context.Context, account did.DID, from time.Time, to time.Time) ([]accountstats.DailyStats, error) {
+	return m.getDailyStatsFunc(ctx, account, from, to)
+}
+
+func TestGetAccountStatsCostFromRollup(t *testing.T) {
+	const tib = 1 << 40
+
+	account := testutil.RandomDID(t)
+
+	now := time.Now().UTC()
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	thisMonth := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC)
+	lastMonth := thisMonth.AddDate(0, -1, 0)
+
+	pricer, err := pricing.New(pricing.Config{
+		Default: pricing.FlatPlan("", 20),
+		Plans: map[string]pricing.Plan{
+			"pro": {IncludedEgress: tib, Tiers: []pricing.Tier{{From: 0, USDPerTiB: 10}, {From: 2 * tib, USDPerTiB: 5}}},
+		},
+	})
+	require.NoError(t, err)
+
+	svc := &service{
+		// spaces are not looked up when the account rollup is recorded
+		consumerTable: &mockConsumerTable{},
+		accountStatsTable: &mockAccountStatsTable{
+			getDailyStatsFunc: func(ctx context.Context, account did.DID, from time.Time, to time.Time) ([]accountstats.DailyStats, error) {
+				return []accountstats.DailyStats{
+					{Date: lastMonth, Egress: tib / 2, Plans: map[string]uint64{"pro": tib / 2}},
+					// crosses the allowance: half of it is charged at $10
+					{Date: lastMonth.AddDate(0, 0, 9), Egress: tib, Plans: map[string]uint64{"pro": tib}},
+					{Date: lastMonth.AddDate(0, 0, 14), Egress: tib / 4, Plans: map[string]uint64{pricing.DefaultPlanName: tib / 4}},
+					// crosses the tier boundary: half of it is charged at $10 and the other half at $5
+					{Date: lastMonth.AddDate(0, 0, 19), Egress: tib, Plans: map[string]uint64{"pro": tib}},
+					// the allowance is renewed every month
+					{Date: thisMonth, Egress: tib, Plans: map[string]uint64{"pro": tib}},
+					// recorded before egress was attributed to plans, priced with the default plan
+					{Date: today, Egress: tib / 2},
+				}, nil
+			},
+		},
+		pricer: pricer,
+	}
+
+	stats, err := svc.getAccountStats(context.Background(), account)
+	require.NoError(t, err)
+
+	assert.Equal(t, uint64(2*tib+tib/2+tib/4), stats.PreviousMonth.Egress)
+	assert.InDelta(t, 5+7.5+5, stats.PreviousMonth.Cost, 1e-9)
+	assert.InDelta(t, 10, stats.CurrentMonth.Cost, 1e-9)
+	assert.InDelta(t, 10, stats.CurrentDay.Cost, 1e-9)
+}
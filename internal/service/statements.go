@@ -0,0 +1,64 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/storacha/go-ucanto/did"
+
+	"github.com/storacha/etracker/internal/db/statements"
+)
+
+var ErrStatementNotFound = statements.ErrNotFound
+
+type GetStatementsResult struct {
+	Statements []statements.Statement
+	NextToken  *string
+}
+
+// GetStatements lists the monthly statements of all accounts, most recent month first within each page
+func (s *service) GetStatements(ctx context.Context, limit int, startToken *string) (*GetStatementsResult, error) {
+	if s.statementTable == nil {
+		return nil, fmt.Errorf("statements are %w", ErrNotConfigured)
+	}
+
+	result, err := s.statementTable.List(ctx, limit, startToken)
+	if err != nil {
+		return nil, err
+	}
+
+	stmts := result.Statements
+	slices.SortFunc(stmts, func(a, b statements.Statement) int {
+		if c := b.Month.Compare(a.Month); c != 0 {
+			return c
+		}
+		return strings.Compare(a.Account.String(), b.Account.String())
+	})
+
+	return &GetStatementsResult{
+		Statements: stmts,
+		NextToken:  result.Cursor,
+	}, nil
+}
+
+// GetAccountStatements lists the monthly statements of an account, oldest first
+func (s *service) GetAccountStatements(ctx context.Context, account did.DID) ([]statements.Statement, error) {
+	if s.statementTable == nil {
+		return nil, fmt.Errorf("statements are %w", ErrNotConfigured)
+	}
+
+	return s.statementTable.ListByAccount(ctx, account)
+}
+
+// GetStatement returns the statement of an account for the month of the given time, or ErrStatementNotFound if
+// the month was not closed
+func (s *service) GetStatement(ctx context.Context, account did.DID, month time.Time) (*statements.Statement, error) {
+	if s.statementTable == nil {
+		return nil, fmt.Errorf("statements are %w", ErrNotConfigured)
+	}
+
+	return s.statementTable.Get(ctx, account, month)
+}
@@ -9,6 +9,8 @@ type Period struct {
 
 type PeriodStats struct {
 	Egress uint64
+	// Cost is the price in USD of the egress, when it is priced
+	Cost   float64
 	Period Period
 }
 
@@ -75,24 +77,41 @@ func (s *Stats) Earliest() time.Time {
 }
 
 func (s *Stats) AddEgress(egress uint64, when time.Time) {
-	// Add the egress to the corresponding period(s)
+	for _, period := range s.periodsOf(when) {
+		period.Egress += egress
+	}
+}
+
+// AddCost adds the price of egress served at the given time to the periods it falls in
+func (s *Stats) AddCost(usd float64, when time.Time) {
+	for _, period := range s.periodsOf(when) {
+		period.Cost += usd
+	}
+}
+
+// periodsOf returns the periods the given time falls in
+func (s *Stats) periodsOf(when time.Time) []*PeriodStats {
+	var periods []*PeriodStats
+
 	// Previous month
 	if !when.Before(s.PreviousMonth.Period.From) && when.Before(s.CurrentMonth.Period.From) {
-		s.PreviousMonth.Egress += egress
+		periods = append(periods, &s.PreviousMonth)
 	}
 
 	// Current month
 	if !when.Before(s.CurrentMonth.Period.From) {
-		s.CurrentMonth.Egress += egress
+		periods = append(periods, &s.CurrentMonth)
 	}
 
 	// Current week
 	if !when.Before(s.CurrentWeek.Period.From) {
-		s.CurrentWeek.Egress += egress
+		periods = append(periods, &s.CurrentWeek)
 	}
 
 	// Current day
 	if !when.Before(s.CurrentDay.Period.From) {
-		s.CurrentDay.Egress += egress
+		periods = append(periods, &s.CurrentDay)
 	}
+
+	return periods
 }
@@ -6,14 +6,30 @@ import (
 	"crypto/subtle"
 	_ "embed"
 	"encoding/base64"
+	"errors"
 	"fmt"
 	"html/template"
 	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
 	"sync"
 	"time"
 
+	"github.com/ipfs/go-cid"
 	logging "github.com/ipfs/go-log/v2"
-
+	cidlink "github.com/ipld/go-ipld-prime/linking/cid"
+	"github.com/storacha/go-ucanto/did"
+	"github.com/storacha/go-ucanto/ucan"
+
+	"github.com/storacha/etracker/internal/billing"
+	"github.com/storacha/etracker/internal/db/anomalies"
+	"github.com/storacha/etracker/internal/db/callbacks"
+	"github.com/storacha/etracker/internal/db/consolidated"
+	"github.com/storacha/etracker/internal/db/ledger"
+	"github.com/storacha/etracker/internal/db/payouts"
+	"github.com/storacha/etracker/internal/db/statements"
+	"github.com/storacha/etracker/internal/pricing"
 	"github.com/storacha/etracker/internal/service"
 )
 
@@ -67,6 +83,24 @@ var cleanupOnce sync.Once
 type StatsService interface {
 	GetAllProvidersStats(ctx context.Context, limit int, startToken *string) (*service.GetAllProvidersStatsResult, error)
 	GetAllAccountsStats(ctx context.Context, limit int, startToken *string) (*service.GetAllAccountsStatsResult, error)
+	GetAnomalies(ctx context.Context, limit int, startToken *string) (*service.GetAnomaliesResult, error)
+	GetHeldBatches(ctx context.Context, limit int, startToken *string) (*service.GetHeldBatchesResult, error)
+	ApproveBatch(ctx context.Context, cause ucan.Link) error
+	RejectBatch(ctx context.Context, cause ucan.Link, reason string) error
+	GetCallbacks(ctx context.Context, limit int, startToken *string) (*service.GetCallbacksResult, error)
+	SetCallback(ctx context.Context, node did.DID, callbackURL string) error
+	GetStatements(ctx context.Context, limit int, startToken *string) (*service.GetStatementsResult, error)
+	GetStatement(ctx context.Context, account did.DID, month time.Time) (*statements.Statement, error)
+	GeneratePayoutRun(ctx context.Context, month time.Time) (*payouts.Run, error)
+	FinalizePayoutRun(ctx context.Context, month time.Time) error
+	GetPayoutRuns(ctx context.Context) ([]payouts.Run, error)
+	GetPayoutRun(ctx context.Context, month time.Time) (*payouts.Run, error)
+	AttestPayoutRun(ctx context.Context, month time.Time) (int, error)
+	GetLedgerBalance(ctx context.Context, book ledger.Book, period service.Period, asOf time.Time) (*service.LedgerBalance, error)
+	PostLedgerAdjustment(ctx context.Context, adjustment service.LedgerAdjustment) (*ledger.Transaction, error)
+	ReverseBatch(ctx context.Context, cause ucan.Link, actor, reason string) (*ledger.Transaction, error)
+	GetExcludedSpacesStats(ctx context.Context) ([]service.ExcludedSpaceStats, error)
+	GetNetworkStats(ctx context.Context, period service.Period) (*service.NetworkStats, error)
 }
 
 //go:embed templates/admin.html.tmpl
@@ -82,15 +116,32 @@ var loginTemplateHTML string
 var loginCSS string
 
 type adminDashboardData struct {
-	ActiveTab               string
-	Providers               []service.ProviderWithStats
-	Accounts                []service.AccountStats
-	NextToken               *string
-	PrevToken               *string
-	Error                   string
-	CSS                     template.CSS
-	ClientEgressUSDPerTiB   float64
-	ProviderEgressUSDPerTiB float64
+	ActiveTab   string
+	Providers   []service.ProviderWithStats
+	Accounts    []service.AccountStats
+	Anomalies   []anomalies.Flag
+	HeldBatches []consolidated.ConsolidatedRecord
+	Callbacks   []callbacks.Registration
+	Statements  []statements.Statement
+	PayoutRuns  []payouts.Run
+	PayoutRun   *payouts.Run
+	Ledger      ledgerQuery
+	Balance     *service.LedgerBalance
+	Excluded    []service.ExcludedSpaceStats
+	Networks    *service.NetworkStats
+	Month       string
+	NextToken   *string
+	PrevToken   *string
+	Error       string
+	CSS         template.CSS
+}
+
+// ledgerQuery is the book and month looked up in the ledger tab, as submitted
+type ledgerQuery struct {
+	Kind  string
+	DID   string
+	Month string
+	AsOf  string
 }
 
 type loginData struct {
@@ -112,6 +163,13 @@ func formatBytes(b uint64) string {
 	return fmt.Sprintf("%.2f %ciB", float64(b)/float64(div), "KMGTPE"[exp])
 }
 
+func formatSignedBytes(b int64) string {
+	if b < 0 {
+		return "-" + formatBytes(uint64(-b))
+	}
+	return formatBytes(uint64(b))
+}
+
 func formatDate(t interface{}) string {
 	// Handle time.Time
 	if v, ok := t.(interface{ Format(string) string }); ok {
@@ -120,12 +178,18 @@ func formatDate(t interface{}) string {
 	return fmt.Sprintf("%v", t)
 }
 
-func formatUSD(bytes uint64, usdPerTiB float64) string {
-	const bytesPerTiB = 1024 * 1024 * 1024 * 1024
-	usd := (float64(bytes) / bytesPerTiB) * usdPerTiB
+func formatDateTime(t time.Time) string {
+	return t.UTC().Format("2006-01-02 15:04 MST")
+}
+
+func formatAmount(usd float64) string {
 	return fmt.Sprintf("$%.2f", usd)
 }
 
+func formatMonth(t time.Time) string {
+	return t.Format("2006-01")
+}
+
 // showLoginForm renders the login form
 func showLoginForm(w http.ResponseWriter, r *http.Request, errorMsg string) {
 	tmpl := template.Must(template.New("login").Parse(loginTemplateHTML))
@@ -229,20 +293,100 @@ func BasicAuthMiddleware(handler http.HandlerFunc, username, password string) ht
 }
 
 // AdminHandler returns an HTTP handler for the admin dashboard
-func AdminHandler(svc StatsService, clientEgressUSDPerTiB, providerEgressUSDPerTiB float64) http.HandlerFunc {
+func AdminHandler(svc StatsService, payRates *pricing.RateCards) http.HandlerFunc {
 	tmpl := template.Must(template.New("admin").Funcs(template.FuncMap{
-		"formatBytes":    formatBytes,
-		"formatDate":     formatDate,
-		"formatCurrency": formatUSD,
+		"formatBytes":       formatBytes,
+		"formatSignedBytes": formatSignedBytes,
+		"formatDate":        formatDate,
+		"formatDateTime":    formatDateTime,
+		// formatPayout prices node egress at the rate of the node in effect on the day it was served
+		"formatPayout": func(node did.DID, egress uint64, when time.Time) string {
+			return formatAmount(payRates.Payout(node, egress, when))
+		},
+		"formatAmount": formatAmount,
+		"formatMonth":  formatMonth,
+		// bookEgress is the egress of the entry served by a node or consumed by an account
+		"bookEgress": func(e ledger.BookEntry) int64 {
+			return e.Book.Sign() * e.Egress
+		},
 	}).Parse(adminTemplateHTML))
 
 	const defaultLimit = 20
 
 	return func(w http.ResponseWriter, r *http.Request) {
 		data := adminDashboardData{
-			CSS:                     template.CSS(adminCSS),
-			ClientEgressUSDPerTiB:   clientEgressUSDPerTiB,
-			ProviderEgressUSDPerTiB: providerEgressUSDPerTiB,
+			CSS: template.CSS(adminCSS),
+		}
+
+		// Handle callback registrations
+		if r.Method == http.MethodPost && r.FormValue("action") == "set-callback" {
+			if err := handleCallbackAction(r, svc); err != nil {
+				log.Errorf("setting node callback: %v", err)
+				data.ActiveTab = "callbacks"
+				data.Error = fmt.Sprintf("Error setting callback: %v", err)
+				if err := tmpl.Execute(w, data); err != nil {
+					log.Errorf("executing admin template: %v", err)
+					http.Error(w, "Internal server error", http.StatusInternalServerError)
+				}
+				return
+			}
+
+			http.Redirect(w, r, "/admin?tab=callbacks", http.StatusSeeOther)
+			return
+		}
+
+		// Handle payout run generation and finalization
+		if r.Method == http.MethodPost && strings.HasSuffix(r.FormValue("action"), "-payout") {
+			month, err := handlePayoutAction(r, svc)
+			if err != nil {
+				log.Errorf("handling payout run: %v", err)
+				data.ActiveTab = "payouts"
+				data.Error = fmt.Sprintf("Error handling payout run: %v", err)
+				if err := tmpl.Execute(w, data); err != nil {
+					log.Errorf("executing admin template: %v", err)
+					http.Error(w, "Internal server error", http.StatusInternalServerError)
+				}
+				return
+			}
+
+			http.Redirect(w, r, "/admin?tab=payouts&month="+month.Format("2006-01"), http.StatusSeeOther)
+			return
+		}
+
+		// Handle ledger adjustments and reversals
+		if r.Method == http.MethodPost && (r.FormValue("action") == "post-adjustment" || r.FormValue("action") == "reverse-batch") {
+			book, err := handleLedgerAction(r, svc)
+			if err != nil {
+				log.Errorf("posting to ledger: %v", err)
+				data.ActiveTab = "ledger"
+				data.Error = fmt.Sprintf("Error posting to ledger: %v", err)
+				if err := tmpl.Execute(w, data); err != nil {
+					log.Errorf("executing admin template: %v", err)
+					http.Error(w, "Internal server error", http.StatusInternalServerError)
+				}
+				return
+			}
+
+			kind, d, _ := strings.Cut(string(book), ":")
+			http.Redirect(w, r, "/admin?tab=ledger&kind="+kind+"&did="+url.QueryEscape(d), http.StatusSeeOther)
+			return
+		}
+
+		// Handle review actions on held batches
+		if r.Method == http.MethodPost && r.FormValue("action") != "" {
+			if err := handleReviewAction(r, svc); err != nil {
+				log.Errorf("reviewing held batch: %v", err)
+				data.ActiveTab = "held"
+				data.Error = fmt.Sprintf("Error reviewing batch: %v", err)
+				if err := tmpl.Execute(w, data); err != nil {
+					log.Errorf("executing admin template: %v", err)
+					http.Error(w, "Internal server error", http.StatusInternalServerError)
+				}
+				return
+			}
+
+			http.Redirect(w, r, "/admin?tab=held", http.StatusSeeOther)
+			return
 		}
 
 		// Get active tab from query parameter (default to "providers")
@@ -278,6 +422,135 @@ func AdminHandler(svc StatsService, clientEgressUSDPerTiB, providerEgressUSDPerT
 				data.PrevToken = startToken // For "back" navigation (simplified)
 			}
 
+		case "anomalies":
+			result, err := svc.GetAnomalies(r.Context(), defaultLimit, startToken)
+			if err != nil {
+				data.Error = fmt.Sprintf("Error fetching anomalies: %v", err)
+				if err := tmpl.Execute(w, data); err != nil {
+					log.Errorf("executing admin template: %v", err)
+					http.Error(w, "Internal server error", http.StatusInternalServerError)
+				}
+				return
+			}
+
+			data.Anomalies = result.Flags
+			data.NextToken = result.NextToken
+			if startToken != nil {
+				data.PrevToken = startToken // For "back" navigation (simplified)
+			}
+
+		case "held":
+			result, err := svc.GetHeldBatches(r.Context(), defaultLimit, startToken)
+			if err != nil {
+				data.Error = fmt.Sprintf("Error fetching held batches: %v", err)
+				if err := tmpl.Execute(w, data); err != nil {
+					log.Errorf("executing admin template: %v", err)
+					http.Error(w, "Internal server error", http.StatusInternalServerError)
+				}
+				return
+			}
+
+			data.HeldBatches = result.Batches
+			data.NextToken = result.NextToken
+			if startToken != nil {
+				data.PrevToken = startToken // For "back" navigation (simplified)
+			}
+
+		case "callbacks":
+			result, err := svc.GetCallbacks(r.Context(), defaultLimit, startToken)
+			if err != nil {
+				data.Error = fmt.Sprintf("Error fetching callbacks: %v", err)
+				if err := tmpl.Execute(w, data); err != nil {
+					log.Errorf("executing admin template: %v", err)
+					http.Error(w, "Internal server error", http.StatusInternalServerError)
+				}
+				return
+			}
+
+			data.Callbacks = result.Registrations
+			data.NextToken = result.NextToken
+			if startToken != nil {
+				data.PrevToken = startToken // For "back" navigation (simplified)
+			}
+
+		case "statements":
+			result, err := svc.GetStatements(r.Context(), defaultLimit, startToken)
+			if err != nil {
+				data.Error = fmt.Sprintf("Error fetching statements: %v", err)
+				if err := tmpl.Execute(w, data); err != nil {
+					log.Errorf("executing admin template: %v", err)
+					http.Error(w, "Internal server error", http.StatusInternalServerError)
+				}
+				return
+			}
+
+			data.Statements = result.Statements
+			data.NextToken = result.NextToken
+			if startToken != nil {
+				data.PrevToken = startToken // For "back" navigation (simplified)
+			}
+
+		case "payouts":
+			runs, err := svc.GetPayoutRuns(r.Context())
+			if err != nil {
+				data.Error = fmt.Sprintf("Error fetching payout runs: %v", err)
+				if err := tmpl.Execute(w, data); err != nil {
+					log.Errorf("executing admin template: %v", err)
+					http.Error(w, "Internal server error", http.StatusInternalServerError)
+				}
+				return
+			}
+
+			data.PayoutRuns = runs
+
+			// Show the lines of the selected run
+			if m := r.URL.Query().Get("month"); m != "" {
+				month, err := time.Parse("2006-01", m)
+				if err == nil {
+					data.PayoutRun, err = svc.GetPayoutRun(r.Context(), month)
+				}
+				if err != nil {
+					data.Error = fmt.Sprintf("Error fetching payout run: %v", err)
+				}
+			}
+
+		case "ledger":
+			q := r.URL.Query()
+			data.Ledger = ledgerQuery{Kind: q.Get("kind"), DID: q.Get("did"), Month: q.Get("month"), AsOf: q.Get("asof")}
+			if data.Ledger.Month == "" {
+				data.Ledger.Month = time.Now().UTC().Format("2006-01")
+			}
+
+			// Show the balance of the selected book
+			if data.Ledger.DID != "" {
+				var err error
+				data.Balance, err = getLedgerBalance(r.Context(), svc, data.Ledger)
+				if err != nil {
+					data.Error = fmt.Sprintf("Error fetching ledger balance: %v", err)
+				}
+			}
+
+		case "excluded":
+			excluded, err := svc.GetExcludedSpacesStats(r.Context())
+			if err != nil {
+				data.Error = fmt.Sprintf("Error fetching excluded spaces: %v", err)
+			}
+			data.Excluded = excluded
+
+		case "networks":
+			data.Month = r.URL.Query().Get("month")
+			if data.Month == "" {
+				data.Month = time.Now().UTC().Format("2006-01")
+			}
+
+			month, err := time.Parse("2006-01", data.Month)
+			if err == nil {
+				data.Networks, err = svc.GetNetworkStats(r.Context(), service.Period{From: month, To: month.AddDate(0, 1, 0)})
+			}
+			if err != nil {
+				data.Error = fmt.Sprintf("Error fetching network stats: %v", err)
+			}
+
 		default: // "providers"
 			result, err := svc.GetAllProvidersStats(r.Context(), defaultLimit, startToken)
 			if err != nil {
@@ -302,3 +575,231 @@ func AdminHandler(svc StatsService, clientEgressUSDPerTiB, providerEgressUSDPerT
 		}
 	}
 }
+
+// StatementHandler returns an HTTP handler downloading the statement of an account for a month, as JSON or
+// as CSV when the format query parameter is "csv"
+func StatementHandler(svc StatsService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		account, err := did.Parse(r.PathValue("account"))
+		if err != nil {
+			http.Error(w, "invalid account DID", http.StatusBadRequest)
+			return
+		}
+
+		month, err := time.Parse("2006-01", r.PathValue("month"))
+		if err != nil {
+			http.Error(w, "invalid month, expected YYYY-MM", http.StatusBadRequest)
+			return
+		}
+
+		statement, err := svc.GetStatement(r.Context(), account, month)
+		if err != nil {
+			if errors.Is(err, service.ErrStatementNotFound) {
+				http.Error(w, "statement not found", http.StatusNotFound)
+				return
+			}
+
+			log.Errorf("getting statement: %v", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+
+		filename := fmt.Sprintf("statement-%s-%s", strings.ReplaceAll(account.String(), ":", "_"), month.Format("2006-01"))
+		switch format := r.URL.Query().Get("format"); format {
+		case "csv":
+			w.Header().Set("Content-Type", "text/csv")
+			w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename+".csv"))
+			err = billing.WriteCSV(w, *statement)
+		case "", "json":
+			w.Header().Set("Content-Type", "application/json")
+			w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename+".json"))
+			err = billing.WriteJSON(w, *statement)
+		default:
+			http.Error(w, fmt.Sprintf("unknown format %q", format), http.StatusBadRequest)
+			return
+		}
+		if err != nil {
+			log.Errorf("sending statement: %v", err)
+		}
+	}
+}
+
+// PayoutHandler returns an HTTP handler downloading a finalized payout run, as JSON or as CSV when the format
+// query parameter is "csv"
+func PayoutHandler(svc StatsService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		month, err := time.Parse("2006-01", r.PathValue("month"))
+		if err != nil {
+			http.Error(w, "invalid month, expected YYYY-MM", http.StatusBadRequest)
+			return
+		}
+
+		run, err := svc.GetPayoutRun(r.Context(), month)
+		if err != nil {
+			if errors.Is(err, service.ErrPayoutRunNotFound) {
+				http.Error(w, "payout run not found", http.StatusNotFound)
+				return
+			}
+
+			log.Errorf("getting payout run: %v", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+
+		// Only finalized runs can be executed
+		if run.Status != payouts.StatusFinalized {
+			http.Error(w, "payout run is not finalized", http.StatusConflict)
+			return
+		}
+
+		filename := fmt.Sprintf("payouts-%s", month.Format("2006-01"))
+		switch format := r.URL.Query().Get("format"); format {
+		case "csv":
+			w.Header().Set("Content-Type", "text/csv")
+			w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename+".csv"))
+			err = billing.WritePayoutCSV(w, *run)
+		case "", "json":
+			w.Header().Set("Content-Type", "application/json")
+			w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename+".json"))
+			err = billing.WritePayoutJSON(w, *run)
+		default:
+			http.Error(w, fmt.Sprintf("unknown format %q", format), http.StatusBadRequest)
+			return
+		}
+		if err != nil {
+			log.Errorf("sending payout run: %v", err)
+		}
+	}
+}
+
+// handlePayoutAction generates, finalizes or attests the payout run of the month in the submitted form
+func handlePayoutAction(r *http.Request, svc StatsService) (time.Time, error) {
+	month, err := time.Parse("2006-01", r.FormValue("month"))
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid month: %w", err)
+	}
+
+	switch action := r.FormValue("action"); action {
+	case "generate-payout":
+		_, err = svc.GeneratePayoutRun(r.Context(), month)
+	case "finalize-payout":
+		err = svc.FinalizePayoutRun(r.Context(), month)
+	case "attest-payout":
+		_, err = svc.AttestPayoutRun(r.Context(), month)
+	default:
+		err = fmt.Errorf("unknown action %q", action)
+	}
+
+	return month, err
+}
+
+// getLedgerBalance derives the balance of the book for the month of the query, as of the end of its day if any
+func getLedgerBalance(ctx context.Context, svc StatsService, q ledgerQuery) (*service.LedgerBalance, error) {
+	book, err := ledger.ParseBook(q.Kind + ":" + q.DID)
+	if err != nil {
+		return nil, err
+	}
+
+	month, err := time.Parse("2006-01", q.Month)
+	if err != nil {
+		return nil, fmt.Errorf("invalid month: %w", err)
+	}
+
+	var asOf time.Time
+	if q.AsOf != "" {
+		day, err := time.Parse("2006-01-02", q.AsOf)
+		if err != nil {
+			return nil, fmt.Errorf("invalid as of date: %w", err)
+		}
+		asOf = day.AddDate(0, 0, 1)
+	}
+
+	return svc.GetLedgerBalance(ctx, book, service.Period{From: month, To: month.AddDate(0, 1, 0)}, asOf)
+}
+
+// handleLedgerAction posts the adjustment or reverses the batch credit in the submitted form, and returns the
+// book to show afterwards
+func handleLedgerAction(r *http.Request, svc StatsService) (ledger.Book, error) {
+	actor, reason := r.FormValue("actor"), r.FormValue("reason")
+
+	switch action := r.FormValue("action"); action {
+	case "post-adjustment":
+		book, err := ledger.ParseBook(r.FormValue("kind") + ":" + r.FormValue("did"))
+		if err != nil {
+			return "", err
+		}
+
+		egress, err := strconv.ParseInt(r.FormValue("egress"), 10, 64)
+		if err != nil {
+			return "", fmt.Errorf("invalid egress: %w", err)
+		}
+
+		adjustment := service.LedgerAdjustment{Book: book, Egress: egress, Actor: actor, Reason: reason}
+		if v := r.FormValue("effective"); v != "" {
+			if adjustment.EffectiveAt, err = time.Parse("2006-01-02", v); err != nil {
+				return "", fmt.Errorf("invalid effective date: %w", err)
+			}
+		}
+		if v := r.FormValue("space"); v != "" {
+			if adjustment.Space, err = did.Parse(v); err != nil {
+				return "", fmt.Errorf("invalid space DID: %w", err)
+			}
+		}
+		if v := r.FormValue("cause"); v != "" {
+			c, err := cid.Parse(v)
+			if err != nil {
+				return "", fmt.Errorf("invalid batch CID: %w", err)
+			}
+			adjustment.Cause = cidlink.Link{Cid: c}
+		}
+
+		if _, err := svc.PostLedgerAdjustment(r.Context(), adjustment); err != nil {
+			return "", err
+		}
+		return book, nil
+
+	case "reverse-batch":
+		c, err := cid.Parse(r.FormValue("cause"))
+		if err != nil {
+			return "", fmt.Errorf("invalid batch CID: %w", err)
+		}
+
+		tx, err := svc.ReverseBatch(r.Context(), cidlink.Link{Cid: c}, actor, reason)
+		if err != nil {
+			return "", err
+		}
+		// the node book comes first in batch credits and their reversals
+		return tx.Entries[0].Book, nil
+
+	default:
+		return "", fmt.Errorf("unknown action %q", action)
+	}
+}
+
+// handleReviewAction approves or rejects the held batch identified in the submitted form
+func handleReviewAction(r *http.Request, svc StatsService) error {
+	c, err := cid.Parse(r.FormValue("cause"))
+	if err != nil {
+		return fmt.Errorf("invalid batch CID: %w", err)
+	}
+	cause := cidlink.Link{Cid: c}
+
+	switch action := r.FormValue("action"); action {
+	case "approve":
+		return svc.ApproveBatch(r.Context(), cause)
+	case "reject":
+		return svc.RejectBatch(r.Context(), cause, r.FormValue("reason"))
+	default:
+		return fmt.Errorf("unknown action %q", action)
+	}
+}
+
+// handleCallbackAction registers or removes the callback URL of the node identified in the submitted form
+func handleCallbackAction(r *http.Request, svc StatsService) error {
+	node, err := did.Parse(r.FormValue("node"))
+	if err != nil {
+		return fmt.Errorf("invalid node DID: %w", err)
+	}
+
+	return svc.SetCallback(r.Context(), node, r.FormValue("callback"))
+}